@@ -18,6 +18,9 @@ package v1alpha1
 import (
 	"github.com/liqotech/liqo/pkg/crdClient"
 	"github.com/liqotech/liqo/pkg/labelPolicy"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -54,6 +57,46 @@ type AdvertisementConfig struct {
 	KeepaliveRetryTime int32 `json:"keepaliveRetryTime,omitempty"`
 	// LabelPolicies contains the policies for each label to be added to remote virtual nodes
 	LabelPolicies []LabelPolicy `json:"labelPolicies,omitempty"`
+	// PricePolicy defines the prices announced in the Advertisements sent to the foreign clusters
+	PricePolicy PricePolicy `json:"pricePolicy,omitempty"`
+}
+
+// PricePolicy defines the prices announced in the Advertisements. Resources without a configured price keep
+// the default ones (1 for a cpu, 2m for a byte of memory and 5 for each container image)
+type PricePolicy struct {
+	// UnitPrices contains the price for a unit of each resource (e.g. cpu, memory).
+	UnitPrices corev1.ResourceList `json:"unitPrices,omitempty"`
+	// ImagePrice is the price for each container image available in the cluster.
+	ImagePrice *resource.Quantity `json:"imagePrice,omitempty"`
+	// PeerOverrides contains the prices to be announced to specific foreign clusters, which replace the default ones.
+	PeerOverrides []PeerPriceOverride `json:"peerOverrides,omitempty"`
+	// TimeMultipliers scale the prices during given hours of the day. If more intervals include the current time,
+	// the first one is applied.
+	TimeMultipliers []TimeMultiplier `json:"timeMultipliers,omitempty"`
+}
+
+// PeerPriceOverride contains the prices to be announced to a specific foreign cluster
+type PeerPriceOverride struct {
+	// ClusterId is the cluster ID of the foreign cluster.
+	ClusterId string `json:"clusterId"`
+	// UnitPrices contains the price for a unit of each resource, replacing the default ones.
+	UnitPrices corev1.ResourceList `json:"unitPrices,omitempty"`
+	// ImagePrice is the price for each container image, replacing the default one.
+	ImagePrice *resource.Quantity `json:"imagePrice,omitempty"`
+}
+
+// TimeMultiplier scales the prices in the [StartHour, EndHour) interval of the day, expressed in UTC.
+// If StartHour is greater than EndHour, the interval spans across midnight.
+type TimeMultiplier struct {
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=23
+	StartHour int32 `json:"startHour"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=24
+	EndHour int32 `json:"endHour"`
+	// Percentage is the percentage the prices are multiplied by (e.g. 150 increases the prices by half).
+	// +kubebuilder:validation:Minimum=0
+	Percentage int32 `json:"percentage"`
 }
 
 type BroadcasterConfig struct {
@@ -65,6 +108,51 @@ type BroadcasterConfig struct {
 	//When EnableBroadcaster is set to false, the home cluster notifies to the foreign he wants to stop sharing resources.
 	//This will trigger the deletion of the virtual-kubelet and, after that, of the Advertisement,
 	EnableBroadcaster bool `json:"enableBroadcaster"`
+	// PeerSharingPolicies defines the resources shared with specific foreign clusters, replacing the ResourceSharingPercentage.
+	// If the resources offered to all the foreign clusters exceed the available ones, each share is reduced proportionally,
+	// so that the cluster is never overcommitted.
+	PeerSharingPolicies []PeerSharingPolicy `json:"peerSharingPolicies,omitempty"`
+	// AdvertisedResources is the allow-list of the resources announced to the foreign clusters (e.g. nvidia.com/gpu, hugepages-2Mi).
+	// If empty, all the allocatable resources are announced.
+	AdvertisedResources []AdvertisedResource `json:"advertisedResources,omitempty"`
+}
+
+// ResourceScaling defines how an announced resource is scaled when only a share of it is offered
+type ResourceScaling string
+
+const (
+	// MilliScaling keeps the precision of the milli-units, as for the cpu
+	MilliScaling ResourceScaling = "Milli"
+	// MegaScaling rounds the resource down to megabytes, as for the memory and the storage
+	MegaScaling ResourceScaling = "Mega"
+	// IntegerScaling rounds the resource down to units, as required by the devices (e.g. nvidia.com/gpu)
+	IntegerScaling ResourceScaling = "Integer"
+)
+
+// AdvertisedResource defines a resource announced to the foreign clusters
+type AdvertisedResource struct {
+	// Name is the name of the resource.
+	Name corev1.ResourceName `json:"name"`
+	// Scaling defines how the resource is scaled. If not set, Milli is used for the cpu, Mega for the memory,
+	// the ephemeral-storage and the hugepages, and Integer for the other resources.
+	// +kubebuilder:validation:Enum="Milli";"Mega";"Integer"
+	Scaling ResourceScaling `json:"scaling,omitempty"`
+}
+
+// PeerSharingPolicy defines the resources shared with a specific foreign cluster
+type PeerSharingPolicy struct {
+	// ClusterId is the cluster ID of the foreign cluster.
+	ClusterId string `json:"clusterId"`
+	// ResourceSharingPercentage defines the percentage of your cluster resources that you will share with the foreign cluster.
+	// If not set, the global ResourceSharingPercentage is used.
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:validation:Minimum=0
+	ResourceSharingPercentage *int32 `json:"resourceSharingPercentage,omitempty"`
+	// Resources defines the absolute amount of the resources shared with the foreign cluster,
+	// which takes precedence over the percentage.
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+	// HiddenResources contains the resources (e.g. extended resources, such as nvidia.com/gpu) not offered to the foreign cluster.
+	HiddenResources []corev1.ResourceName `json:"hiddenResources,omitempty"`
 }
 
 // AcceptPolicy defines the policy to accept/refuse an Advertisement
@@ -75,8 +163,11 @@ const (
 	// AutoAcceptAll can be achieved by setting MaxAcceptableAdvertisement to 1000000
 	// AutoRefuseAll can be achieved by setting MaxAcceptableAdvertisement to 0
 	AutoAcceptMax AcceptPolicy = "AutoAcceptMax"
-	// ManualAccept means every Advertisement received will need a manual accept/refuse, which can be done by updating its status
+	// ManualAccept means every Advertisement received will be kept Pending until it is manually accepted or refused,
+	// which can be done by adding the decision for the Advertisement to the ManualDecisions
 	ManualAccept AcceptPolicy = "Manual"
+	// PolicyAccept means all the Advertisement satisfying the AcceptRules will be accepted until the MaxAcceptableAdvertisement limit is reached
+	PolicyAccept AcceptPolicy = "Policy"
 )
 
 type AdvOperatorConfig struct {
@@ -86,11 +177,47 @@ type AdvOperatorConfig struct {
 	// +kubebuilder:validation:Maximum=1000000
 	MaxAcceptableAdvertisement int32 `json:"maxAcceptableAdvertisement"`
 	// AcceptPolicy defines the policy to accept/refuse an Advertisement.
-	// Possible values are AutoAcceptMax and Manual.
+	// Possible values are AutoAcceptMax, Manual and Policy.
 	// AutoAcceptMax means all the Advertisement received will be accepted until the MaxAcceptableAdvertisement limit is reached;
-	// Manual means every Advertisement received will need a manual accept/refuse, which can be done by updating its status.
-	// +kubebuilder:validation:Enum="AutoAcceptMax";"Manual"
+	// Manual means every Advertisement received will be kept Pending until it is manually accepted or refused,
+	// which can be done by adding the decision for the Advertisement to the ManualDecisions;
+	// Policy means all the Advertisement satisfying the AcceptRules will be accepted until the MaxAcceptableAdvertisement limit is reached.
+	// +kubebuilder:validation:Enum="AutoAcceptMax";"Manual";"Policy"
 	AcceptPolicy AcceptPolicy `json:"acceptPolicy"`
+	// AcceptRules defines the rules an Advertisement has to satisfy to be accepted with the Policy AcceptPolicy.
+	AcceptRules AcceptRules `json:"acceptRules,omitempty"`
+	// MaxPrices defines the price ceiling for each resource: Advertisements announcing a higher price, or no price
+	// for a resource with a ceiling, are refused, whatever the AcceptPolicy. The prices are checked again at every
+	// update of the Advertisements, and the accepted ones exceeding the ceiling are revoked.
+	MaxPrices corev1.ResourceList `json:"maxPrices,omitempty"`
+	// ManualDecisions contains the decisions taken on the Advertisements kept Pending by the Manual AcceptPolicy,
+	// indexed by the name and the UID of the Advertisement (i.e. <name>/<uid>), so that a decision does not apply to the
+	// Advertisements recreated later by the same foreign cluster. They are part of the ClusterConfig, since the
+	// Advertisements can be modified by the foreign clusters sending them.
+	ManualDecisions map[string]AdvertisementDecision `json:"manualDecisions,omitempty"`
+}
+
+// AdvertisementDecision is the decision taken on an Advertisement with the Manual AcceptPolicy
+// +kubebuilder:validation:Enum="Accepted";"Refused"
+type AdvertisementDecision string
+
+const (
+	// DecisionAccepted accepts the Advertisement of the foreign cluster
+	DecisionAccepted AdvertisementDecision = "Accepted"
+	// DecisionRefused refuses the Advertisement of the foreign cluster
+	DecisionRefused AdvertisementDecision = "Refused"
+)
+
+// AcceptRules defines the rules an Advertisement has to satisfy to be accepted with the Policy AcceptPolicy.
+// Empty rules are always satisfied.
+type AcceptRules struct {
+	// MinResources defines the minimum amount of each resource (e.g. cpu, memory) the Advertisement has to announce.
+	MinResources corev1.ResourceList `json:"minResources,omitempty"`
+	// RequiredLabels contains the labels the Advertisement has to announce, with the given values.
+	RequiredLabels map[string]string `json:"requiredLabels,omitempty"`
+	// AllowedClusterIds contains the IDs of the clusters whose Advertisements can be accepted.
+	// If empty, the Advertisements from any cluster can be accepted.
+	AllowedClusterIds []string `json:"allowedClusterIds,omitempty"`
 }
 
 // LabelPolicy define a key-value structure to indicate which keys have to be aggregated and with which policy
@@ -98,7 +225,7 @@ type LabelPolicy struct {
 	// Label Key to be aggregated in new virtual nodes
 	Key string `json:"key"`
 	// Merge labels Policy
-	// +kubebuilder:validation:Enum="LabelPolicyAnyTrue";"LabelPolicyAllTrue";"LabelPolicyAnyTrueNoLabelIfFalse";"LabelPolicyAllTrueNoLabelIfFalse"
+	// +kubebuilder:validation:Enum="LabelPolicyAnyTrue";"LabelPolicyAllTrue";"LabelPolicyAnyTrueNoLabelIfFalse";"LabelPolicyAllTrueNoLabelIfFalse";"LabelPolicyTopology"
 	// +kubebuilder:default="LabelPolicyAnyTrue"
 	Policy labelPolicy.LabelPolicyType `json:"policy,omitempty"`
 }
@@ -131,6 +258,38 @@ type AuthConfig struct {
 	// Allow remote clusters to get an identity with an empty token
 	// +kubebuilder:default=false
 	AllowEmptyToken bool `json:"allowEmptyToken,omitempty"`
+	// PermissionProfiles defines the permissions granted to the remote clusters, replacing the default ones.
+	// +optional
+	PermissionProfiles PermissionProfiles `json:"permissionProfiles,omitempty"`
+}
+
+// PermissionProfiles defines the permissions granted to the remote clusters for each purpose. The profiles not set
+// keep the default permissions. The templates granting permissions which would allow a remote cluster to escalate its
+// privileges (e.g. on the RBAC resources, or on all the resources or verbs) are refused, keeping the previous ones.
+type PermissionProfiles struct {
+	// PeeringOnly is granted by the auth service to the identities of the remote clusters, to request a peering.
+	PeeringOnly *PermissionTemplate `json:"peeringOnly,omitempty"`
+	// Advertisement is granted to the foreign clusters a peering is requested to, to send their Advertisements.
+	Advertisement *PermissionTemplate `json:"advertisement,omitempty"`
+	// Offloading is granted to the virtual kubelets of the foreign clusters, to offload their pods. Its rules are
+	// granted only in the namespaces created by the virtual kubelets. Being shared by all the foreign clusters, it
+	// does not support $(CLUSTER_ID).
+	Offloading *PermissionTemplate `json:"offloading,omitempty"`
+	// Replication is granted to the CRD replicators of the foreign clusters, replacing the rules generated from the
+	// ResourcesToReplicate of the DispatcherConfig. Being shared by all the foreign clusters, it supports only cluster
+	// rules, without $(CLUSTER_ID).
+	Replication *PermissionTemplate `json:"replication,omitempty"`
+}
+
+// PermissionTemplate defines the rules of the roles granting a profile to the remote clusters. In the resource
+// names, $(CLUSTER_ID) is replaced with the ID of the remote cluster.
+type PermissionTemplate struct {
+	// Rules are granted in the Liqo namespace (in the namespaces created by the virtual kubelets, for the offloading
+	// profile).
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+	// ClusterRules are granted cluster-wide. They cannot grant the secrets, nor the exec, attach and port-forward of
+	// the pods, which are allowed only in the rules.
+	ClusterRules []rbacv1.PolicyRule `json:"clusterRules,omitempty"`
 }
 
 type LiqonetConfig struct {
@@ -176,6 +335,18 @@ type AgentConfig struct {
 
 // ClusterConfigStatus defines the observed state of ClusterConfig
 type ClusterConfigStatus struct {
+	// IngoingAdvertisements reports the usage of the capacity for the Advertisements received from other clusters.
+	// +optional
+	IngoingAdvertisements IngoingAdvertisementsStatus `json:"ingoingAdvertisements,omitempty"`
+}
+
+// IngoingAdvertisementsStatus reports the usage of the capacity for the Advertisements received from other clusters.
+// It is computed by the advertisement operator from the existing Advertisements.
+type IngoingAdvertisementsStatus struct {
+	// AcceptedAdvertisements is the number of Advertisements currently accepted.
+	AcceptedAdvertisements int32 `json:"acceptedAdvertisements"`
+	// AvailableAdvertisements is the number of Advertisements that can still be accepted with the AutoAcceptMax policy.
+	AvailableAdvertisements int32 `json:"availableAdvertisements"`
 }
 
 // +kubebuilder:object:root=true
@@ -20,12 +20,63 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AcceptRules) DeepCopyInto(out *AcceptRules) {
+	*out = *in
+	if in.MinResources != nil {
+		in, out := &in.MinResources, &out.MinResources
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.RequiredLabels != nil {
+		in, out := &in.RequiredLabels, &out.RequiredLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AllowedClusterIds != nil {
+		in, out := &in.AllowedClusterIds, &out.AllowedClusterIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcceptRules.
+func (in *AcceptRules) DeepCopy() *AcceptRules {
+	if in == nil {
+		return nil
+	}
+	out := new(AcceptRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AdvOperatorConfig) DeepCopyInto(out *AdvOperatorConfig) {
 	*out = *in
+	in.AcceptRules.DeepCopyInto(&out.AcceptRules)
+	if in.MaxPrices != nil {
+		in, out := &in.MaxPrices, &out.MaxPrices
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ManualDecisions != nil {
+		in, out := &in.ManualDecisions, &out.ManualDecisions
+		*out = make(map[string]AdvertisementDecision, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdvOperatorConfig.
@@ -38,16 +89,32 @@ func (in *AdvOperatorConfig) DeepCopy() *AdvOperatorConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdvertisedResource) DeepCopyInto(out *AdvertisedResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdvertisedResource.
+func (in *AdvertisedResource) DeepCopy() *AdvertisedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(AdvertisedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AdvertisementConfig) DeepCopyInto(out *AdvertisementConfig) {
 	*out = *in
-	out.OutgoingConfig = in.OutgoingConfig
-	out.IngoingConfig = in.IngoingConfig
+	in.OutgoingConfig.DeepCopyInto(&out.OutgoingConfig)
+	in.IngoingConfig.DeepCopyInto(&out.IngoingConfig)
 	if in.LabelPolicies != nil {
 		in, out := &in.LabelPolicies, &out.LabelPolicies
 		*out = make([]LabelPolicy, len(*in))
 		copy(*out, *in)
 	}
+	in.PricePolicy.DeepCopyInto(&out.PricePolicy)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdvertisementConfig.
@@ -79,6 +146,7 @@ func (in *AgentConfig) DeepCopy() *AgentConfig {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthConfig) DeepCopyInto(out *AuthConfig) {
 	*out = *in
+	in.PermissionProfiles.DeepCopyInto(&out.PermissionProfiles)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthConfig.
@@ -94,6 +162,18 @@ func (in *AuthConfig) DeepCopy() *AuthConfig {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BroadcasterConfig) DeepCopyInto(out *BroadcasterConfig) {
 	*out = *in
+	if in.PeerSharingPolicies != nil {
+		in, out := &in.PeerSharingPolicies, &out.PeerSharingPolicies
+		*out = make([]PeerSharingPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AdvertisedResources != nil {
+		in, out := &in.AdvertisedResources, &out.AdvertisedResources
+		*out = make([]AdvertisedResource, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BroadcasterConfig.
@@ -170,7 +250,7 @@ func (in *ClusterConfigSpec) DeepCopyInto(out *ClusterConfigSpec) {
 	*out = *in
 	in.AdvertisementConfig.DeepCopyInto(&out.AdvertisementConfig)
 	out.DiscoveryConfig = in.DiscoveryConfig
-	out.AuthConfig = in.AuthConfig
+	in.AuthConfig.DeepCopyInto(&out.AuthConfig)
 	in.LiqonetConfig.DeepCopyInto(&out.LiqonetConfig)
 	in.DispatcherConfig.DeepCopyInto(&out.DispatcherConfig)
 	out.AgentConfig = in.AgentConfig
@@ -189,6 +269,7 @@ func (in *ClusterConfigSpec) DeepCopy() *ClusterConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterConfigStatus) DeepCopyInto(out *ClusterConfigStatus) {
 	*out = *in
+	out.IngoingAdvertisements = in.IngoingAdvertisements
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConfigStatus.
@@ -251,6 +332,21 @@ func (in *DispatcherConfig) DeepCopy() *DispatcherConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngoingAdvertisementsStatus) DeepCopyInto(out *IngoingAdvertisementsStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngoingAdvertisementsStatus.
+func (in *IngoingAdvertisementsStatus) DeepCopy() *IngoingAdvertisementsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IngoingAdvertisementsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LabelPolicy) DeepCopyInto(out *LabelPolicy) {
 	*out = *in
@@ -286,6 +382,168 @@ func (in *LiqonetConfig) DeepCopy() *LiqonetConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerPriceOverride) DeepCopyInto(out *PeerPriceOverride) {
+	*out = *in
+	if in.UnitPrices != nil {
+		in, out := &in.UnitPrices, &out.UnitPrices
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ImagePrice != nil {
+		in, out := &in.ImagePrice, &out.ImagePrice
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerPriceOverride.
+func (in *PeerPriceOverride) DeepCopy() *PeerPriceOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerPriceOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerSharingPolicy) DeepCopyInto(out *PeerSharingPolicy) {
+	*out = *in
+	if in.ResourceSharingPercentage != nil {
+		in, out := &in.ResourceSharingPercentage, &out.ResourceSharingPercentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.HiddenResources != nil {
+		in, out := &in.HiddenResources, &out.HiddenResources
+		*out = make([]v1.ResourceName, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerSharingPolicy.
+func (in *PeerSharingPolicy) DeepCopy() *PeerSharingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerSharingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermissionProfiles) DeepCopyInto(out *PermissionProfiles) {
+	*out = *in
+	if in.PeeringOnly != nil {
+		in, out := &in.PeeringOnly, &out.PeeringOnly
+		*out = new(PermissionTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Advertisement != nil {
+		in, out := &in.Advertisement, &out.Advertisement
+		*out = new(PermissionTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Offloading != nil {
+		in, out := &in.Offloading, &out.Offloading
+		*out = new(PermissionTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replication != nil {
+		in, out := &in.Replication, &out.Replication
+		*out = new(PermissionTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermissionProfiles.
+func (in *PermissionProfiles) DeepCopy() *PermissionProfiles {
+	if in == nil {
+		return nil
+	}
+	out := new(PermissionProfiles)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermissionTemplate) DeepCopyInto(out *PermissionTemplate) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]rbacv1.PolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ClusterRules != nil {
+		in, out := &in.ClusterRules, &out.ClusterRules
+		*out = make([]rbacv1.PolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermissionTemplate.
+func (in *PermissionTemplate) DeepCopy() *PermissionTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(PermissionTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PricePolicy) DeepCopyInto(out *PricePolicy) {
+	*out = *in
+	if in.UnitPrices != nil {
+		in, out := &in.UnitPrices, &out.UnitPrices
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ImagePrice != nil {
+		in, out := &in.ImagePrice, &out.ImagePrice
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.PeerOverrides != nil {
+		in, out := &in.PeerOverrides, &out.PeerOverrides
+		*out = make([]PeerPriceOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TimeMultipliers != nil {
+		in, out := &in.TimeMultipliers, &out.TimeMultipliers
+		*out = make([]TimeMultiplier, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PricePolicy.
+func (in *PricePolicy) DeepCopy() *PricePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PricePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Resource) DeepCopyInto(out *Resource) {
 	*out = *in
@@ -300,3 +558,18 @@ func (in *Resource) DeepCopy() *Resource {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeMultiplier) DeepCopyInto(out *TimeMultiplier) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeMultiplier.
+func (in *TimeMultiplier) DeepCopy() *TimeMultiplier {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeMultiplier)
+	in.DeepCopyInto(out)
+	return out
+}
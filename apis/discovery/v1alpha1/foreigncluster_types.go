@@ -51,6 +51,9 @@ type ForeignClusterSpec struct {
 	// +kubebuilder:default="Unknown"
 	// Indicates if this remote cluster is trusted or not
 	TrustMode discovery.TrustMode `json:"trustMode,omitempty"`
+	// Mapping between local StorageClass names and the ones to be used in the foreign cluster
+	// when PersistentVolumeClaims are reflected. Not mapped classes fall back to the foreign default StorageClass
+	StorageClassMapping map[string]string `json:"storageClassMapping,omitempty"`
 }
 
 type ClusterIdentity struct {
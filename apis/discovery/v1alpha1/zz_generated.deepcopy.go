@@ -45,7 +45,7 @@ func (in *ForeignCluster) DeepCopyInto(out *ForeignCluster) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -103,6 +103,13 @@ func (in *ForeignClusterList) DeepCopyObject() runtime.Object {
 func (in *ForeignClusterSpec) DeepCopyInto(out *ForeignClusterSpec) {
 	*out = *in
 	out.ClusterIdentity = in.ClusterIdentity
+	if in.StorageClassMapping != nil {
+		in, out := &in.StorageClassMapping, &out.StorageClassMapping
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ForeignClusterSpec.
@@ -36,6 +36,8 @@ type AdvertisementSpec struct {
 	Labels map[string]string `json:"labels,omitempty"`
 	// Neighbors is a map where the key is the name of a virtual node (representing a foreign cluster) and the value are the resources allocatable on that node.
 	Neighbors map[corev1.ResourceName]corev1.ResourceList `json:"neighbors,omitempty"`
+	// Zones describes the topology of the cluster, i.e. the regions and zones its physical nodes belong to.
+	Zones []Zone `json:"zones,omitempty"`
 	// Properties can contain any additional information about the cluster.
 	Properties map[corev1.ResourceName]string `json:"properties,omitempty"`
 	// Prices contains the possible prices for every kind of resource (cpu, memory, image).
@@ -44,24 +46,37 @@ type AdvertisementSpec struct {
 	// Timestamp is the time instant when this Advertisement was created.
 	Timestamp metav1.Time `json:"timestamp"`
 	// TimeToLive is the time instant until this Advertisement will be valid.
-	// If not refreshed, an Advertisement will expire after 30 minutes.
+	// If not refreshed, an Advertisement will expire after 30 minutes: the virtual node is set NotReady and cordoned,
+	// and the Advertisement is deleted if it is not renewed within a grace period.
 	TimeToLive metav1.Time `json:"timeToLive"`
 }
 
+// Zone describes a topology domain of the cluster
+type Zone struct {
+	// Region is the value of the topology.kubernetes.io/region label of the nodes in the zone.
+	Region string `json:"region,omitempty"`
+	// Zone is the value of the topology.kubernetes.io/zone label of the nodes in the zone.
+	Zone string `json:"zone,omitempty"`
+	// Nodes is the number of physical nodes in the zone.
+	Nodes int32 `json:"nodes"`
+}
+
 // AdvPhase describes the phase of the Advertisement
 type AdvPhase string
 
 const (
 	AdvertisementAccepted AdvPhase = "Accepted"
 	AdvertisementRefused  AdvPhase = "Refused"
+	AdvertisementPending  AdvPhase = "Pending"
 )
 
 // AdvertisementStatus defines the observed state of Advertisement
 type AdvertisementStatus struct {
 	// AdvertisementStatus is the status of this Advertisement.
 	// When the adv is created it is checked by the operator, which sets this field to "Accepted" or "Refused" on tha base of cluster configuration.
+	// With the Manual AcceptPolicy the field is set to "Pending" until an administrator takes a decision.
 	// If the Advertisement is accepted a virtual-kubelet for the foreign cluster will be created.
-	// +kubebuilder:validation:Enum="";"Accepted";"Refused"
+	// +kubebuilder:validation:Enum="";"Accepted";"Refused";"Pending"
 	AdvertisementStatus AdvPhase `json:"advertisementStatus"`
 	// VkCreated indicates if the virtual-kubelet for this Advertisement has been created or not.
 	VkCreated bool `json:"vkCreated"`
@@ -69,6 +84,9 @@ type AdvertisementStatus struct {
 	VkReference object_references.DeploymentReference `json:"vkReference,omitempty"`
 	// VnodeReference is a reference to the virtual node linked to this Advertisement
 	VnodeReference object_references.NodeReference `json:"vnodeReference,omitempty"`
+	// Expired indicates that the TimeToLive has passed without the Advertisement being renewed.
+	// While expired, the virtual node is NotReady and cordoned; the field is reset when the Advertisement is renewed.
+	Expired bool `json:"expired,omitempty"`
 }
 
 // +kubebuilder:object:root=true
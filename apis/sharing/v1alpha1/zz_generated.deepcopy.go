@@ -120,6 +120,11 @@ func (in *AdvertisementSpec) DeepCopyInto(out *AdvertisementSpec) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]Zone, len(*in))
+		copy(*out, *in)
+	}
 	if in.Properties != nil {
 		in, out := &in.Properties, &out.Properties
 		*out = make(map[v1.ResourceName]string, len(*in))
@@ -165,3 +170,18 @@ func (in *AdvertisementStatus) DeepCopy() *AdvertisementStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Zone) DeepCopyInto(out *Zone) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Zone.
+func (in *Zone) DeepCopy() *Zone {
+	if in == nil {
+		return nil
+	}
+	out := new(Zone)
+	in.DeepCopyInto(out)
+	return out
+}
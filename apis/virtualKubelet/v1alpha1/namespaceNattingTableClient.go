@@ -6,6 +6,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 )
 
 func CreateClient(kubeconfig string) (*crdClient.CRDClient, error) {
@@ -31,6 +32,11 @@ func CreateClient(kubeconfig string) (*crdClient.CRDClient, error) {
 		&NamespaceNattingTableList{},
 		Keyer,
 		GroupResource)
+	crdClient.AddToRegistry("namespaceoffloadings",
+		&NamespaceOffloading{},
+		&NamespaceOffloadingList{},
+		NamespaceOffloadingKeyer,
+		NamespaceOffloadingGroupResource)
 
 	return clientSet, nil
 }
@@ -43,3 +49,13 @@ func Keyer(obj runtime.Object) (string, error) {
 
 	return ns.Name, nil
 }
+
+// NamespaceOffloadingKeyer returns the namespace/name key of the NamespaceOffloading, as the real informers do.
+func NamespaceOffloadingKeyer(obj runtime.Object) (string, error) {
+	offloading, ok := obj.(*NamespaceOffloading)
+	if !ok {
+		return "", errors.New("cannot cast received object to NamespaceOffloading")
+	}
+
+	return cache.MetaNamespaceKeyFunc(offloading)
+}
@@ -0,0 +1,113 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceOffloadingName is the name of the NamespaceOffloading ruling the offloading of its namespace: the ones
+// with a different name are ignored.
+const NamespaceOffloadingName = "offloading"
+
+// ReflectedResource is an API type reflected to the foreign clusters together with the offloaded pods.
+// +kubebuilder:validation:Enum=configmaps;secrets;services
+type ReflectedResource string
+
+const (
+	// ReflectedConfigMaps reflects the configmaps of the namespace.
+	ReflectedConfigMaps ReflectedResource = "configmaps"
+	// ReflectedSecrets reflects the secrets of the namespace.
+	ReflectedSecrets ReflectedResource = "secrets"
+	// ReflectedServices reflects the services of the namespace, together with their endpointslices.
+	ReflectedServices ReflectedResource = "services"
+)
+
+// NamespaceOffloadingSpec defines the desired state of NamespaceOffloading
+type NamespaceOffloadingSpec struct {
+	// Enabled states whether the pods of the namespace may be offloaded.
+	Enabled bool `json:"enabled"`
+	// ClusterIDs are the foreign clusters the namespace may be offloaded to. All of them, if empty.
+	ClusterIDs []string `json:"clusterIDs,omitempty"`
+	// RemoteNamespace is the name of the namespace in the foreign clusters. The name of the namespace followed by the
+	// home cluster ID, if empty. It is only considered when the namespace is offloaded for the first time, and it is
+	// refused if the namespace already exists in a foreign cluster, without having been created by the home cluster.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	RemoteNamespace string `json:"remoteNamespace,omitempty"`
+	// ReflectedResources are the API types reflected to the foreign clusters. All of them, if empty.
+	ReflectedResources []ReflectedResource `json:"reflectedResources,omitempty"`
+}
+
+// NamespaceOffloadingStatus defines the observed state of NamespaceOffloading
+type NamespaceOffloadingStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:printcolumn:name="Enabled",type=boolean,JSONPath=`.spec.enabled`
+// +kubebuilder:printcolumn:name="Remote Namespace",type=string,JSONPath=`.spec.remoteNamespace`
+// NamespaceOffloading is the Schema for the namespaceoffloadings API, ruling the offloading of its namespace
+type NamespaceOffloading struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceOffloadingSpec   `json:"spec,omitempty"`
+	Status NamespaceOffloadingStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceOffloadingList contains a list of NamespaceOffloading
+type NamespaceOffloadingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceOffloading `json:"items"`
+}
+
+// AllowsCluster returns whether the namespace may be offloaded to the given foreign cluster.
+func (spec *NamespaceOffloadingSpec) AllowsCluster(clusterID string) bool {
+	if !spec.Enabled {
+		return false
+	}
+	if len(spec.ClusterIDs) == 0 {
+		return true
+	}
+	for _, id := range spec.ClusterIDs {
+		if id == clusterID {
+			return true
+		}
+	}
+	return false
+}
+
+// Reflects returns whether the given API type is reflected to the foreign clusters.
+func (spec *NamespaceOffloadingSpec) Reflects(resource ReflectedResource) bool {
+	if len(spec.ReflectedResources) == 0 {
+		return true
+	}
+	for _, reflected := range spec.ReflectedResources {
+		if reflected == resource {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceOffloading{}, &NamespaceOffloadingList{})
+}
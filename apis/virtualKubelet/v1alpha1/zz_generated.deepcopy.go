@@ -125,3 +125,102 @@ func (in *NamespaceNattingTableStatus) DeepCopy() *NamespaceNattingTableStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceOffloading) DeepCopyInto(out *NamespaceOffloading) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceOffloading.
+func (in *NamespaceOffloading) DeepCopy() *NamespaceOffloading {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceOffloading)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceOffloading) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceOffloadingList) DeepCopyInto(out *NamespaceOffloadingList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespaceOffloading, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceOffloadingList.
+func (in *NamespaceOffloadingList) DeepCopy() *NamespaceOffloadingList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceOffloadingList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceOffloadingList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceOffloadingSpec) DeepCopyInto(out *NamespaceOffloadingSpec) {
+	*out = *in
+	if in.ClusterIDs != nil {
+		in, out := &in.ClusterIDs, &out.ClusterIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReflectedResources != nil {
+		in, out := &in.ReflectedResources, &out.ReflectedResources
+		*out = make([]ReflectedResource, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceOffloadingSpec.
+func (in *NamespaceOffloadingSpec) DeepCopy() *NamespaceOffloadingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceOffloadingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceOffloadingStatus) DeepCopyInto(out *NamespaceOffloadingStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceOffloadingStatus.
+func (in *NamespaceOffloadingStatus) DeepCopy() *NamespaceOffloadingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceOffloadingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -17,9 +17,9 @@ package main
 
 import (
 	"flag"
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
 	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	"github.com/liqotech/liqo/pkg/crdClient"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -60,7 +60,8 @@ func main() {
 	var metricsAddr, localKubeconfig, clusterId string
 	var enableLeaderElection bool
 	var kubeletNamespace, kubeletImage, initKubeletImage string
-	var runsInKindEnv bool
+	var runsInKindEnv, allowAllNamespaces bool
+	var expirationGracePeriod time.Duration
 
 	flag.StringVar(&metricsAddr, "metrics-addr", defaultMetricsaddr, "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false, "Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
@@ -70,6 +71,8 @@ func main() {
 	flag.StringVar(&kubeletImage, "kubelet-image", defaultVKImage, "The image of the virtual kubelet to be deployed")
 	flag.StringVar(&initKubeletImage, "init-kubelet-image", defaultInitVKImage, "The image of the virtual kubelet init container to be deployed")
 	flag.BoolVar(&runsInKindEnv, "run-in-kind", false, "The cluster in which the controller runs is managed by kind")
+	flag.DurationVar(&expirationGracePeriod, "expiration-grace-period", advop.DefaultExpirationGracePeriod, "The time an expired Advertisement is kept, with its virtual node cordoned, waiting to be renewed")
+	flag.BoolVar(&allowAllNamespaces, "allow-all-namespaces", false, "Allow the virtual kubelets to offload the namespaces with neither a NamespaceOffloading nor the liqo.io/enabled=true label")
 	flag.Parse()
 
 	if clusterId == "" {
@@ -101,24 +104,19 @@ func main() {
 		klog.Error(err)
 		os.Exit(1)
 	}
-	go csrApprover.WatchCSR(clientset, "liqo.io/csr=true", 5*time.Second)
+	go csrApprover.WatchCSR(clientset, "liqo.io/csr=true", 5*time.Second, "Liqo Advertisement Operator",
+		csrApprover.NewVirtualKubeletCSRValidator(kubeletNamespace))
 
-	// get the number of already accepted advertisements
 	advClient, err := advtypes.CreateAdvertisementClient(localKubeconfig, nil, true)
 	if err != nil {
 		klog.Errorln(err, "unable to create local client for Advertisement")
 		os.Exit(1)
 	}
-	var acceptedAdv int32
-	advList, err := advClient.Resource("advertisements").List(metav1.ListOptions{})
+
+	configClient, err := configv1alpha1.CreateClusterConfigClient(localKubeconfig, false)
 	if err != nil {
-		klog.Error(err)
-	} else {
-		for _, adv := range advList.(*advtypes.AdvertisementList).Items {
-			if adv.Status.AdvertisementStatus == advtypes.AdvertisementAccepted {
-				acceptedAdv++
-			}
-		}
+		klog.Errorln(err, "unable to create local client for ClusterConfig")
+		os.Exit(1)
 	}
 
 	discoveryConfig, err := crdClient.NewKubeconfig(localKubeconfig, &discoveryv1alpha1.GroupVersion)
@@ -133,18 +131,21 @@ func main() {
 	}
 
 	r := &advop.AdvertisementReconciler{
-		Client:           mgr.GetClient(),
-		Scheme:           mgr.GetScheme(),
-		EventsRecorder:   mgr.GetEventRecorderFor("AdvertisementOperator"),
-		KindEnvironment:  runsInKindEnv,
-		KubeletNamespace: kubeletNamespace,
-		VKImage:          kubeletImage,
-		InitVKImage:      initKubeletImage,
-		HomeClusterId:    clusterId,
-		AcceptedAdvNum:   acceptedAdv,
-		AdvClient:        advClient,
-		DiscoveryClient:  discoveryClient,
-		RetryTimeout:     1 * time.Minute,
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		EventsRecorder:        mgr.GetEventRecorderFor("AdvertisementOperator"),
+		KindEnvironment:       runsInKindEnv,
+		KubeletNamespace:      kubeletNamespace,
+		VKImage:               kubeletImage,
+		InitVKImage:           initKubeletImage,
+		HomeClusterId:         clusterId,
+		AdvClient:             advClient,
+		DiscoveryClient:       discoveryClient,
+		ConfigClient:          configClient,
+		APIReader:             mgr.GetAPIReader(),
+		RetryTimeout:          1 * time.Minute,
+		ExpirationGracePeriod: expirationGracePeriod,
+		AllowAllNamespaces:    allowAllNamespaces,
 	}
 
 	if err = r.SetupWithManager(mgr); err != nil {
@@ -153,10 +154,12 @@ func main() {
 	}
 	// +kubebuilder:scaffold:builder
 
+	stop := ctrl.SetupSignalHandler()
 	r.WatchConfiguration(localKubeconfig, nil)
+	r.WatchOffloadingNamespaces(stop)
 
 	klog.Info("starting manager as advertisement-operator")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(stop); err != nil {
 		klog.Error(err)
 		os.Exit(1)
 	}
@@ -20,6 +20,13 @@ func main() {
 	var keyFile string
 	var useTls bool
 
+	var limits auth_service.RequestLimits
+	var rateLimit, clusterRateLimit float64
+	var audit auth_service.AuditConfig
+	var auditLogMaxSize int64
+	var identityGC auth_service.IdentityGCConfig
+	var maxCertificateLifetime time.Duration
+
 	flag.StringVar(&namespace, "namespace", "default", "Namespace where your configs are stored.")
 	flag.StringVar(&kubeconfigPath, "kubeconfigPath", filepath.Join(os.Getenv("HOME"), ".kube", "config"), "For debug purpose, set path to local kubeconfig")
 	flag.Int64Var(&resyncSeconds, "resyncSeconds", 30, "Resync seconds for the informers")
@@ -27,6 +34,23 @@ func main() {
 	flag.StringVar(&certFile, "certFile", "/certs/cert.pem", "Path to cert file")
 	flag.StringVar(&keyFile, "keyFile", "/certs/key.pem", "Path to key file")
 	flag.BoolVar(&useTls, "useTls", false, "Enable HTTPS server")
+	flag.Float64Var(&rateLimit, "rateLimit", 1, "Requests per second allowed for each source IP (unlimited, if 0)")
+	flag.IntVar(&limits.Burst, "burst", 10, "Burst of requests allowed for each source IP")
+	flag.IntVar(&limits.LockoutThreshold, "lockoutThreshold", 5, "Consecutive refused credentials locking out the source IP (never, if 0)")
+	flag.DurationVar(&limits.LockoutDuration, "lockoutDuration", 10*time.Minute, "Duration of the lockouts")
+	flag.Float64Var(&clusterRateLimit, "clusterRateLimit", 0.2, "Requests per second allowed for each cluster ID from any source IP, the exceeding ones are delayed (unlimited, if 0)")
+	flag.IntVar(&limits.ClusterBurst, "clusterBurst", 5, "Burst of requests allowed for each cluster ID from any source IP")
+	flag.DurationVar(&limits.ClusterMaxDelay, "clusterMaxDelay", 10*time.Second, "Maximum delay of the requests of a cluster ID, refused afterwards")
+	flag.StringVar(&limits.RemoteAddressHeader, "remoteAddressHeader", "", "Header set by a trusted proxy with the client address (e.g. X-Forwarded-For)")
+	flag.StringVar(&audit.File, "auditLogFile", "", "Path of the audit log of the identity requests (disabled, if empty)")
+	flag.Int64Var(&auditLogMaxSize, "auditLogMaxSize", 10, "Size in megabytes rotating the audit log")
+	flag.IntVar(&audit.MaxBackups, "auditLogMaxBackups", 3, "Number of rotated audit logs retained")
+	flag.BoolVar(&audit.Events, "auditEvents", false, "Emit the audit records as Kubernetes events")
+	flag.DurationVar(&identityGC.Interval, "identityGCInterval", 10*time.Minute, "Interval of the garbage collection of the identities not used by any peering (disabled, if 0)")
+	flag.DurationVar(&identityGC.IdleTimeout, "identityIdleTimeout", 24*time.Hour, "Time after its creation an identity never used by a peering is deleted")
+	flag.DurationVar(&identityGC.UnpeeringGracePeriod, "identityUnpeeringGracePeriod", 7*24*time.Hour, "Time after the unpeering an identity is deleted")
+	flag.BoolVar(&identityGC.DryRun, "identityGCDryRun", false, "Only report the identities not used by any peering, without deleting them")
+	flag.DurationVar(&maxCertificateLifetime, "maxCertificateLifetime", 0, "Maximum lifetime of the certificate identities, refused if signed for longer (unlimited, if 0)")
 	flag.Parse()
 
 	klog.Info("Namespace: ", namespace)
@@ -39,6 +63,20 @@ func main() {
 
 	authService.GetAuthServiceConfig(kubeconfigPath)
 
+	limits.RateLimit = float32(rateLimit)
+	limits.ClusterRateLimit = float32(clusterRateLimit)
+	authService.ConfigureRequestLimits(limits)
+
+	audit.MaxSize = auditLogMaxSize * 1024 * 1024
+	audit.PodName = os.Getenv("POD_NAME")
+	if err = authService.ConfigureAudit(audit); err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+
+	authService.ConfigureIdentityGC(identityGC)
+	authService.ConfigureMaxCertificateLifetime(maxCertificateLifetime)
+
 	if err = authService.Start(listeningPort, certFile, keyFile); err != nil {
 		klog.Error(err)
 		os.Exit(1)
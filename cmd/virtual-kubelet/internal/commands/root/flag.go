@@ -82,6 +82,7 @@ func InstallFlags(flags *pflag.FlagSet, c *Opts) {
 	flags.StringVar(&c.ForeignClusterId, "foreign-cluster-id", c.ForeignClusterId, "The Id of the foreign cluster")
 	flags.StringVar(&c.KubeletNamespace, "kubelet-namespace", c.KubeletNamespace, "The namespace of the virtual kubelet")
 	flags.StringVar(&c.HomeClusterId, "home-cluster-id", c.HomeClusterId, "The Id of the home cluster")
+	flags.BoolVar(&c.AllowAllNamespaces, "allow-all-namespaces", c.AllowAllNamespaces, "Allow the offloading of the namespaces with neither a NamespaceOffloading nor the liqo.io/enabled=true label")
 	flags.BoolVar(&c.Profiling, "enable-profiling", c.Profiling, "Enable pprof profiling")
 
 	flagset := flag.NewFlagSet("klog", flag.PanicOnError)
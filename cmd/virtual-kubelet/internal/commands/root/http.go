@@ -28,6 +28,7 @@ import (
 	"github.com/liqotech/liqo/cmd/virtual-kubelet/internal/provider"
 	"github.com/liqotech/liqo/internal/virtualKubelet/node/api"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // AcceptedCiphers is the list of accepted TLS ciphers, with known weak ciphers elided
@@ -87,6 +88,8 @@ func setupHTTPServer(ctx context.Context, p provider.Provider, cfg *apiServerCon
 
 		podRoutes := api.PodHandlerConfig{
 			RunInContainer:        p.RunInContainer,
+			AttachToContainer:     p.AttachToContainer,
+			PortForward:           p.PortForward,
 			GetContainerLogs:      p.GetContainerLogs,
 			GetPodsFromKubernetes: getPodsFromKubernetes,
 			GetStatsSummary:       p.GetStatsSummary,
@@ -121,6 +124,8 @@ func setupHTTPServer(ctx context.Context, p provider.Provider, cfg *apiServerCon
 			GetStatsSummary: summaryHandlerFunc,
 		}
 		api.AttachPodMetricsRoutes(podMetricsRoutes, mux)
+		// the metrics of the virtual kubelet itself, e.g. the pod statuses repaired by the incoming reflection
+		mux.Handle("/metrics", promhttp.Handler())
 		s := &http.Server{
 			Handler: mux,
 		}
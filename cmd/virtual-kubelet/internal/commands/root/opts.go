@@ -84,6 +84,9 @@ type Opts struct {
 	ForeignClusterId string
 	HomeClusterId    string
 	KubeletNamespace string
+	// AllowAllNamespaces allows the offloading of the namespaces with neither a NamespaceOffloading nor the
+	// liqo.io/enabled=true label, as before their introduction
+	AllowAllNamespaces bool
 
 	Version   string
 	Profiling bool
@@ -62,15 +62,16 @@ func (s *Store) Exists(name string) bool {
 
 // InitConfig is the config passed to initialize a registered provider.
 type InitConfig struct {
-	ConfigPath        string
-	NodeName          string
-	InternalIP        string
-	DaemonPort        int32
-	KubeClusterDomain string
-	ResourceManager   *manager.ResourceManager
-	ClusterId         string
-	RemoteKubeConfig  string
-	HomeClusterId     string
+	ConfigPath         string
+	NodeName           string
+	InternalIP         string
+	DaemonPort         int32
+	KubeClusterDomain  string
+	ResourceManager    *manager.ResourceManager
+	ClusterId          string
+	RemoteKubeConfig   string
+	HomeClusterId      string
+	AllowAllNamespaces bool
 }
 
 type InitFunc func(InitConfig) (Provider, error)
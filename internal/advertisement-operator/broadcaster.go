@@ -7,11 +7,16 @@ import (
 	advpkg "github.com/liqotech/liqo/pkg/advertisement-operator"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	"github.com/liqotech/liqo/pkg/labelPolicy"
+	"github.com/liqotech/liqo/pkg/pricing"
 	pkg "github.com/liqotech/liqo/pkg/virtualKubelet"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/klog"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+	"k8s.io/kubernetes/pkg/util/slice"
+	"math/big"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +30,13 @@ import (
 	resourcehelper "k8s.io/kubectl/pkg/util/resource"
 )
 
+const (
+	// AdvertisementTTL is the validity of the Advertisements sent to the foreign cluster
+	AdvertisementTTL = 30 * time.Minute
+	// the Advertisement is renewed well before its expiration, so that a few failed attempts do not make it expire
+	advertisementRenewalInterval = AdvertisementTTL / 3
+)
+
 type AdvertisementBroadcaster struct {
 	// local-related variables
 	LocalClient     *crdClient.CRDClient
@@ -37,7 +49,13 @@ type AdvertisementBroadcaster struct {
 	ForeignClusterId   string
 	PeeringRequestName string
 	ClusterConfig      configv1alpha1.ClusterConfigSpec
-	mutex              sync.Mutex
+	// pricing engine computing the prices announced to the foreign cluster; if nil, the PricePolicy in the ClusterConfig is applied
+	PricingEngine pricing.PricingEngine
+	mutex         sync.Mutex
+	// last Advertisement sent, enforced in the namespaces used by the foreign cluster
+	enforcedAdv        *advtypes.Advertisement
+	enforcedNamespaces map[string]bool
+	enforcerMutex      sync.Mutex
 }
 
 // convenience struct, to be returned in func
@@ -45,9 +63,10 @@ type AdvResources struct {
 	PhysicalNodes *corev1.NodeList
 	VirtualNodes  *corev1.NodeList
 	Availability  corev1.ResourceList
-	Limits        corev1.ResourceList
+	Limits        corev1.ResourceList // largest amount of resources a single container can be given
 	Images        []corev1.ContainerImage
 	Labels        map[string]string
+	Zones         []advtypes.Zone
 }
 
 // start the broadcaster which sends Advertisement messages
@@ -166,7 +185,7 @@ func StartBroadcaster(homeClusterId, localKubeconfigPath, peeringRequestName, sa
 
 }
 
-// generate an Advertisement message every 10 minutes and post it to remote clusters
+// generate an Advertisement message every 10 minutes and post it to remote clusters, renewing its TimeToLive
 func (b *AdvertisementBroadcaster) GenerateAdvertisement() {
 
 	var once sync.Once
@@ -194,13 +213,17 @@ func (b *AdvertisementBroadcaster) GenerateAdvertisement() {
 			time.Sleep(1 * time.Minute)
 			continue
 		}
-
-		// start the remote watcher over this Advertisement; the watcher must be launched only once
-		go once.Do(func() {
-			b.WatchAdvertisement(adv.Name)
+		// limit the resources usable by the foreign cluster to the announced ones
+		b.EnforceAdvertisedResources(adv)
+
+		// start the remote watcher over this Advertisement and the watcher over the namespaces used by the foreign cluster;
+		// the watchers must be launched only once
+		once.Do(func() {
+			go b.WatchAdvertisement(adv.Name)
+			go b.WatchGuestNamespaces()
 		})
 
-		time.Sleep(10 * time.Minute)
+		time.Sleep(advertisementRenewalInterval)
 	}
 }
 
@@ -208,7 +231,11 @@ func (b *AdvertisementBroadcaster) GenerateAdvertisement() {
 func (b *AdvertisementBroadcaster) CreateAdvertisement(advRes *AdvResources) advtypes.Advertisement {
 
 	// set prices field
-	prices := ComputePrices(advRes.Images)
+	pricingEngine := b.PricingEngine
+	if pricingEngine == nil {
+		pricingEngine = pricing.NewPricingEngine(b.ClusterConfig.AdvertisementConfig.PricePolicy)
+	}
+	prices := pricingEngine.ComputePrices(b.ForeignClusterId, advRes.Images, time.Now())
 	// use virtual nodes to build neighbours
 	neighbours := make(map[corev1.ResourceName]corev1.ResourceList)
 	for _, vnode := range advRes.VirtualNodes.Items {
@@ -225,7 +252,7 @@ func (b *AdvertisementBroadcaster) CreateAdvertisement(advRes *AdvResources) adv
 			LimitRange: corev1.LimitRangeSpec{
 				Limits: []corev1.LimitRangeItem{
 					{
-						Type:                 "",
+						Type:                 corev1.LimitTypeContainer,
 						Max:                  advRes.Limits,
 						Min:                  nil,
 						Default:              nil,
@@ -240,6 +267,7 @@ func (b *AdvertisementBroadcaster) CreateAdvertisement(advRes *AdvResources) adv
 				ScopeSelector: nil,
 			},
 			Labels:     advRes.Labels,
+			Zones:      advRes.Zones,
 			Neighbors:  neighbours,
 			Properties: nil,
 			Prices:     prices,
@@ -248,7 +276,7 @@ func (b *AdvertisementBroadcaster) CreateAdvertisement(advRes *AdvResources) adv
 				Name:      b.KubeconfigSecretForForeign.Name,
 			},
 			Timestamp:  metav1.NewTime(time.Now()),
-			TimeToLive: metav1.NewTime(time.Now().Add(30 * time.Minute)),
+			TimeToLive: metav1.NewTime(time.Now().Add(AdvertisementTTL)),
 		},
 	}
 	return adv
@@ -276,9 +304,13 @@ func (b *AdvertisementBroadcaster) GetResourcesForAdv() (advRes *AdvResources, e
 		klog.Errorln("Could not list pods, retry in 1 minute")
 		return nil, err
 	}
-	reqs, limits := GetAllPodsResources(nodeNonTerminatedPodsList)
-	// compute resources to be announced to the other cluster
-	availability, images := ComputeAnnouncedResources(physicalNodes, reqs, int64(b.ClusterConfig.AdvertisementConfig.OutgoingConfig.ResourceSharingPercentage))
+	reqs, _ := GetAllPodsResources(nodeNonTerminatedPodsList)
+	// compute the free resources, and the share to be announced to the other cluster
+	outgoingConfig := &b.ClusterConfig.AdvertisementConfig.OutgoingConfig
+	free, images := ComputeAnnouncedResources(physicalNodes, reqs, 100)
+	free = FilterAdvertisedResources(free, outgoingConfig)
+	availability := ComputePeerShare(free, b.ForeignClusterId, b.getPeers(), outgoingConfig)
+	limits := ComputeContainerLimits(physicalNodes, availability)
 
 	labels := GetLabels(physicalNodes, b.ClusterConfig.AdvertisementConfig.LabelPolicies)
 
@@ -289,9 +321,31 @@ func (b *AdvertisementBroadcaster) GetResourcesForAdv() (advRes *AdvResources, e
 		Limits:        limits,
 		Images:        images,
 		Labels:        labels,
+		Zones:         GetZones(physicalNodes),
 	}, nil
 }
 
+// get the IDs of the foreign clusters this cluster is sharing resources with, i.e. the ones which sent a PeeringRequest
+func (b *AdvertisementBroadcaster) getPeers() []string {
+	peers := []string{b.ForeignClusterId}
+	tmp, err := b.DiscoveryClient.Resource("peeringrequests").List(metav1.ListOptions{})
+	if err != nil {
+		klog.Errorln(err, "Unable to list PeeringRequests: the resources of the other peers are not reserved")
+		return peers
+	}
+	prList, ok := tmp.(*discoveryv1alpha1.PeeringRequestList)
+	if !ok {
+		klog.Error("retrieved object is not a PeeringRequestList")
+		return peers
+	}
+	for i := range prList.Items {
+		if prList.Items[i].Name != b.ForeignClusterId {
+			peers = append(peers, prList.Items[i].Name)
+		}
+	}
+	return peers
+}
+
 func (b *AdvertisementBroadcaster) SendAdvertisementToForeignCluster(advToCreate advtypes.Advertisement) (*advtypes.Advertisement, error) {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
@@ -303,11 +357,12 @@ func (b *AdvertisementBroadcaster) SendAdvertisementToForeignCluster(advToCreate
 		// Advertisement already created, update it
 		adv = obj.(*advtypes.Advertisement)
 		advToCreate.ObjectMeta = adv.ObjectMeta
-		_, err = b.RemoteClient.Resource("advertisements").Update(adv.Name, &advToCreate, metav1.UpdateOptions{})
+		obj, err = b.RemoteClient.Resource("advertisements").Update(adv.Name, &advToCreate, metav1.UpdateOptions{})
 		if err != nil {
 			klog.Errorln("Unable to update Advertisement " + advToCreate.Name)
 			return nil, err
 		}
+		adv = obj.(*advtypes.Advertisement)
 	} else if k8serrors.IsNotFound(err) {
 		secretForeign, err := b.RemoteClient.Client().CoreV1().Secrets(b.KubeconfigSecretForForeign.Namespace).Get(context.TODO(), b.KubeconfigSecretForForeign.Name, metav1.GetOptions{})
 		if err != nil {
@@ -483,6 +538,37 @@ func GetLabels(physicalNodes *corev1.NodeList, labelPolicies []configv1alpha1.La
 	return labels
 }
 
+// GetZones returns the regions and zones the physical nodes belong to, on the basis of their well-known topology labels.
+// The zones are sorted, so that the Advertisement does not change if the topology does not
+func GetZones(physicalNodes *corev1.NodeList) []advtypes.Zone {
+	var zones []advtypes.Zone
+	for _, node := range physicalNodes.Items {
+		region := node.Labels[corev1.LabelZoneRegionStable]
+		zone := node.Labels[corev1.LabelZoneFailureDomainStable]
+		if region == "" && zone == "" {
+			continue
+		}
+		found := false
+		for i := range zones {
+			if zones[i].Region == region && zones[i].Zone == zone {
+				zones[i].Nodes++
+				found = true
+				break
+			}
+		}
+		if !found {
+			zones = append(zones, advtypes.Zone{Region: region, Zone: zone, Nodes: 1})
+		}
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].Region != zones[j].Region {
+			return zones[i].Region < zones[j].Region
+		}
+		return zones[i].Zone < zones[j].Zone
+	})
+	return zones
+}
+
 // create announced resources for advertisement
 func ComputeAnnouncedResources(physicalNodes *corev1.NodeList, reqs corev1.ResourceList, sharingPercentage int64) (availability corev1.ResourceList, images []corev1.ContainerImage) {
 	// get allocatable resources in all the physical nodes
@@ -497,30 +583,189 @@ func ComputeAnnouncedResources(physicalNodes *corev1.NodeList, reqs corev1.Resou
 		if v.Value() < 0 {
 			v.Set(0)
 		}
-		if k == corev1.ResourceCPU {
-			// use millis
-			v.SetScaled(v.MilliValue()*sharingPercentage/100, resource.Milli)
-		} else if k == corev1.ResourceMemory {
-			// use mega
-			v.SetScaled(v.ScaledValue(resource.Mega)*sharingPercentage/100, resource.Mega)
-		} else {
-			v.Set(v.Value() * sharingPercentage / 100)
-		}
-		availability[k] = v
+		availability[k] = scaleResource(defaultResourceScaling(k), v, sharingPercentage, 100)
 	}
 	return availability, images
 }
 
-// create prices resource for advertisement
+// ComputeContainerLimits returns the largest amount of each announced resource a single container can be given:
+// a container cannot span several nodes, hence it is bounded by the biggest physical node, and by the announced share
+func ComputeContainerLimits(physicalNodes *corev1.NodeList, availability corev1.ResourceList) corev1.ResourceList {
+	limits := corev1.ResourceList{}
+	for k, v := range availability {
+		if k == corev1.ResourcePods {
+			continue
+		}
+		var largest resource.Quantity
+		for i := range physicalNodes.Items {
+			if allocatable, ok := physicalNodes.Items[i].Status.Allocatable[k]; ok && allocatable.Cmp(largest) > 0 {
+				largest = allocatable
+			}
+		}
+		if largest.Cmp(v) > 0 {
+			largest = v
+		}
+		limits[k] = largest.DeepCopy()
+	}
+	return limits
+}
+
+// create prices resource for advertisement, using the default prices
 func ComputePrices(images []corev1.ContainerImage) corev1.ResourceList {
-	//TODO: logic to set prices
-	prices := corev1.ResourceList{}
-	prices[corev1.ResourceCPU] = *resource.NewQuantity(1, resource.DecimalSI)
-	prices[corev1.ResourceMemory] = resource.MustParse("2m")
-	for _, image := range images {
-		for _, name := range image.Names {
-			prices[corev1.ResourceName(name)] = *resource.NewQuantity(5, resource.DecimalSI)
+	return pricing.NewPricingEngine(configv1alpha1.PricePolicy{}).ComputePrices("", images, time.Now())
+}
+
+// compute the share of the free resources to be announced to a foreign cluster, on the basis of its sharing policy.
+// The shares of all the peers are computed as well: if their sum exceeds the free resources, each share is
+// reduced proportionally, so that the resources offered to all the peers never go beyond the cluster capacity
+func ComputePeerShare(free corev1.ResourceList, foreignClusterId string, peers []string, config *configv1alpha1.BroadcasterConfig) corev1.ResourceList {
+	if !slice.ContainsString(peers, foreignClusterId, nil) {
+		peers = append(peers, foreignClusterId)
+	}
+	demands := make(map[string]corev1.ResourceList, len(peers))
+	for _, peer := range peers {
+		demands[peer] = computePeerDemand(free, getPeerSharingPolicy(peer, config), config)
+	}
+
+	share := corev1.ResourceList{}
+	for k, v := range free {
+		demand, ok := demands[foreignClusterId][k]
+		if !ok {
+			// the resource is hidden to the foreign cluster
+			continue
+		}
+		total := resource.Quantity{}
+		for _, peer := range peers {
+			if d, ok := demands[peer][k]; ok {
+				total.Add(d)
+			}
+		}
+		if total.Cmp(v) <= 0 {
+			share[k] = demand
+		} else {
+			// the peers request more than the available resources: reduce the share proportionally
+			scaling := GetResourceScaling(k, config)
+			share[k] = scaleResource(scaling, demand, resourceUnits(scaling, v), resourceUnits(scaling, total))
+		}
+	}
+	return share
+}
+
+func getPeerSharingPolicy(clusterId string, config *configv1alpha1.BroadcasterConfig) *configv1alpha1.PeerSharingPolicy {
+	for i := range config.PeerSharingPolicies {
+		if config.PeerSharingPolicies[i].ClusterId == clusterId {
+			return &config.PeerSharingPolicies[i]
+		}
+	}
+	return nil
+}
+
+// compute the resources a peer would be offered if the cluster was shared only with it
+func computePeerDemand(free corev1.ResourceList, policy *configv1alpha1.PeerSharingPolicy, config *configv1alpha1.BroadcasterConfig) corev1.ResourceList {
+	percentage := int64(config.ResourceSharingPercentage)
+	if policy != nil && policy.ResourceSharingPercentage != nil {
+		percentage = int64(*policy.ResourceSharingPercentage)
+	}
+
+	demand := corev1.ResourceList{}
+	for k, v := range free {
+		if policy != nil && containsResourceName(policy.HiddenResources, k) {
+			continue
+		}
+		if policy != nil {
+			if absolute, ok := policy.Resources[k]; ok {
+				// the absolute share cannot exceed the free resources
+				if absolute.Cmp(v) > 0 {
+					demand[k] = v.DeepCopy()
+				} else {
+					demand[k] = absolute.DeepCopy()
+				}
+				continue
+			}
+		}
+		demand[k] = scaleResource(GetResourceScaling(k, config), v, percentage, 100)
+	}
+	return demand
+}
+
+// FilterAdvertisedResources returns the resources in the allow-list of the BroadcasterConfig, or all of them
+// (including the hugepages and the extended resources) if no allow-list is configured
+func FilterAdvertisedResources(resources corev1.ResourceList, config *configv1alpha1.BroadcasterConfig) corev1.ResourceList {
+	if len(config.AdvertisedResources) == 0 {
+		return resources.DeepCopy()
+	}
+	filtered := corev1.ResourceList{}
+	for _, advertised := range config.AdvertisedResources {
+		if v, ok := resources[advertised.Name]; ok {
+			filtered[advertised.Name] = v.DeepCopy()
+		}
+	}
+	return filtered
+}
+
+// GetResourceScaling returns the scaling rule of a resource: the one set in the allow-list, if any, or the default one
+func GetResourceScaling(k corev1.ResourceName, config *configv1alpha1.BroadcasterConfig) configv1alpha1.ResourceScaling {
+	for _, advertised := range config.AdvertisedResources {
+		if advertised.Name == k && advertised.Scaling != "" {
+			return advertised.Scaling
+		}
+	}
+	return defaultResourceScaling(k)
+}
+
+func defaultResourceScaling(k corev1.ResourceName) configv1alpha1.ResourceScaling {
+	switch {
+	case k == corev1.ResourceCPU:
+		return configv1alpha1.MilliScaling
+	case k == corev1.ResourceMemory || k == corev1.ResourceEphemeralStorage || v1helper.IsHugePageResourceName(k):
+		return configv1alpha1.MegaScaling
+	default:
+		return configv1alpha1.IntegerScaling
+	}
+}
+
+// scale a resource by num/den, according to the given scaling rule
+func scaleResource(scaling configv1alpha1.ResourceScaling, v resource.Quantity, num, den int64) resource.Quantity {
+	scaled := v.DeepCopy()
+	value := mulDiv(resourceUnits(scaling, v), num, den)
+	switch scaling {
+	case configv1alpha1.MilliScaling:
+		scaled.SetScaled(value, resource.Milli)
+	case configv1alpha1.MegaScaling:
+		scaled.SetScaled(value, resource.Mega)
+	default:
+		scaled.Set(value)
+	}
+	return scaled
+}
+
+// get the number of units of a resource according to the given scaling rule, rounding down: the Quantity
+// helpers round up instead, announcing more than the available resources
+func resourceUnits(scaling configv1alpha1.ResourceScaling, v resource.Quantity) int64 {
+	switch scaling {
+	case configv1alpha1.MilliScaling:
+		return v.MilliValue()
+	case configv1alpha1.MegaScaling:
+		return v.Value() / 1000000
+	default:
+		return v.MilliValue() / 1000
+	}
+}
+
+// compute value*num/den without overflowing
+func mulDiv(value, num, den int64) int64 {
+	if den == 0 {
+		return 0
+	}
+	res := new(big.Int).Mul(big.NewInt(value), big.NewInt(num))
+	return res.Quo(res, big.NewInt(den)).Int64()
+}
+
+func containsResourceName(names []corev1.ResourceName, name corev1.ResourceName) bool {
+	for _, n := range names {
+		if n == name {
+			return true
 		}
 	}
-	return prices
+	return false
 }
@@ -3,12 +3,14 @@ package advertisementOperator
 import (
 	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
 	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/clusterConfig"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	pkg "github.com/liqotech/liqo/pkg/virtualKubelet"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
+	"reflect"
 	"time"
 )
 
@@ -49,12 +51,33 @@ func (b *AdvertisementBroadcaster) WatchConfiguration(kubeconfigPath string, cli
 			b.updateAdvertisement()
 		}
 
+		if !reflect.DeepEqual(newConfig.PeerSharingPolicies, b.ClusterConfig.AdvertisementConfig.OutgoingConfig.PeerSharingPolicies) {
+			// the per-peer sharing policies have been modified: update the advertisement
+			klog.Info("AdvertisementConfig changed: the PeerSharingPolicies have changed")
+			b.ClusterConfig.AdvertisementConfig.OutgoingConfig = newConfig
+			b.updateAdvertisement()
+		}
+
+		if !reflect.DeepEqual(newConfig.AdvertisedResources, b.ClusterConfig.AdvertisementConfig.OutgoingConfig.AdvertisedResources) {
+			// the allow-list of the announced resources has been modified: update the advertisement
+			klog.Info("AdvertisementConfig changed: the AdvertisedResources have changed")
+			b.ClusterConfig.AdvertisementConfig.OutgoingConfig = newConfig
+			b.updateAdvertisement()
+		}
+
 		if differentLabels(b.ClusterConfig.AdvertisementConfig.LabelPolicies, configuration.Spec.AdvertisementConfig.LabelPolicies) {
 			// update label policies
 			b.ClusterConfig.AdvertisementConfig.LabelPolicies = configuration.Spec.AdvertisementConfig.LabelPolicies
 			b.updateAdvertisement()
 		}
 
+		if !reflect.DeepEqual(b.ClusterConfig.AdvertisementConfig.PricePolicy, configuration.Spec.AdvertisementConfig.PricePolicy) {
+			// the price policy has been modified: update the advertisement with the new prices
+			klog.Info("AdvertisementConfig changed: the PricePolicy has changed")
+			b.ClusterConfig.AdvertisementConfig.PricePolicy = configuration.Spec.AdvertisementConfig.PricePolicy
+			b.updateAdvertisement()
+		}
+
 	}, client, kubeconfigPath)
 }
 
@@ -64,16 +87,22 @@ func (b *AdvertisementBroadcaster) updateAdvertisement() {
 		klog.Errorln(err, "Error while computing resources for Advertisement")
 	}
 	advToCreate := b.CreateAdvertisement(advRes)
-	_, err = b.SendAdvertisementToForeignCluster(advToCreate)
+	adv, err := b.SendAdvertisementToForeignCluster(advToCreate)
 	if err != nil {
 		klog.Errorln(err, "Error while sending Advertisement to cluster "+b.ForeignClusterId)
+		return
 	}
+	b.EnforceAdvertisedResources(adv)
 }
 
 func (r *AdvertisementReconciler) WatchConfiguration(kubeconfigPath string, client *crdClient.CRDClient) {
+	r.offloadingTemplate = auth.NewPermissionTemplateHolder(auth.OffloadingProfile)
 	go clusterConfig.WatchConfiguration(func(configuration *configv1alpha1.ClusterConfig) {
+		r.handleOffloadingProfile(&configuration.Spec.AuthConfig.PermissionProfiles)
+
 		newConfig := configuration.Spec.AdvertisementConfig
-		if newConfig.IngoingConfig != r.ClusterConfig.IngoingConfig {
+		oldConfig := r.ClusterConfig
+		if !reflect.DeepEqual(newConfig.IngoingConfig, r.ClusterConfig.IngoingConfig) {
 			// the config update is related to the advertisement operator
 			// list all advertisements
 			obj, err := r.AdvClient.Resource("advertisements").List(metav1.ListOptions{})
@@ -83,25 +112,47 @@ func (r *AdvertisementReconciler) WatchConfiguration(kubeconfigPath string, clie
 			}
 			advList := obj.(*advtypes.AdvertisementList)
 
-			if newConfig.IngoingConfig.AcceptPolicy == configv1alpha1.AutoAcceptMax && newConfig.IngoingConfig.MaxAcceptableAdvertisement != r.ClusterConfig.IngoingConfig.MaxAcceptableAdvertisement {
+			if newConfig.IngoingConfig.AcceptPolicy != r.ClusterConfig.IngoingConfig.AcceptPolicy {
+				// the accept policy has changed: the pending Advertisements may have to be checked with the new one
+				klog.Infof("AdvertisementConfig changed: the AcceptPolicy has changed from %v to %v",
+					r.ClusterConfig.IngoingConfig.AcceptPolicy, newConfig.IngoingConfig.AcceptPolicy)
+				if err, _ := r.ManageAcceptPolicyUpdate(newConfig, advList); err != nil {
+					klog.Error(err, err.Error())
+					return
+				}
+			} else if newConfig.IngoingConfig.AcceptPolicy != configv1alpha1.ManualAccept && newConfig.IngoingConfig.MaxAcceptableAdvertisement != r.ClusterConfig.IngoingConfig.MaxAcceptableAdvertisement {
 				// the accept policy is set to AutoAcceptMax and the Maximum has changed: re-check all Advertisements and update if needed
 				klog.Infof("AdvertisementConfig changed: the AcceptPolicy is %v and the MaxAcceptableAdvertisement has changed from %v to %v",
 					newConfig.IngoingConfig.AcceptPolicy, r.ClusterConfig.IngoingConfig.MaxAcceptableAdvertisement, newConfig.IngoingConfig.MaxAcceptableAdvertisement)
-				err, advToUpdate := r.ManageMaximumUpdate(newConfig, advList)
-				if err != nil {
+				if err, _ := r.ManageMaximumUpdate(newConfig, advList); err != nil {
 					klog.Error(err, err.Error())
 					return
 				}
-				for i := range advToUpdate.Items {
-					adv := advToUpdate.Items[i]
-					r.UpdateAdvertisement(&adv)
+			} else {
+				r.ClusterConfig = newConfig
+			}
+			if !reflect.DeepEqual(newConfig.IngoingConfig.AcceptRules, oldConfig.IngoingConfig.AcceptRules) ||
+				!reflect.DeepEqual(newConfig.IngoingConfig.MaxPrices, oldConfig.IngoingConfig.MaxPrices) {
+				// the rules have changed: the existing Advertisements have to be checked against the new ones
+				klog.Info("AdvertisementConfig changed: the AcceptRules or the MaxPrices have changed")
+				if err, _ := r.ManageAcceptRulesUpdate(newConfig, advList); err != nil {
+					klog.Error(err, err.Error())
+					return
 				}
 			}
+			// the acceptance capacity may have changed
+			if err := r.updateAcceptanceStatus(); err != nil {
+				klog.Error(err)
+			}
 		}
 	}, client, kubeconfigPath)
 }
 
+// ManageMaximumUpdate saves the new configuration and, if the maximum has increased, accepts the refused
+// Advertisements which fit in the new capacity. It returns the Advertisements whose status has been updated
 func (r *AdvertisementReconciler) ManageMaximumUpdate(newConfig configv1alpha1.AdvertisementConfig, advList *advtypes.AdvertisementList) (error, advtypes.AdvertisementList) {
+	r.acceptanceLock.Lock()
+	defer r.acceptanceLock.Unlock()
 
 	advToUpdate := advtypes.AdvertisementList{Items: []advtypes.Advertisement{}}
 	if newConfig.IngoingConfig.MaxAcceptableAdvertisement > r.ClusterConfig.IngoingConfig.MaxAcceptableAdvertisement {
@@ -110,9 +161,14 @@ func (r *AdvertisementReconciler) ManageMaximumUpdate(newConfig configv1alpha1.A
 		for i := 0; i < len(advList.Items); i++ {
 			adv := &advList.Items[i]
 			if adv.Status.AdvertisementStatus == advtypes.AdvertisementRefused {
-				r.CheckAdvertisement(adv)
+				if err := r.CheckAdvertisement(adv); err != nil {
+					return err, advToUpdate
+				}
 				if adv.Status.AdvertisementStatus == advtypes.AdvertisementAccepted {
-					// the adv status has changed: it must be updated
+					// the adv status has changed: it is updated immediately, so that it is counted by the next checks
+					if err := r.UpdateAdvertisement(adv); err != nil {
+						return err, advToUpdate
+					}
 					advToUpdate.Items = append(advToUpdate.Items, *adv)
 				}
 			}
@@ -125,6 +181,82 @@ func (r *AdvertisementReconciler) ManageMaximumUpdate(newConfig configv1alpha1.A
 	return nil, advToUpdate
 }
 
+// ManageAcceptPolicyUpdate saves the new configuration and, if an automatic policy has been set, checks the
+// Advertisements still waiting for a manual decision. It returns the Advertisements whose status has been updated
+func (r *AdvertisementReconciler) ManageAcceptPolicyUpdate(newConfig configv1alpha1.AdvertisementConfig, advList *advtypes.AdvertisementList) (error, advtypes.AdvertisementList) {
+	r.acceptanceLock.Lock()
+	defer r.acceptanceLock.Unlock()
+
+	advToUpdate := advtypes.AdvertisementList{Items: []advtypes.Advertisement{}}
+	r.ClusterConfig = newConfig
+	if newConfig.IngoingConfig.AcceptPolicy == configv1alpha1.ManualAccept {
+		// previously accepted and refused adv are not modified
+		return nil, advToUpdate
+	}
+	for i := 0; i < len(advList.Items); i++ {
+		adv := &advList.Items[i]
+		if adv.Status.AdvertisementStatus == advtypes.AdvertisementPending {
+			if err := r.CheckAdvertisement(adv); err != nil {
+				return err, advToUpdate
+			}
+			if err := r.UpdateAdvertisement(adv); err != nil {
+				return err, advToUpdate
+			}
+			advToUpdate.Items = append(advToUpdate.Items, *adv)
+		}
+	}
+	return nil, advToUpdate
+}
+
+// ManageAcceptRulesUpdate saves the new configuration and checks the existing Advertisements against the new AcceptRules
+// and MaxPrices: the accepted and pending Advertisements no longer satisfying them are revoked, while the refused ones
+// satisfying them are checked again. It returns the Advertisements whose status has been updated
+func (r *AdvertisementReconciler) ManageAcceptRulesUpdate(newConfig configv1alpha1.AdvertisementConfig, advList *advtypes.AdvertisementList) (error, advtypes.AdvertisementList) {
+	r.acceptanceLock.Lock()
+	defer r.acceptanceLock.Unlock()
+
+	advToUpdate := advtypes.AdvertisementList{Items: []advtypes.Advertisement{}}
+	r.ClusterConfig = newConfig
+	for i := 0; i < len(advList.Items); i++ {
+		adv := &advList.Items[i]
+		if r.isDeleting(adv) {
+			continue
+		}
+		switch adv.Status.AdvertisementStatus {
+		case advtypes.AdvertisementAccepted, advtypes.AdvertisementPending:
+			reason, ok := CheckMaxPrices(adv, newConfig.IngoingConfig.MaxPrices)
+			if ok && adv.Status.AdvertisementStatus == advtypes.AdvertisementAccepted &&
+				newConfig.IngoingConfig.AcceptPolicy == configv1alpha1.PolicyAccept {
+				reason, ok = CheckAcceptRules(adv, &newConfig.IngoingConfig.AcceptRules)
+			}
+			if ok {
+				continue
+			}
+			if err := r.revokeAdvertisement(adv, reason); err != nil {
+				return err, advToUpdate
+			}
+			advToUpdate.Items = append(advToUpdate.Items, *adv)
+		case advtypes.AdvertisementRefused:
+			// with the Manual AcceptPolicy the refusals are decided by the administrator
+			if newConfig.IngoingConfig.AcceptPolicy == configv1alpha1.ManualAccept {
+				continue
+			}
+			if err := r.CheckAdvertisement(adv); err != nil {
+				return err, advToUpdate
+			}
+			if adv.Status.AdvertisementStatus == advtypes.AdvertisementRefused {
+				continue
+			}
+			// the adv status has changed: it is updated immediately, so that it is counted by the next checks
+			if err := r.UpdateAdvertisement(adv); err != nil {
+				return err, advToUpdate
+			}
+			advToUpdate.Items = append(advToUpdate.Items, *adv)
+		}
+	}
+	return nil, advToUpdate
+}
+
 func differentLabels(current []configv1alpha1.LabelPolicy, next []configv1alpha1.LabelPolicy) bool {
 	if len(current) != len(next) {
 		return true
@@ -18,10 +18,12 @@ package advertisementOperator
 import (
 	"context"
 	goerrors "errors"
+	"fmt"
 	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
 	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 	advpkg "github.com/liqotech/liqo/pkg/advertisement-operator"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	"github.com/liqotech/liqo/pkg/discovery"
 	objectreferences "github.com/liqotech/liqo/pkg/object-references"
@@ -43,23 +45,46 @@ import (
 
 const FinalizerString = "advertisement.sharing.liqo.io/virtual-kubelet"
 
+const (
+	// DefaultExpirationGracePeriod is the time an expired Advertisement is kept, with its virtual node NotReady and
+	// cordoned, waiting to be renewed before being deleted
+	DefaultExpirationGracePeriod = 10 * time.Minute
+	// interval between two checks of the Advertisements expiration
+	expirationCheckInterval = 1 * time.Minute
+	// ExpirationCordonAnnotation marks the virtual nodes cordoned because of the expiration of their Advertisement,
+	// so that only those are uncordoned on renewal, and not the ones cordoned by the administrator
+	ExpirationCordonAnnotation = "liqo.io/cordoned-on-expiration"
+)
+
 // AdvertisementReconciler reconciles a Advertisement object
 type AdvertisementReconciler struct {
 	client.Client
-	Scheme             *runtime.Scheme
-	EventsRecorder     record.EventRecorder
-	KubeletNamespace   string
-	KindEnvironment    bool
-	VKImage            string
-	InitVKImage        string
-	HomeClusterId      string
-	AcceptedAdvNum     int32
-	ClusterConfig      configv1alpha1.AdvertisementConfig
-	AdvClient          *crdClient.CRDClient
-	DiscoveryClient    *crdClient.CRDClient
-	RetryTimeout       time.Duration
-	garbaceCollector   sync.Once
-	checkRemoteCluster map[string]*sync.Once
+	Scheme                *runtime.Scheme
+	EventsRecorder        record.EventRecorder
+	KubeletNamespace      string
+	KindEnvironment       bool
+	VKImage               string
+	InitVKImage           string
+	HomeClusterId         string
+	ClusterConfig         configv1alpha1.AdvertisementConfig
+	AdvClient             *crdClient.CRDClient
+	DiscoveryClient       *crdClient.CRDClient
+	ConfigClient          *crdClient.CRDClient
+	APIReader             client.Reader
+	RetryTimeout          time.Duration
+	ExpirationGracePeriod time.Duration
+	AllowAllNamespaces    bool
+	garbaceCollector      sync.Once
+	checkRemoteCluster    map[string]*sync.Once
+	acceptanceLock        sync.Mutex
+	// offloadingTemplate is the template of the permissions granted to the virtual kubelets of the foreign clusters
+	offloadingTemplate *auth.PermissionTemplateHolder
+	offloadingApplied  bool
+	// acceptanceStatus is the acceptance status last reported in the ClusterConfigs
+	acceptanceStatus     *configv1alpha1.IngoingAdvertisementsStatus
+	acceptanceStatusLock sync.Mutex
+	// invalidDecisions contains the invalid manual decisions already reported, indexed by Advertisement name
+	invalidDecisions map[string]configv1alpha1.AdvertisementDecision
 }
 
 // +kubebuilder:rbac:groups=sharing.liqo.io,resources=advertisements,verbs=get;list;watch;create;update;patch;delete
@@ -72,20 +97,24 @@ type AdvertisementReconciler struct {
 // +kubebuilder:rbac:groups=config.liqo.io,resources=clusterconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests/approval,verbs=get;update;patch
-// +kubebuilder:rbac:groups=certificates.k8s.io,resourceNames=kubernetes.io/legacy-unknown,resources=signers,verbs=approve
+// +kubebuilder:rbac:groups=certificates.k8s.io,resourceNames=kubernetes.io/kubelet-serving,resources=signers,verbs=approve
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;create;update
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;create;update
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=events/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;update;patch
 
 func (r *AdvertisementReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 
-	// start the advertisement garbage collector
+	// start the advertisement expiration checker
 	go r.garbaceCollector.Do(func() {
-		r.cleanOldAdvertisements()
+		r.checkExpiredAdvertisements()
 	})
 
 	// initialize the checkRemoteCluster map
@@ -99,8 +128,11 @@ func (r *AdvertisementReconciler) Reconcile(req ctrl.Request) (ctrl.Result, erro
 		if errors.IsNotFound(err) {
 			// reconcile was triggered by a delete request
 			klog.Info("Advertisement " + req.Name + " deleted")
-			// TODO: decrease r.AcceptedAdvNum if the advertisement was ACCEPTED
-			return ctrl.Result{RequeueAfter: r.RetryTimeout}, client.IgnoreNotFound(err)
+			if err := r.updateAcceptanceStatus(); err != nil {
+				klog.Error(err)
+				return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+			}
+			return ctrl.Result{RequeueAfter: r.RetryTimeout}, nil
 		} else {
 			// not managed error
 			klog.Error(err)
@@ -108,10 +140,13 @@ func (r *AdvertisementReconciler) Reconcile(req ctrl.Request) (ctrl.Result, erro
 		}
 	}
 
-	// if the Advertisement is deleting and it was in accepted state, we have to decrease the counter
-	if r.isDeleting(&adv) && adv.Status.AdvertisementStatus == advtypes.AdvertisementAccepted {
-		r.AcceptedAdvNum--
-		klog.Infof("Currently accepted Advertisements: %v", r.AcceptedAdvNum)
+	// the accepted Advertisements are counted from the existing objects, hence a deleting Advertisement frees
+	// its slot as soon as its deletion starts
+	if r.isDeleting(&adv) {
+		if err := r.updateAcceptanceStatus(); err != nil {
+			klog.Error(err)
+			return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+		}
 	}
 
 	// we do that on Advertisement creation
@@ -132,8 +167,54 @@ func (r *AdvertisementReconciler) Reconcile(req ctrl.Request) (ctrl.Result, erro
 
 	// filter advertisements and create a virtual-kubelet only for the good ones
 	if adv.Status.AdvertisementStatus == "" {
-		r.CheckAdvertisement(&adv)
-		r.UpdateAdvertisement(&adv)
+		r.acceptanceLock.Lock()
+		defer r.acceptanceLock.Unlock()
+		if err := r.CheckAdvertisement(&adv); err != nil {
+			klog.Error(err)
+			return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+		}
+		if err := r.UpdateAdvertisement(&adv); err != nil {
+			klog.Error(err)
+			return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+		}
+		if err := r.updateAcceptanceStatus(); err != nil {
+			klog.Error(err)
+			return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+		}
+		return ctrl.Result{RequeueAfter: r.RetryTimeout}, nil
+	}
+
+	// the prices may change at every renewal of the Advertisement, hence the ceiling is checked at every spec change
+	if !r.isDeleting(&adv) {
+		if reason, ok := CheckMaxPrices(&adv, r.ClusterConfig.IngoingConfig.MaxPrices); !ok {
+			if err := r.revokeAdvertisement(&adv, reason); err != nil {
+				klog.Error(err)
+				return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+			}
+			return ctrl.Result{RequeueAfter: r.RetryTimeout}, nil
+		}
+	}
+
+	// with the Manual AcceptPolicy, wait for an administrator to accept or refuse the Advertisement; with the other
+	// ones, check it again
+	if adv.Status.AdvertisementStatus == advtypes.AdvertisementPending {
+		r.acceptanceLock.Lock()
+		defer r.acceptanceLock.Unlock()
+		decided, err := r.CheckPendingAdvertisement(&adv)
+		if err != nil {
+			klog.Error(err)
+			return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+		}
+		if decided {
+			if err := r.UpdateAdvertisement(&adv); err != nil {
+				klog.Error(err)
+				return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+			}
+			if err := r.updateAcceptanceStatus(); err != nil {
+				klog.Error(err)
+				return ctrl.Result{RequeueAfter: r.RetryTimeout}, err
+			}
+		}
 		return ctrl.Result{RequeueAfter: r.RetryTimeout}, nil
 	}
 
@@ -246,42 +327,269 @@ func (r *AdvertisementReconciler) UpdateForeignCluster(adv *advtypes.Advertiseme
 }
 
 // check if the advertisement is interesting and set its status accordingly
-func (r *AdvertisementReconciler) CheckAdvertisement(adv *advtypes.Advertisement) {
+// the caller is in charge of holding the acceptanceLock until the new status has been saved, so that the
+// Advertisement is taken into account by the following checks
+func (r *AdvertisementReconciler) CheckAdvertisement(adv *advtypes.Advertisement) error {
 	// if announced resources are negative, always refuse the Adv
 	for _, v := range adv.Spec.ResourceQuota.Hard {
 		if v.Value() < 0 {
 			adv.Status.AdvertisementStatus = advtypes.AdvertisementRefused
-			return
+			return nil
 		}
 	}
 
+	// if a price is above the configured ceiling, always refuse the Adv
+	if reason, ok := CheckMaxPrices(adv, r.ClusterConfig.IngoingConfig.MaxPrices); !ok {
+		klog.Infof("Advertisement %v refused: %v", adv.Name, reason)
+		adv.Status.AdvertisementStatus = advtypes.AdvertisementRefused
+		return nil
+	}
+
 	switch r.ClusterConfig.IngoingConfig.AcceptPolicy {
 	case configv1alpha1.AutoAcceptMax:
-		if r.AcceptedAdvNum < r.ClusterConfig.IngoingConfig.MaxAcceptableAdvertisement {
-			// the adv accepted so far are less than the configured maximum
-			adv.Status.AdvertisementStatus = advtypes.AdvertisementAccepted
-			r.AcceptedAdvNum++
-		} else {
-			// the maximum has been reached: cannot accept
+		return r.acceptUpToMaximum(adv)
+	case configv1alpha1.PolicyAccept:
+		if reason, ok := CheckAcceptRules(adv, &r.ClusterConfig.IngoingConfig.AcceptRules); !ok {
+			klog.Infof("Advertisement %v refused: %v", adv.Name, reason)
 			adv.Status.AdvertisementStatus = advtypes.AdvertisementRefused
+			return nil
 		}
+		return r.acceptUpToMaximum(adv)
 	case configv1alpha1.ManualAccept:
-		//TODO: manual accept/refuse, now we refuse all
+		// the decision is up to the administrator: the adv is kept pending until it is accepted or refused
+		adv.Status.AdvertisementStatus = advtypes.AdvertisementPending
+	}
+	return nil
+}
+
+// acceptUpToMaximum accepts the Advertisement if the configured maximum has not been reached yet
+func (r *AdvertisementReconciler) acceptUpToMaximum(adv *advtypes.Advertisement) error {
+	acceptedAdvNum, err := r.GetAcceptedAdvNum()
+	if err != nil {
+		return err
+	}
+	if acceptedAdvNum < r.ClusterConfig.IngoingConfig.MaxAcceptableAdvertisement {
+		// the adv accepted so far are less than the configured maximum
+		adv.Status.AdvertisementStatus = advtypes.AdvertisementAccepted
+	} else {
+		// the maximum has been reached: cannot accept
 		adv.Status.AdvertisementStatus = advtypes.AdvertisementRefused
 	}
+	return nil
+}
+
+// CheckMaxPrices checks that the prices announced in the Advertisement do not exceed the given ceiling. A resource
+// with a ceiling but without a price is a violation, since its price is unknown. If not, the reason is returned
+func CheckMaxPrices(adv *advtypes.Advertisement, maxPrices v1.ResourceList) (reason string, ok bool) {
+	for k, maxPrice := range maxPrices {
+		price, found := adv.Spec.Prices[k]
+		if !found {
+			return fmt.Sprintf("the price for %v is not announced, while the maximum is %v", k, maxPrice.String()), false
+		}
+		if price.Cmp(maxPrice) > 0 {
+			return fmt.Sprintf("the price for %v is %v, above the maximum %v", k, price.String(), maxPrice.String()), false
+		}
+	}
+	return "", true
 }
 
-func (r *AdvertisementReconciler) UpdateAdvertisement(adv *advtypes.Advertisement) {
-	if adv.Status.AdvertisementStatus == advtypes.AdvertisementAccepted {
+// revokeAdvertisement withdraws the acceptance of an Advertisement which no longer satisfies the configuration.
+// A Pending Advertisement is refused, while an accepted one is deleted together with its virtual-kubelet, so that
+// the Advertisement sent again by the foreign cluster is checked from scratch
+func (r *AdvertisementReconciler) revokeAdvertisement(adv *advtypes.Advertisement, reason string) error {
+	switch adv.Status.AdvertisementStatus {
+	case advtypes.AdvertisementPending:
+		klog.Infof("Advertisement %v refused: %v", adv.Name, reason)
+		adv.Status.AdvertisementStatus = advtypes.AdvertisementRefused
+		if err := r.UpdateAdvertisement(adv); err != nil {
+			return err
+		}
+	case advtypes.AdvertisementAccepted:
+		r.recordEvent("Advertisement "+adv.Name+" revoked: "+reason, "Warning", "AdvertisementRevoked", adv)
+		if err := r.Delete(context.Background(), adv); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	default:
+		return nil
+	}
+	return r.updateAcceptanceStatus()
+}
+
+// CheckAcceptRules checks if the Advertisement satisfies the given rules. If not, the reason is returned
+func CheckAcceptRules(adv *advtypes.Advertisement, rules *configv1alpha1.AcceptRules) (reason string, ok bool) {
+	if len(rules.AllowedClusterIds) > 0 && !slice.ContainsString(rules.AllowedClusterIds, adv.Spec.ClusterId, nil) {
+		return fmt.Sprintf("cluster %v is not allowed", adv.Spec.ClusterId), false
+	}
+	for k, minQuantity := range rules.MinResources {
+		quantity, found := adv.Spec.ResourceQuota.Hard[k]
+		if !found || quantity.Cmp(minQuantity) < 0 {
+			return fmt.Sprintf("the announced %v is %v, below the minimum %v", k, quantity.String(), minQuantity.String()), false
+		}
+	}
+	for k, v := range rules.RequiredLabels {
+		if value, found := adv.Spec.Labels[k]; !found || value != v {
+			return fmt.Sprintf("the required label %v=%v is not announced", k, v), false
+		}
+	}
+	return "", true
+}
+
+// GetAcceptedAdvNum returns the number of Advertisements currently accepted. Advertisements being deleted are not
+// counted, as their virtual-kubelet is going to be removed. The APIReader, if set, is used to read the Advertisements
+// directly from the API server, in order not to rely on a possibly stale cache
+func (r *AdvertisementReconciler) GetAcceptedAdvNum() (int32, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	return r.countAcceptedAdvertisements(reader)
+}
+
+// countAcceptedAdvertisements returns the number of Advertisements currently accepted, read through the given reader
+func (r *AdvertisementReconciler) countAcceptedAdvertisements(reader client.Reader) (int32, error) {
+	var advList advtypes.AdvertisementList
+	if err := reader.List(context.Background(), &advList, &client.ListOptions{}); err != nil {
+		return 0, err
+	}
+	var acceptedAdvNum int32
+	for i := range advList.Items {
+		adv := &advList.Items[i]
+		if adv.Status.AdvertisementStatus == advtypes.AdvertisementAccepted && !r.isDeleting(adv) {
+			acceptedAdvNum++
+		}
+	}
+	return acceptedAdvNum, nil
+}
+
+// updateAcceptanceStatus reports in the ClusterConfig status the number of accepted Advertisements
+// and how many more can be accepted with the current configuration. The Advertisements are read from the cache,
+// and the ClusterConfigs are updated only when the reported status changes
+func (r *AdvertisementReconciler) updateAcceptanceStatus() error {
+	if r.ConfigClient == nil {
+		return nil
+	}
+	r.acceptanceStatusLock.Lock()
+	defer r.acceptanceStatusLock.Unlock()
+
+	acceptedAdvNum, err := r.countAcceptedAdvertisements(r.Client)
+	if err != nil {
+		return err
+	}
+	availableAdvNum := r.ClusterConfig.IngoingConfig.MaxAcceptableAdvertisement - acceptedAdvNum
+	if availableAdvNum < 0 || r.ClusterConfig.IngoingConfig.AcceptPolicy == configv1alpha1.ManualAccept {
+		availableAdvNum = 0
+	}
+	status := configv1alpha1.IngoingAdvertisementsStatus{
+		AcceptedAdvertisements:  acceptedAdvNum,
+		AvailableAdvertisements: availableAdvNum,
+	}
+	if r.acceptanceStatus != nil && *r.acceptanceStatus == status {
+		return nil
+	}
+
+	tmp, err := r.ConfigClient.Resource("clusterconfigs").List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	configList, ok := tmp.(*configv1alpha1.ClusterConfigList)
+	if !ok {
+		return goerrors.New("retrieved object is not a ClusterConfigList")
+	}
+	for i := range configList.Items {
+		config := &configList.Items[i]
+		if config.Status.IngoingAdvertisements == status {
+			continue
+		}
+		config.Status.IngoingAdvertisements = status
+		if _, err = r.ConfigClient.Resource("clusterconfigs").UpdateStatus(config.Name, config, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	// the status is saved only once all the ClusterConfigs have been updated, so that a failure is retried
+	r.acceptanceStatus = &status
+	klog.V(4).Infof("Currently accepted Advertisements: %v", acceptedAdvNum)
+	return nil
+}
+
+// CheckPendingAdvertisement checks a Pending Advertisement: with the Manual AcceptPolicy, the decision taken by the
+// administrator is applied; otherwise, the Advertisement is checked again with the current AcceptPolicy, since it may
+// have changed while the operator was not running or its update may have failed. It returns true if the Advertisement
+// status has been modified
+func (r *AdvertisementReconciler) CheckPendingAdvertisement(adv *advtypes.Advertisement) (bool, error) {
+	if r.ClusterConfig.IngoingConfig.AcceptPolicy == configv1alpha1.ManualAccept {
+		return r.CheckManualDecision(adv), nil
+	}
+	klog.Infof("Advertisement %v is pending, but the AcceptPolicy is %v: checking it again", adv.Name,
+		r.ClusterConfig.IngoingConfig.AcceptPolicy)
+	if err := r.CheckAdvertisement(adv); err != nil {
+		return false, err
+	}
+	return adv.Status.AdvertisementStatus != advtypes.AdvertisementPending, nil
+}
+
+// ManualDecisionKey returns the key of the decision on the Advertisement in the ManualDecisions: its name and UID, so
+// that a decision is not applied to the Advertisements recreated later by the same foreign cluster
+func ManualDecisionKey(adv *advtypes.Advertisement) string {
+	return adv.Name + "/" + string(adv.UID)
+}
+
+// CheckManualDecision applies to a Pending Advertisement the decision taken by the administrator in the ManualDecisions
+// of the ClusterConfig, which cannot be modified by the foreign clusters. It returns true if the Advertisement status
+// has been modified
+func (r *AdvertisementReconciler) CheckManualDecision(adv *advtypes.Advertisement) bool {
+	decision, ok := r.ClusterConfig.IngoingConfig.ManualDecisions[ManualDecisionKey(adv)]
+	if !ok {
+		klog.V(4).Infof("Advertisement %v is waiting for a manual decision", adv.Name)
+		return false
+	}
+
+	switch decision {
+	case configv1alpha1.DecisionAccepted:
+		adv.Status.AdvertisementStatus = advtypes.AdvertisementAccepted
+	case configv1alpha1.DecisionRefused:
+		adv.Status.AdvertisementStatus = advtypes.AdvertisementRefused
+	default:
+		// the invalid decision is reported only once, not at every check of the pending Advertisement
+		if r.invalidDecisions == nil {
+			r.invalidDecisions = make(map[string]configv1alpha1.AdvertisementDecision)
+		}
+		if reported, found := r.invalidDecisions[adv.Name]; !found || reported != decision {
+			r.invalidDecisions[adv.Name] = decision
+			r.recordEvent("Advertisement "+adv.Name+" has an invalid decision "+string(decision)+": allowed values are "+
+				string(configv1alpha1.DecisionAccepted)+" and "+string(configv1alpha1.DecisionRefused), "Warning", "InvalidDecision", adv)
+		}
+		return false
+	}
+	delete(r.invalidDecisions, adv.Name)
+	klog.Infof("Advertisement %v manually %v", adv.Name, strings.ToLower(string(decision)))
+	return true
+}
+
+// UpdateAdvertisement saves the Advertisement status, recording an event for its acceptance or refusal
+func (r *AdvertisementReconciler) UpdateAdvertisement(adv *advtypes.Advertisement) error {
+	switch adv.Status.AdvertisementStatus {
+	case advtypes.AdvertisementAccepted:
 		metav1.SetMetaDataAnnotation(&adv.ObjectMeta, "advertisementStatus", "accepted")
-		r.recordEvent("Advertisement "+adv.Name+" accepted", "Normal", "AdvertisementAccepted", adv)
-	} else if adv.Status.AdvertisementStatus == advtypes.AdvertisementRefused {
+	case advtypes.AdvertisementRefused:
 		metav1.SetMetaDataAnnotation(&adv.ObjectMeta, "advertisementStatus", "refused")
-		r.recordEvent("Advertisement "+adv.Name+" refused", "Normal", "AdvertisementRefused", adv)
+	case advtypes.AdvertisementPending:
+		metav1.SetMetaDataAnnotation(&adv.ObjectMeta, "advertisementStatus", "pending")
 	}
 	if err := r.Status().Update(context.Background(), adv); err != nil {
-		klog.Error(err)
+		return err
+	}
+
+	// the events are recorded only once the status has been saved
+	switch adv.Status.AdvertisementStatus {
+	case advtypes.AdvertisementAccepted:
+		r.recordEvent("Advertisement "+adv.Name+" accepted", "Normal", "AdvertisementAccepted", adv)
+	case advtypes.AdvertisementRefused:
+		r.recordEvent("Advertisement "+adv.Name+" refused", "Normal", "AdvertisementRefused", adv)
+	case advtypes.AdvertisementPending:
+		r.recordEvent("Advertisement "+adv.Name+" is waiting for a manual decision, with key "+ManualDecisionKey(adv),
+			"Normal", "AdvertisementPending", adv)
 	}
+	return nil
 }
 
 func (r *AdvertisementReconciler) createVirtualKubelet(ctx context.Context, adv *advtypes.Advertisement) error {
@@ -326,7 +634,7 @@ func (r *AdvertisementReconciler) createVirtualKubelet(ctx context.Context, adv
 		return err
 	}
 	// Create the virtual Kubelet
-	deploy := advpkg.CreateVkDeployment(adv, name, r.KubeletNamespace, r.VKImage, r.InitVKImage, nodeName, r.HomeClusterId)
+	deploy := advpkg.CreateVkDeployment(adv, name, r.KubeletNamespace, r.VKImage, r.InitVKImage, nodeName, r.HomeClusterId, r.AllowAllNamespaces)
 	err = advpkg.CreateOrUpdate(r.Client, ctx, deploy)
 	if err != nil {
 		return err
@@ -352,29 +660,100 @@ func (r *AdvertisementReconciler) recordEvent(msg string, eventType string, even
 	r.EventsRecorder.Event(adv, eventType, eventReason, msg)
 }
 
-func (r *AdvertisementReconciler) cleanOldAdvertisements() {
-	var advList advtypes.AdvertisementList
-	// every 10 minutes list advertisements and deletes the expired ones
+// checkExpiredAdvertisements periodically checks the TimeToLive of the Advertisements
+func (r *AdvertisementReconciler) checkExpiredAdvertisements() {
 	for {
+		var advList advtypes.AdvertisementList
 		if err := r.Client.List(context.Background(), &advList, &client.ListOptions{}); err != nil {
 			klog.Error(err)
-			continue
-		}
-		for i := range advList.Items {
-			adv := advList.Items[i]
-			now := metav1.NewTime(time.Now())
-			if adv.Spec.TimeToLive.Before(now.DeepCopy()) {
-				// gracefully delete the Advertisement
-				if err := r.Delete(context.Background(), &adv); err != nil {
+		} else {
+			for i := range advList.Items {
+				if err := r.CheckAdvertisementExpiration(&advList.Items[i], time.Now()); err != nil {
 					klog.Error(err)
 				}
-				klog.Infof("Adv %v expired. TimeToLive was %v", adv.Name, adv.Spec.TimeToLive)
 			}
 		}
-		time.Sleep(10 * time.Minute)
+		time.Sleep(expirationCheckInterval)
 	}
 }
 
+// CheckAdvertisementExpiration handles the expiration of the Advertisement at the given time.
+// When the TimeToLive has passed, the virtual node is cordoned and the Advertisement is marked as expired, so that the
+// virtual-kubelet sets the node NotReady. If the Advertisement is not renewed by the broadcaster within the grace
+// period, it is deleted; if it is renewed, the virtual node is made available again
+func (r *AdvertisementReconciler) CheckAdvertisementExpiration(adv *advtypes.Advertisement, now time.Time) error {
+	if r.isDeleting(adv) {
+		return nil
+	}
+	gracePeriod := r.ExpirationGracePeriod
+	if gracePeriod == 0 {
+		gracePeriod = DefaultExpirationGracePeriod
+	}
+	ttl := adv.Spec.TimeToLive.Time
+
+	switch {
+	case now.After(ttl.Add(gracePeriod)):
+		// the grace period has ended: gracefully delete the Advertisement
+		r.recordEvent("Advertisement "+adv.Name+" expired and not renewed: deleting it", "Warning", "AdvertisementDeleted", adv)
+		if err := r.Delete(context.Background(), adv); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		klog.Infof("Adv %v expired. TimeToLive was %v", adv.Name, adv.Spec.TimeToLive)
+	case now.After(ttl):
+		if adv.Status.Expired {
+			return nil
+		}
+		if err := r.setVirtualNodeUnschedulable(adv, true); err != nil {
+			return err
+		}
+		adv.Status.Expired = true
+		if err := r.Status().Update(context.Background(), adv); err != nil {
+			return err
+		}
+		r.recordEvent(fmt.Sprintf("Advertisement %v expired: the virtual node is cordoned and will be deleted at %v if the Advertisement is not renewed",
+			adv.Name, ttl.Add(gracePeriod).Format(time.RFC3339)), "Warning", "AdvertisementExpired", adv)
+	case adv.Status.Expired:
+		// the Advertisement has been renewed during the grace period
+		if err := r.setVirtualNodeUnschedulable(adv, false); err != nil {
+			return err
+		}
+		adv.Status.Expired = false
+		if err := r.Status().Update(context.Background(), adv); err != nil {
+			return err
+		}
+		r.recordEvent("Advertisement "+adv.Name+" renewed: the virtual node is available again", "Normal", "AdvertisementRenewed", adv)
+	}
+	return nil
+}
+
+// setVirtualNodeUnschedulable cordons or uncordons the virtual node created for the Advertisement, if any.
+// A node already cordoned is left untouched, and only the nodes cordoned here, marked with the
+// ExpirationCordonAnnotation, are uncordoned
+func (r *AdvertisementReconciler) setVirtualNodeUnschedulable(adv *advtypes.Advertisement, unschedulable bool) error {
+	if adv.Status.VnodeReference.Name == "" {
+		return nil
+	}
+	var node v1.Node
+	if err := r.Get(context.Background(), client.ObjectKey{Name: adv.Status.VnodeReference.Name}, &node); err != nil {
+		// the virtual node may not have been registered yet
+		return client.IgnoreNotFound(err)
+	}
+	_, cordonedOnExpiration := node.Annotations[ExpirationCordonAnnotation]
+	switch {
+	case unschedulable && !node.Spec.Unschedulable:
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[ExpirationCordonAnnotation] = adv.Name
+	case !unschedulable && cordonedOnExpiration:
+		delete(node.Annotations, ExpirationCordonAnnotation)
+	default:
+		return nil
+	}
+	node.Spec.Unschedulable = unschedulable
+	return r.Update(context.Background(), &node)
+}
+
 func (r *AdvertisementReconciler) checkClusterStatus(adv advtypes.Advertisement) error {
 	// get the kubeconfig provided by the foreign cluster
 	remoteKubeconfig, err := r.AdvClient.Client().CoreV1().Secrets(adv.Spec.KubeConfigRef.Namespace).Get(context.Background(), adv.Spec.KubeConfigRef.Name, metav1.GetOptions{})
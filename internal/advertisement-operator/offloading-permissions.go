@@ -0,0 +1,142 @@
+package advertisementOperator
+
+import (
+	"context"
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+	"time"
+)
+
+// offloadingNamespacesResync is the interval the RoleBindings of the namespaces created by the virtual kubelets of
+// the foreign clusters are checked, restoring them if deleted.
+const offloadingNamespacesResync = 5 * time.Minute
+
+// handleOffloadingProfile updates the offloading template and, if changed or not applied yet, applies it to the
+// ClusterRoles bound to the virtual kubelets of the foreign clusters.
+func (r *AdvertisementReconciler) handleOffloadingProfile(profiles *configv1alpha1.PermissionProfiles) {
+	changed, err := r.offloadingTemplate.Update(auth.ConfiguredPermissionTemplate(profiles, auth.OffloadingProfile))
+	if err != nil {
+		klog.Error(err)
+	}
+	if !changed && r.offloadingApplied {
+		return
+	}
+	if err = r.applyOffloadingClusterRoles(r.offloadingTemplate.Get()); err != nil {
+		klog.Errorf("unable to apply the %s permission template: %v", auth.OffloadingProfile, err)
+		r.offloadingApplied = false
+		return
+	}
+	r.offloadingApplied = true
+}
+
+// applyOffloadingClusterRoles creates or updates the ClusterRoles granting the offloading template: the one with
+// the cluster rules, bound cluster-wide, and the one with the rules, bound in the namespaces created by the virtual
+// kubelets of the foreign clusters.
+func (r *AdvertisementReconciler) applyOffloadingClusterRoles(template *configv1alpha1.PermissionTemplate) error {
+	if err := r.applyOffloadingClusterRole(auth.OffloadingClusterRoleName, template.ClusterRules); err != nil {
+		return err
+	}
+	return r.applyOffloadingClusterRole(auth.OffloadingNamespaceClusterRoleName, template.Rules)
+}
+
+// applyOffloadingClusterRole creates or updates a ClusterRole granting the offloading template.
+func (r *AdvertisementReconciler) applyOffloadingClusterRole(name string, rules []rbacv1.PolicyRule) error {
+	client := r.AdvClient.Client().RbacV1().ClusterRoles()
+	role, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		role = &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Rules: rules,
+		}
+		if _, err = client.Create(context.TODO(), role, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		klog.Infof("ClusterRole %s created with the %s permission template", role.Name, auth.OffloadingProfile)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(role.Rules, rules) {
+		return nil
+	}
+	role.Rules = rules
+	if _, err = client.Update(context.TODO(), role, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	klog.Infof("ClusterRole %s updated with the %s permission template", role.Name, auth.OffloadingProfile)
+	return nil
+}
+
+// WatchOffloadingNamespaces binds the rules of the offloading template to the virtual kubelets of the foreign
+// clusters in the namespaces they create, marked with the cluster ID of their home cluster, until stopped. The
+// virtual kubelets cannot label the existing namespaces, hence they are never granted the rules in the other ones.
+func (r *AdvertisementReconciler) WatchOffloadingNamespaces(stop <-chan struct{}) {
+	factory := informers.NewSharedInformerFactoryWithOptions(r.AdvClient.Client(), offloadingNamespacesResync,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = virtualKubelet.RemoteClusterIdLabel
+		}))
+
+	factory.Core().V1().Namespaces().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			r.bindOffloadingRole(obj.(*corev1.Namespace))
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			r.bindOffloadingRole(newObj.(*corev1.Namespace))
+		},
+	})
+	factory.Start(stop)
+}
+
+// bindOffloadingRole creates or updates the RoleBinding granting the rules of the offloading template in the
+// namespace.
+func (r *AdvertisementReconciler) bindOffloadingRole(namespace *corev1.Namespace) {
+	if namespace.Status.Phase == corev1.NamespaceTerminating {
+		return
+	}
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      auth.OffloadingRoleBindingName,
+			Namespace: namespace.Name,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      auth.OffloadingServiceAccountName,
+			Namespace: r.KubeletNamespace,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     auth.OffloadingNamespaceClusterRoleName,
+		},
+	}
+
+	client := r.AdvClient.Client().RbacV1().RoleBindings(namespace.Name)
+	current, err := client.Get(context.TODO(), binding.Name, metav1.GetOptions{})
+	switch {
+	case k8serrors.IsNotFound(err):
+		if _, err = client.Create(context.TODO(), binding, metav1.CreateOptions{}); err == nil {
+			klog.Infof("RoleBinding %s/%s created with the %s permission template", binding.Namespace, binding.Name, auth.OffloadingProfile)
+		}
+	case err == nil && !equality.Semantic.DeepEqual(current.Subjects, binding.Subjects):
+		current.Subjects = binding.Subjects
+		if _, err = client.Update(context.TODO(), current, metav1.UpdateOptions{}); err == nil {
+			klog.Infof("RoleBinding %s/%s updated with the %s permission template", binding.Namespace, binding.Name, auth.OffloadingProfile)
+		}
+	}
+	if err != nil {
+		klog.Errorf("unable to bind the %s permission template in namespace %s: %v", auth.OffloadingProfile, namespace.Name, err)
+	}
+}
@@ -0,0 +1,226 @@
+package advertisementOperator
+
+import (
+	"context"
+	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
+	pkg "github.com/liqotech/liqo/pkg/virtualKubelet"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+	"strings"
+	"time"
+)
+
+const (
+	GuestResourceQuotaName = "liqo-advertised-quota"
+	GuestLimitRangeName    = "liqo-advertised-limits"
+)
+
+// requests given to the containers of the foreign cluster not declaring them, unless they exceed the default limits
+var defaultContainerRequests = corev1.ResourceList{
+	corev1.ResourceCPU:              resource.MustParse("100m"),
+	corev1.ResourceMemory:           resource.MustParse("128Mi"),
+	corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+}
+
+// EnforceAdvertisedResources creates or updates, in every local namespace used by the foreign cluster, a ResourceQuota
+// and a LimitRange matching the given Advertisement, so that the foreign cluster cannot use more than the announced resources.
+// The announced resources are split among the namespaces, as each quota is enforced on its own namespace only
+func (b *AdvertisementBroadcaster) EnforceAdvertisedResources(adv *advtypes.Advertisement) {
+	b.enforcerMutex.Lock()
+	defer b.enforcerMutex.Unlock()
+	b.enforcedAdv = adv.DeepCopy()
+
+	namespaces, err := b.LocalClient.Client().CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: b.guestNamespaceSelector(),
+	})
+	if err != nil {
+		klog.Errorln(err, "Unable to list the namespaces used by cluster "+b.ForeignClusterId)
+		return
+	}
+	b.enforcedNamespaces = make(map[string]bool, len(namespaces.Items))
+	for i := range namespaces.Items {
+		b.enforcedNamespaces[namespaces.Items[i].Name] = true
+	}
+	for i := range namespaces.Items {
+		if err = b.enforceInNamespace(namespaces.Items[i].Name, adv, len(namespaces.Items)); err != nil {
+			klog.Errorf("Unable to enforce the advertised resources in namespace %v: %v", namespaces.Items[i].Name, err)
+		}
+	}
+}
+
+// WatchGuestNamespaces enforces the advertised resources in the namespaces created by the foreign cluster
+// as soon as they appear, without waiting for the next Advertisement to be generated. Since the resources
+// are split among the namespaces, the share of each of them is recomputed whenever a namespace is added or removed
+func (b *AdvertisementBroadcaster) WatchGuestNamespaces() {
+	for {
+		watcher, err := b.LocalClient.Client().CoreV1().Namespaces().Watch(context.TODO(), metav1.ListOptions{
+			LabelSelector: b.guestNamespaceSelector(),
+		})
+		if err != nil {
+			klog.Errorln(err, "Unable to watch the namespaces used by cluster "+b.ForeignClusterId)
+			time.Sleep(1 * time.Minute)
+			continue
+		}
+		for event := range watcher.ResultChan() {
+			ns, ok := event.Object.(*corev1.Namespace)
+			if !ok || (event.Type != watch.Added && event.Type != watch.Deleted) {
+				continue
+			}
+			b.enforcerMutex.Lock()
+			adv := b.enforcedAdv
+			// nothing to do if no Advertisement has been sent yet, or if the set of namespaces is unchanged
+			changed := b.enforcedNamespaces[ns.Name] != (event.Type == watch.Added)
+			b.enforcerMutex.Unlock()
+			if adv == nil || !changed {
+				continue
+			}
+			b.EnforceAdvertisedResources(adv)
+		}
+	}
+}
+
+func (b *AdvertisementBroadcaster) guestNamespaceSelector() string {
+	return strings.Join([]string{pkg.RemoteClusterIdLabel, b.ForeignClusterId}, "=")
+}
+
+func (b *AdvertisementBroadcaster) enforceInNamespace(namespace string, adv *advtypes.Advertisement, namespaces int) error {
+	client := b.LocalClient.Client().CoreV1()
+
+	quota := ForgeGuestResourceQuota(namespace, adv, namespaces)
+	oldQuota, err := client.ResourceQuotas(namespace).Get(context.TODO(), quota.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = client.ResourceQuotas(namespace).Create(context.TODO(), quota, metav1.CreateOptions{})
+	} else if err == nil {
+		oldQuota.Spec = quota.Spec
+		_, err = client.ResourceQuotas(namespace).Update(context.TODO(), oldQuota, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	limitRange := ForgeGuestLimitRange(namespace, adv, quota.Spec.Hard)
+	oldLimitRange, err := client.LimitRanges(namespace).Get(context.TODO(), limitRange.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = client.LimitRanges(namespace).Create(context.TODO(), limitRange, metav1.CreateOptions{})
+	} else if err == nil {
+		oldLimitRange.Spec = limitRange.Spec
+		_, err = client.LimitRanges(namespace).Update(context.TODO(), oldLimitRange, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	klog.V(4).Infof("Advertised resources enforced in namespace %v", namespace)
+	return nil
+}
+
+// ForgeGuestResourceQuota creates the ResourceQuota granting a namespace its share of the resources announced in the
+// Advertisement, which are evenly split among the given number of namespaces and rounded down.
+// Extended resources and hugepages can be limited only on requests, hence the related quota names are prefixed
+func ForgeGuestResourceQuota(namespace string, adv *advtypes.Advertisement, namespaces int) *corev1.ResourceQuota {
+	if namespaces < 1 {
+		namespaces = 1
+	}
+	hard := corev1.ResourceList{}
+	for k, v := range adv.Spec.ResourceQuota.Hard {
+		share := scaleResource(defaultResourceScaling(k), v, 1, int64(namespaces))
+		if v1helper.IsExtendedResourceName(k) || v1helper.IsHugePageResourceName(k) {
+			k = corev1.ResourceName(corev1.DefaultResourceRequestsPrefix + string(k))
+		}
+		hard[k] = share
+	}
+
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GuestResourceQuotaName,
+			Namespace: namespace,
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: hard,
+		},
+	}
+}
+
+// ForgeGuestLimitRange creates the LimitRange matching the per-container limits announced in the Advertisement, further
+// bounded by the quota of the namespace. Default requests and limits are set as well: the quota requires every container
+// to declare them, and the ones created without would be rejected. Only the resources supported by the container limits
+// are kept, and zero values are dropped, as they would prevent any pod from running
+func ForgeGuestLimitRange(namespace string, adv *advtypes.Advertisement, hard corev1.ResourceList) *corev1.LimitRange {
+	limits := make([]corev1.LimitRangeItem, 0, len(adv.Spec.LimitRange.Limits))
+	for _, item := range adv.Spec.LimitRange.Limits {
+		if item.Type == "" {
+			item.Type = corev1.LimitTypeContainer
+		}
+		limitRangeItem := corev1.LimitRangeItem{
+			Type:                 item.Type,
+			Max:                  minLimits(filterLimits(item.Max), filterLimits(hard)),
+			Min:                  filterLimits(item.Min),
+			Default:              filterLimits(item.Default),
+			DefaultRequest:       filterLimits(item.DefaultRequest),
+			MaxLimitRequestRatio: filterLimits(item.MaxLimitRequestRatio),
+		}
+		if item.Type == corev1.LimitTypeContainer {
+			setContainerDefaults(&limitRangeItem)
+		}
+		limits = append(limits, limitRangeItem)
+	}
+
+	return &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GuestLimitRangeName,
+			Namespace: namespace,
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: limits,
+		},
+	}
+}
+
+// setContainerDefaults fills the default limits of the containers with the maximum ones, and their default requests
+// with the smallest among the default limits and the defaultContainerRequests
+func setContainerDefaults(item *corev1.LimitRangeItem) {
+	if len(item.Default) == 0 {
+		item.Default = item.Max.DeepCopy()
+	}
+	if len(item.DefaultRequest) == 0 {
+		item.DefaultRequest = corev1.ResourceList{}
+		for k, v := range item.Default {
+			request, ok := defaultContainerRequests[k]
+			if !ok || v.Cmp(request) < 0 {
+				request = v
+			}
+			item.DefaultRequest[k] = request.DeepCopy()
+		}
+	}
+}
+
+// minLimits returns, for every resource in limits, the smallest value between limits and bounds
+func minLimits(limits, bounds corev1.ResourceList) corev1.ResourceList {
+	if limits == nil {
+		return nil
+	}
+	for k, v := range limits {
+		if bound, ok := bounds[k]; ok && bound.Cmp(v) < 0 {
+			limits[k] = bound.DeepCopy()
+		}
+	}
+	return limits
+}
+
+func filterLimits(limits corev1.ResourceList) corev1.ResourceList {
+	if limits == nil {
+		return nil
+	}
+	filtered := corev1.ResourceList{}
+	for _, k := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage} {
+		if v, ok := limits[k]; ok && !v.IsZero() {
+			filtered[k] = v.DeepCopy()
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,202 @@
+package auth_service
+
+import (
+	"encoding/json"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditConfig configures the audit log of the identity requests.
+type AuditConfig struct {
+	// File is the path of the audit log (disabled, if empty), rotated once it exceeds MaxSize bytes, keeping
+	// MaxBackups rotated files.
+	File       string
+	MaxSize    int64
+	MaxBackups int
+	// Events enables the emission of the audit records as Kubernetes events, involving the auth service Pod.
+	Events bool
+	// PodName is the name of the auth service Pod.
+	PodName string
+}
+
+type auditResult string
+
+const (
+	auditResultAccepted    auditResult = "Accepted"
+	auditResultRefused     auditResult = "Refused"
+	auditResultRateLimited auditResult = "RateLimited"
+	auditResultLockedOut   auditResult = "LockedOut"
+	auditResultError       auditResult = "Error"
+	// the results of the garbage collection of the identities
+	auditResultOrphaned auditResult = "Orphaned"
+	auditResultDeleted  auditResult = "Deleted"
+)
+
+// auditRecord is an entry of the audit log, describing the outcome of an identity request, or of the garbage
+// collection of an identity, which has no source IP.
+type auditRecord struct {
+	Time      time.Time   `json:"time"`
+	SourceIP  string      `json:"sourceIP,omitempty"`
+	ClusterID string      `json:"clusterID,omitempty"`
+	Result    auditResult `json:"result"`
+	// Identity is the subject the permissions have been granted to, e.g. the ServiceAccount created
+	Identity string `json:"identity,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// auditLogger writes the audit records to a rotating file and, optionally, emits them as Kubernetes events.
+type auditLogger struct {
+	file     *rotatingFile
+	recorder record.EventRecorder
+	pod      *v1.ObjectReference
+}
+
+func (authService *AuthServiceCtrl) newAuditLogger(config AuditConfig) (*auditLogger, error) {
+	logger := &auditLogger{}
+	if config.File != "" {
+		file, err := newRotatingFile(config.File, config.MaxSize, config.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		logger.file = file
+	}
+
+	if config.Events {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: authService.clientset.CoreV1().Events(authService.namespace)})
+		logger.recorder = broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "liqo-auth"})
+		logger.pod = &v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  authService.namespace,
+			Name:       config.PodName,
+		}
+	}
+	return logger, nil
+}
+
+// record writes the audit record. It has no effect on a nil logger.
+func (logger *auditLogger) record(record *auditRecord) {
+	if logger == nil {
+		return
+	}
+	record.Time = time.Now().UTC()
+
+	if logger.file != nil {
+		bytes, err := json.Marshal(record)
+		if err != nil {
+			klog.Error(err)
+			return
+		}
+		if _, err = logger.file.Write(append(bytes, '\n')); err != nil {
+			klog.Error(err)
+		}
+	}
+
+	// the rate limited requests are not emitted, not to flood the events
+	if logger.recorder != nil && record.Result != auditResultRateLimited {
+		eventType := v1.EventTypeWarning
+		if record.Result == auditResultAccepted || record.Result == auditResultDeleted {
+			eventType = v1.EventTypeNormal
+		}
+		reason := "IdentityRequest" + string(record.Result)
+		var message string
+		if record.SourceIP == "" {
+			reason = "Identity" + string(record.Result)
+			message = fmt.Sprintf("identity %s of cluster %s: %s", record.Identity, record.ClusterID, record.Result)
+		} else {
+			message = fmt.Sprintf("identity request from %s: %s", record.SourceIP, record.Result)
+			if record.ClusterID != "" {
+				message = fmt.Sprintf("identity request of cluster %s from %s: %s", record.ClusterID, record.SourceIP, record.Result)
+			}
+			if record.Identity != "" {
+				message += fmt.Sprintf(", granted to %s", record.Identity)
+			}
+		}
+		if record.Message != "" {
+			message += fmt.Sprintf(" (%s)", record.Message)
+		}
+		logger.recorder.Event(logger.pod, eventType, reason, message)
+	}
+}
+
+// rotatingFile is a file renamed, with a numeric suffix, once it exceeds the maximum size.
+type rotatingFile struct {
+	mutex      sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	file := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (file *rotatingFile) Write(bytes []byte) (int, error) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	if file.maxSize > 0 && file.size > 0 && file.size+int64(len(bytes)) > file.maxSize {
+		if err := file.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := file.file.Write(bytes)
+	file.size += int64(n)
+	return n, err
+}
+
+func (file *rotatingFile) open() error {
+	f, err := os.OpenFile(file.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	file.file, file.size = f, info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files, discarding the oldest one, and opens a new file. It must be called holding the lock.
+func (file *rotatingFile) rotate() error {
+	if err := file.file.Close(); err != nil {
+		return err
+	}
+
+	backup := func(i int) string {
+		return fmt.Sprintf("%s.%d", file.path, i)
+	}
+	if file.maxBackups > 0 {
+		for i := file.maxBackups - 1; i > 0; i-- {
+			if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(file.path, backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(file.path); err != nil {
+		return err
+	}
+	return file.open()
+}
@@ -25,8 +25,10 @@ func (authService *AuthServiceCtrl) GetAuthServiceConfig(kubeconfigPath string)
 
 func (authService *AuthServiceCtrl) handleConfiguration(config configv1alpha1.AuthConfig) {
 	authService.configMutex.Lock()
-	defer authService.configMutex.Unlock()
 	authService.config = config.DeepCopy()
+	authService.configMutex.Unlock()
+
+	authService.handlePermissionProfiles(&config.PermissionProfiles)
 }
 
 func (authService *AuthServiceCtrl) GetConfig() *configv1alpha1.AuthConfig {
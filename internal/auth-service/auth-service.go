@@ -5,8 +5,11 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/liqotech/liqo/apis/config/v1alpha1"
 	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/clusterID"
 	"github.com/liqotech/liqo/pkg/crdClient"
+	"github.com/liqotech/liqo/pkg/csrApprover"
+	"io/ioutil"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -22,27 +25,44 @@ import (
 
 //cluster-role
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=create;delete
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=create;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=list;create;update;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;create;delete
+// +kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests/approval,verbs=update
+// +kubebuilder:rbac:groups=certificates.k8s.io,resources=signers,resourceNames=kubernetes.io/kube-apiserver-client,verbs=approve
 // +kubebuilder:rbac:groups=config.liqo.io,resources=clusterconfigs,verbs=get;list;watch;create
-// +kubebuilder:rbac:groups=discovery.liqo.io,resources=peeringrequests,verbs=get;create;update;delete
+// +kubebuilder:rbac:groups=discovery.liqo.io,resources=peeringrequests,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=discovery.liqo.io,resources=foreignclusters,verbs=get;list
 //role
 // +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=secrets,verbs=create;update;get;list;watch;delete
 // +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=configmaps,verbs=create;update;get;list;watch;delete
-// +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=serviceaccounts,verbs=get;list;watch;create;delete
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,namespace="do-not-care",resources=roles,verbs=create;delete
+// +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=serviceaccounts,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,namespace="do-not-care",resources=roles,verbs=list;create;update;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,namespace="do-not-care",resources=rolebindings,verbs=create;delete
 
 type AuthServiceCtrl struct {
-	namespace      string
-	clientset      kubernetes.Interface
-	saInformer     cache.SharedIndexInformer
-	nodeInformer   cache.SharedIndexInformer
-	secretInformer cache.SharedIndexInformer
-	useTls         bool
+	namespace       string
+	clientset       kubernetes.Interface
+	discoveryClient *crdClient.CRDClient
+	saInformer      cache.SharedIndexInformer
+	nodeInformer    cache.SharedIndexInformer
+	secretInformer  cache.SharedIndexInformer
+	useTls          bool
+	// apiServerCA is the CA certificate of the API server, provided to the remote clusters with a certificate identity
+	apiServerCA []byte
+	// maxCertificateLifetime is the maximum lifetime of the certificate identities (unlimited, if 0)
+	maxCertificateLifetime time.Duration
 
 	credentialsValidator credentialsValidator
 	clusterId            clusterID.ClusterID
+	// peeringTemplate is the template of the permissions granted to the identities
+	peeringTemplate *auth.PermissionTemplateHolder
+
+	// requestLimiter, auditLogger and identityCollector are nil, if not configured
+	requestLimiter    *requestLimiter
+	auditLogger       *auditLogger
+	identityCollector *identityCollector
 
 	config          *v1alpha1.AuthConfig
 	discoveryConfig v1alpha1.DiscoveryConfig
@@ -58,6 +78,10 @@ func NewAuthServiceCtrl(namespace string, kubeconfigPath string, resyncTime time
 	if err != nil {
 		return nil, err
 	}
+	discoveryClient, err := crdClient.NewFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
 	informerFactory := informers.NewSharedInformerFactoryWithOptions(clientset, resyncTime, informers.WithNamespace(namespace))
 
@@ -75,36 +99,89 @@ func NewAuthServiceCtrl(namespace string, kubeconfigPath string, resyncTime time
 		return nil, err
 	}
 
+	apiServerCA := config.CAData
+	if len(apiServerCA) == 0 && config.CAFile != "" {
+		if apiServerCA, err = ioutil.ReadFile(config.CAFile); err != nil {
+			return nil, err
+		}
+	}
+
 	informerFactory.Start(wait.NeverStop)
 	informerFactory.WaitForCacheSync(wait.NeverStop)
 
 	return &AuthServiceCtrl{
 		namespace:            namespace,
 		clientset:            clientset,
+		discoveryClient:      discoveryClient,
 		saInformer:           saInformer,
 		nodeInformer:         nodeInformer,
 		secretInformer:       secretInformer,
 		clusterId:            clusterId,
 		useTls:               useTls,
+		apiServerCA:          apiServerCA,
 		credentialsValidator: &tokenValidator{},
+		peeringTemplate:      auth.NewPermissionTemplateHolder(auth.PeeringOnlyProfile),
 	}, nil
 }
 
+// ConfigureRequestLimits enables the limits of the requests, protecting the auth service from flooding and brute-force
+// attacks on the tokens.
+func (authService *AuthServiceCtrl) ConfigureRequestLimits(limits RequestLimits) {
+	authService.requestLimiter = newRequestLimiter(limits)
+}
+
+// ConfigureMaxCertificateLifetime refuses the certificate identities living longer than the given lifetime.
+func (authService *AuthServiceCtrl) ConfigureMaxCertificateLifetime(lifetime time.Duration) {
+	authService.maxCertificateLifetime = lifetime
+}
+
+// ConfigureAudit enables the audit log of the identity requests.
+func (authService *AuthServiceCtrl) ConfigureAudit(config AuditConfig) error {
+	logger, err := authService.newAuditLogger(config)
+	if err != nil {
+		return err
+	}
+	authService.auditLogger = logger
+	return nil
+}
+
 func (authService *AuthServiceCtrl) Start(listeningPort string, certFile string, keyFile string) error {
 	if err := authService.configureToken(); err != nil {
 		return err
 	}
 
+	// the remote clusters renew their certificate identity by themselves
+	csrApprover.WatchCSR(authService.clientset, auth.CertificateSigningRequestLabel, 0, certificateApprover, authService.validateRenewal)
+
 	router := httprouter.New()
 
 	router.POST("/identity", authService.role)
 	router.GET("/ids", authService.ids)
 
+	if authService.requestLimiter != nil {
+		go wait.Until(authService.requestLimiter.prune, time.Minute, wait.NeverStop)
+	}
+	if authService.identityCollector != nil && authService.identityCollector.config.Interval > 0 {
+		go wait.Until(authService.collectIdentities, authService.identityCollector.config.Interval, wait.NeverStop)
+	}
+
+	// the timeouts prevent the slow clients from exhausting the connections, the identity requests wait for the
+	// signature of the certificates
+	server := &http.Server{
+		Addr:              strings.Join([]string{":", listeningPort}, ""),
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       time.Minute,
+		MaxHeaderBytes:    1 << 16,
+	}
+
 	var err error
 	if authService.useTls {
-		err = http.ListenAndServeTLS(strings.Join([]string{":", listeningPort}, ""), certFile, keyFile, router)
+		err = server.ListenAndServeTLS(certFile, keyFile)
 	} else {
-		err = http.ListenAndServe(strings.Join([]string{":", listeningPort}, ""), router)
+		err = server.ListenAndServe()
 	}
 	if err != nil {
 		klog.Error(err)
@@ -119,11 +196,28 @@ func (authService *AuthServiceCtrl) configureToken() error {
 	}
 
 	authService.secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			newSecret, ok := obj.(*v1.Secret)
+			if !ok || !isScopedTokenSecret(newSecret) {
+				return
+			}
+
+			if err := authService.fillScopedToken(newSecret); err != nil {
+				klog.Error(err)
+				return
+			}
+		},
 		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
 			newSecret, ok := newObj.(*v1.Secret)
 			if !ok {
 				return
 			}
+			if isScopedTokenSecret(newSecret) {
+				if err := authService.fillScopedToken(newSecret); err != nil {
+					klog.Error(err)
+				}
+				return
+			}
 			if newSecret.Name != AuthTokenSecretName {
 				return
 			}
@@ -134,6 +228,14 @@ func (authService *AuthServiceCtrl) configureToken() error {
 					klog.Error(err)
 					return
 				}
+				return
+			}
+
+			if gracePeriod, ok := getRotationGracePeriod(newSecret); ok {
+				if err := authService.rotateToken(gracePeriod); err != nil {
+					klog.Error(err)
+					return
+				}
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -9,17 +9,36 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
 	"strings"
+	"time"
 )
 
 const (
 	AuthTokenSecretName = "auth-token"
+
+	// RotateTokenAnnotation requests the rotation of the token in the AuthTokenSecretName secret. Its value is the
+	// grace period the previous token is still accepted for (defaultTokenGracePeriod, if empty).
+	RotateTokenAnnotation = "auth.liqo.io/rotate"
+	// PreviousTokenExpirationAnnotation is the RFC 3339 time the previous token stops being accepted at.
+	PreviousTokenExpirationAnnotation = "auth.liqo.io/previous-token-expiration"
+
+	tokenKey         = "token"
+	previousTokenKey = "previous-token"
+
+	defaultTokenGracePeriod = 1 * time.Hour
 )
 
 type tokenManager interface {
 	getToken() (string, error)
 	createToken() error
+	// getPreviousToken returns the token replaced by the last rotation, if still accepted.
+	getPreviousToken() (string, bool)
+	// useScopedToken consumes a use of the scoped token, if it allows the cluster to request an identity.
+	useScopedToken(token string, clusterID string) (bool, error)
+	// releaseScopedToken gives back the use of the scoped token consumed by an identity request which failed.
+	releaseScopedToken(token string)
 }
 
 func (authService *AuthServiceCtrl) getToken() (string, error) {
@@ -62,7 +81,7 @@ func (authService *AuthServiceCtrl) createToken() error {
 				Name: AuthTokenSecretName,
 			},
 			StringData: map[string]string{
-				"token": token,
+				tokenKey: token,
 			},
 		}
 		_, err = authService.clientset.CoreV1().Secrets(authService.namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
@@ -74,8 +93,82 @@ func (authService *AuthServiceCtrl) createToken() error {
 	return nil
 }
 
+func (authService *AuthServiceCtrl) getPreviousToken() (string, bool) {
+	obj, exists, err := authService.secretInformer.GetStore().GetByKey(strings.Join([]string{authService.namespace, AuthTokenSecretName}, "/"))
+	if err != nil || !exists {
+		return "", false
+	}
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return "", false
+	}
+
+	token, ok := secret.Data[previousTokenKey]
+	if !ok {
+		return "", false
+	}
+	expiration, err := time.Parse(time.RFC3339, secret.Annotations[PreviousTokenExpirationAnnotation])
+	if err != nil || !time.Now().Before(expiration) {
+		return "", false
+	}
+	return string(token), true
+}
+
+// rotateToken replaces the token with a new one, still accepting the previous one for the grace period, so that
+// the clusters which already retrieved it can complete the authentication.
+func (authService *AuthServiceCtrl) rotateToken(gracePeriod time.Duration) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := authService.clientset.CoreV1().Secrets(authService.namespace).Get(context.TODO(), AuthTokenSecretName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Data[previousTokenKey] = secret.Data[tokenKey]
+		secret.Data[tokenKey] = []byte(token)
+		secret.Annotations[PreviousTokenExpirationAnnotation] = time.Now().Add(gracePeriod).UTC().Format(time.RFC3339)
+		delete(secret.Annotations, RotateTokenAnnotation)
+
+		_, err = authService.clientset.CoreV1().Secrets(authService.namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	klog.Infof("token rotated, the previous one is accepted for %v", gracePeriod)
+	return nil
+}
+
+// getRotationGracePeriod returns the grace period requested by the RotateTokenAnnotation of the secret, if any.
+func getRotationGracePeriod(secret *v1.Secret) (time.Duration, bool) {
+	value, ok := secret.Annotations[RotateTokenAnnotation]
+	if !ok {
+		return 0, false
+	}
+	if value == "" {
+		return defaultTokenGracePeriod, true
+	}
+	gracePeriod, err := time.ParseDuration(value)
+	if err != nil || gracePeriod < 0 {
+		klog.Warningf("invalid token rotation grace period %q, falling back to %v", value, defaultTokenGracePeriod)
+		return defaultTokenGracePeriod, true
+	}
+	return gracePeriod, true
+}
+
 func (authService *AuthServiceCtrl) getTokenFromSecret(secret *v1.Secret) (string, error) {
-	v, ok := secret.Data["token"]
+	v, ok := secret.Data[tokenKey]
 	if !ok {
 		// TODO: specialise secret type
 		err := errors.New("invalid secret")
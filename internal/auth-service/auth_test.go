@@ -1,7 +1,11 @@
 package auth_service
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
 	"github.com/liqotech/liqo/apis/config/v1alpha1"
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/clusterID/test"
 	"github.com/liqotech/liqo/pkg/testUtils"
@@ -9,11 +13,21 @@ import (
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/types"
+	"io/ioutil"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -24,7 +38,10 @@ func TestAuth(t *testing.T) {
 }
 
 type tokenManagerMock struct {
-	token string
+	token         string
+	previousToken string
+	// scopedTokens maps the scoped tokens to the cluster allowed to use them
+	scopedTokens map[string]string
 }
 
 func (man *tokenManagerMock) getToken() (string, error) {
@@ -36,6 +53,17 @@ func (man *tokenManagerMock) createToken() error {
 	return nil
 }
 
+func (man *tokenManagerMock) getPreviousToken() (string, bool) {
+	return man.previousToken, man.previousToken != ""
+}
+
+func (man *tokenManagerMock) useScopedToken(token string, clusterID string) (bool, error) {
+	expectedClusterID, ok := man.scopedTokens[token]
+	return ok && expectedClusterID == clusterID, nil
+}
+
+func (man *tokenManagerMock) releaseScopedToken(token string) {}
+
 var _ = Describe("Auth", func() {
 
 	var (
@@ -51,6 +79,8 @@ var _ = Describe("Auth", func() {
 	BeforeSuite(func() {
 
 		_ = tMan.createToken()
+		tMan.previousToken = "token-previous"
+		tMan.scopedTokens = map[string]string{"token-scoped": "test1"}
 
 		var err error
 		cluster, _, err = testUtils.NewTestCluster([]string{filepath.Join("..", "..", "deployments", "liqo", "crds")})
@@ -80,12 +110,14 @@ var _ = Describe("Auth", func() {
 		authService = AuthServiceCtrl{
 			namespace:            "default",
 			clientset:            cluster.GetClient().Client(),
+			discoveryClient:      cluster.GetClient(),
 			saInformer:           saInformer,
 			nodeInformer:         nodeInformer,
 			secretInformer:       secretInformer,
 			clusterId:            &clusterID,
 			useTls:               false,
 			credentialsValidator: &tokenValidator{},
+			peeringTemplate:      auth.NewPermissionTemplateHolder(auth.PeeringOnlyProfile),
 		}
 	})
 
@@ -113,6 +145,70 @@ var _ = Describe("Auth", func() {
 			}).Should(Equal(128))
 		})
 
+		It("Rotate Token", func() {
+			oldToken, err := authService.getToken()
+			Expect(err).To(BeNil())
+
+			err = authService.rotateToken(time.Hour)
+			Expect(err).To(BeNil())
+			Eventually(func() string {
+				token, _ := authService.getToken()
+				return token
+			}).ShouldNot(Equal(oldToken))
+			Eventually(func() string {
+				token, _ := authService.getPreviousToken()
+				return token
+			}).Should(Equal(oldToken))
+		})
+
+		It("Use Scoped Token", func() {
+			_, err := authService.clientset.CoreV1().Secrets("default").Create(context.TODO(), &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "scoped-token",
+					Labels: map[string]string{ScopedTokenLabel: ""},
+					Annotations: map[string]string{
+						ScopedTokenClusterIDAnnotation: "cluster-scoped",
+						ScopedTokenMaxUsesAnnotation:   "1",
+					},
+				},
+				StringData: map[string]string{"token": "scoped"},
+			}, metav1.CreateOptions{})
+			Expect(err).To(BeNil())
+			Eventually(func() bool {
+				_, exists, _ := authService.secretInformer.GetStore().GetByKey("default/scoped-token")
+				return exists
+			}).Should(BeTrue())
+
+			Expect(authService.useScopedToken("scoped", "cluster-other")).To(BeFalse())
+			Expect(authService.useScopedToken("scoped", "cluster-scoped")).To(BeTrue())
+			Expect(authService.useScopedToken("scoped", "cluster-scoped")).To(BeFalse())
+
+			// the use released by a failed identity request can be consumed again
+			authService.releaseScopedToken("scoped")
+			Expect(authService.useScopedToken("scoped", "cluster-scoped")).To(BeTrue())
+			Expect(authService.useScopedToken("scoped", "cluster-scoped")).To(BeFalse())
+		})
+
+		It("Refuse Expired Scoped Token", func() {
+			_, err := authService.clientset.CoreV1().Secrets("default").Create(context.TODO(), &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "expired-token",
+					Labels: map[string]string{ScopedTokenLabel: ""},
+					Annotations: map[string]string{
+						ScopedTokenExpirationAnnotation: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+					},
+				},
+				StringData: map[string]string{"token": "expired"},
+			}, metav1.CreateOptions{})
+			Expect(err).To(BeNil())
+			Eventually(func() bool {
+				_, exists, _ := authService.secretInformer.GetStore().GetByKey("default/expired-token")
+				return exists
+			}).Should(BeTrue())
+
+			Expect(authService.useScopedToken("expired", "cluster-scoped")).To(BeFalse())
+		})
+
 	})
 
 	Context("Credential Validator", func() {
@@ -126,7 +222,7 @@ var _ = Describe("Auth", func() {
 		DescribeTable("Credential Validator table",
 			func(c credentialValidatorTestcase) {
 				authService.config = &c.config
-				err := authService.credentialsValidator.checkCredentials(&c.credentials, authService.getConfigProvider(), &tMan)
+				_, err := authService.credentialsValidator.checkCredentials(&c.credentials, authService.getConfigProvider(), &tMan)
 				Expect(err).To(c.expectedOutput)
 			},
 
@@ -173,10 +269,372 @@ var _ = Describe("Auth", func() {
 				},
 				expectedOutput: HaveOccurred(),
 			}),
+
+			Entry("previous token accepted", credentialValidatorTestcase{
+				credentials: auth.IdentityRequest{
+					Token:     "token-previous",
+					ClusterID: "test1",
+				},
+				config: v1alpha1.AuthConfig{
+					AllowEmptyToken: false,
+				},
+				expectedOutput: BeNil(),
+			}),
+
+			Entry("scoped token accepted", credentialValidatorTestcase{
+				credentials: auth.IdentityRequest{
+					Token:     "token-scoped",
+					ClusterID: "test1",
+				},
+				config: v1alpha1.AuthConfig{
+					AllowEmptyToken: false,
+				},
+				expectedOutput: BeNil(),
+			}),
+
+			Entry("scoped token refused", credentialValidatorTestcase{
+				credentials: auth.IdentityRequest{
+					Token:     "token-scoped",
+					ClusterID: "test2",
+				},
+				config: v1alpha1.AuthConfig{
+					AllowEmptyToken: false,
+				},
+				expectedOutput: HaveOccurred(),
+			}),
 		)
 
 	})
 
+	Context("Certificate Identity", func() {
+
+		It("Renew Certificate Identity", func() {
+			clusterID := uuid.New().String()
+			request, _, err := auth.NewCertificateRequest(clusterID)
+			Expect(err).To(BeNil())
+
+			signerName := certificatesv1beta1.KubeAPIServerClientSignerName
+			csr := &certificatesv1beta1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: auth.CertificateSigningRequestName(clusterID),
+				},
+				Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+					Request:    request,
+					SignerName: &signerName,
+					Usages:     []certificatesv1beta1.KeyUsage{certificatesv1beta1.UsageClientAuth},
+					Username:   clusterID,
+				},
+			}
+			// only the clusters with a certificate identity can renew it
+			Expect(authService.validateRenewal(csr)).To(HaveOccurred())
+
+			Expect(authService.createPermissions(certificateIdentity(clusterID))).To(Succeed())
+			Expect(authService.validateRenewal(csr)).To(Succeed())
+
+			// the permissions are granted again if the cluster asks for a new certificate
+			Expect(authService.createPermissions(certificateIdentity(clusterID))).To(Succeed())
+		})
+
+	})
+
+	Context("Permission Templates", func() {
+
+		It("Reconcile Permissions", func() {
+			clusterID := uuid.New().String()
+			identity := certificateIdentity(clusterID)
+			Expect(authService.createPermissions(identity)).To(Succeed())
+
+			profiles := &v1alpha1.PermissionProfiles{
+				PeeringOnly: &v1alpha1.PermissionTemplate{
+					ClusterRules: []rbacv1.PolicyRule{
+						{
+							APIGroups:     []string{"discovery.liqo.io"},
+							Resources:     []string{"peeringrequests"},
+							ResourceNames: []string{auth.ClusterIDPlaceholder},
+							Verbs:         []string{"get"},
+						},
+					},
+				},
+			}
+			authService.handlePermissionProfiles(profiles)
+
+			role, err := authService.clientset.RbacV1().Roles(authService.namespace).Get(context.TODO(), identity.name, metav1.GetOptions{})
+			Expect(err).To(BeNil())
+			Expect(role.Rules).To(BeEmpty())
+			clusterRole, err := authService.clientset.RbacV1().ClusterRoles().Get(context.TODO(), identity.name, metav1.GetOptions{})
+			Expect(err).To(BeNil())
+			// the rules to renew the certificate identity are kept
+			Expect(clusterRole.Rules).To(HaveLen(3))
+			Expect(clusterRole.Rules[0].ResourceNames).To(ConsistOf(clusterID))
+
+			// an invalid template is refused, keeping the previous one
+			profiles.PeeringOnly.ClusterRules[0].Verbs = []string{"*"}
+			authService.handlePermissionProfiles(profiles)
+			clusterRole, err = authService.clientset.RbacV1().ClusterRoles().Get(context.TODO(), identity.name, metav1.GetOptions{})
+			Expect(err).To(BeNil())
+			Expect(clusterRole.Rules[0].Verbs).To(ConsistOf("get"))
+
+			// without a template, the default one is restored
+			authService.handlePermissionProfiles(&v1alpha1.PermissionProfiles{})
+			role, err = authService.clientset.RbacV1().Roles(authService.namespace).Get(context.TODO(), identity.name, metav1.GetOptions{})
+			Expect(err).To(BeNil())
+			Expect(role.Rules).To(Equal(auth.RenderRules(auth.DefaultPermissionTemplate(auth.PeeringOnlyProfile).Rules, clusterID)))
+		})
+
+	})
+
+	Context("Identity Garbage Collection", func() {
+
+		It("Collect Identities", func() {
+			authService.ConfigureIdentityGC(IdentityGCConfig{IdleTimeout: time.Hour, UnpeeringGracePeriod: time.Minute})
+			now := time.Now()
+			authService.identityCollector.now = func() time.Time { return now }
+
+			peeredClusterID, idleClusterID := uuid.New().String(), uuid.New().String()
+			for _, clusterID := range []string{peeredClusterID, idleClusterID} {
+				sa, err := authService.createServiceAccount(clusterID)
+				Expect(err).To(BeNil())
+				Expect(authService.createPermissions(serviceAccountIdentity(clusterID, sa))).To(Succeed())
+			}
+			certificateClusterID := uuid.New().String()
+			Expect(authService.createPermissions(certificateIdentity(certificateClusterID))).To(Succeed())
+
+			_, err := authService.discoveryClient.Resource("peeringrequests").Create(&discoveryv1alpha1.PeeringRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: peeredClusterID,
+				},
+				Spec: discoveryv1alpha1.PeeringRequestSpec{
+					ClusterIdentity: discoveryv1alpha1.ClusterIdentity{
+						ClusterID: peeredClusterID,
+					},
+					Namespace: "default",
+				},
+			}, metav1.CreateOptions{})
+			Expect(err).To(BeNil())
+
+			getServiceAccount := func(clusterID string) error {
+				_, err := authService.clientset.CoreV1().ServiceAccounts(authService.namespace).Get(context.TODO(), "remote-"+clusterID, metav1.GetOptions{})
+				return err
+			}
+			getClusterRole := func(clusterID string) error {
+				_, err := authService.clientset.RbacV1().ClusterRoles().Get(context.TODO(), "remote-"+clusterID, metav1.GetOptions{})
+				return err
+			}
+
+			// the identities are kept until the idle timeout
+			authService.collectIdentities()
+			Expect(getServiceAccount(idleClusterID)).To(Succeed())
+			Expect(getClusterRole(certificateClusterID)).To(Succeed())
+
+			now = now.Add(2 * time.Hour)
+			authService.collectIdentities()
+			Expect(kerrors.IsNotFound(getServiceAccount(idleClusterID))).To(BeTrue())
+			Expect(kerrors.IsNotFound(getClusterRole(certificateClusterID))).To(BeTrue())
+			Expect(getServiceAccount(peeredClusterID)).To(Succeed())
+
+			// the identity is deleted after the unpeering, once the grace period has elapsed
+			Expect(authService.discoveryClient.Resource("peeringrequests").Delete(peeredClusterID, metav1.DeleteOptions{})).To(Succeed())
+			authService.collectIdentities()
+			Expect(getServiceAccount(peeredClusterID)).To(Succeed())
+
+			now = now.Add(2 * time.Minute)
+			authService.ConfigureIdentityGC(IdentityGCConfig{UnpeeringGracePeriod: time.Minute, DryRun: true})
+			authService.identityCollector.now = func() time.Time { return now }
+			authService.collectIdentities()
+			Expect(getServiceAccount(peeredClusterID)).To(Succeed())
+
+			authService.identityCollector.config.DryRun = false
+			authService.collectIdentities()
+			Expect(kerrors.IsNotFound(getServiceAccount(peeredClusterID))).To(BeTrue())
+		})
+
+	})
+
+	Context("Request Limits", func() {
+
+		It("Rate Limit Requests", func() {
+			limiter := newRequestLimiter(RequestLimits{RateLimit: 1, Burst: 2})
+			key := sourceIPKey("203.0.113.1")
+
+			for i := 0; i < 2; i++ {
+				allowed, _, _ := limiter.allow(key)
+				Expect(allowed).To(BeTrue())
+			}
+			allowed, retryAfter, lockedOut := limiter.allow(key)
+			Expect(allowed).To(BeFalse())
+			Expect(lockedOut).To(BeFalse())
+			Expect(retryAfter).To(Equal(time.Second))
+
+			// the other sources are not affected
+			allowed, _, _ = limiter.allow(sourceIPKey("203.0.113.2"))
+			Expect(allowed).To(BeTrue())
+		})
+
+		It("Lock Out After Refused Credentials", func() {
+			now := time.Now()
+			limiter := newRequestLimiter(RequestLimits{LockoutThreshold: 3, LockoutDuration: time.Minute})
+			limiter.now = func() time.Time { return now }
+			key := sourceIPKey("203.0.113.1")
+
+			Expect(limiter.recordFailure(key)).To(BeFalse())
+			// a success resets the refused credentials
+			limiter.recordSuccess(key)
+			Expect(limiter.recordFailure(key)).To(BeFalse())
+			Expect(limiter.recordFailure(key)).To(BeFalse())
+			Expect(limiter.recordFailure(key)).To(BeTrue())
+
+			allowed, retryAfter, lockedOut := limiter.allow(key)
+			Expect(allowed).To(BeFalse())
+			Expect(lockedOut).To(BeTrue())
+			Expect(retryAfter).To(Equal(time.Minute))
+
+			// the other addresses are not locked out
+			allowed, _, _ = limiter.allow(sourceIPKey("203.0.113.2"))
+			Expect(allowed).To(BeTrue())
+
+			now = now.Add(time.Minute + time.Second)
+			allowed, _, _ = limiter.allow(key)
+			Expect(allowed).To(BeTrue())
+
+			now = now.Add(requestLimiterRetention + time.Second)
+			limiter.prune()
+			Expect(limiter.sources).To(BeEmpty())
+		})
+
+		It("Slow Down the Requests of a Cluster ID", func() {
+			limiter := newRequestLimiter(RequestLimits{
+				RateLimit:        1,
+				Burst:            1,
+				LockoutThreshold: 1,
+				LockoutDuration:  time.Minute,
+				ClusterRateLimit: 10,
+				ClusterBurst:     1,
+				ClusterMaxDelay:  time.Second,
+			})
+
+			// the requests of the same cluster ID from several addresses are delayed, while each address is within
+			// its own limits
+			start := time.Now()
+			for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+				allowed, _, _ := limiter.allow(sourceIPKey(ip))
+				Expect(allowed).To(BeTrue())
+				allowed, _ = limiter.waitCluster(context.TODO(), "cluster1")
+				Expect(allowed).To(BeTrue())
+				// the refused credentials lock out the address, but not the cluster ID
+				Expect(limiter.recordFailure(sourceIPKey(ip))).To(BeTrue())
+			}
+			Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond))
+
+			// the other cluster IDs are not slowed down
+			start = time.Now()
+			allowed, _ := limiter.waitCluster(context.TODO(), "cluster2")
+			Expect(allowed).To(BeTrue())
+			Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+
+			// the requests whose turn comes after the maximum delay are refused
+			limiter.limits.ClusterMaxDelay = time.Millisecond
+			allowed, retryAfter := limiter.waitCluster(context.TODO(), "cluster2")
+			Expect(allowed).To(BeFalse())
+			Expect(retryAfter).To(Equal(time.Millisecond))
+
+			now := time.Now()
+			limiter.now = func() time.Time { return now.Add(requestLimiterRetention + time.Minute) }
+			limiter.prune()
+			Expect(limiter.clusters).To(BeEmpty())
+		})
+
+		It("Send Too Many Requests", func() {
+			service := &AuthServiceCtrl{requestLimiter: newRequestLimiter(RequestLimits{RateLimit: 0.1, Burst: 1})}
+			Expect(service.checkRequestLimits(sourceIPKey("203.0.113.1"))).To(BeEmpty())
+
+			result, err := service.checkRequestLimits(sourceIPKey("203.0.113.1"))
+			Expect(result).To(Equal(auditResultRateLimited))
+			recorder := httptest.NewRecorder()
+			service.handleError(recorder, err)
+			Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(recorder.Header().Get("Retry-After")).To(Equal("10"))
+		})
+
+		It("Get Remote Address", func() {
+			request := httptest.NewRequest(http.MethodGet, "/ids", nil)
+			request.RemoteAddr = "203.0.113.1:1234"
+			request.Header.Set("X-Forwarded-For", "192.0.2.1, 198.51.100.1")
+
+			var limiter *requestLimiter
+			Expect(limiter.remoteAddress(request)).To(Equal("203.0.113.1"))
+			limiter = newRequestLimiter(RequestLimits{RemoteAddressHeader: "X-Forwarded-For"})
+			Expect(limiter.remoteAddress(request)).To(Equal("198.51.100.1"))
+		})
+
+	})
+
+	Context("Audit Log", func() {
+
+		It("Rotate Audit Log", func() {
+			dir, err := ioutil.TempDir("", "audit")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "audit.log")
+
+			logger := &auditLogger{}
+			logger.file, err = newRotatingFile(path, 300, 2)
+			Expect(err).To(BeNil())
+
+			for i := 0; i < 10; i++ {
+				logger.record(&auditRecord{
+					SourceIP:  "203.0.113.1",
+					ClusterID: "cluster1",
+					Result:    auditResultAccepted,
+					Identity:  "ServiceAccount liqo/remote-cluster1",
+				})
+			}
+
+			bytes, err := ioutil.ReadFile(path)
+			Expect(err).To(BeNil())
+			Expect(len(bytes)).To(BeNumerically("<=", 300))
+			record := auditRecord{}
+			Expect(json.Unmarshal(bytes[:strings.Index(string(bytes), "\n")], &record)).To(Succeed())
+			Expect(record.ClusterID).To(Equal("cluster1"))
+			Expect(record.Result).To(Equal(auditResultAccepted))
+
+			Expect(path + ".1").To(BeAnExistingFile())
+			Expect(path + ".2").To(BeAnExistingFile())
+			Expect(path + ".3").ToNot(BeAnExistingFile())
+		})
+
+	})
+
+	Context("Certificate Lifetime", func() {
+
+		var certificate []byte
+
+		BeforeEach(func() {
+			var err error
+			// valid for one year, as the default certificates signed by the clusters
+			certificate, _, err = certutil.GenerateSelfSignedCertKey("cluster1", nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			authService.ConfigureMaxCertificateLifetime(0)
+		})
+
+		It("accepts any lifetime, if no maximum is configured", func() {
+			Expect(authService.checkCertificateLifetime("cluster1", certificate)).To(Succeed())
+		})
+
+		It("accepts the certificates living less than the maximum", func() {
+			authService.ConfigureMaxCertificateLifetime(2 * 365 * 24 * time.Hour)
+			Expect(authService.checkCertificateLifetime("cluster1", certificate)).To(Succeed())
+		})
+
+		It("refuses the certificates living longer than the maximum", func() {
+			authService.ConfigureMaxCertificateLifetime(30 * 24 * time.Hour)
+			Expect(authService.checkCertificateLifetime("cluster1", certificate)).To(MatchError(ContainSubstring("exceeds the maximum")))
+		})
+
+	})
+
 	Context("ServiceAccount Creation", func() {
 
 		type serviceAccountTestcase struct {
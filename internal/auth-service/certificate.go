@@ -0,0 +1,142 @@
+package auth_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/liqotech/liqo/pkg/auth"
+	"github.com/liqotech/liqo/pkg/csrApprover"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"net/http"
+	"time"
+)
+
+const (
+	certificateApprover = "Liqo Auth Service"
+
+	// longCertificateLifetime is the lifetime of the certificate identities reported as too long, when no maximum
+	// lifetime is configured.
+	longCertificateLifetime = 90 * 24 * time.Hour
+)
+
+// certificateBackoff is the backoff waiting for the signature of the certificates.
+var certificateBackoff = wait.Backoff{
+	Steps:    10,
+	Duration: 100 * time.Millisecond,
+	Factor:   1.5,
+}
+
+// signCertificate creates and approves a CSR for the certificate request of the remote cluster, and returns the
+// certificate signed by the cluster. The v1beta1 CSRs cannot request a lifetime, which is the one of the signer of
+// the cluster: the certificates living longer than the configured maximum lifetime are refused.
+func (authService *AuthServiceCtrl) signCertificate(remoteClusterId string, certificateRequest []byte) ([]byte, error) {
+	name := auth.CertificateSigningRequestName(remoteClusterId)
+	csrClient := authService.clientset.CertificatesV1beta1().CertificateSigningRequests()
+
+	// a previous CSR cannot be reused, since its request has been already signed
+	if err := csrClient.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	signerName := certificatesv1beta1.KubeAPIServerClientSignerName
+	csr, err := csrClient.Create(context.TODO(), &certificatesv1beta1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+			Request:    certificateRequest,
+			SignerName: &signerName,
+			Usages: []certificatesv1beta1.KeyUsage{
+				certificatesv1beta1.UsageDigitalSignature,
+				certificatesv1beta1.UsageKeyEncipherment,
+				certificatesv1beta1.UsageClientAuth,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if err = csrApprover.ApproveCSR(authService.clientset, csr, "This CSR was approved by "+certificateApprover); err != nil {
+		return nil, err
+	}
+
+	var certificate []byte
+	err = retry.OnError(certificateBackoff, isNoContent, func() error {
+		csr, err := csrClient.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if len(csr.Status.Certificate) == 0 {
+			return &kerrors.StatusError{ErrStatus: metav1.Status{
+				Status: metav1.StatusFailure,
+				Code:   http.StatusNoContent,
+				Reason: metav1.StatusReasonNotFound,
+			}}
+		}
+		certificate = csr.Status.Certificate
+		return nil
+	})
+	if isNoContent(err) {
+		// not refused, the remote cluster will retry
+		return nil, errors.New("the certificate has not been signed in time")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = csrClient.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+		klog.Warningf("unable to delete CSR %s: %v", name, err)
+	}
+	if err = authService.checkCertificateLifetime(remoteClusterId, certificate); err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+// checkCertificateLifetime refuses the certificates living longer than the maximum lifetime, if configured, and
+// otherwise reports the ones living longer than longCertificateLifetime.
+func (authService *AuthServiceCtrl) checkCertificateLifetime(remoteClusterId string, certificate []byte) error {
+	notBefore, notAfter, err := auth.CertificateValidity(certificate)
+	if err != nil {
+		return err
+	}
+
+	lifetime := notAfter.Sub(notBefore)
+	switch {
+	case authService.maxCertificateLifetime > 0 && lifetime > authService.maxCertificateLifetime:
+		return fmt.Errorf("the certificate of cluster %s expires at %s, its lifetime %s exceeds the maximum %s",
+			remoteClusterId, notAfter.UTC().Format(time.RFC3339), lifetime, authService.maxCertificateLifetime)
+	case authService.maxCertificateLifetime == 0 && lifetime > longCertificateLifetime:
+		klog.Warningf("the certificate of cluster %s expires at %s, consider reducing the lifetime of the certificates signed by the cluster",
+			remoteClusterId, notAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// validateRenewal accepts the CSRs created by the remote clusters to renew their certificate identity.
+func (authService *AuthServiceCtrl) validateRenewal(csr *certificatesv1beta1.CertificateSigningRequest) error {
+	if err := csrApprover.ValidateRemoteIdentityCSR(csr); err != nil {
+		return err
+	}
+	remoteClusterId := csr.Spec.Username
+	if csr.Name != auth.CertificateSigningRequestName(remoteClusterId) {
+		return fmt.Errorf("unexpected name for a CSR of cluster %s", remoteClusterId)
+	}
+
+	// only the clusters already granted a certificate identity can renew it
+	identity := certificateIdentity(remoteClusterId)
+	binding, err := authService.clientset.RbacV1().ClusterRoleBindings().Get(context.TODO(), identity.name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("no certificate identity found for cluster %s: %v", remoteClusterId, err)
+	}
+	for _, subject := range binding.Subjects {
+		if subject == identity.subject {
+			return nil
+		}
+	}
+	return fmt.Errorf("no certificate identity found for cluster %s", remoteClusterId)
+}
@@ -2,38 +2,38 @@ package auth_service
 
 import (
 	"context"
-	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
-	v1 "k8s.io/api/core/v1"
+	"github.com/liqotech/liqo/pkg/auth"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func (authService *AuthServiceCtrl) createClusterRole(remoteClusterId string, sa *v1.ServiceAccount) (*rbacv1.ClusterRole, error) {
+func (authService *AuthServiceCtrl) createClusterRole(identity *remoteIdentity) (*rbacv1.ClusterRole, error) {
+	return authService.clientset.RbacV1().ClusterRoles().Create(context.TODO(), authService.forgeClusterRole(identity), metav1.CreateOptions{})
+}
+
+// forgeClusterRole returns the ClusterRole granting the cluster rules of the peering-only profile to the identity.
+func (authService *AuthServiceCtrl) forgeClusterRole(identity *remoteIdentity) *rbacv1.ClusterRole {
 	role := &rbacv1.ClusterRole{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: sa.Name,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "v1",
-					Kind:       "ServiceAccount",
-					Name:       sa.Name,
-					UID:        sa.UID,
-				},
-			},
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				APIGroups: []string{discoveryv1alpha1.GroupVersion.Group},
-				Resources: []string{"peeringrequests"},
-				Verbs:     []string{"create"},
-			},
-			{
-				APIGroups:     []string{discoveryv1alpha1.GroupVersion.Group},
-				Resources:     []string{"peeringrequests"},
-				Verbs:         []string{"get", "delete", "update"},
-				ResourceNames: []string{remoteClusterId},
-			},
+			Name:            identity.name,
+			Labels:          identity.labels(),
+			OwnerReferences: identity.owners,
 		},
+		Rules: auth.RenderRules(authService.peeringTemplate.Get().ClusterRules, identity.clusterID),
+	}
+	if identity.isCertificate() {
+		// the remote cluster renews its client certificate by itself, whatever the profile
+		role.Rules = append(role.Rules, rbacv1.PolicyRule{
+			APIGroups: []string{certificatesv1beta1.GroupName},
+			Resources: []string{"certificatesigningrequests"},
+			Verbs:     []string{"create"},
+		}, rbacv1.PolicyRule{
+			APIGroups:     []string{certificatesv1beta1.GroupName},
+			Resources:     []string{"certificatesigningrequests"},
+			Verbs:         []string{"get", "delete"},
+			ResourceNames: []string{auth.CertificateSigningRequestName(identity.clusterID)},
+		})
 	}
-	return authService.clientset.RbacV1().ClusterRoles().Create(context.TODO(), role, metav1.CreateOptions{})
+	return role
 }
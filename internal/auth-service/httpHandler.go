@@ -1,99 +1,152 @@
 package auth_service
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/julienschmidt/httprouter"
 	"github.com/liqotech/liqo/pkg/auth"
+	"github.com/liqotech/liqo/pkg/csrApprover"
 	"io/ioutil"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/klog"
+	"math"
 	"net/http"
+	"strconv"
 )
 
+// maxIdentityRequestSize is the maximum size of the body of an identity request.
+const maxIdentityRequestSize = 1 << 20
+
 func (authService *AuthServiceCtrl) role(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	bytes, err := ioutil.ReadAll(r.Body)
+	sourceIP := authService.requestLimiter.remoteAddress(r)
+	record := &auditRecord{SourceIP: sourceIP}
+
+	if result, err := authService.checkRequestLimits(sourceIPKey(sourceIP)); err != nil {
+		authService.refuseRequest(w, record, result, err)
+		return
+	}
+
+	bytes, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIdentityRequestSize))
 	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
+		authService.refuseRequest(w, record, auditResultRefused, kerrors.NewBadRequest(err.Error()))
 		return
 	}
 
 	roleRequest := auth.IdentityRequest{}
 	err = json.Unmarshal(bytes, &roleRequest)
 	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
+		authService.refuseRequest(w, record, auditResultRefused, kerrors.NewBadRequest(err.Error()))
 		return
 	}
+	record.ClusterID = roleRequest.ClusterID
 
-	// check that the provided credentials are valid
-	if err = authService.credentialsValidator.checkCredentials(&roleRequest, authService.getConfigProvider(), authService.getTokenManager()); err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
+	if result, err := authService.waitClusterLimits(r.Context(), roleRequest.ClusterID); err != nil {
+		authService.refuseRequest(w, record, result, err)
 		return
 	}
 
-	sa, err := authService.createServiceAccount(roleRequest.ClusterID)
-	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
-		return
+	// check that the certificate request, if any, is valid before consuming the credentials
+	if len(roleRequest.CertificateSigningRequest) > 0 {
+		if err = csrApprover.ValidateClientCertificateRequest(roleRequest.CertificateSigningRequest, roleRequest.ClusterID); err != nil {
+			authService.refuseRequest(w, record, auditResultRefused, kerrors.NewBadRequest(err.Error()))
+			return
+		}
 	}
 
-	role, err := authService.createRole(roleRequest.ClusterID, sa)
+	// check that the provided credentials are valid
+	scoped, err := authService.credentialsValidator.checkCredentials(&roleRequest, authService.getConfigProvider(), authService.getTokenManager())
 	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
+		result := auditResultError
+		if kerrors.IsForbidden(err) {
+			result = auditResultRefused
+			if authService.requestLimiter.recordFailure(sourceIPKey(sourceIP)) {
+				klog.Warningf("identity requests from %s locked out after repeated refused credentials", sourceIP)
+				result = auditResultLockedOut
+			}
+		}
+		authService.refuseRequest(w, record, result, err)
 		return
 	}
+	authService.requestLimiter.recordSuccess(sourceIPKey(sourceIP))
 
-	_, err = authService.createRoleBinding(sa, role)
-	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
-		return
+	var kubeconfig string
+	var identity *remoteIdentity
+	if len(roleRequest.CertificateSigningRequest) > 0 {
+		kubeconfig, identity, err = authService.createCertificateIdentity(roleRequest.ClusterID, roleRequest.CertificateSigningRequest)
+	} else {
+		kubeconfig, identity, err = authService.createServiceAccountIdentity(roleRequest.ClusterID)
 	}
-
-	clusterRole, err := authService.createClusterRole(roleRequest.ClusterID, sa)
-	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
-		return
+	if identity != nil {
+		record.Identity = identity.String()
 	}
-
-	_, err = authService.createClusterRoleBinding(sa, clusterRole)
 	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
+		// the use of the scoped token is given back, for the cluster to retry
+		if scoped {
+			authService.getTokenManager().releaseScopedToken(roleRequest.Token)
+		}
+		authService.refuseRequest(w, record, auditResultError, err)
 		return
 	}
 
-	sa, err = authService.getServiceAccountCompleted(roleRequest.ClusterID)
+	record.Result = auditResultAccepted
+	authService.auditLogger.record(record)
+
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write([]byte(kubeconfig))
 	if err != nil {
 		klog.Error(err)
-		authService.handleError(w, err)
 		return
 	}
+}
 
-	kubeconfig, err := authService.createKubeConfig(sa)
-	if err != nil {
-		klog.Error(err)
-		authService.handleError(w, err)
-		return
+// checkRequestLimits returns a TooManyRequests error if the source is rate limited or locked out.
+func (authService *AuthServiceCtrl) checkRequestLimits(key string) (auditResult, error) {
+	allowed, retryAfter, lockedOut := authService.requestLimiter.allow(key)
+	if allowed {
+		return "", nil
+	}
+	retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
+	if lockedOut {
+		return auditResultLockedOut, kerrors.NewTooManyRequests("too many refused credentials, retry later", retryAfterSeconds)
 	}
+	return auditResultRateLimited, kerrors.NewTooManyRequests("too many requests, retry later", retryAfterSeconds)
+}
 
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write([]byte(kubeconfig))
-	if err != nil {
-		klog.Error(err)
-		return
+// waitClusterLimits delays the request until the rate limit of the cluster ID allows it, returning a TooManyRequests
+// error if it would be delayed for too long.
+func (authService *AuthServiceCtrl) waitClusterLimits(ctx context.Context, clusterID string) (auditResult, error) {
+	allowed, retryAfter := authService.requestLimiter.waitCluster(ctx, clusterID)
+	if allowed {
+		return "", nil
 	}
+	return auditResultRateLimited, kerrors.NewTooManyRequests(
+		"too many requests for the cluster ID, retry later", int(math.Ceil(retryAfter.Seconds())))
+}
+
+// refuseRequest audits the failed identity request and sends the error.
+func (authService *AuthServiceCtrl) refuseRequest(w http.ResponseWriter, record *auditRecord, result auditResult, err error) {
+	klog.Error(err)
+	record.Result = result
+	record.Message = err.Error()
+	authService.auditLogger.record(record)
+	authService.handleError(w, err)
 }
 
 func (authService *AuthServiceCtrl) handleError(w http.ResponseWriter, err error) {
-	switch err.(type) {
+	switch err := err.(type) {
 	case *kerrors.StatusError:
-		authService.sendError(w, "forbidden", http.StatusForbidden)
+		// only the errors caused by the request are detailed, the other ones may leak information about the cluster
+		switch code := int(err.Status().Code); code {
+		case http.StatusBadRequest:
+			authService.sendError(w, err.Error(), code)
+		case http.StatusTooManyRequests:
+			if details := err.Status().Details; details != nil && details.RetryAfterSeconds > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(details.RetryAfterSeconds)))
+			}
+			authService.sendError(w, err.Error(), code)
+		default:
+			authService.sendError(w, "forbidden", http.StatusForbidden)
+		}
 	default:
 		authService.sendError(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -0,0 +1,260 @@
+package auth_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"strings"
+	"time"
+)
+
+// IdentityGCConfig configures the garbage collection of the identities of the remote clusters no longer peered.
+type IdentityGCConfig struct {
+	// Interval is the time between two collections (disabled, if not positive).
+	Interval time.Duration
+	// IdleTimeout is the time after its creation an identity never used by a peering is deleted.
+	IdleTimeout time.Duration
+	// UnpeeringGracePeriod is the time after the unpeering an identity is deleted.
+	UnpeeringGracePeriod time.Duration
+	// DryRun only reports the orphaned identities, without deleting them.
+	DryRun bool
+}
+
+const (
+	// peeredAnnotation marks the identities used by a peering, unpeeredAnnotation records when the peering ended.
+	peeredAnnotation   = "auth.liqo.io/peered"
+	unpeeredAnnotation = "auth.liqo.io/unpeered-at"
+)
+
+// identityCollector deletes the identities of the remote clusters which are not peered anymore, or which never peered.
+type identityCollector struct {
+	config IdentityGCConfig
+	now    func() time.Time
+	// reported are the orphaned identities already reported, not to report them on each collection
+	reported map[string]bool
+}
+
+// collectedIdentity is an identity along with the object tracking its peering: the ServiceAccount, or the ClusterRole
+// of the certificate identities.
+type collectedIdentity struct {
+	*remoteIdentity
+	meta   *metav1.ObjectMeta
+	update func() error
+}
+
+// ConfigureIdentityGC enables the garbage collection of the identities.
+func (authService *AuthServiceCtrl) ConfigureIdentityGC(config IdentityGCConfig) {
+	authService.identityCollector = &identityCollector{
+		config:   config,
+		now:      time.Now,
+		reported: map[string]bool{},
+	}
+}
+
+// collectIdentities deletes the identities not used by any peering since the configured time, reporting the orphaned
+// ones. An identity is used by a peering while the PeeringRequest of its cluster exists, or the ForeignCluster of its
+// cluster is joined.
+func (authService *AuthServiceCtrl) collectIdentities() {
+	peered, err := authService.getPeeredClusters()
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	identities, err := authService.listIdentities()
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	for _, identity := range identities {
+		if err = authService.collectIdentity(identity, peered[identity.clusterID]); err != nil {
+			klog.Errorf("unable to collect identity %s: %v", identity, err)
+		}
+	}
+}
+
+// getPeeredClusters returns the IDs of the remote clusters peered with the local one.
+func (authService *AuthServiceCtrl) getPeeredClusters() (map[string]bool, error) {
+	peered := map[string]bool{}
+
+	tmp, err := authService.discoveryClient.Resource("peeringrequests").List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	prs, ok := tmp.(*discoveryv1alpha1.PeeringRequestList)
+	if !ok {
+		return nil, errors.New("retrieved object is not a PeeringRequestList")
+	}
+	for i := range prs.Items {
+		peered[prs.Items[i].Name] = true
+		peered[prs.Items[i].Spec.ClusterIdentity.ClusterID] = true
+	}
+
+	tmp, err = authService.discoveryClient.Resource("foreignclusters").List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	fcs, ok := tmp.(*discoveryv1alpha1.ForeignClusterList)
+	if !ok {
+		return nil, errors.New("retrieved object is not a ForeignClusterList")
+	}
+	for i := range fcs.Items {
+		if fcs.Items[i].Status.Incoming.Joined {
+			peered[fcs.Items[i].Spec.ClusterIdentity.ClusterID] = true
+		}
+	}
+	return peered, nil
+}
+
+// listIdentities returns the identities created by the auth service: the ServiceAccounts named after the remote
+// clusters, and the certificate identities, recognized by the label of their ClusterRole.
+func (authService *AuthServiceCtrl) listIdentities() ([]*collectedIdentity, error) {
+	var identities []*collectedIdentity
+
+	sas, err := authService.clientset.CoreV1().ServiceAccounts(authService.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range sas.Items {
+		sa := &sas.Items[i]
+		// the ServiceAccounts created by previous versions are not labeled
+		if !strings.HasPrefix(sa.Name, "remote-") {
+			continue
+		}
+		clusterID := sa.Labels[auth.RemoteClusterIDLabel]
+		if clusterID == "" {
+			clusterID = strings.TrimPrefix(sa.Name, "remote-")
+		}
+		identities = append(identities, &collectedIdentity{
+			remoteIdentity: serviceAccountIdentity(clusterID, sa),
+			meta:           &sa.ObjectMeta,
+			update: func() error {
+				_, err := authService.clientset.CoreV1().ServiceAccounts(authService.namespace).Update(context.TODO(), sa, metav1.UpdateOptions{})
+				return err
+			},
+		})
+	}
+
+	clusterRoles, err := authService.clientset.RbacV1().ClusterRoles().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", auth.IdentityKindLabel, rbacv1.UserKind),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusterRoles.Items {
+		clusterRole := &clusterRoles.Items[i]
+		identities = append(identities, &collectedIdentity{
+			remoteIdentity: certificateIdentity(clusterRole.Labels[auth.RemoteClusterIDLabel]),
+			meta:           &clusterRole.ObjectMeta,
+			update: func() error {
+				_, err := authService.clientset.RbacV1().ClusterRoles().Update(context.TODO(), clusterRole, metav1.UpdateOptions{})
+				return err
+			},
+		})
+	}
+	return identities, nil
+}
+
+// collectIdentity records the peering of the identity, deleting it once expired.
+func (authService *AuthServiceCtrl) collectIdentity(identity *collectedIdentity, peered bool) error {
+	collector := authService.identityCollector
+	now := collector.now()
+	key := identity.String()
+
+	if identity.meta.Annotations == nil {
+		identity.meta.Annotations = map[string]string{}
+	}
+	annotations := identity.meta.Annotations
+
+	if peered {
+		delete(collector.reported, key)
+		if _, unpeered := annotations[unpeeredAnnotation]; annotations[peeredAnnotation] == "true" && !unpeered {
+			return nil
+		}
+		annotations[peeredAnnotation] = "true"
+		delete(annotations, unpeeredAnnotation)
+		return identity.update()
+	}
+
+	var expiration time.Time
+	if annotations[peeredAnnotation] == "true" {
+		unpeeredAt, err := time.Parse(time.RFC3339, annotations[unpeeredAnnotation])
+		if err != nil {
+			// the peering has just ended
+			unpeeredAt = now
+			annotations[unpeeredAnnotation] = unpeeredAt.UTC().Format(time.RFC3339)
+			if err = identity.update(); err != nil {
+				return err
+			}
+		}
+		expiration = unpeeredAt.Add(collector.config.UnpeeringGracePeriod)
+	} else {
+		expiration = identity.meta.CreationTimestamp.Add(collector.config.IdleTimeout)
+	}
+
+	if now.Before(expiration) || collector.config.DryRun {
+		if collector.reported[key] {
+			return nil
+		}
+		collector.reported[key] = true
+		message := fmt.Sprintf("not used by any peering, it will be deleted at %s", expiration.UTC().Format(time.RFC3339))
+		if collector.config.DryRun {
+			message = fmt.Sprintf("not used by any peering, it expires at %s (dry run)", expiration.UTC().Format(time.RFC3339))
+		}
+		klog.Warningf("identity %s of cluster %s %s", identity, identity.clusterID, message)
+		authService.auditLogger.record(&auditRecord{
+			ClusterID: identity.clusterID,
+			Result:    auditResultOrphaned,
+			Identity:  key,
+			Message:   message,
+		})
+		return nil
+	}
+
+	if err := authService.deleteIdentity(identity.remoteIdentity); err != nil {
+		return err
+	}
+	delete(collector.reported, key)
+	klog.Infof("identity %s of cluster %s deleted, since not used by any peering", identity, identity.clusterID)
+	authService.auditLogger.record(&auditRecord{
+		ClusterID: identity.clusterID,
+		Result:    auditResultDeleted,
+		Identity:  key,
+	})
+	return nil
+}
+
+// deleteIdentity deletes the identity along with its permissions: the ones of the ServiceAccounts are deleted with
+// them, being their owner.
+func (authService *AuthServiceCtrl) deleteIdentity(identity *remoteIdentity) error {
+	ignoreNotFound := func(err error) error {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	background := metav1.DeletePropagationBackground
+	options := metav1.DeleteOptions{PropagationPolicy: &background}
+	if !identity.isCertificate() {
+		return ignoreNotFound(authService.clientset.CoreV1().ServiceAccounts(authService.namespace).Delete(context.TODO(), identity.subject.Name, options))
+	}
+
+	rbac := authService.clientset.RbacV1()
+	if err := ignoreNotFound(rbac.RoleBindings(authService.namespace).Delete(context.TODO(), identity.name, options)); err != nil {
+		return err
+	}
+	if err := ignoreNotFound(rbac.Roles(authService.namespace).Delete(context.TODO(), identity.name, options)); err != nil {
+		return err
+	}
+	if err := ignoreNotFound(rbac.ClusterRoleBindings().Delete(context.TODO(), identity.name, options)); err != nil {
+		return err
+	}
+	return ignoreNotFound(rbac.ClusterRoles().Delete(context.TODO(), identity.name, options))
+}
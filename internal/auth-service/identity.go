@@ -0,0 +1,145 @@
+package auth_service
+
+import (
+	"fmt"
+	"github.com/liqotech/liqo/pkg/auth"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// remoteIdentity is the subject the permissions of a remote cluster are granted to: either a ServiceAccount, or the
+// user authenticated by a client certificate.
+type remoteIdentity struct {
+	clusterID string
+	// name is the name of the RBAC resources granting the permissions
+	name    string
+	subject rbacv1.Subject
+	owners  []metav1.OwnerReference
+}
+
+func serviceAccountIdentity(remoteClusterId string, sa *v1.ServiceAccount) *remoteIdentity {
+	return &remoteIdentity{
+		clusterID: remoteClusterId,
+		name:      sa.Name,
+		subject: rbacv1.Subject{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      sa.Name,
+			Namespace: sa.Namespace,
+		},
+		owners: []metav1.OwnerReference{
+			{
+				APIVersion: "v1",
+				Kind:       "ServiceAccount",
+				Name:       sa.Name,
+				UID:        sa.UID,
+			},
+		},
+	}
+}
+
+// certificateIdentity returns the identity of the remote cluster authenticated by a client certificate, whose common
+// name is the cluster ID.
+func certificateIdentity(remoteClusterId string) *remoteIdentity {
+	return &remoteIdentity{
+		clusterID: remoteClusterId,
+		name:      fmt.Sprintf("remote-%s", remoteClusterId),
+		subject: rbacv1.Subject{
+			APIGroup: rbacv1.GroupName,
+			Kind:     rbacv1.UserKind,
+			Name:     remoteClusterId,
+		},
+	}
+}
+
+func (identity *remoteIdentity) isCertificate() bool {
+	return identity.subject.Kind == rbacv1.UserKind
+}
+
+// labels returns the labels of the roles granting the permissions to the identity, from which it can be recovered.
+func (identity *remoteIdentity) labels() map[string]string {
+	return map[string]string{
+		auth.RemoteClusterIDLabel: identity.clusterID,
+		auth.IdentityKindLabel:    identity.subject.Kind,
+	}
+}
+
+// labeledIdentity returns the identity the role with the given metadata has been created for.
+func labeledIdentity(meta *metav1.ObjectMeta) *remoteIdentity {
+	return &remoteIdentity{
+		clusterID: meta.Labels[auth.RemoteClusterIDLabel],
+		name:      meta.Name,
+		subject:   rbacv1.Subject{Kind: meta.Labels[auth.IdentityKindLabel]},
+		owners:    meta.OwnerReferences,
+	}
+}
+
+func (identity *remoteIdentity) String() string {
+	if identity.subject.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", identity.subject.Kind, identity.subject.Namespace, identity.subject.Name)
+	}
+	return fmt.Sprintf("%s %s", identity.subject.Kind, identity.subject.Name)
+}
+
+// createServiceAccountIdentity creates a ServiceAccount for the remote cluster, grants it the permissions, and returns
+// a kubeconfig with its token, along with the identity created.
+func (authService *AuthServiceCtrl) createServiceAccountIdentity(remoteClusterId string) (string, *remoteIdentity, error) {
+	sa, err := authService.createServiceAccount(remoteClusterId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	identity := serviceAccountIdentity(remoteClusterId, sa)
+	if err = authService.createPermissions(identity); err != nil {
+		return "", identity, err
+	}
+
+	sa, err = authService.getServiceAccountCompleted(remoteClusterId)
+	if err != nil {
+		return "", identity, err
+	}
+	kubeconfig, err := authService.createKubeConfig(sa)
+	return kubeconfig, identity, err
+}
+
+// createCertificateIdentity grants the permissions to the remote cluster authenticated by its client certificate, and
+// returns a kubeconfig with the certificate signed for the certificate request, along with the identity.
+func (authService *AuthServiceCtrl) createCertificateIdentity(remoteClusterId string, certificateRequest []byte) (string, *remoteIdentity, error) {
+	identity := certificateIdentity(remoteClusterId)
+	if err := authService.createPermissions(identity); err != nil {
+		return "", identity, err
+	}
+
+	certificate, err := authService.signCertificate(remoteClusterId, certificateRequest)
+	if err != nil {
+		return "", identity, err
+	}
+	kubeconfig, err := authService.createCertificateKubeConfig(identity, certificate)
+	return kubeconfig, identity, err
+}
+
+// createPermissions creates the RBAC resources granting the permissions to the identity. The ones of the certificate
+// identities may already exist, in case the remote cluster asks for a new certificate.
+func (authService *AuthServiceCtrl) createPermissions(identity *remoteIdentity) error {
+	ignoreExisting := func(err error) error {
+		if identity.isCertificate() && kerrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+
+	if _, err := authService.createRole(identity); ignoreExisting(err) != nil {
+		return err
+	}
+	if _, err := authService.createRoleBinding(identity); ignoreExisting(err) != nil {
+		return err
+	}
+	if _, err := authService.createClusterRole(identity); ignoreExisting(err) != nil {
+		return err
+	}
+	if _, err := authService.createClusterRoleBinding(identity); ignoreExisting(err) != nil {
+		return err
+	}
+	return nil
+}
@@ -14,6 +14,13 @@ import (
 // - clusterName	-> the custom name for the home cluster (to be displayed in GUIs)
 // - guestNamespace	-> the namespace where to create secrets and resources to be shared with the home cluster
 func (authService *AuthServiceCtrl) ids(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	sourceIP := authService.requestLimiter.remoteAddress(r)
+	if _, err := authService.checkRequestLimits(sourceIPKey(sourceIP)); err != nil {
+		klog.V(4).Infof("request from %s refused: %v", sourceIP, err)
+		authService.handleError(w, err)
+		return
+	}
+
 	idsResponse := authService.getIdsResponse()
 
 	res, err := json.Marshal(idsResponse)
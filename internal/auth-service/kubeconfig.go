@@ -19,6 +19,37 @@ func (authService *AuthServiceCtrl) createKubeConfig(serviceAccount *v1.ServiceA
 		return "", err
 	}
 
+	server, err := authService.getApiServerUrl()
+	if err != nil {
+		return "", err
+	}
+	token := string(secret.Data["token"])
+
+	cnf := kubeconfigutil.CreateWithToken(server, "service-cluster", serviceAccount.Name, secret.Data["ca.crt"], token)
+	r, err := runtime.Encode(clientcmdlatest.Codec, cnf)
+	if err != nil {
+		return "", err
+	}
+	return string(r), nil
+}
+
+// createCertificateKubeConfig creates a kube-config file for the client certificate of a remote cluster, without the
+// private key, which is known only by the remote cluster
+func (authService *AuthServiceCtrl) createCertificateKubeConfig(identity *remoteIdentity, certificate []byte) (string, error) {
+	server, err := authService.getApiServerUrl()
+	if err != nil {
+		return "", err
+	}
+
+	cnf := kubeconfigutil.CreateWithCerts(server, "service-cluster", identity.clusterID, authService.apiServerCA, nil, certificate)
+	r, err := runtime.Encode(clientcmdlatest.Codec, cnf)
+	if err != nil {
+		return "", err
+	}
+	return string(r), nil
+}
+
+func (authService *AuthServiceCtrl) getApiServerUrl() (string, error) {
 	address, ok := os.LookupEnv("APISERVER")
 	if !ok || address == "" {
 		nodes := authService.nodeInformer.GetStore().List()
@@ -35,7 +66,7 @@ func (authService *AuthServiceCtrl) createKubeConfig(serviceAccount *v1.ServiceA
 		}
 
 		if node == nil {
-			err = errors.New("no APISERVER env variable found and no master node found, one of the two values must be present")
+			err := errors.New("no APISERVER env variable found and no master node found, one of the two values must be present")
 			klog.Error(err)
 			return "", err
 		}
@@ -47,13 +78,5 @@ func (authService *AuthServiceCtrl) createKubeConfig(serviceAccount *v1.ServiceA
 		port = "6443"
 	}
 
-	token := string(secret.Data["token"])
-	server := "https://" + address + ":" + port
-
-	cnf := kubeconfigutil.CreateWithToken(server, "service-cluster", serviceAccount.Name, secret.Data["ca.crt"], token)
-	r, err := runtime.Encode(clientcmdlatest.Codec, cnf)
-	if err != nil {
-		return "", err
-	}
-	return string(r), nil
+	return "https://" + address + ":" + port, nil
 }
@@ -0,0 +1,67 @@
+package auth_service
+
+import (
+	"context"
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// handlePermissionProfiles updates the peering-only template and, if changed, reconciles the permissions of the
+// existing identities.
+func (authService *AuthServiceCtrl) handlePermissionProfiles(profiles *configv1alpha1.PermissionProfiles) {
+	changed, err := authService.peeringTemplate.Update(auth.ConfiguredPermissionTemplate(profiles, auth.PeeringOnlyProfile))
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	if !changed {
+		return
+	}
+	if err = authService.reconcilePermissions(); err != nil {
+		klog.Error(err)
+	}
+}
+
+// reconcilePermissions updates the rules of the roles granted to the existing identities, as per the peering-only
+// template in use.
+func (authService *AuthServiceCtrl) reconcilePermissions() error {
+	listOptions := metav1.ListOptions{LabelSelector: auth.RemoteClusterIDLabel}
+
+	roles, err := authService.clientset.RbacV1().Roles(authService.namespace).List(context.TODO(), listOptions)
+	if err != nil {
+		return err
+	}
+	for i := range roles.Items {
+		role := &roles.Items[i]
+		expected := authService.forgeRole(labeledIdentity(&role.ObjectMeta))
+		if equality.Semantic.DeepEqual(role.Rules, expected.Rules) {
+			continue
+		}
+		role.Rules = expected.Rules
+		if _, err = authService.clientset.RbacV1().Roles(authService.namespace).Update(context.TODO(), role, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.Infof("Role %s updated with the %s permission template", role.Name, auth.PeeringOnlyProfile)
+	}
+
+	clusterRoles, err := authService.clientset.RbacV1().ClusterRoles().List(context.TODO(), listOptions)
+	if err != nil {
+		return err
+	}
+	for i := range clusterRoles.Items {
+		clusterRole := &clusterRoles.Items[i]
+		expected := authService.forgeClusterRole(labeledIdentity(&clusterRole.ObjectMeta))
+		if equality.Semantic.DeepEqual(clusterRole.Rules, expected.Rules) {
+			continue
+		}
+		clusterRole.Rules = expected.Rules
+		if _, err = authService.clientset.RbacV1().ClusterRoles().Update(context.TODO(), clusterRole, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.Infof("ClusterRole %s updated with the %s permission template", clusterRole.Name, auth.PeeringOnlyProfile)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package auth_service
+
+import (
+	"context"
+	"k8s.io/client-go/util/flowcontrol"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestLimits are the limits of the requests to the auth service, enforced for each source IP and, from any source
+// IP, for each cluster ID requested. The cluster IDs are self-declared, hence their requests are only slowed down and
+// never locked out: otherwise, anyone could lock a cluster out.
+type RequestLimits struct {
+	// RateLimit is the number of requests per second allowed for each source IP (unlimited, if not positive), with
+	// bursts of Burst requests.
+	RateLimit float32
+	Burst     int
+	// LockoutThreshold is the number of consecutive refused credentials locking the source IP out for LockoutDuration
+	// (never, if not positive).
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// ClusterRateLimit is the number of requests per second allowed for each cluster ID (unlimited, if not positive),
+	// with bursts of ClusterBurst requests. The exceeding requests are delayed until their turn, and refused only if
+	// it comes after ClusterMaxDelay.
+	ClusterRateLimit float32
+	ClusterBurst     int
+	ClusterMaxDelay  time.Duration
+	// RemoteAddressHeader is the header set by a trusted proxy with the address of the client, if any. In case of a
+	// list of addresses, the last one is used, since the previous ones may be forged by the client.
+	RemoteAddressHeader string
+}
+
+// requestLimiterRetention is the time the state of a source is retained after its last request.
+const requestLimiterRetention = 1 * time.Hour
+
+// requestLimiter rate limits the requests of each source IP, locking it out after repeated refused credentials, and
+// slows down the requests of each cluster ID.
+type requestLimiter struct {
+	limits RequestLimits
+	now    func() time.Time
+
+	mutex    sync.Mutex
+	sources  map[string]*requestSource
+	clusters map[string]*requestSource
+}
+
+type requestSource struct {
+	limiter     flowcontrol.RateLimiter
+	failures    int
+	lockedUntil time.Time
+	lastSeen    time.Time
+}
+
+func newRequestLimiter(limits RequestLimits) *requestLimiter {
+	return &requestLimiter{
+		limits:   limits,
+		now:      time.Now,
+		sources:  map[string]*requestSource{},
+		clusters: map[string]*requestSource{},
+	}
+}
+
+// sourceIPKey returns the key of the source of the requests.
+func sourceIPKey(ip string) string {
+	return "ip/" + ip
+}
+
+// remoteAddress returns the address of the client of the request.
+func (limiter *requestLimiter) remoteAddress(r *http.Request) string {
+	if limiter != nil && limiter.limits.RemoteAddressHeader != "" {
+		if addresses := r.Header.Get(limiter.limits.RemoteAddressHeader); addresses != "" {
+			list := strings.Split(addresses, ",")
+			return strings.TrimSpace(list[len(list)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allow returns whether a request of the source is allowed and, if not, the time after which to retry and whether
+// the source is locked out. A nil limiter allows any request.
+func (limiter *requestLimiter) allow(key string) (allowed bool, retryAfter time.Duration, lockedOut bool) {
+	if limiter == nil {
+		return true, 0, false
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	source := limiter.getSource(limiter.sources, key, now, limiter.limits.RateLimit, limiter.limits.Burst)
+	if now.Before(source.lockedUntil) {
+		return false, source.lockedUntil.Sub(now), true
+	}
+	if !source.limiter.TryAccept() {
+		return false, time.Duration(float32(time.Second) / limiter.limits.RateLimit), false
+	}
+	return true, 0, false
+}
+
+// waitCluster delays the request of the cluster ID until its turn, according to the rate limit of the cluster ID from
+// any source IP. It returns whether the request is allowed and, if not, the time after which to retry: the request is
+// refused if its turn comes after the maximum delay, or the context is done in the meantime. A nil limiter allows any
+// request.
+func (limiter *requestLimiter) waitCluster(ctx context.Context, clusterID string) (allowed bool, retryAfter time.Duration) {
+	if limiter == nil || limiter.limits.ClusterRateLimit <= 0 {
+		return true, 0
+	}
+	limiter.mutex.Lock()
+	cluster := limiter.getSource(limiter.clusters, clusterID, limiter.now(), limiter.limits.ClusterRateLimit, limiter.limits.ClusterBurst)
+	limiter.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, limiter.limits.ClusterMaxDelay)
+	defer cancel()
+	if err := cluster.limiter.Wait(ctx); err != nil {
+		return false, limiter.limits.ClusterMaxDelay
+	}
+	return true, 0
+}
+
+// recordFailure counts refused credentials of the sources, returning whether any of them has been locked out.
+func (limiter *requestLimiter) recordFailure(keys ...string) bool {
+	if limiter == nil || limiter.limits.LockoutThreshold <= 0 {
+		return false
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	lockedOut := false
+	for _, key := range keys {
+		source := limiter.getSource(limiter.sources, key, now, limiter.limits.RateLimit, limiter.limits.Burst)
+		source.failures++
+		if source.failures >= limiter.limits.LockoutThreshold {
+			source.failures = 0
+			source.lockedUntil = now.Add(limiter.limits.LockoutDuration)
+			lockedOut = true
+		}
+	}
+	return lockedOut
+}
+
+// recordSuccess resets the refused credentials of the sources.
+func (limiter *requestLimiter) recordSuccess(keys ...string) {
+	if limiter == nil {
+		return
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	for _, key := range keys {
+		limiter.getSource(limiter.sources, key, now, limiter.limits.RateLimit, limiter.limits.Burst).failures = 0
+	}
+}
+
+// prune forgets the sources neither locked out nor seen recently, as well as the cluster IDs not seen recently,
+// bounding the memory used.
+func (limiter *requestLimiter) prune() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	for key, source := range limiter.sources {
+		if now.After(source.lockedUntil) && now.Sub(source.lastSeen) > requestLimiterRetention {
+			delete(limiter.sources, key)
+		}
+	}
+	for clusterID, cluster := range limiter.clusters {
+		if now.Sub(cluster.lastSeen) > requestLimiterRetention {
+			delete(limiter.clusters, clusterID)
+		}
+	}
+}
+
+// getSource returns the state of the source in the given ones, initializing it with the given rate limit if needed.
+// It must be called holding the lock.
+func (limiter *requestLimiter) getSource(sources map[string]*requestSource, key string, now time.Time,
+	rateLimit float32, burst int) *requestSource {
+	source, ok := sources[key]
+	if !ok {
+		source = &requestSource{
+			limiter: flowcontrol.NewFakeAlwaysRateLimiter(),
+		}
+		if rateLimit > 0 {
+			if burst < 1 {
+				burst = 1
+			}
+			source.limiter = flowcontrol.NewTokenBucketRateLimiter(rateLimit, burst)
+		}
+		sources[key] = source
+	}
+	source.lastSeen = now
+	return source
+}
@@ -2,37 +2,23 @@ package auth_service
 
 import (
 	"context"
-	v1 "k8s.io/api/core/v1"
+	"github.com/liqotech/liqo/pkg/auth"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func (authService *AuthServiceCtrl) createRole(remoteClusterId string, sa *v1.ServiceAccount) (*rbacv1.Role, error) {
-	role := &rbacv1.Role{
+func (authService *AuthServiceCtrl) createRole(identity *remoteIdentity) (*rbacv1.Role, error) {
+	return authService.clientset.RbacV1().Roles(authService.namespace).Create(context.TODO(), authService.forgeRole(identity), metav1.CreateOptions{})
+}
+
+// forgeRole returns the Role granting the namespaced rules of the peering-only profile to the identity.
+func (authService *AuthServiceCtrl) forgeRole(identity *remoteIdentity) *rbacv1.Role {
+	return &rbacv1.Role{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: sa.Name,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "v1",
-					Kind:       "ServiceAccount",
-					Name:       sa.Name,
-					UID:        sa.UID,
-				},
-			},
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				APIGroups: []string{v1.SchemeGroupVersion.Group},
-				Resources: []string{"secrets"},
-				Verbs:     []string{"create"},
-			},
-			{
-				APIGroups:     []string{v1.SchemeGroupVersion.Group},
-				Resources:     []string{"secrets"},
-				Verbs:         []string{"get", "delete"},
-				ResourceNames: []string{remoteClusterId},
-			},
+			Name:            identity.name,
+			Labels:          identity.labels(),
+			OwnerReferences: identity.owners,
 		},
+		Rules: auth.RenderRules(authService.peeringTemplate.Get().Rules, identity.clusterID),
 	}
-	return authService.clientset.RbacV1().Roles(authService.namespace).Create(context.TODO(), role, metav1.CreateOptions{})
 }
@@ -2,35 +2,21 @@ package auth_service
 
 import (
 	"context"
-	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func (authService *AuthServiceCtrl) createRoleBinding(sa *v1.ServiceAccount, role *rbacv1.Role) (*rbacv1.RoleBinding, error) {
+func (authService *AuthServiceCtrl) createRoleBinding(identity *remoteIdentity) (*rbacv1.RoleBinding, error) {
 	rb := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: sa.Name,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "v1",
-					Kind:       "ServiceAccount",
-					Name:       sa.Name,
-					UID:        sa.UID,
-				},
-			},
-		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      "ServiceAccount",
-				Name:      sa.Name,
-				Namespace: sa.Namespace,
-			},
+			Name:            identity.name,
+			OwnerReferences: identity.owners,
 		},
+		Subjects: []rbacv1.Subject{identity.subject},
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: rbacv1.SchemeGroupVersion.Group,
 			Kind:     "Role",
-			Name:     role.Name,
+			Name:     identity.name,
 		},
 	}
 	return authService.clientset.RbacV1().RoleBindings(authService.namespace).Create(context.TODO(), rb, metav1.CreateOptions{})
@@ -0,0 +1,191 @@
+package auth_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"strconv"
+	"time"
+)
+
+const (
+	// ScopedTokenLabel marks the secrets storing the scoped tokens, which can be listed and revoked as any other secret.
+	ScopedTokenLabel = "auth.liqo.io/scoped-token"
+	// ScopedTokenClusterIDAnnotation binds the scoped token to the cluster with the given ID (any, if missing).
+	ScopedTokenClusterIDAnnotation = "auth.liqo.io/cluster-id"
+	// ScopedTokenMaxUsesAnnotation is the number of identities the scoped token can be used for (unlimited, if missing).
+	ScopedTokenMaxUsesAnnotation = "auth.liqo.io/max-uses"
+	// ScopedTokenExpirationAnnotation is the RFC 3339 time the scoped token expires at (never, if missing).
+	ScopedTokenExpirationAnnotation = "auth.liqo.io/expiration"
+	// ScopedTokenUsesAnnotation counts the identities requested with the scoped token.
+	ScopedTokenUsesAnnotation = "auth.liqo.io/uses"
+)
+
+func isScopedTokenSecret(secret *v1.Secret) bool {
+	_, ok := secret.Labels[ScopedTokenLabel]
+	return ok
+}
+
+// fillScopedToken generates the token of the scoped token secrets created without one.
+func (authService *AuthServiceCtrl) fillScopedToken(secret *v1.Secret) error {
+	if len(secret.Data[tokenKey]) != 0 {
+		return nil
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+	secret = secret.DeepCopy()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[tokenKey] = []byte(token)
+
+	// in case of conflict, the token is generated on the next update event
+	if _, err = authService.clientset.CoreV1().Secrets(authService.namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil && !kerrors.IsConflict(err) {
+		klog.Error(err)
+		return err
+	}
+	return nil
+}
+
+// scopedTokenSecretName returns the name of the secret storing the scoped token, if any.
+func (authService *AuthServiceCtrl) scopedTokenSecretName(token string) string {
+	// all the scoped tokens are compared, not to leak which one is matching
+	var name string
+	for _, obj := range authService.secretInformer.GetStore().List() {
+		secret, ok := obj.(*v1.Secret)
+		if !ok || secret.Namespace != authService.namespace || !isScopedTokenSecret(secret) {
+			continue
+		}
+		if equalTokens(token, string(secret.Data[tokenKey])) {
+			name = secret.Name
+		}
+	}
+	return name
+}
+
+// useScopedToken reserves a use of the scoped token, if it allows the cluster to request an identity: the use is given
+// back by releaseScopedToken if the identity cannot be created, while the concurrent requests cannot exceed the maximum.
+func (authService *AuthServiceCtrl) useScopedToken(token string, clusterID string) (bool, error) {
+	name := authService.scopedTokenSecretName(token)
+	if name == "" {
+		return false, nil
+	}
+
+	// the uses are counted on the current secret, so that concurrent requests cannot exceed the maximum
+	used := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := authService.clientset.CoreV1().Secrets(authService.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			// revoked in the meanwhile
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !isScopedTokenSecret(secret) || !equalTokens(token, string(secret.Data[tokenKey])) {
+			return nil
+		}
+		uses, err := checkScopedToken(secret, clusterID, time.Now())
+		if err != nil {
+			klog.Warningf("scoped token %s refused for cluster %s: %v", name, clusterID, err)
+			return nil
+		}
+
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[ScopedTokenUsesAnnotation] = strconv.Itoa(uses + 1)
+		if _, err = authService.clientset.CoreV1().Secrets(authService.namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		used = true
+		return nil
+	})
+	if err != nil {
+		klog.Error(err)
+		return false, err
+	}
+	if used {
+		klog.Infof("cluster %s authenticated with the scoped token %s", clusterID, name)
+	}
+	return used, nil
+}
+
+// releaseScopedToken gives back the use of the scoped token reserved by useScopedToken, as the identity has not been
+// created.
+func (authService *AuthServiceCtrl) releaseScopedToken(token string) {
+	name := authService.scopedTokenSecretName(token)
+	if name == "" {
+		return
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := authService.clientset.CoreV1().Secrets(authService.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			// revoked in the meanwhile
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !isScopedTokenSecret(secret) || !equalTokens(token, string(secret.Data[tokenKey])) {
+			return nil
+		}
+		uses, err := strconv.Atoi(secret.Annotations[ScopedTokenUsesAnnotation])
+		if err != nil || uses <= 0 {
+			return nil
+		}
+		secret.Annotations[ScopedTokenUsesAnnotation] = strconv.Itoa(uses - 1)
+		_, err = authService.clientset.CoreV1().Secrets(authService.namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		klog.Errorf("unable to release the use of the scoped token %s: %v", name, err)
+		return
+	}
+	klog.Infof("use of the scoped token %s released, as the identity has not been created", name)
+}
+
+// checkScopedToken returns an error if the scoped token does not allow the cluster to request an identity, and the
+// number of identities already requested with it otherwise.
+func checkScopedToken(secret *v1.Secret, clusterID string, now time.Time) (int, error) {
+	if expectedClusterID, ok := secret.Annotations[ScopedTokenClusterIDAnnotation]; ok && expectedClusterID != clusterID {
+		return 0, fmt.Errorf("bound to cluster %s", expectedClusterID)
+	}
+
+	if value, ok := secret.Annotations[ScopedTokenExpirationAnnotation]; ok {
+		expiration, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid expiration %q", value)
+		}
+		if !now.Before(expiration) {
+			return 0, errors.New("expired")
+		}
+	}
+
+	uses := 0
+	if value, ok := secret.Annotations[ScopedTokenUsesAnnotation]; ok {
+		var err error
+		if uses, err = strconv.Atoi(value); err != nil {
+			return 0, fmt.Errorf("invalid uses %q", value)
+		}
+	}
+	if value, ok := secret.Annotations[ScopedTokenMaxUsesAnnotation]; ok {
+		maxUses, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid max uses %q", value)
+		}
+		if uses >= maxUses {
+			return 0, errors.New("already used")
+		}
+	}
+	return uses, nil
+}
@@ -3,7 +3,9 @@ package auth_service
 import (
 	"context"
 	"fmt"
+	"github.com/liqotech/liqo/pkg/auth"
 	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -80,6 +82,10 @@ func (authService *AuthServiceCtrl) createServiceAccount(remoteClusterId string)
 	sa := &v1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: fmt.Sprintf("remote-%s", remoteClusterId),
+			Labels: map[string]string{
+				auth.RemoteClusterIDLabel: remoteClusterId,
+				auth.IdentityKindLabel:    rbacv1.ServiceAccountKind,
+			},
 		},
 	}
 	return authService.clientset.CoreV1().ServiceAccounts(authService.namespace).Create(context.TODO(), sa, metav1.CreateOptions{})
@@ -1,6 +1,7 @@
 package auth_service
 
 import (
+	"crypto/subtle"
 	"github.com/liqotech/liqo/pkg/auth"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -9,27 +10,30 @@ import (
 )
 
 type credentialsValidator interface {
-	checkCredentials(roleRequest *auth.IdentityRequest, configProvider authConfigProvider, tokenManager tokenManager) error
+	// checkCredentials returns an error if the credentials are not valid, and whether a use of a scoped token has been
+	// consumed otherwise, to be released if the identity cannot be created.
+	checkCredentials(roleRequest *auth.IdentityRequest, configProvider authConfigProvider, tokenManager tokenManager) (scoped bool, err error)
 
 	validEmptyToken(configProvider authConfigProvider) bool
-	validToken(tokenManager tokenManager, token string) (bool, error)
+	validToken(tokenManager tokenManager, token string, clusterID string) (valid, scoped bool, err error)
 }
 
 type tokenValidator struct{}
 
-func (tokenValidator *tokenValidator) checkCredentials(roleRequest *auth.IdentityRequest, configProvider authConfigProvider, tokenManager tokenManager) error {
+func (tokenValidator *tokenValidator) checkCredentials(roleRequest *auth.IdentityRequest, configProvider authConfigProvider, tokenManager tokenManager) (bool, error) {
 	// token check fails if:
-	// 1. token is different from the correct one
+	// 1. token is different from the correct one, from the previous one still accepted and from the scoped ones
+	//    allowing the cluster to request an identity
 	// 2. token is empty but in the cluster config empty token is not allowed
 
 	if tokenValidator.validEmptyToken(configProvider) {
-		return nil
+		return false, nil
 	}
-	if valid, err := tokenValidator.validToken(tokenManager, roleRequest.Token); err != nil {
+	if valid, scoped, err := tokenValidator.validToken(tokenManager, roleRequest.Token, roleRequest.ClusterID); err != nil {
 		klog.Error(err)
-		return err
+		return false, err
 	} else if valid {
-		return nil
+		return scoped, nil
 	} else {
 		err = &kerrors.StatusError{ErrStatus: metav1.Status{
 			Status: metav1.StatusFailure,
@@ -37,7 +41,7 @@ func (tokenValidator *tokenValidator) checkCredentials(roleRequest *auth.Identit
 			Reason: metav1.StatusReasonForbidden,
 		}}
 		klog.Error(err)
-		return err
+		return false, err
 	}
 }
 
@@ -45,12 +49,25 @@ func (tokenValidator *tokenValidator) validEmptyToken(configProvider authConfigP
 	return configProvider.GetConfig().AllowEmptyToken
 }
 
-func (tokenValidator *tokenValidator) validToken(tokenManager tokenManager, token string) (bool, error) {
+func (tokenValidator *tokenValidator) validToken(tokenManager tokenManager, token string, clusterID string) (valid, scoped bool, err error) {
 	correctToken, err := tokenManager.getToken()
 	if err != nil {
 		klog.Error(err)
-		return false, err
+		return false, false, err
 	}
 
-	return token == correctToken, nil
+	if equalTokens(token, correctToken) {
+		return true, false, nil
+	}
+	if previousToken, ok := tokenManager.getPreviousToken(); ok && equalTokens(token, previousToken) {
+		klog.Infof("cluster %s authenticated with the previous token", clusterID)
+		return true, false, nil
+	}
+	valid, err = tokenManager.useScopedToken(token, clusterID)
+	return valid, valid, err
+}
+
+// equalTokens compares the tokens in constant time, not to leak the correct one. An empty token never matches.
+func equalTokens(token string, correctToken string) bool {
+	return correctToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(correctToken)) == 1
 }
@@ -3,9 +3,11 @@ package discovery
 import (
 	"context"
 	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/clusterConfig"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
@@ -13,6 +15,8 @@ import (
 
 type ConfigProvider interface {
 	GetConfig() *configv1alpha1.DiscoveryConfig
+	// GetPermissionTemplate returns the template of the permissions granted to the foreign clusters for the profile.
+	GetPermissionTemplate(profile auth.PermissionProfile) *configv1alpha1.PermissionTemplate
 }
 
 func (discovery *DiscoveryCtrl) GetConfig() *configv1alpha1.DiscoveryConfig {
@@ -24,6 +28,7 @@ func (discovery *DiscoveryCtrl) GetDiscoveryConfig(crdClient *crdClient.CRDClien
 	isFirst := true
 	go clusterConfig.WatchConfiguration(func(configuration *configv1alpha1.ClusterConfig) {
 		discovery.handleConfiguration(configuration.Spec.DiscoveryConfig)
+		discovery.handlePermissionProfiles(&configuration.Spec.AuthConfig.PermissionProfiles)
 		discovery.handleDispatcherConfig(configuration.Spec.DispatcherConfig)
 		if isFirst {
 			waitFirst <- true
@@ -54,14 +59,9 @@ func (discovery *DiscoveryCtrl) handleDispatcherConfig(config configv1alpha1.Dis
 		return
 	}
 
-	// create rules array
-	rules := []rbacv1.PolicyRule{}
-	for _, res := range config.ResourcesToReplicate {
-		rules = append(rules, rbacv1.PolicyRule{
-			Verbs:     []string{"*"},
-			APIGroups: []string{res.Group},
-			Resources: []string{res.Resource, res.Resource + "/status"},
-		})
+	rules := discovery.replicationRules(&config)
+	if !create && equality.Semantic.DeepEqual(role.Rules, rules) {
+		return
 	}
 	role.Rules = rules
 
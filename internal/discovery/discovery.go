@@ -5,6 +5,7 @@ import (
 	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
 	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/clusterID"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	"k8s.io/klog"
@@ -28,6 +29,10 @@ type DiscoveryCtrl struct {
 	resolveContextRefreshTime int
 
 	dialTcpTimeout time.Duration
+
+	// advertisementTemplate and replicationTemplate are the templates of the permissions granted to the foreign clusters
+	advertisementTemplate *auth.PermissionTemplateHolder
+	replicationTemplate   *auth.PermissionTemplateHolder
 }
 
 func NewDiscoveryCtrl(namespace string, clusterId clusterID.ClusterID, kubeconfigPath string, resolveContextRefreshTime int, dialTcpTimeout time.Duration) (*DiscoveryCtrl, error) {
@@ -70,6 +75,8 @@ func GetDiscoveryCtrl(namespace string, crdClient *crdClient.CRDClient, advClien
 		stopMDNSClient:            make(chan bool, 1),
 		resolveContextRefreshTime: resolveContextRefreshTime,
 		dialTcpTimeout:            dialTcpTimeout,
+		advertisementTemplate:     auth.NewPermissionTemplateHolder(auth.AdvertisementProfile),
+		replicationTemplate:       auth.NewPermissionTemplateHolder(auth.ReplicationProfile),
 	}
 }
 
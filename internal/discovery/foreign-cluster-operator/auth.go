@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/crdClient"
@@ -34,6 +35,9 @@ import (
 // if our status is EmptyRefused, this means that the remote cluster refused out request with the empty token, so we will
 // wait to have a token to ask for the role again
 //
+// if our status is Accepted, but there is no secret (e.g. the identity has been refused by the remote cluster and
+// deleted), we ask for a new role
+//
 // while we are waiting for that secret this function will return no error, but an empty client
 func (r *ForeignClusterReconciler) getRemoteClient(fc *discoveryv1alpha1.ForeignCluster, gv *schema.GroupVersion) (*crdClient.CRDClient, error) {
 	if strings.HasPrefix(fc.Spec.AuthUrl, "fake://") {
@@ -56,16 +60,9 @@ func (r *ForeignClusterReconciler) getRemoteClient(fc *discoveryv1alpha1.Foreign
 		return nil, err
 	}
 
-	if fc.Status.AuthStatus == discovery.AuthStatusAccepted {
-		// TODO: handle this possibility
-		// this can happen if the role was accepted but the local secret has been removed
-		err := errors.New("auth status is accepted but there is no secret found")
-		klog.Error(err)
-		return nil, err
-	}
-
 	// not existing role
-	if fc.Status.AuthStatus == discovery.AuthStatusPending || (fc.Status.AuthStatus == discovery.AuthStatusEmptyRefused && r.getAuthToken(fc) != "") {
+	if fc.Status.AuthStatus == discovery.AuthStatusPending || fc.Status.AuthStatus == discovery.AuthStatusAccepted ||
+		(fc.Status.AuthStatus == discovery.AuthStatusEmptyRefused && r.getAuthToken(fc) != "") {
 		kubeconfigStr, err := r.askRemoteIdentity(fc)
 		if err != nil {
 			klog.Error(err)
@@ -90,13 +87,18 @@ func (r *ForeignClusterReconciler) getRemoteClient(fc *discoveryv1alpha1.Foreign
 	return nil, nil
 }
 
+// identitySelector selects the secrets of the remote identity
+func identitySelector(fc *discoveryv1alpha1.ForeignCluster) string {
+	return strings.Join([]string{
+		strings.Join([]string{discovery.ClusterIdLabel, fc.Spec.ClusterIdentity.ClusterID}, "="),
+		discovery.RemoteIdentityLabel,
+	}, ",")
+}
+
 // load remote identity from a secret
 func (r *ForeignClusterReconciler) getIdentity(fc *discoveryv1alpha1.ForeignCluster, gv *schema.GroupVersion) (*crdClient.CRDClient, error) {
 	secrets, err := r.crdClient.Client().CoreV1().Secrets(r.Namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: strings.Join([]string{
-			strings.Join([]string{discovery.ClusterIdLabel, fc.Spec.ClusterIdentity.ClusterID}, "="),
-			discovery.RemoteIdentityLabel,
-		}, ","),
+		LabelSelector: identitySelector(fc),
 	})
 	if err != nil {
 		klog.Error(err)
@@ -125,7 +127,38 @@ func (r *ForeignClusterReconciler) getIdentity(fc *discoveryv1alpha1.ForeignClus
 
 	fc.Status.AuthStatus = discovery.AuthStatusAccepted
 
-	return crdClient.NewFromConfig(config)
+	client, err := crdClient.NewFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.renewCertificateIdentity(fc, roleSecret, config.CertData, client.Client()); err != nil {
+		// the current certificate is still valid
+		klog.Error(err)
+	}
+	return client, nil
+}
+
+// deleteRefusedIdentity deletes the remote identity if the remote cluster refused it (e.g. it has been deleted by the
+// garbage collection of the remote cluster, or its permissions have been revoked), so that a new one is requested on
+// the next reconciliation. It returns the given error.
+func (r *ForeignClusterReconciler) deleteRefusedIdentity(fc *discoveryv1alpha1.ForeignCluster, err error) error {
+	if !kerrors.IsUnauthorized(err) && !kerrors.IsForbidden(err) {
+		return err
+	}
+	klog.Warningf("identity for cluster %s refused, a new one will be requested: %v", fc.Spec.ClusterIdentity.ClusterID, err)
+
+	client := r.crdClient.Client().CoreV1().Secrets(r.Namespace)
+	secrets, listErr := client.List(context.TODO(), metav1.ListOptions{LabelSelector: identitySelector(fc)})
+	if listErr != nil {
+		klog.Error(listErr)
+		return err
+	}
+	for i := range secrets.Items {
+		if deleteErr := client.Delete(context.TODO(), secrets.Items[i].Name, metav1.DeleteOptions{}); deleteErr != nil && !kerrors.IsNotFound(deleteErr) {
+			klog.Error(deleteErr)
+		}
+	}
+	return err
 }
 
 // load the auth token form a labelled secret
@@ -156,9 +189,20 @@ func (r *ForeignClusterReconciler) getAuthToken(fc *discoveryv1alpha1.ForeignClu
 func (r *ForeignClusterReconciler) askRemoteIdentity(fc *discoveryv1alpha1.ForeignCluster) (string, error) {
 	token := r.getAuthToken(fc)
 
+	// the private key never leaves this cluster. The certificate identities are available only for the cluster IDs
+	// which are UUIDs, as the generated ones
+	var certificateRequest, key []byte
+	if _, err := uuid.Parse(r.clusterID.GetClusterID()); err == nil {
+		if certificateRequest, key, err = auth.NewCertificateRequest(r.clusterID.GetClusterID()); err != nil {
+			klog.Error(err)
+			return "", err
+		}
+	}
+
 	roleRequest := auth.IdentityRequest{
-		ClusterID: r.clusterID.GetClusterID(),
-		Token:     token,
+		ClusterID:                 r.clusterID.GetClusterID(),
+		Token:                     token,
+		CertificateSigningRequest: certificateRequest,
 	}
 	jsonRequest, err := json.Marshal(roleRequest)
 	if err != nil {
@@ -180,7 +224,11 @@ func (r *ForeignClusterReconciler) askRemoteIdentity(fc *discoveryv1alpha1.Forei
 	case http.StatusCreated:
 		fc.Status.AuthStatus = discovery.AuthStatusAccepted
 		klog.Info("Identity Created")
-		return string(body), nil
+		if key == nil {
+			return string(body), nil
+		}
+		// the clusters not supporting certificate identities return a ServiceAccount one, left unchanged
+		return kubeconfig.SetClientCredentials(string(body), nil, key)
 	case http.StatusForbidden:
 		if token == "" {
 			fc.Status.AuthStatus = discovery.AuthStatusEmptyRefused
@@ -0,0 +1,116 @@
+package foreign_cluster_operator
+
+import (
+	"context"
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
+	"github.com/liqotech/liqo/pkg/kubeconfig"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"time"
+)
+
+// renewalKeyKey is the key of the identity secret storing the private key of the pending certificate renewal.
+const renewalKeyKey = "renewal-key"
+
+// renewCertificateIdentity renews the client certificate of the remote identity before its expiration: the CSR is
+// created on the foreign cluster, authenticated by the current certificate, and the new certificate replaces it in
+// the identity secret once signed. It has no effect for the ServiceAccount identities.
+func (r *ForeignClusterReconciler) renewCertificateIdentity(fc *discoveryv1alpha1.ForeignCluster, identitySecret *v1.Secret,
+	certificate []byte, foreignClient kubernetes.Interface) error {
+	if len(certificate) == 0 {
+		return nil
+	}
+
+	name := auth.CertificateSigningRequestName(r.clusterID.GetClusterID())
+	csrClient := foreignClient.CertificatesV1beta1().CertificateSigningRequests()
+
+	key, pending := identitySecret.Data[renewalKeyKey]
+	if !pending {
+		if renew, err := auth.CertificateNeedsRenewal(certificate, time.Now()); err != nil || !renew {
+			return err
+		}
+
+		request, key, err := auth.NewCertificateRequest(r.clusterID.GetClusterID())
+		if err != nil {
+			return err
+		}
+		// the previous CSR, if any, has been already signed
+		if err = csrClient.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+		signerName := certificatesv1beta1.KubeAPIServerClientSignerName
+		_, err = csrClient.Create(context.TODO(), &certificatesv1beta1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{auth.CertificateSigningRequestLabel: "true"},
+			},
+			Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+				Request:    request,
+				SignerName: &signerName,
+				Usages: []certificatesv1beta1.KeyUsage{
+					certificatesv1beta1.UsageDigitalSignature,
+					certificatesv1beta1.UsageKeyEncipherment,
+					certificatesv1beta1.UsageClientAuth,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+
+		identitySecret.Data[renewalKeyKey] = key
+		if _, err = r.crdClient.Client().CoreV1().Secrets(r.Namespace).Update(context.TODO(), identitySecret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.Infof("renewal of the certificate identity for cluster %s requested", fc.Spec.ClusterIdentity.ClusterID)
+		return nil
+	}
+
+	csr, err := csrClient.Get(context.TODO(), name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		// the renewal is requested again on the next reconciliation
+		delete(identitySecret.Data, renewalKeyKey)
+		_, err = r.crdClient.Client().CoreV1().Secrets(r.Namespace).Update(context.TODO(), identitySecret, metav1.UpdateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1beta1.CertificateDenied {
+			klog.Warningf("renewal of the certificate identity for cluster %s denied: %s",
+				fc.Spec.ClusterIdentity.ClusterID, condition.Message)
+			if err = csrClient.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+				return err
+			}
+			delete(identitySecret.Data, renewalKeyKey)
+			_, err = r.crdClient.Client().CoreV1().Secrets(r.Namespace).Update(context.TODO(), identitySecret, metav1.UpdateOptions{})
+			return err
+		}
+	}
+	if len(csr.Status.Certificate) == 0 {
+		// not signed yet
+		return nil
+	}
+
+	kubeconfigStr, err := kubeconfig.SetClientCredentials(string(identitySecret.Data["kubeconfig"]), csr.Status.Certificate, key)
+	if err != nil {
+		return err
+	}
+	identitySecret.Data["kubeconfig"] = []byte(kubeconfigStr)
+	delete(identitySecret.Data, renewalKeyKey)
+	if _, err = r.crdClient.Client().CoreV1().Secrets(r.Namespace).Update(context.TODO(), identitySecret, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	klog.Infof("certificate identity for cluster %s renewed", fc.Spec.ClusterIdentity.ClusterID)
+
+	if err = csrClient.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+		klog.Warningf("unable to delete CSR %s: %v", name, err)
+	}
+	return nil
+}
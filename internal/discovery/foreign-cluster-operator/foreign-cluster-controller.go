@@ -27,6 +27,7 @@ import (
 	"github.com/liqotech/liqo/internal/discovery"
 	"github.com/liqotech/liqo/internal/discovery/kubeconfig"
 	"github.com/liqotech/liqo/internal/discovery/utils"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/clusterID"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	discoveryPkg "github.com/liqotech/liqo/pkg/discovery"
@@ -77,14 +78,14 @@ type ForeignClusterReconciler struct {
 // +kubebuilder:rbac:groups=sharing.liqo.io,resources=advertisements,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups=sharing.liqo.io,resources=advertisements/status,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=list
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;create;update
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;list;create;update
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;create
 //role
 // +kubebuilder:rbac:groups=core,namespace="liqo",resources=services,verbs=get
 // +kubebuilder:rbac:groups=core,namespace="liqo",resources=configmaps,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups=core,namespace="liqo",resources=serviceaccounts,verbs=get;list;watch;create
 // +kubebuilder:rbac:groups=core,namespace="liqo",resources=secrets,verbs=get;list;watch;create;update;delete
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,namespace="liqo",resources=roles,verbs=get;create;update
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,namespace="liqo",resources=roles,verbs=get;list;create;update
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,namespace="liqo",resources=rolebindings,verbs=get;create
 
 func (r *ForeignClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
@@ -307,31 +308,16 @@ func (r *ForeignClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		requireUpdate = true
 	}
 
-	// if join is required (both automatically or by user) and status is not set to joined
-	// create new peering request
-	if foreignDiscoveryClient != nil && fc.Spec.Join && !fc.Status.Outgoing.Joined {
-		fc, err = r.Peer(fc, foreignDiscoveryClient)
+	if foreignDiscoveryClient != nil {
+		var peeringUpdated bool
+		fc, peeringUpdated, err = r.updatePeering(fc, foreignDiscoveryClient)
 		if err != nil {
 			return ctrl.Result{
 				Requeue:      true,
 				RequeueAfter: r.RequeueAfter,
 			}, err
 		}
-		requireUpdate = true
-	}
-
-	// if join is no more required and status is set to joined
-	// or if this foreign cluster is being deleted
-	// delete peering request
-	if foreignDiscoveryClient != nil && (!fc.Spec.Join || !fc.DeletionTimestamp.IsZero()) && fc.Status.Outgoing.Joined {
-		fc, err = r.Unpeer(fc, foreignDiscoveryClient)
-		if err != nil {
-			return ctrl.Result{
-				Requeue:      true,
-				RequeueAfter: r.RequeueAfter,
-			}, err
-		}
-		requireUpdate = true
+		requireUpdate = requireUpdate || peeringUpdated
 	}
 
 	if !fc.Spec.Join && !fc.Status.Outgoing.Joined && slice.ContainsString(fc.Finalizers, FinalizerString, nil) {
@@ -406,6 +392,37 @@ func (r *ForeignClusterReconciler) Update(fc *discoveryv1alpha1.ForeignCluster)
 	return fc, err
 }
 
+// updatePeering peers or unpeers the foreign cluster, as required by its spec and status, and returns whether its status
+// has been updated. If the foreign cluster refuses the identity, the identity is deleted for a new one to be requested
+// on the next reconciliation, and the foreign cluster is returned unchanged along with the error.
+func (r *ForeignClusterReconciler) updatePeering(fc *discoveryv1alpha1.ForeignCluster,
+	foreignDiscoveryClient *crdClient.CRDClient) (*discoveryv1alpha1.ForeignCluster, bool, error) {
+	requireUpdate := false
+
+	// if join is required (both automatically or by user) and status is not set to joined
+	// create new peering request
+	if fc.Spec.Join && !fc.Status.Outgoing.Joined {
+		peered, err := r.Peer(fc, foreignDiscoveryClient)
+		if err != nil {
+			return fc, requireUpdate, r.deleteRefusedIdentity(fc, err)
+		}
+		fc, requireUpdate = peered, true
+	}
+
+	// if join is no more required and status is set to joined
+	// or if this foreign cluster is being deleted
+	// delete peering request
+	if (!fc.Spec.Join || !fc.DeletionTimestamp.IsZero()) && fc.Status.Outgoing.Joined {
+		unpeered, err := r.Unpeer(fc, foreignDiscoveryClient)
+		if err != nil {
+			return fc, requireUpdate, r.deleteRefusedIdentity(fc, err)
+		}
+		fc, requireUpdate = unpeered, true
+	}
+
+	return fc, requireUpdate, nil
+}
+
 func (r *ForeignClusterReconciler) Peer(fc *discoveryv1alpha1.ForeignCluster, foreignDiscoveryClient *crdClient.CRDClient) (*discoveryv1alpha1.ForeignCluster, error) {
 	// create PeeringRequest
 	klog.Info("Creating PeeringRequest")
@@ -780,13 +797,7 @@ func (r *ForeignClusterReconciler) createClusterRoleIfNotExists(clusterID string
 					},
 				},
 			},
-			Rules: []rbacv1.PolicyRule{
-				{
-					Verbs:     []string{"get", "list", "create", "update", "delete", "watch"},
-					APIGroups: []string{"sharing.liqo.io"},
-					Resources: []string{"advertisements", "advertisements/status"},
-				},
-			},
+			Rules: auth.RenderRules(r.ConfigProvider.GetPermissionTemplate(auth.AdvertisementProfile).ClusterRules, clusterID),
 		}
 		return r.crdClient.Client().RbacV1().ClusterRoles().Create(context.TODO(), role, metav1.CreateOptions{})
 	} else if err != nil {
@@ -813,13 +824,7 @@ func (r *ForeignClusterReconciler) createRoleIfNotExists(clusterID string, owner
 					},
 				},
 			},
-			Rules: []rbacv1.PolicyRule{
-				{
-					Verbs:     []string{"get", "list", "create", "update", "delete", "watch"},
-					APIGroups: []string{""},
-					Resources: []string{"secrets"},
-				},
-			},
+			Rules: auth.RenderRules(r.ConfigProvider.GetPermissionTemplate(auth.AdvertisementProfile).Rules, clusterID),
 		}
 		return r.crdClient.Client().RbacV1().Roles(r.Namespace).Create(context.TODO(), role, metav1.CreateOptions{})
 	} else if err != nil {
@@ -1,16 +1,25 @@
 package foreign_cluster_operator
 
 import (
+	"context"
 	"github.com/liqotech/liqo/apis/config/v1alpha1"
 	v1alpha12 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/clusterID/test"
+	"github.com/liqotech/liqo/pkg/crdClient"
 	"github.com/liqotech/liqo/pkg/discovery"
 	"github.com/liqotech/liqo/pkg/testUtils"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -24,6 +33,10 @@ func (c *configMock) GetConfig() *v1alpha1.DiscoveryConfig {
 	return &c.config
 }
 
+func (c *configMock) GetPermissionTemplate(profile auth.PermissionProfile) *v1alpha1.PermissionTemplate {
+	return auth.DefaultPermissionTemplate(profile)
+}
+
 func TestForeignClusterOperator(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Wan Suite")
@@ -252,4 +265,95 @@ var _ = Describe("ForeignClusterOperator", func() {
 
 	})
 
+	// refused identity
+
+	Context("Refused identity", func() {
+
+		var (
+			server        *httptest.Server
+			foreignClient *crdClient.CRDClient
+		)
+
+		BeforeEach(func() {
+			// the foreign cluster refuses every request, as if the identity has been deleted
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			}))
+
+			var err error
+			foreignClient, err = crdClient.NewFromConfig(&rest.Config{
+				Host:    server.URL,
+				APIPath: "/apis",
+				ContentConfig: rest.ContentConfig{
+					GroupVersion:         &v1alpha12.GroupVersion,
+					NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+				},
+			})
+			Expect(err).To(BeNil())
+
+			_, err = controller.crdClient.Client().CoreV1().Secrets(controller.Namespace).Create(context.TODO(), &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "remote-identity-",
+					Labels: map[string]string{
+						discovery.ClusterIdLabel:      "foreign-cluster",
+						discovery.RemoteIdentityLabel: "",
+					},
+				},
+			}, metav1.CreateOptions{})
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		DescribeTable("Refused identity table",
+			func(join, joined bool) {
+				obj, err := controller.crdClient.Resource("foreignclusters").Create(&v1alpha12.ForeignCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "foreign-cluster",
+						Labels: map[string]string{
+							discovery.DiscoveryTypeLabel: string(discovery.ManualDiscovery),
+							discovery.ClusterIdLabel:     "foreign-cluster",
+						},
+					},
+					Spec: v1alpha12.ForeignClusterSpec{
+						ClusterIdentity: v1alpha12.ClusterIdentity{
+							ClusterID:   "foreign-cluster",
+							ClusterName: "ClusterTest2",
+						},
+						Namespace:     "liqo",
+						Join:          join,
+						DiscoveryType: discovery.ManualDiscovery,
+						TrustMode:     discovery.TrustModeUntrusted,
+					},
+				}, metav1.CreateOptions{})
+				Expect(err).To(BeNil())
+
+				fc, ok := obj.(*v1alpha12.ForeignCluster)
+				Expect(ok).To(BeTrue())
+				fc.Status.Outgoing = v1alpha12.Outgoing{
+					Joined:                   joined,
+					RemotePeeringRequestName: "local-cluster",
+				}
+
+				fc, requireUpdate, err := controller.updatePeering(fc, foreignClient)
+				Expect(errors.IsUnauthorized(err)).To(BeTrue())
+				Expect(requireUpdate).To(BeFalse())
+				Expect(fc).NotTo(BeNil())
+				Expect(fc.Status.Outgoing.Joined).To(Equal(joined))
+
+				// the identity is deleted, for a new one to be requested
+				secrets, err := controller.crdClient.Client().CoreV1().Secrets(controller.Namespace).List(context.TODO(),
+					metav1.ListOptions{LabelSelector: identitySelector(fc)})
+				Expect(err).To(BeNil())
+				Expect(secrets.Items).To(BeEmpty())
+			},
+
+			Entry("peer", true, false),
+			Entry("unpeer", false, true),
+		)
+
+	})
+
 })
@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"context"
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	"github.com/liqotech/liqo/pkg/auth"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// GetPermissionTemplate returns the template of the profile in use, nil for the replication profile if not configured.
+func (discovery *DiscoveryCtrl) GetPermissionTemplate(profile auth.PermissionProfile) *configv1alpha1.PermissionTemplate {
+	switch profile {
+	case auth.AdvertisementProfile:
+		return discovery.advertisementTemplate.Get()
+	case auth.ReplicationProfile:
+		return discovery.replicationTemplate.Get()
+	default:
+		return auth.DefaultPermissionTemplate(profile)
+	}
+}
+
+// handlePermissionProfiles updates the templates in use and, if the advertisement one changed, reconciles the
+// permissions of the existing foreign clusters. The replication template is applied by handleDispatcherConfig.
+func (discovery *DiscoveryCtrl) handlePermissionProfiles(profiles *configv1alpha1.PermissionProfiles) {
+	if _, err := discovery.replicationTemplate.Update(auth.ConfiguredPermissionTemplate(profiles, auth.ReplicationProfile)); err != nil {
+		klog.Error(err)
+	}
+
+	changed, err := discovery.advertisementTemplate.Update(auth.ConfiguredPermissionTemplate(profiles, auth.AdvertisementProfile))
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	if !changed {
+		return
+	}
+	if err = discovery.reconcileAdvertisementPermissions(); err != nil {
+		klog.Error(err)
+	}
+}
+
+// reconcileAdvertisementPermissions updates the rules of the roles granted to the foreign clusters, i.e. the ones owned
+// by a ForeignCluster and named after its cluster ID, as per the advertisement template in use.
+func (discovery *DiscoveryCtrl) reconcileAdvertisementPermissions() error {
+	template := discovery.advertisementTemplate.Get()
+	client := discovery.crdClient.Client().RbacV1()
+
+	roles, err := client.Roles(discovery.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range roles.Items {
+		role := &roles.Items[i]
+		rules := auth.RenderRules(template.Rules, role.Name)
+		if !isOwnedByForeignCluster(role.OwnerReferences) || equality.Semantic.DeepEqual(role.Rules, rules) {
+			continue
+		}
+		role.Rules = rules
+		if _, err = client.Roles(discovery.Namespace).Update(context.TODO(), role, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.Infof("Role %s updated with the %s permission template", role.Name, auth.AdvertisementProfile)
+	}
+
+	clusterRoles, err := client.ClusterRoles().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range clusterRoles.Items {
+		clusterRole := &clusterRoles.Items[i]
+		rules := auth.RenderRules(template.ClusterRules, clusterRole.Name)
+		if !isOwnedByForeignCluster(clusterRole.OwnerReferences) || equality.Semantic.DeepEqual(clusterRole.Rules, rules) {
+			continue
+		}
+		clusterRole.Rules = rules
+		if _, err = client.ClusterRoles().Update(context.TODO(), clusterRole, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.Infof("ClusterRole %s updated with the %s permission template", clusterRole.Name, auth.AdvertisementProfile)
+	}
+	return nil
+}
+
+func isOwnedByForeignCluster(owners []metav1.OwnerReference) bool {
+	for i := range owners {
+		if owners[i].Kind == "ForeignCluster" {
+			return true
+		}
+	}
+	return false
+}
+
+// replicationRules returns the rules granted to the CRD replicators of the foreign clusters: the ones of the
+// replication template, if configured, otherwise the ones generated from the resources to replicate.
+func (discovery *DiscoveryCtrl) replicationRules(config *configv1alpha1.DispatcherConfig) []rbacv1.PolicyRule {
+	if template := discovery.GetPermissionTemplate(auth.ReplicationProfile); template != nil {
+		return template.ClusterRules
+	}
+	rules := []rbacv1.PolicyRule{}
+	for _, res := range config.ResourcesToReplicate {
+		rules = append(rules, rbacv1.PolicyRule{
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+			APIGroups: []string{res.Group},
+			Resources: []string{res.Resource, res.Resource + "/status"},
+		})
+	}
+	return rules
+}
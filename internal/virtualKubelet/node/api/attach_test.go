@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// attachRequest is an attach request received by the provider.
+type attachRequest struct {
+	namespace, pod, container string
+	sizes                     []TermSize
+}
+
+// newAttachServer starts a kubelet server attaching to containers which echo their input, and report the
+// received terminal sizes once their input is closed.
+func newAttachServer(t *testing.T, err error) (*httptest.Server, chan attachRequest) {
+	requests := make(chan attachRequest, 1)
+	handler := PodHandler(PodHandlerConfig{
+		AttachToContainer: func(ctx context.Context, namespace, pod, container string, attach AttachIO) error {
+			request := attachRequest{namespace: namespace, pod: pod, container: container}
+			defer func() { requests <- request }()
+			if err != nil {
+				return err
+			}
+			if attach.TTY() {
+				request.sizes = append(request.sizes, <-attach.Resize())
+			}
+			if attach.Stdin() != nil {
+				if _, err := io.Copy(attach.Stdout(), attach.Stdin()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}, false)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func attachTo(t *testing.T, server *httptest.Server, path string, options remotecommand.StreamOptions) error {
+	u, err := url.Parse(server.URL + path)
+	assert.NilError(t, err)
+	executor, err := remotecommand.NewSPDYExecutor(&rest.Config{Host: server.URL}, "POST", u)
+	assert.NilError(t, err)
+	return executor.Stream(options)
+}
+
+// sizeQueue returns the given terminal size, and then blocks until the stream is over.
+type sizeQueue struct {
+	size *remotecommand.TerminalSize
+	done chan struct{}
+}
+
+func (q *sizeQueue) Next() *remotecommand.TerminalSize {
+	if size := q.size; size != nil {
+		q.size = nil
+		return size
+	}
+	<-q.done
+	return nil
+}
+
+func TestHandleContainerAttach(t *testing.T) {
+	server, requests := newAttachServer(t, nil)
+
+	var stdout, stderr bytes.Buffer
+	err := attachTo(t, server, "/attach/namespace/pod/container?input=1&output=1&error=1", remotecommand.StreamOptions{
+		Stdin:  strings.NewReader("hello"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, stdout.String(), "hello")
+
+	request := <-requests
+	assert.Equal(t, request.namespace, "namespace")
+	assert.Equal(t, request.pod, "pod")
+	assert.Equal(t, request.container, "container")
+	assert.Assert(t, is.Len(request.sizes, 0))
+}
+
+func TestHandleContainerAttachTTY(t *testing.T) {
+	server, requests := newAttachServer(t, nil)
+
+	var stdout bytes.Buffer
+	queue := &sizeQueue{size: &remotecommand.TerminalSize{Width: 80, Height: 24}, done: make(chan struct{})}
+	defer close(queue.done)
+	err := attachTo(t, server, "/attach/namespace/pod/container?input=1&output=1&tty=1", remotecommand.StreamOptions{
+		Stdin:             strings.NewReader("hello"),
+		Stdout:            &stdout,
+		Tty:               true,
+		TerminalSizeQueue: queue,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, stdout.String(), "hello")
+
+	request := <-requests
+	assert.DeepEqual(t, request.sizes, []TermSize{{Width: 80, Height: 24}})
+}
+
+func TestHandleContainerAttachError(t *testing.T) {
+	server, requests := newAttachServer(t, errors.New("container not running"))
+
+	var stdout bytes.Buffer
+	err := attachTo(t, server, "/attach/namespace/pod/container?output=1", remotecommand.StreamOptions{Stdout: &stdout})
+	assert.ErrorContains(t, err, "container not running")
+	<-requests
+}
+
+func TestHandleContainerAttachInvalidRequests(t *testing.T) {
+	server, _ := newAttachServer(t, nil)
+
+	// no stream requested
+	resp, err := http.Post(server.URL+"/attach/namespace/pod/container", "", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
+
+	// container missing
+	resp, err = http.Post(server.URL+"/attach/namespace/pod?output=1", "", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotFound)
+
+	// provider not supporting attach
+	server = httptest.NewServer(PodHandler(PodHandlerConfig{}, false))
+	defer server.Close()
+	resp, err = http.Post(server.URL+"/attach/namespace/pod/container?output=1", "", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotImplemented)
+}
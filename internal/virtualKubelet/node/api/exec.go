@@ -167,6 +167,19 @@ type containerExecContext struct {
 // This is called by remotecommand.ServeExec
 func (c *containerExecContext) ExecInContainer(name string, uid types.UID, container string, cmd []string, in io.Reader, out, err io.WriteCloser, tty bool, resize <-chan remoteutils.TerminalSize, timeout time.Duration) error {
 
+	eio := newExecIO(tty, in, out, err)
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+
+	if tty {
+		go forwardResize(ctx, resize, eio.chResize)
+	}
+
+	return c.h(c.ctx, c.namespace, c.pod, c.container, cmd, eio)
+}
+
+func newExecIO(tty bool, in io.Reader, out, err io.WriteCloser) *execIO {
 	eio := &execIO{
 		tty:    tty,
 		stdin:  in,
@@ -177,35 +190,30 @@ func (c *containerExecContext) ExecInContainer(name string, uid types.UID, conta
 	if tty {
 		eio.chResize = make(chan TermSize)
 	}
+	return eio
+}
 
-	ctx, cancel := context.WithCancel(c.ctx)
-	defer cancel()
-
-	if tty {
-		go func() {
-			send := func(s remoteutils.TerminalSize) bool {
-				select {
-				case eio.chResize <- TermSize{Width: s.Width, Height: s.Height}:
-					return false
-				case <-ctx.Done():
-					return true
-				}
-			}
+// forwardResize forwards the terminal resize events to the handler, until the context is done
+func forwardResize(ctx context.Context, resize <-chan remoteutils.TerminalSize, chResize chan<- TermSize) {
+	send := func(s remoteutils.TerminalSize) bool {
+		select {
+		case chResize <- TermSize{Width: s.Width, Height: s.Height}:
+			return false
+		case <-ctx.Done():
+			return true
+		}
+	}
 
-			for {
-				select {
-				case s := <-resize:
-					if send(s) {
-						return
-					}
-				case <-ctx.Done():
-					return
-				}
+	for {
+		select {
+		case s := <-resize:
+			if send(s) {
+				return
 			}
-		}()
+		case <-ctx.Done():
+			return
+		}
 	}
-
-	return c.h(c.ctx, c.namespace, c.pod, c.container, cmd, eio)
 }
 
 type execIO struct {
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// portForwardRequest is a port-forward request received by the provider.
+type portForwardRequest struct {
+	namespace, pod string
+	port           int32
+}
+
+// newPortForwardServer starts a kubelet server forwarding the ports of pods which echo the data received.
+func newPortForwardServer(t *testing.T, err error) (*httptest.Server, chan portForwardRequest) {
+	requests := make(chan portForwardRequest, 1)
+	handler := PodHandler(PodHandlerConfig{
+		PortForward: func(ctx context.Context, namespace, pod string, port int32, stream io.ReadWriteCloser) error {
+			requests <- portForwardRequest{namespace: namespace, pod: pod, port: port}
+			if err != nil {
+				return err
+			}
+			_, err := io.Copy(stream, stream)
+			return err
+		},
+	}, false)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// forwardPort sends the data to the given port, returning the data and the error message received.
+func forwardPort(t *testing.T, server *httptest.Server, path string, port int, data string) (received, message string) {
+	u, err := url.Parse(server.URL + path)
+	assert.NilError(t, err)
+	transport, upgrader, err := spdy.RoundTripperFor(&rest.Config{Host: server.URL})
+	assert.NilError(t, err)
+	conn, _, err := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", u).Dial(portforward.PortForwardProtocolV1Name)
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	headers := http.Header{}
+	headers.Set(corev1.StreamType, corev1.StreamTypeError)
+	headers.Set(corev1.PortHeader, strconv.Itoa(port))
+	headers.Set(corev1.PortForwardRequestIDHeader, "0")
+	errorStream, err := conn.CreateStream(headers)
+	assert.NilError(t, err)
+	errorStream.Close()
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeData)
+	dataStream, err := conn.CreateStream(headers)
+	assert.NilError(t, err)
+
+	_, err = io.WriteString(dataStream, data)
+	assert.NilError(t, err)
+	dataStream.Close()
+
+	return readAll(t, dataStream), readAll(t, errorStream)
+}
+
+func readAll(t *testing.T, stream httpstream.Stream) string {
+	content, err := ioutil.ReadAll(stream)
+	assert.NilError(t, err)
+	return string(content)
+}
+
+func TestHandlePortForward(t *testing.T) {
+	server, requests := newPortForwardServer(t, nil)
+
+	received, message := forwardPort(t, server, "/portForward/namespace/pod", 8080, "ping")
+	assert.Equal(t, received, "ping")
+	assert.Equal(t, message, "")
+
+	request := <-requests
+	assert.Equal(t, request, portForwardRequest{namespace: "namespace", pod: "pod", port: 8080})
+}
+
+func TestHandlePortForwardError(t *testing.T) {
+	server, requests := newPortForwardServer(t, errors.New("connection refused"))
+
+	received, message := forwardPort(t, server, "/portForward/namespace/pod", 8080, "ping")
+	assert.Equal(t, received, "")
+	assert.Assert(t, is.Contains(message, "connection refused"))
+	<-requests
+}
+
+func TestHandlePortForwardInvalidRequests(t *testing.T) {
+	server, _ := newPortForwardServer(t, nil)
+
+	// pod missing
+	resp, err := http.Post(server.URL+"/portForward/namespace", "", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotFound)
+
+	// provider not supporting port-forward
+	server = httptest.NewServer(PodHandler(PodHandlerConfig{}, false))
+	defer server.Close()
+	resp, err = http.Post(server.URL+"/portForward/namespace/pod", "", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotImplemented)
+}
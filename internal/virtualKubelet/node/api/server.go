@@ -32,8 +32,10 @@ type ServeMux interface {
 }
 
 type PodHandlerConfig struct {
-	RunInContainer   ContainerExecHandlerFunc
-	GetContainerLogs ContainerLogsHandlerFunc
+	RunInContainer    ContainerExecHandlerFunc
+	AttachToContainer ContainerAttachHandlerFunc
+	PortForward       PortForwardHandlerFunc
+	GetContainerLogs  ContainerLogsHandlerFunc
 	// GetPods is meant to enumerate the pods that the provider knows about
 	GetPods PodListerFunc
 	// GetPodsFromKubernetes is meant to enumerate the pods that the node is meant to be running
@@ -59,6 +61,18 @@ func PodHandler(p PodHandlerConfig, debug bool) http.Handler {
 			p.RunInContainer,
 		),
 	).Methods("POST", "GET")
+	r.HandleFunc(
+		"/attach/{namespace}/{pod}/{container}",
+		HandleContainerAttach(
+			p.AttachToContainer,
+		),
+	).Methods("POST", "GET")
+	r.HandleFunc(
+		"/portForward/{namespace}/{pod}",
+		HandlePortForward(
+			p.PortForward,
+		),
+	).Methods("POST", "GET")
 
 	f := HandlePodStatsSummary(p.GetStatsSummary)
 	r.HandleFunc("/stats/summary", f).Methods("GET")
@@ -14,8 +14,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// create deployment for a virtual-kubelet
-func CreateVkDeployment(adv *advtypes.Advertisement, vkName, vkNamespace, vkImage, initVKImage, nodeName, homeClusterId string) *appsv1.Deployment {
+// create deployment for a virtual-kubelet, allowing the offloading of all the namespaces if allowAllNamespaces is set
+func CreateVkDeployment(adv *advtypes.Advertisement, vkName, vkNamespace, vkImage, initVKImage, nodeName, homeClusterId string,
+	allowAllNamespaces bool) *appsv1.Deployment {
 
 	command := []string{
 		"/usr/bin/virtual-kubelet",
@@ -35,6 +36,9 @@ func CreateVkDeployment(adv *advtypes.Advertisement, vkName, vkNamespace, vkImag
 		"--home-cluster-id",
 		homeClusterId,
 	}
+	if allowAllNamespaces {
+		args = append(args, "--allow-all-namespaces")
+	}
 
 	volumes := []v1.Volume{
 		{
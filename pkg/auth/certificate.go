@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/x509/pkix"
+	"errors"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"time"
+)
+
+const (
+	// CertificateSigningRequestLabel marks the CertificateSigningRequests renewing the certificate identities of the
+	// remote clusters.
+	CertificateSigningRequestLabel = "auth.liqo.io/remote-identity"
+)
+
+// CertificateSigningRequestName returns the name of the CertificateSigningRequest of the certificate identity of the
+// cluster.
+func CertificateSigningRequestName(clusterID string) string {
+	return "liqo-identity-" + clusterID
+}
+
+// NewCertificateRequest generates a private key and a certificate request for a client certificate with the cluster
+// ID as common name, both PEM encoded.
+func NewCertificateRequest(clusterID string) (request []byte, key []byte, err error) {
+	key, err = keyutil.MakeEllipticPrivateKeyPEM()
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := keyutil.ParsePrivateKeyPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request, err = certutil.MakeCSR(privateKey, &pkix.Name{CommonName: clusterID}, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return request, key, nil
+}
+
+// CertificateValidity returns the validity period of the PEM encoded certificate.
+func CertificateValidity(certificate []byte) (notBefore, notAfter time.Time, err error) {
+	certs, err := certutil.ParseCertsPEM(certificate)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if len(certs) == 0 {
+		return time.Time{}, time.Time{}, errors.New("no certificate found")
+	}
+	return certs[0].NotBefore, certs[0].NotAfter, nil
+}
+
+// CertificateNeedsRenewal returns whether the PEM encoded certificate is past two thirds of its lifetime.
+func CertificateNeedsRenewal(certificate []byte, now time.Time) (bool, error) {
+	notBefore, notAfter, err := CertificateValidity(certificate)
+	if err != nil {
+		return false, err
+	}
+	renewal := notBefore.Add(notAfter.Sub(notBefore) * 2 / 3)
+	return !now.Before(renewal), nil
+}
@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"strings"
+	"sync"
+)
+
+// PermissionProfile identifies the permissions granted to the remote clusters for a given purpose.
+type PermissionProfile string
+
+const (
+	// PeeringOnlyProfile is granted by the auth service to the identities of the remote clusters, to request a peering.
+	PeeringOnlyProfile PermissionProfile = "peeringOnly"
+	// AdvertisementProfile is granted to the foreign clusters a peering is requested to, to send their Advertisements.
+	AdvertisementProfile PermissionProfile = "advertisement"
+	// OffloadingProfile is granted to the virtual kubelets of the foreign clusters, to offload their pods.
+	OffloadingProfile PermissionProfile = "offloading"
+	// ReplicationProfile is granted to the CRD replicators of the foreign clusters.
+	ReplicationProfile PermissionProfile = "replication"
+)
+
+const (
+	// ClusterIDPlaceholder is replaced, in the resource names of the rules, with the ID of the remote cluster.
+	ClusterIDPlaceholder = "$(CLUSTER_ID)"
+
+	// RemoteClusterIDLabel and IdentityKindLabel mark the roles granting the permissions of a profile to a remote
+	// cluster, with its cluster ID and the kind of its identity (ServiceAccount or User), so that they can be
+	// reconciled when the profile changes.
+	RemoteClusterIDLabel = "auth.liqo.io/remote-cluster-id"
+	IdentityKindLabel    = "auth.liqo.io/identity-kind"
+
+	// OffloadingClusterRoleName is the name of the ClusterRole granting the cluster rules of the offloading profile to
+	// the virtual kubelets of the foreign clusters.
+	OffloadingClusterRoleName = "liqo-remote-offloading"
+	// OffloadingNamespaceClusterRoleName is the name of the ClusterRole with the rules of the offloading profile,
+	// bound by the OffloadingRoleBindingName RoleBindings in the namespaces created by the virtual kubelets of the
+	// foreign clusters.
+	OffloadingNamespaceClusterRoleName = "liqo-remote-offloading-namespace"
+	OffloadingRoleBindingName          = "liqo-remote-offloading"
+	// OffloadingServiceAccountName is the ServiceAccount, in the Liqo namespace, of the virtual kubelets of the
+	// foreign clusters.
+	OffloadingServiceAccountName = "vk-remote"
+)
+
+var allVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+var defaultPermissionTemplates = map[PermissionProfile]*configv1alpha1.PermissionTemplate{
+	PeeringOnlyProfile: {
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{v1.GroupName},
+				Resources: []string{"secrets"},
+				Verbs:     []string{"create"},
+			},
+			{
+				APIGroups:     []string{v1.GroupName},
+				Resources:     []string{"secrets"},
+				Verbs:         []string{"get", "delete"},
+				ResourceNames: []string{ClusterIDPlaceholder},
+			},
+		},
+		ClusterRules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"discovery.liqo.io"},
+				Resources: []string{"peeringrequests"},
+				Verbs:     []string{"create"},
+			},
+			{
+				APIGroups:     []string{"discovery.liqo.io"},
+				Resources:     []string{"peeringrequests"},
+				Verbs:         []string{"get", "delete", "update"},
+				ResourceNames: []string{ClusterIDPlaceholder},
+			},
+		},
+	},
+	AdvertisementProfile: {
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{v1.GroupName},
+				Resources: []string{"secrets"},
+				Verbs:     []string{"get", "list", "create", "update", "delete", "watch"},
+			},
+		},
+		ClusterRules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"sharing.liqo.io"},
+				Resources: []string{"advertisements", "advertisements/status"},
+				Verbs:     []string{"get", "list", "create", "update", "delete", "watch"},
+			},
+		},
+	},
+	OffloadingProfile: {
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{v1.GroupName},
+				Resources: []string{"pods", "services", "configmaps", "secrets", "serviceaccounts", "persistentvolumeclaims"},
+				Verbs:     allVerbs,
+			},
+			{
+				APIGroups: []string{v1.GroupName},
+				Resources: []string{"pods/log", "pods/exec", "pods/attach", "pods/portforward"},
+				Verbs:     []string{"get", "create"},
+			},
+			{
+				APIGroups: []string{"apps"},
+				Resources: []string{"replicasets", "daemonsets"},
+				Verbs:     allVerbs,
+			},
+			{
+				APIGroups: []string{"discovery.k8s.io"},
+				Resources: []string{"endpointslices"},
+				Verbs:     allVerbs,
+			},
+			{
+				// the stats of the offloaded pods, if the stats summary of the foreign nodes is not available
+				APIGroups: []string{"metrics.k8s.io"},
+				Resources: []string{"pods"},
+				Verbs:     []string{"get", "list"},
+			},
+		},
+		ClusterRules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{v1.GroupName},
+				Resources: []string{"namespaces"},
+				Verbs:     []string{"get", "list", "watch", "create", "delete"},
+			},
+			{
+				// the status of the offloaded DaemonSets is watched in all the namespaces
+				APIGroups: []string{"apps"},
+				Resources: []string{"daemonsets"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				// the stats summary of the foreign nodes hosting the offloaded pods
+				APIGroups: []string{v1.GroupName},
+				Resources: []string{"nodes/proxy"},
+				Verbs:     []string{"get"},
+			},
+		},
+	},
+}
+
+// forbiddenVerbs and forbiddenResources would allow a remote cluster to escalate its privileges.
+var forbiddenVerbs = map[string]bool{"*": true, "escalate": true, "bind": true, "impersonate": true}
+
+var forbiddenResources = map[string]map[string]bool{
+	rbacv1.GroupName:                  {"*": true},
+	"admissionregistration.k8s.io":    {"*": true},
+	"apiextensions.k8s.io":            {"*": true},
+	certificatesv1beta1.GroupName:     {"certificatesigningrequests/approval": true, "signers": true},
+	"authentication.k8s.io":           {"*": true},
+	v1.GroupName:                      {"serviceaccounts/token": true},
+	"discovery.liqo.io":               {"foreignclusters": true},
+	configv1alpha1.GroupVersion.Group: {"*": true},
+}
+
+// forbiddenClusterResources would grant access to the credentials and the workloads of the whole cluster, hence they
+// are allowed only in the namespaced rules.
+var forbiddenClusterResources = map[string]map[string]bool{
+	v1.GroupName: {"secrets": true, "pods/exec": true, "pods/attach": true, "pods/portforward": true},
+}
+
+// nodesProxyVerbs are the only verbs allowed on the proxy of the nodes, granted only to the virtual kubelets to read the
+// stats summary of the foreign nodes: the other ones would allow to run commands in all the pods of the nodes.
+var nodesProxyVerbs = map[string]bool{"get": true}
+
+// DefaultPermissionTemplate returns the default template of the profile. The replication profile has no default
+// template, since it is generated from the resources to replicate.
+func DefaultPermissionTemplate(profile PermissionProfile) *configv1alpha1.PermissionTemplate {
+	return defaultPermissionTemplates[profile].DeepCopy()
+}
+
+// ConfiguredPermissionTemplate returns the template of the profile in the permission profiles, nil if not set.
+func ConfiguredPermissionTemplate(profiles *configv1alpha1.PermissionProfiles, profile PermissionProfile) *configv1alpha1.PermissionTemplate {
+	switch profile {
+	case PeeringOnlyProfile:
+		return profiles.PeeringOnly
+	case AdvertisementProfile:
+		return profiles.Advertisement
+	case OffloadingProfile:
+		return profiles.Offloading
+	case ReplicationProfile:
+		return profiles.Replication
+	default:
+		return nil
+	}
+}
+
+// PermissionTemplateHolder holds the template of a profile in use, which is replaced only by valid templates.
+type PermissionTemplateHolder struct {
+	profile     PermissionProfile
+	mutex       sync.RWMutex
+	initialized bool
+	// configured is the last template configured, template the one in use
+	configured *configv1alpha1.PermissionTemplate
+	template   *configv1alpha1.PermissionTemplate
+}
+
+// NewPermissionTemplateHolder returns a holder of the template of the profile, initially the default one.
+func NewPermissionTemplateHolder(profile PermissionProfile) *PermissionTemplateHolder {
+	return &PermissionTemplateHolder{
+		profile:  profile,
+		template: DefaultPermissionTemplate(profile),
+	}
+}
+
+// Update validates the template configured for the profile (nil for the default one) and, if valid, uses it from now
+// on. It returns whether the template in use may differ from the one of the existing roles, i.e. on the first update
+// and whenever it changes: an invalid template is refused, keeping the previous one.
+func (holder *PermissionTemplateHolder) Update(configured *configv1alpha1.PermissionTemplate) (changed bool, err error) {
+	holder.mutex.Lock()
+	defer holder.mutex.Unlock()
+
+	if holder.initialized && equality.Semantic.DeepEqual(configured, holder.configured) {
+		return false, nil
+	}
+	first := !holder.initialized
+	holder.initialized = true
+	holder.configured = configured.DeepCopy()
+	if err = ValidatePermissionTemplate(holder.profile, configured); err != nil {
+		return false, fmt.Errorf("invalid %s permission template, the previous one is kept: %v", holder.profile, err)
+	}
+
+	template := configured.DeepCopy()
+	if template == nil {
+		template = DefaultPermissionTemplate(holder.profile)
+	}
+	changed = first || !equality.Semantic.DeepEqual(template, holder.template)
+	holder.template = template
+	return changed, nil
+}
+
+// Get returns the template in use, nil for the replication profile if not configured.
+func (holder *PermissionTemplateHolder) Get() *configv1alpha1.PermissionTemplate {
+	holder.mutex.RLock()
+	defer holder.mutex.RUnlock()
+	return holder.template.DeepCopy()
+}
+
+// ValidatePermissionTemplate checks that the template of the profile does not grant permissions allowing a remote
+// cluster to escalate its privileges, such as the ones on the RBAC resources, or on all the resources or verbs, nor
+// the cluster-wide ones on the secrets and on the containers of the pods. The proxy of the nodes can be read only by the
+// virtual kubelets, through the cluster rules of the offloading profile.
+func ValidatePermissionTemplate(profile PermissionProfile, template *configv1alpha1.PermissionTemplate) error {
+	if template == nil {
+		return nil
+	}
+	if profile == ReplicationProfile && len(template.Rules) > 0 {
+		return fmt.Errorf("the %s profile supports only cluster rules", profile)
+	}
+	// the roles of these profiles are shared by all the remote clusters
+	if profile == OffloadingProfile || profile == ReplicationProfile {
+		for _, rules := range [][]rbacv1.PolicyRule{template.Rules, template.ClusterRules} {
+			for i := range rules {
+				for _, name := range rules[i].ResourceNames {
+					if strings.Contains(name, ClusterIDPlaceholder) {
+						return fmt.Errorf("the %s profile does not support %s", profile, ClusterIDPlaceholder)
+					}
+				}
+			}
+		}
+	}
+	for i := range template.Rules {
+		if err := validateRule(profile, &template.Rules[i], false); err != nil {
+			return fmt.Errorf("rule %d: %v", i, err)
+		}
+	}
+	for i := range template.ClusterRules {
+		if err := validateRule(profile, &template.ClusterRules[i], true); err != nil {
+			return fmt.Errorf("cluster rule %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func validateRule(profile PermissionProfile, rule *rbacv1.PolicyRule, clusterWide bool) error {
+	if len(rule.NonResourceURLs) > 0 {
+		return errors.New("non-resource URLs are not allowed")
+	}
+	if len(rule.Verbs) == 0 || len(rule.APIGroups) == 0 || len(rule.Resources) == 0 {
+		return errors.New("verbs, API groups and resources are required")
+	}
+	for _, verb := range rule.Verbs {
+		if forbiddenVerbs[verb] {
+			return fmt.Errorf("verb %s is not allowed", verb)
+		}
+	}
+	for _, group := range rule.APIGroups {
+		if group == "*" {
+			return errors.New("all the API groups are not allowed")
+		}
+		for _, resource := range rule.Resources {
+			if resource == "*" || strings.HasPrefix(resource, "*/") {
+				return errors.New("all the resources are not allowed")
+			}
+			if forbidden := forbiddenResources[group]; forbidden["*"] || forbidden[resource] {
+				return fmt.Errorf("resource %s of API group %q is not allowed", resource, group)
+			}
+			if clusterWide && forbiddenClusterResources[group][resource] {
+				return fmt.Errorf("resource %s of API group %q is allowed only in the namespaced rules", resource, group)
+			}
+			if group == v1.GroupName && resource == "nodes/proxy" {
+				if profile != OffloadingProfile || !clusterWide {
+					return fmt.Errorf("resource %s is allowed only in the cluster rules of the %s profile", resource, OffloadingProfile)
+				}
+				for _, verb := range rule.Verbs {
+					if !nodesProxyVerbs[verb] {
+						return fmt.Errorf("verb %s is not allowed on resource %s", verb, resource)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RenderRules returns a copy of the rules, replacing the placeholder in their resource names with the cluster ID.
+func RenderRules(rules []rbacv1.PolicyRule, clusterID string) []rbacv1.PolicyRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	rendered := make([]rbacv1.PolicyRule, len(rules))
+	for i := range rules {
+		rules[i].DeepCopyInto(&rendered[i])
+		for j, name := range rendered[i].ResourceNames {
+			rendered[i].ResourceNames[j] = strings.ReplaceAll(name, ClusterIDPlaceholder, clusterID)
+		}
+	}
+	return rendered
+}
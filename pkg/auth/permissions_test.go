@@ -0,0 +1,96 @@
+package auth
+
+import (
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"testing"
+)
+
+func TestValidatePermissionTemplate(t *testing.T) {
+	for profile := range defaultPermissionTemplates {
+		assert.NoError(t, ValidatePermissionTemplate(profile, DefaultPermissionTemplate(profile)), profile)
+	}
+
+	rule := func(group, resource, verb string) rbacv1.PolicyRule {
+		return rbacv1.PolicyRule{APIGroups: []string{group}, Resources: []string{resource}, Verbs: []string{verb}}
+	}
+	testcases := []struct {
+		description string
+		profile     PermissionProfile
+		template    *configv1alpha1.PermissionTemplate
+		valid       bool
+	}{
+		{"nil template", PeeringOnlyProfile, nil, true},
+		{"namespaced rule", PeeringOnlyProfile, &configv1alpha1.PermissionTemplate{Rules: []rbacv1.PolicyRule{rule("", "secrets", "get")}}, true},
+		{"all verbs", PeeringOnlyProfile, &configv1alpha1.PermissionTemplate{Rules: []rbacv1.PolicyRule{rule("", "secrets", "*")}}, false},
+		{"escalate verb", PeeringOnlyProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "pods", "escalate")}}, false},
+		{"all API groups", PeeringOnlyProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("*", "pods", "get")}}, false},
+		{"all resources", AdvertisementProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("apps", "*", "get")}}, false},
+		{"all subresources", AdvertisementProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "*/status", "get")}}, false},
+		{"RBAC resource", AdvertisementProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule(rbacv1.GroupName, "roles", "get")}}, false},
+		{"ServiceAccount token", OffloadingProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "serviceaccounts/token", "create")}}, false},
+		{"missing verbs", OffloadingProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}}}}, false},
+		{"non-resource URL", OffloadingProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}}}}, false},
+		{"namespaced rule of the replication profile", ReplicationProfile, &configv1alpha1.PermissionTemplate{Rules: []rbacv1.PolicyRule{rule("", "pods", "get")}}, false},
+		{"namespaced rule of the offloading profile", OffloadingProfile, &configv1alpha1.PermissionTemplate{Rules: []rbacv1.PolicyRule{rule("", "secrets", "get")}}, true},
+		{"cluster-wide secrets", OffloadingProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "secrets", "get")}}, false},
+		{"cluster-wide exec", AdvertisementProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "pods/exec", "create")}}, false},
+		{"node proxy read by the virtual kubelets", OffloadingProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "nodes/proxy", "get")}}, true},
+		{"node proxy written by the virtual kubelets", OffloadingProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "nodes/proxy", "create")}}, false},
+		{"namespaced node proxy", OffloadingProfile, &configv1alpha1.PermissionTemplate{Rules: []rbacv1.PolicyRule{rule("", "nodes/proxy", "get")}}, false},
+		{"node proxy of another profile", AdvertisementProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{rule("", "nodes/proxy", "get")}}, false},
+		{"cluster ID in the namespaced rules of a shared profile", OffloadingProfile, &configv1alpha1.PermissionTemplate{Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{ClusterIDPlaceholder}, Verbs: []string{"get"}}}}, false},
+		{"cluster ID of a shared profile", ReplicationProfile, &configv1alpha1.PermissionTemplate{ClusterRules: []rbacv1.PolicyRule{{
+			APIGroups: []string{"sharing.liqo.io"}, Resources: []string{"advertisements"}, ResourceNames: []string{ClusterIDPlaceholder}, Verbs: []string{"get"}}}}, false},
+	}
+	for _, tc := range testcases {
+		err := ValidatePermissionTemplate(tc.profile, tc.template)
+		if tc.valid {
+			assert.NoError(t, err, tc.description)
+		} else {
+			assert.Error(t, err, tc.description)
+		}
+	}
+}
+
+func TestRenderRules(t *testing.T) {
+	template := DefaultPermissionTemplate(PeeringOnlyProfile)
+	rules := RenderRules(template.Rules, "cluster-1")
+	assert.Equal(t, []string{"cluster-1"}, rules[1].ResourceNames)
+	// the template is not modified
+	assert.Equal(t, []string{ClusterIDPlaceholder}, template.Rules[1].ResourceNames)
+	assert.Nil(t, RenderRules(nil, "cluster-1"))
+}
+
+func TestPermissionTemplateHolder(t *testing.T) {
+	holder := NewPermissionTemplateHolder(AdvertisementProfile)
+	assert.Equal(t, DefaultPermissionTemplate(AdvertisementProfile), holder.Get())
+
+	// the first update always reports a change, so that the existing roles are reconciled
+	changed, err := holder.Update(nil)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = holder.Update(nil)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	configured := &configv1alpha1.PermissionTemplate{
+		Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+	}
+	changed, err = holder.Update(configured)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, configured, holder.Get())
+
+	invalid := configured.DeepCopy()
+	invalid.Rules[0].Verbs = []string{"*"}
+	changed, err = holder.Update(invalid)
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, configured, holder.Get())
+
+	// the replication profile has no default template
+	assert.Nil(t, NewPermissionTemplateHolder(ReplicationProfile).Get())
+}
@@ -3,4 +3,7 @@ package auth
 type IdentityRequest struct {
 	ClusterID string `json:"clusterID"`
 	Token     string `json:"token"`
+	// CertificateSigningRequest is the PEM encoded certificate request of the client certificate identifying the
+	// cluster. If missing, the cluster is identified by a ServiceAccount token.
+	CertificateSigningRequest []byte `json:"certificateSigningRequest,omitempty"`
 }
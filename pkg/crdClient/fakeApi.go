@@ -6,6 +6,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/rest"
+	"reflect"
 )
 
 type FakeClient struct {
@@ -34,7 +35,13 @@ func (c *FakeClient) Get(name string, _ metav1.GetOptions) (runtime.Object, erro
 }
 
 func (c *FakeClient) List(_ metav1.ListOptions) (runtime.Object, error) {
-	panic("to implement")
+	result := reflect.New(c.resource.PluralType)
+	items := result.Elem().FieldByName("Items")
+	for _, obj := range c.storage.List() {
+		items.Set(reflect.Append(items, reflect.ValueOf(obj).Elem()))
+	}
+
+	return result.Interface().(runtime.Object), nil
 }
 
 func (c *FakeClient) Watch(_ metav1.ListOptions) (watch.Interface, error) {
@@ -2,16 +2,31 @@ package csrApprover
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
 	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
+	"strings"
 	"time"
 )
 
+// Validator checks whether a CSR can be approved, returning the reason of the refusal otherwise.
+type Validator func(csr *certificatesv1beta1.CertificateSigningRequest) error
+
 func approveCSR(clientSet k8s.Interface, csr *certificatesv1beta1.CertificateSigningRequest) error {
+	return ApproveCSR(clientSet, csr, "This CSR was approved by Liqo Advertisement Operator")
+}
+
+// ApproveCSR approves the CSR, unless already approved.
+func ApproveCSR(clientSet k8s.Interface, csr *certificatesv1beta1.CertificateSigningRequest, message string) error {
 	// certificate already added to CSR
 	if csr.Status.Certificate != nil {
 		return nil
@@ -26,7 +41,7 @@ func approveCSR(clientSet k8s.Interface, csr *certificatesv1beta1.CertificateSig
 	csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1beta1.CertificateSigningRequestCondition{
 		Type:           certificatesv1beta1.CertificateApproved,
 		Reason:         "LiqoApproval",
-		Message:        "This CSR was approved by Liqo Advertisement Operator",
+		Message:        message,
 		LastUpdateTime: metav1.Now(),
 	})
 	_, errApproval := clientSet.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(context.TODO(), csr, metav1.UpdateOptions{})
@@ -36,7 +51,25 @@ func approveCSR(clientSet k8s.Interface, csr *certificatesv1beta1.CertificateSig
 	return nil
 }
 
-func WatchCSR(clientset k8s.Interface, label string, resyncPeriod time.Duration) {
+func denyCSR(clientSet k8s.Interface, csr *certificatesv1beta1.CertificateSigningRequest, reason error) error {
+	for _, b := range csr.Status.Conditions {
+		if b.Type == certificatesv1beta1.CertificateApproved || b.Type == certificatesv1beta1.CertificateDenied {
+			return nil
+		}
+	}
+	csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1beta1.CertificateSigningRequestCondition{
+		Type:           certificatesv1beta1.CertificateDenied,
+		Reason:         "LiqoValidationFailed",
+		Message:        reason.Error(),
+		LastUpdateTime: metav1.Now(),
+	})
+	_, err := clientSet.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(context.TODO(), csr, metav1.UpdateOptions{})
+	return err
+}
+
+// WatchCSR approves, on behalf of the approver, the CSRs with the given label accepted by the validator, and denies
+// the other ones.
+func WatchCSR(clientset k8s.Interface, label string, resyncPeriod time.Duration, approver string, validate Validator) {
 
 	stop := make(chan struct{})
 	lo := func(options *metav1.ListOptions) {
@@ -54,7 +87,14 @@ func WatchCSR(clientset k8s.Interface, label string, resyncPeriod time.Duration)
 				klog.Error("Unable to cast object")
 				return
 			}
-			err := approveCSR(clientset, csr)
+			if err := validate(csr); err != nil {
+				klog.Warningf("CSR %v refused: %v", csr.Name, err)
+				if err = denyCSR(clientset, csr.DeepCopy(), err); err != nil {
+					klog.Error(err)
+				}
+				return
+			}
+			err := ApproveCSR(clientset, csr.DeepCopy(), "This CSR was approved by "+approver)
 			if err != nil {
 				klog.Error(err)
 			} else {
@@ -65,3 +105,112 @@ func WatchCSR(clientset k8s.Interface, label string, resyncPeriod time.Duration)
 
 	go informer.Start(stop)
 }
+
+// NewVirtualKubeletCSRValidator returns the validator accepting the CSRs of the serving certificates of the virtual
+// kubelets running in the given namespace: each CSR has to be requested by the ServiceAccount of a virtual kubelet,
+// for the virtual node of the same foreign cluster.
+func NewVirtualKubeletCSRValidator(kubeletNamespace string) Validator {
+	serviceAccountPrefix := fmt.Sprintf("system:serviceaccount:%s:%s", kubeletNamespace, virtualKubelet.VirtualKubeletPrefix)
+
+	return func(csr *certificatesv1beta1.CertificateSigningRequest) error {
+		if signer := signerName(csr); signer != certificatesv1beta1.KubeletServingSignerName {
+			return fmt.Errorf("unexpected signer %s", signer)
+		}
+		if err := validateUsages(csr, certificatesv1beta1.UsageServerAuth); err != nil {
+			return err
+		}
+
+		clusterID := strings.TrimPrefix(csr.Spec.Username, serviceAccountPrefix)
+		if clusterID == csr.Spec.Username || clusterID == "" {
+			return fmt.Errorf("requested by %s, which is not a virtual kubelet", csr.Spec.Username)
+		}
+
+		request, err := parseCertificateRequest(csr.Spec.Request)
+		if err != nil {
+			return err
+		}
+		if expected := "system:node:" + virtualKubelet.VirtualNodePrefix + clusterID; request.Subject.CommonName != expected {
+			return fmt.Errorf("unexpected common name %s, the virtual node is %s", request.Subject.CommonName, expected)
+		}
+		if len(request.Subject.Organization) != 1 || request.Subject.Organization[0] != "system:nodes" {
+			return fmt.Errorf("unexpected organizations %v", request.Subject.Organization)
+		}
+		return nil
+	}
+}
+
+// ValidateRemoteIdentityCSR accepts the CSRs of the client certificates of the remote clusters, requested by the
+// clusters themselves to renew their certificate identity.
+func ValidateRemoteIdentityCSR(csr *certificatesv1beta1.CertificateSigningRequest) error {
+	if signer := signerName(csr); signer != certificatesv1beta1.KubeAPIServerClientSignerName {
+		return fmt.Errorf("unexpected signer %s", signer)
+	}
+	if err := validateUsages(csr, certificatesv1beta1.UsageClientAuth); err != nil {
+		return err
+	}
+	return ValidateClientCertificateRequest(csr.Spec.Request, csr.Spec.Username)
+}
+
+// ValidateClientCertificateRequest accepts the PEM encoded certificate requests of the client certificates of the
+// remote clusters: the common name has to be the cluster ID, without any group or alternative name, which would
+// grant further permissions.
+func ValidateClientCertificateRequest(pemRequest []byte, clusterID string) error {
+	if _, err := uuid.Parse(clusterID); err != nil {
+		return fmt.Errorf("invalid cluster ID %s", clusterID)
+	}
+
+	request, err := parseCertificateRequest(pemRequest)
+	if err != nil {
+		return err
+	}
+	if request.Subject.CommonName != clusterID {
+		return fmt.Errorf("common name %s different from the cluster ID %s", request.Subject.CommonName, clusterID)
+	}
+	if len(request.Subject.Organization) != 0 || len(request.Subject.OrganizationalUnit) != 0 {
+		return errors.New("groups are not allowed")
+	}
+	if len(request.DNSNames) != 0 || len(request.IPAddresses) != 0 || len(request.EmailAddresses) != 0 || len(request.URIs) != 0 {
+		return errors.New("alternative names are not allowed")
+	}
+	return nil
+}
+
+func parseCertificateRequest(pemRequest []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(pemRequest)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("PEM block of type CERTIFICATE REQUEST not found")
+	}
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err = request.CheckSignature(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func signerName(csr *certificatesv1beta1.CertificateSigningRequest) string {
+	if csr.Spec.SignerName == nil {
+		return certificatesv1beta1.LegacyUnknownSignerName
+	}
+	return *csr.Spec.SignerName
+}
+
+// validateUsages checks that the usages are the required one plus, at most, digital signature and key encipherment.
+func validateUsages(csr *certificatesv1beta1.CertificateSigningRequest, required certificatesv1beta1.KeyUsage) error {
+	found := false
+	for _, usage := range csr.Spec.Usages {
+		switch usage {
+		case required:
+			found = true
+		case certificatesv1beta1.UsageDigitalSignature, certificatesv1beta1.UsageKeyEncipherment:
+		default:
+			return fmt.Errorf("unexpected usage %s", usage)
+		}
+	}
+	if !found {
+		return fmt.Errorf("missing usage %s", required)
+	}
+	return nil
+}
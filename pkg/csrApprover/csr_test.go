@@ -2,10 +2,15 @@ package csrApprover
 
 import (
 	"context"
+	"crypto/x509/pkix"
+	"github.com/google/uuid"
+	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/stretchr/testify/assert"
 	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	testclient "k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
 	"testing"
 )
 
@@ -43,3 +48,103 @@ func TestNewNamespaceWithSuffix(t *testing.T) {
 	assert.Equal(t, conditions[0].Reason, "LiqoApproval")
 	assert.Equal(t, conditions[0].Message, "This CSR was approved by Liqo Advertisement Operator")
 }
+
+func TestValidateClientCertificateRequest(t *testing.T) {
+	clusterID := uuid.New().String()
+	request, _, err := auth.NewCertificateRequest(clusterID)
+	assert.NoError(t, err)
+	assert.NoError(t, ValidateClientCertificateRequest(request, clusterID))
+
+	// the common name has to be the cluster ID
+	assert.Error(t, ValidateClientCertificateRequest(request, uuid.New().String()))
+
+	// the cluster ID has to be a UUID, not to impersonate other users
+	request, _, err = auth.NewCertificateRequest("system:serviceaccount:liqo:liqo-auth")
+	assert.NoError(t, err)
+	assert.Error(t, ValidateClientCertificateRequest(request, "system:serviceaccount:liqo:liqo-auth"))
+
+	// no group can be requested
+	key, err := keyutil.MakeEllipticPrivateKeyPEM()
+	assert.NoError(t, err)
+	privateKey, err := keyutil.ParsePrivateKeyPEM(key)
+	assert.NoError(t, err)
+	request, err = certutil.MakeCSR(privateKey, &pkix.Name{CommonName: clusterID, Organization: []string{"system:masters"}}, nil, nil)
+	assert.NoError(t, err)
+	assert.Error(t, ValidateClientCertificateRequest(request, clusterID))
+
+	assert.Error(t, ValidateClientCertificateRequest([]byte("invalid"), clusterID))
+}
+
+func TestValidateRemoteIdentityCSR(t *testing.T) {
+	clusterID := uuid.New().String()
+	request, _, err := auth.NewCertificateRequest(clusterID)
+	assert.NoError(t, err)
+
+	signerName := certificatesv1beta1.KubeAPIServerClientSignerName
+	csr := &certificatesv1beta1.CertificateSigningRequest{
+		Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+			Request:    request,
+			SignerName: &signerName,
+			Usages:     []certificatesv1beta1.KeyUsage{certificatesv1beta1.UsageDigitalSignature, certificatesv1beta1.UsageClientAuth},
+			Username:   clusterID,
+		},
+	}
+	assert.NoError(t, ValidateRemoteIdentityCSR(csr))
+
+	// requested by a different user
+	csr.Spec.Username = uuid.New().String()
+	assert.Error(t, ValidateRemoteIdentityCSR(csr))
+	csr.Spec.Username = clusterID
+
+	// requesting a serving certificate
+	csr.Spec.Usages = append(csr.Spec.Usages, certificatesv1beta1.UsageServerAuth)
+	assert.Error(t, ValidateRemoteIdentityCSR(csr))
+	csr.Spec.Usages = csr.Spec.Usages[:2]
+
+	// signed by a different signer
+	csr.Spec.SignerName = nil
+	assert.Error(t, ValidateRemoteIdentityCSR(csr))
+}
+
+func TestVirtualKubeletCSRValidator(t *testing.T) {
+	clusterID := uuid.New().String()
+	newRequest := func(commonName string) []byte {
+		key, err := keyutil.MakeEllipticPrivateKeyPEM()
+		assert.NoError(t, err)
+		privateKey, err := keyutil.ParsePrivateKeyPEM(key)
+		assert.NoError(t, err)
+		request, err := certutil.MakeCSR(privateKey, &pkix.Name{CommonName: commonName, Organization: []string{"system:nodes"}}, nil, nil)
+		assert.NoError(t, err)
+		return request
+	}
+
+	validate := NewVirtualKubeletCSRValidator("liqo")
+	signerName := certificatesv1beta1.KubeletServingSignerName
+	csr := &certificatesv1beta1.CertificateSigningRequest{
+		Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+			Request:    newRequest("system:node:liqo-" + clusterID),
+			SignerName: &signerName,
+			Usages:     []certificatesv1beta1.KeyUsage{certificatesv1beta1.UsageDigitalSignature, certificatesv1beta1.UsageServerAuth},
+			Username:   "system:serviceaccount:liqo:virtual-kubelet-" + clusterID,
+		},
+	}
+	assert.NoError(t, validate(csr))
+
+	// requested by a remote cluster, or by a ServiceAccount of a different namespace
+	csr.Spec.Username = clusterID
+	assert.Error(t, validate(csr))
+	csr.Spec.Username = "system:serviceaccount:default:virtual-kubelet-" + clusterID
+	assert.Error(t, validate(csr))
+	csr.Spec.Username = "system:serviceaccount:liqo:virtual-kubelet-" + clusterID
+
+	// for a node different from the virtual node of the virtual kubelet
+	csr.Spec.Request = newRequest("system:node:worker-1")
+	assert.Error(t, validate(csr))
+	csr.Spec.Request = newRequest("system:node:liqo-" + uuid.New().String())
+	assert.Error(t, validate(csr))
+	csr.Spec.Request = newRequest("system:node:liqo-" + clusterID)
+
+	// signed by the legacy signer
+	csr.Spec.SignerName = nil
+	assert.Error(t, validate(csr))
+}
@@ -49,3 +49,29 @@ func CreateSecret(client kubernetes.Interface, namespace string, kubeconfig stri
 	}
 	return identitySecret, nil
 }
+
+// SetClientCredentials sets the private key of the users of the kubeconfig authenticated by a client certificate and,
+// if not nil, replaces their certificate.
+func SetClientCredentials(kubeconfig string, certificate []byte, key []byte) (string, error) {
+	cnf, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return "", LoadConfigError{
+			error: err.Error(),
+		}
+	}
+	for _, authInfo := range cnf.AuthInfos {
+		if len(authInfo.ClientCertificateData) == 0 {
+			continue
+		}
+		if certificate != nil {
+			authInfo.ClientCertificateData = certificate
+		}
+		authInfo.ClientKeyData = key
+	}
+
+	bytes, err := clientcmd.Write(*cnf)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
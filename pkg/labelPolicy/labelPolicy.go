@@ -14,6 +14,8 @@ const (
 	LabelPolicyAnyTrueNoLabelIfFalse LabelPolicyType = "LabelPolicyAnyTrueNoLabelIfFalse"
 	// add val="" label if each node has a val=true or val="" label
 	LabelPolicyAllTrueNoLabelIfFalse LabelPolicyType = "LabelPolicyAllTrueNoLabelIfFalse"
+	// add val=<value> label if all the nodes having the label share the same value (e.g. topology.kubernetes.io/zone)
+	LabelPolicyTopology LabelPolicyType = "LabelPolicyTopology"
 )
 
 type LabelPolicy interface {
@@ -30,6 +32,8 @@ func GetInstance(policyType LabelPolicyType) LabelPolicy {
 		return &AnyTrueNoLabelIfFalse{}
 	case LabelPolicyAllTrueNoLabelIfFalse:
 		return &AllTrueNoLabelIfFalse{}
+	case LabelPolicyTopology:
+		return &Topology{}
 	default:
 		return &AnyTrue{}
 	}
@@ -148,3 +148,39 @@ func TestAllTrueNoLabelIfFalse(t *testing.T) {
 	assert.EqualValues(t, "", val)
 	assert.Equal(t, insert, true)
 }
+
+func TestTopology(t *testing.T) {
+	policy := GetInstance(LabelPolicyTopology)
+	assert.NotNil(t, policy)
+
+	zonalNode := func(region, zone string) v1.Node {
+		labels := map[string]string{v1.LabelZoneRegionStable: region}
+		if zone != "" {
+			labels[v1.LabelZoneFailureDomainStable] = zone
+		}
+		return v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+	}
+
+	// single-zone cluster
+	zonalNodes := &v1.NodeList{Items: []v1.Node{zonalNode("eu-west-1", "eu-west-1a"), zonalNode("eu-west-1", "eu-west-1a")}}
+	val, insert := policy.Process(zonalNodes, v1.LabelZoneRegionStable)
+	assert.EqualValues(t, "eu-west-1", val)
+	assert.Equal(t, insert, true)
+	val, insert = policy.Process(zonalNodes, v1.LabelZoneFailureDomainStable)
+	assert.EqualValues(t, "eu-west-1a", val)
+	assert.Equal(t, insert, true)
+
+	// multi-zone cluster, with a node without zone
+	zonalNodes.Items = append(zonalNodes.Items, zonalNode("eu-west-1", "eu-west-1b"), zonalNode("eu-west-1", ""))
+	val, insert = policy.Process(zonalNodes, v1.LabelZoneRegionStable)
+	assert.EqualValues(t, "eu-west-1", val)
+	assert.Equal(t, insert, true)
+	val, insert = policy.Process(zonalNodes, v1.LabelZoneFailureDomainStable)
+	assert.EqualValues(t, "", val)
+	assert.Equal(t, insert, false)
+
+	// label missing on all the nodes
+	val, insert = policy.Process(nodes, "test5")
+	assert.EqualValues(t, "", val)
+	assert.Equal(t, insert, false)
+}
@@ -0,0 +1,21 @@
+package labelPolicy
+
+import corev1 "k8s.io/api/core/v1"
+
+type Topology struct{}
+
+// the label is added with the value shared by all the nodes having it: if the nodes belong to different topology
+// domains (e.g. zones), the virtual node cannot be placed in any of them and the label is not added
+func (t *Topology) Process(physicalNodes *corev1.NodeList, key string) (value string, insertLabel bool) {
+	for _, node := range physicalNodes.Items {
+		v, ok := node.Labels[key]
+		if !ok {
+			continue
+		}
+		if value != "" && v != value {
+			return "", false
+		}
+		value = v
+	}
+	return value, value != ""
+}
@@ -0,0 +1,75 @@
+package pricing
+
+import (
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"time"
+)
+
+// PolicyPricing computes the prices according to the PricePolicy set in the ClusterConfig
+type PolicyPricing struct {
+	Policy configv1alpha1.PricePolicy
+}
+
+func (pp *PolicyPricing) ComputePrices(foreignClusterId string, images []corev1.ContainerImage, now time.Time) corev1.ResourceList {
+	unitPrices := defaultUnitPrices.DeepCopy()
+	imagePrice := defaultImagePrice.DeepCopy()
+
+	// apply the cluster prices, then the ones specific for the foreign cluster
+	mergePrices(unitPrices, &imagePrice, pp.Policy.UnitPrices, pp.Policy.ImagePrice)
+	for _, override := range pp.Policy.PeerOverrides {
+		if override.ClusterId == foreignClusterId {
+			mergePrices(unitPrices, &imagePrice, override.UnitPrices, override.ImagePrice)
+			break
+		}
+	}
+
+	percentage := pp.timeMultiplier(now)
+	prices := corev1.ResourceList{}
+	for k, v := range unitPrices {
+		prices[k] = scale(v, percentage)
+	}
+	for _, image := range images {
+		for _, name := range image.Names {
+			prices[corev1.ResourceName(name)] = scale(imagePrice, percentage)
+		}
+	}
+	return prices
+}
+
+// timeMultiplier returns the percentage of the first TimeMultiplier including the given time, or 100 if none matches
+func (pp *PolicyPricing) timeMultiplier(now time.Time) int64 {
+	hour := int32(now.UTC().Hour())
+	for _, tm := range pp.Policy.TimeMultipliers {
+		if tm.StartHour <= tm.EndHour {
+			if hour >= tm.StartHour && hour < tm.EndHour {
+				return int64(tm.Percentage)
+			}
+		} else if hour >= tm.StartHour || hour < tm.EndHour {
+			// the interval spans across midnight
+			return int64(tm.Percentage)
+		}
+	}
+	return 100
+}
+
+func mergePrices(unitPrices corev1.ResourceList, imagePrice *resource.Quantity, newUnitPrices corev1.ResourceList, newImagePrice *resource.Quantity) {
+	for k, v := range newUnitPrices {
+		unitPrices[k] = v.DeepCopy()
+	}
+	if newImagePrice != nil {
+		*imagePrice = newImagePrice.DeepCopy()
+	}
+}
+
+// scale multiplies the price by the given percentage, keeping the nano precision of the quantities, since the unit
+// prices of some resources (e.g. the memory bytes) are fractions of milli-units
+func scale(price resource.Quantity, percentage int64) resource.Quantity {
+	if percentage == 100 {
+		return price
+	}
+	scaled := resource.NewScaledQuantity(price.ScaledValue(resource.Nano)*percentage/100, resource.Nano)
+	scaled.Format = price.Format
+	return *scaled
+}
@@ -0,0 +1,28 @@
+package pricing
+
+import (
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"time"
+)
+
+// PricingEngine computes the prices announced in the Advertisement sent to a foreign cluster.
+// Custom pricing logics can be plugged in the broadcaster by implementing this interface.
+type PricingEngine interface {
+	ComputePrices(foreignClusterId string, images []corev1.ContainerImage, now time.Time) corev1.ResourceList
+}
+
+// default prices, used for the resources without a price in the PricePolicy
+var (
+	defaultUnitPrices = corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewQuantity(1, resource.DecimalSI),
+		corev1.ResourceMemory: resource.MustParse("2m"),
+	}
+	defaultImagePrice = *resource.NewQuantity(5, resource.DecimalSI)
+)
+
+// NewPricingEngine returns a new PricingEngine applying the given PricePolicy
+func NewPricingEngine(policy configv1alpha1.PricePolicy) PricingEngine {
+	return &PolicyPricing{Policy: policy}
+}
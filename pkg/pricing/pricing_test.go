@@ -0,0 +1,103 @@
+package pricing
+
+import (
+	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"testing"
+	"time"
+)
+
+var images = []corev1.ContainerImage{
+	{
+		Names: []string{"image1"},
+	},
+}
+
+func assertPrice(t *testing.T, prices corev1.ResourceList, name corev1.ResourceName, expected string) {
+	price, ok := prices[name]
+	assert.True(t, ok)
+	assert.Equal(t, 0, price.Cmp(resource.MustParse(expected)), "price for %v is %v, expected %v", name, price.String(), expected)
+}
+
+func TestDefaultPrices(t *testing.T) {
+	prices := NewPricingEngine(configv1alpha1.PricePolicy{}).ComputePrices("cluster1", images, time.Now())
+
+	assert.Len(t, prices, 3)
+	assertPrice(t, prices, corev1.ResourceCPU, "1")
+	assertPrice(t, prices, corev1.ResourceMemory, "2m")
+	assertPrice(t, prices, "image1", "5")
+}
+
+func TestPeerOverrides(t *testing.T) {
+	imagePrice := resource.MustParse("3")
+	policy := configv1alpha1.PricePolicy{
+		UnitPrices: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("2"),
+		},
+		PeerOverrides: []configv1alpha1.PeerPriceOverride{
+			{
+				ClusterId: "cluster2",
+				UnitPrices: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("1m"),
+				},
+				ImagePrice: &imagePrice,
+			},
+		},
+	}
+	engine := NewPricingEngine(policy)
+
+	// the cluster prices are applied to the clusters without overrides
+	prices := engine.ComputePrices("cluster1", images, time.Now())
+	assertPrice(t, prices, corev1.ResourceCPU, "2")
+	assertPrice(t, prices, corev1.ResourceMemory, "2m")
+	assertPrice(t, prices, "image1", "5")
+
+	// the overrides replace the cluster prices
+	prices = engine.ComputePrices("cluster2", images, time.Now())
+	assertPrice(t, prices, corev1.ResourceCPU, "2")
+	assertPrice(t, prices, corev1.ResourceMemory, "1m")
+	assertPrice(t, prices, "image1", "3")
+}
+
+func TestTimeMultipliers(t *testing.T) {
+	policy := configv1alpha1.PricePolicy{
+		TimeMultipliers: []configv1alpha1.TimeMultiplier{
+			{StartHour: 8, EndHour: 18, Percentage: 200},
+			{StartHour: 22, EndHour: 6, Percentage: 50},
+		},
+	}
+	engine := NewPricingEngine(policy)
+
+	day := time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)
+	prices := engine.ComputePrices("cluster1", images, day)
+	assertPrice(t, prices, corev1.ResourceCPU, "2")
+	assertPrice(t, prices, corev1.ResourceMemory, "4m")
+	assertPrice(t, prices, "image1", "10")
+
+	night := time.Date(2020, 10, 1, 2, 0, 0, 0, time.UTC)
+	prices = engine.ComputePrices("cluster1", images, night)
+	assertPrice(t, prices, corev1.ResourceCPU, "500m")
+	assertPrice(t, prices, corev1.ResourceMemory, "1m")
+
+	evening := time.Date(2020, 10, 1, 20, 0, 0, 0, time.UTC)
+	prices = engine.ComputePrices("cluster1", images, evening)
+	assertPrice(t, prices, corev1.ResourceCPU, "1")
+}
+
+func TestSubMilliPrices(t *testing.T) {
+	policy := configv1alpha1.PricePolicy{
+		UnitPrices: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("3u"),
+		},
+		TimeMultipliers: []configv1alpha1.TimeMultiplier{
+			{StartHour: 0, EndHour: 24, Percentage: 50},
+		},
+	}
+
+	// the prices below the milli-unit are scaled without being rounded to milli-units
+	prices := NewPricingEngine(policy).ComputePrices("cluster1", images, time.Now())
+	assertPrice(t, prices, corev1.ResourceMemory, "1500n")
+	assertPrice(t, prices, corev1.ResourceCPU, "500m")
+}
@@ -3,6 +3,7 @@ package apiReflection
 const (
 	Configmaps = iota
 	EndpointSlices
+	PersistentVolumeClaims
 	Pods
 	ReplicaSets
 	Services
@@ -12,12 +13,13 @@ const (
 type ApiType int
 
 var ApiNames = map[ApiType]string{
-	Configmaps:     "configmaps",
-	EndpointSlices: "endpointslices",
-	Pods:           "pods",
-	ReplicaSets:    "replicasets",
-	Services:       "services",
-	Secrets:        "secrets",
+	Configmaps:             "configmaps",
+	EndpointSlices:         "endpointslices",
+	PersistentVolumeClaims: "persistentvolumeclaims",
+	Pods:                   "pods",
+	ReplicaSets:            "replicasets",
+	Services:               "services",
+	Secrets:                "secrets",
 }
 
 type ApiEvent struct {
@@ -22,15 +22,17 @@ func NewIncomingReflectorsController(homeClient, foreignClient kubernetes.Interf
 	opts map[options.OptionKey]options.Option) IncomingAPIReflectorsController {
 	controller := &IncomingReflectorsController{
 		&ReflectorsController{
-			reflectionType:   ri.IncomingReflection,
-			outputChan:       outputChan,
-			homeClient:       homeClient,
-			foreignClient:    foreignClient,
-			apiReflectors:    make(map[apimgmt.ApiType]ri.APIReflector),
-			namespaceNatting: namespaceNatting,
-			namespacedStops:  make(map[string]chan struct{}),
-			reflectionGroup:  &sync.WaitGroup{},
-			cacheManager:     cacheManager,
+			reflectionType:     ri.IncomingReflection,
+			outputChan:         outputChan,
+			homeClient:         homeClient,
+			foreignClient:      foreignClient,
+			apiReflectors:      make(map[apimgmt.ApiType]ri.APIReflector),
+			namespaceNatting:   namespaceNatting,
+			namespacedStops:    make(map[string]chan struct{}),
+			namespacedApis:     make(map[string]map[apimgmt.ApiType]bool),
+			namespacedCleanups: make(map[string]*namespaceCleanup),
+			reflectionGroup:    &sync.WaitGroup{},
+			cacheManager:       cacheManager,
 		},
 	}
 
@@ -70,7 +72,3 @@ func (c *IncomingReflectorsController) Start() {
 func (c *IncomingReflectorsController) SetInforming(api apimgmt.ApiType, handler func(interface{})) {
 	c.apiReflectors[api].(ri.APIReflector).SetInforming(handler)
 }
-
-func (c *IncomingReflectorsController) stopNamespaceReflection(namespace string) {
-	close(c.namespacedStops[namespace])
-}
@@ -10,6 +10,7 @@ import (
 	"github.com/liqotech/liqo/pkg/virtualKubelet/storage"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
+	"reflect"
 	"sync"
 )
 
@@ -23,15 +24,17 @@ func NewOutgoingReflectorsController(homeClient, foreignClient kubernetes.Interf
 	opts map[options.OptionKey]options.Option) OutGoingAPIReflectorsController {
 	controller := &OutgoingReflectorsController{
 		&ReflectorsController{
-			reflectionType:   ri.OutgoingReflection,
-			outputChan:       outputChan,
-			homeClient:       homeClient,
-			foreignClient:    foreignClient,
-			apiReflectors:    make(map[apimgmt.ApiType]ri.APIReflector),
-			namespaceNatting: namespaceNatting,
-			namespacedStops:  make(map[string]chan struct{}),
-			reflectionGroup:  &sync.WaitGroup{},
-			cacheManager:     cacheManager,
+			reflectionType:     ri.OutgoingReflection,
+			outputChan:         outputChan,
+			homeClient:         homeClient,
+			foreignClient:      foreignClient,
+			apiReflectors:      make(map[apimgmt.ApiType]ri.APIReflector),
+			namespaceNatting:   namespaceNatting,
+			namespacedStops:    make(map[string]chan struct{}),
+			namespacedApis:     make(map[string]map[apimgmt.ApiType]bool),
+			namespacedCleanups: make(map[string]*namespaceCleanup),
+			reflectionGroup:    &sync.WaitGroup{},
+			cacheManager:       cacheManager,
 		},
 	}
 
@@ -65,11 +68,37 @@ func (c *OutgoingReflectorsController) Start() {
 			klog.V(2).Infof("outgoing reflection for namespace %v started", ns)
 		case ns := <-c.namespaceNatting.PollStopOutgoingReflection():
 			c.stopNamespaceReflection(ns)
-			klog.V(2).Infof("incoming reflection for namespace %v started", ns)
+			klog.V(2).Infof("outgoing reflection for namespace %v stopped", ns)
+		case ns := <-c.namespaceNatting.PollRestartOutgoingReflection():
+			if c.restartNamespaceReflection(ns) {
+				klog.V(2).Infof("outgoing reflection for namespace %v restarted", ns)
+			}
 		}
 	}
 }
 
-func (c *OutgoingReflectorsController) stopNamespaceReflection(namespace string) {
+// restartNamespaceReflection restarts the reflection of the namespace if the API types reflected according to its
+// offloading policy have changed, and returns whether it has been restarted. The objects of the API types no longer
+// reflected are deleted from the foreign namespace, while the other ones are kept.
+func (c *OutgoingReflectorsController) restartNamespaceReflection(namespace string) bool {
+	cleanup, ok := c.namespacedCleanups[namespace]
+	if !ok {
+		return false
+	}
+	reflected, apis := c.namespacedApis[namespace], c.outgoingApis(namespace)
+	if reflect.DeepEqual(reflected, apis) {
+		return false
+	}
+
+	cleanup.apis = make(map[apimgmt.ApiType]bool)
+	for api := range reflected {
+		if !apis[api] {
+			cleanup.apis[api] = true
+		}
+	}
 	close(c.namespacedStops[namespace])
+	<-cleanup.done
+
+	c.startNamespaceReflection(namespace)
+	return true
 }
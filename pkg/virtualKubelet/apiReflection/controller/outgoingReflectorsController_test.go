@@ -0,0 +1,122 @@
+package controller
+
+import (
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
+	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors/outgoing"
+	ri "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors/reflectorsInterfaces"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping/test"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/storage"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeReflector records the namespaces set up and cleaned up for its API type.
+type fakeReflector struct {
+	ri.APIReflector
+	setups   []string
+	cleanups []string
+}
+
+func (r *fakeReflector) SetupHandlers(_ apimgmt.ApiType, _ ri.ReflectionType, namespace, _ string) {
+	r.setups = append(r.setups, namespace)
+}
+
+func (r *fakeReflector) SetSpecializedPreProcessingHandlers() {}
+
+func (r *fakeReflector) HandleEvent(interface{}) {}
+
+func (r *fakeReflector) CleanupNamespace(namespace string) {
+	r.cleanups = append(r.cleanups, namespace)
+}
+
+func TestRestartNamespaceReflection(t *testing.T) {
+	mapper := &test.MockNamespaceMapper{
+		Cache:      map[string]string{"homeNamespace": "homeNamespace-natted"},
+		Offloading: map[string]*nattingv1.NamespaceOffloadingSpec{},
+	}
+	c := &OutgoingReflectorsController{
+		&ReflectorsController{
+			reflectionType:     ri.OutgoingReflection,
+			homeClient:         fake.NewSimpleClientset(),
+			foreignClient:      fake.NewSimpleClientset(),
+			apiReflectors:      make(map[apimgmt.ApiType]ri.APIReflector),
+			namespaceNatting:   test.NewMockNamespaceMapperController(mapper),
+			namespacedStops:    make(map[string]chan struct{}),
+			namespacedApis:     make(map[string]map[apimgmt.ApiType]bool),
+			namespacedCleanups: make(map[string]*namespaceCleanup),
+			reflectionGroup:    &sync.WaitGroup{},
+			cacheManager:       storage.NewManager(fake.NewSimpleClientset(), fake.NewSimpleClientset()),
+		},
+	}
+	reflectors := make(map[apimgmt.ApiType]*fakeReflector)
+	for api := range outgoing.ReflectorBuilders {
+		reflectors[api] = &fakeReflector{}
+		c.apiReflectors[api] = reflectors[api]
+	}
+
+	// collect returns the API types whose reflectors have been set up and cleaned up since the last call.
+	collect := func() (setups, cleanups map[apimgmt.ApiType]bool) {
+		setups, cleanups = make(map[apimgmt.ApiType]bool), make(map[apimgmt.ApiType]bool)
+		for api, reflector := range reflectors {
+			if len(reflector.setups) > 0 {
+				setups[api] = true
+			}
+			if len(reflector.cleanups) > 0 {
+				cleanups[api] = true
+			}
+			reflector.setups, reflector.cleanups = nil, nil
+		}
+		return setups, cleanups
+	}
+
+	all := make(map[apimgmt.ApiType]bool)
+	for api := range outgoing.ReflectorBuilders {
+		all[api] = true
+	}
+
+	c.startNamespaceReflection("homeNamespace")
+	if setups, _ := collect(); !reflect.DeepEqual(setups, all) {
+		t.Fatalf("expected all the API types to be reflected, got %v", setups)
+	}
+
+	// the offloading policy is unchanged
+	if c.restartNamespaceReflection("homeNamespace") {
+		t.Errorf("reflection restarted although the reflected API types are unchanged")
+	}
+
+	// the namespace is not reflected
+	if c.restartNamespaceReflection("otherNamespace") {
+		t.Errorf("reflection restarted for a namespace not reflected")
+	}
+
+	// the secrets and the services are no longer reflected
+	mapper.Offloading["homeNamespace"] = &nattingv1.NamespaceOffloadingSpec{
+		Enabled:            true,
+		ReflectedResources: []nattingv1.ReflectedResource{nattingv1.ReflectedConfigMaps},
+	}
+	if !c.restartNamespaceReflection("homeNamespace") {
+		t.Fatalf("reflection not restarted although the reflected API types have changed")
+	}
+	setups, cleanups := collect()
+	expectedSetups := map[apimgmt.ApiType]bool{apimgmt.Configmaps: true, apimgmt.PersistentVolumeClaims: true}
+	if !reflect.DeepEqual(setups, expectedSetups) {
+		t.Errorf("expected the reflected API types %v, got %v", expectedSetups, setups)
+	}
+	expectedCleanups := map[apimgmt.ApiType]bool{apimgmt.EndpointSlices: true, apimgmt.Secrets: true, apimgmt.Services: true}
+	if !reflect.DeepEqual(cleanups, expectedCleanups) {
+		t.Errorf("expected the cleaned up API types %v, got %v", expectedCleanups, cleanups)
+	}
+
+	// the reflection stops, deleting all the reflected objects
+	c.stopNamespaceReflection("homeNamespace")
+	c.reflectionGroup.Wait()
+	if _, cleanups := collect(); !reflect.DeepEqual(cleanups, all) {
+		t.Errorf("expected all the API types to be cleaned up, got %v", cleanups)
+	}
+	if c.restartNamespaceReflection("homeNamespace") {
+		t.Errorf("reflection restarted for a namespace no longer reflected")
+	}
+}
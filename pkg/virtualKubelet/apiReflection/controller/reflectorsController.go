@@ -1,6 +1,7 @@
 package controller
 
 import (
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
 	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors/incoming"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors/outgoing"
@@ -8,6 +9,7 @@ import (
 	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/options"
 	reflectionCache "github.com/liqotech/liqo/pkg/virtualKubelet/storage"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 	"sync"
@@ -37,6 +39,15 @@ type IncomingAPIReflectorsController interface {
 	SetInforming(api apimgmt.ApiType, handler func(interface{}))
 }
 
+// offloadingReflectedResources maps the outgoing API types to the resources which can be excluded from the reflection
+// by the NamespaceOffloadings. The API types not listed are always reflected, as the offloaded pods depend on them.
+var offloadingReflectedResources = map[apimgmt.ApiType]nattingv1.ReflectedResource{
+	apimgmt.Configmaps:     nattingv1.ReflectedConfigMaps,
+	apimgmt.EndpointSlices: nattingv1.ReflectedServices,
+	apimgmt.Secrets:        nattingv1.ReflectedSecrets,
+	apimgmt.Services:       nattingv1.ReflectedServices,
+}
+
 type ReflectorsController struct {
 	reflectionType   ri.ReflectionType
 	outputChan       chan apimgmt.ApiEvent
@@ -47,6 +58,31 @@ type ReflectorsController struct {
 	reflectionGroup  *sync.WaitGroup
 	namespaceNatting namespacesMapping.MapperController
 	namespacedStops  map[string]chan struct{}
+	// namespacedApis are the outgoing API types reflected in each namespace, according to its offloading policy
+	namespacedApis map[string]map[apimgmt.ApiType]bool
+	// namespacedCleanups are the cleanups performed when the reflection of each namespace stops
+	namespacedCleanups map[string]*namespaceCleanup
+}
+
+// namespaceCleanup is the cleanup of the objects reflected in a namespace, performed when its reflection stops.
+type namespaceCleanup struct {
+	// apis are the API types whose reflected objects are deleted, all of them if nil
+	apis map[apimgmt.ApiType]bool
+	// done is closed once the cleanup has been performed
+	done chan struct{}
+}
+
+// outgoingApis returns the outgoing API types reflected in the namespace, according to its offloading policy.
+func (c *ReflectorsController) outgoingApis(namespace string) map[apimgmt.ApiType]bool {
+	apis := make(map[apimgmt.ApiType]bool)
+	policy, hasPolicy := c.namespaceNatting.OffloadingPolicy(namespace)
+	for api := range outgoing.ReflectorBuilders {
+		if resource, ok := offloadingReflectedResources[api]; ok && hasPolicy && !policy.Reflects(resource) {
+			continue
+		}
+		apis[api] = true
+	}
+	return apis
 }
 
 func (c *ReflectorsController) startNamespaceReflection(namespace string) {
@@ -72,6 +108,12 @@ func (c *ReflectorsController) startNamespaceReflection(namespace string) {
 			klog.Errorf("error while starting namespace caching - ERR: %v", err)
 			return
 		}
+
+		for _, reflector := range c.apiReflectors {
+			if resyncer, ok := reflector.(ri.ResyncingAPIReflector); ok {
+				go wait.Until(func() { resyncer.Resync(namespace) }, resyncer.ResyncPeriod(), c.namespacedStops[namespace])
+			}
+		}
 	}
 
 	if c.reflectionType == ri.OutgoingReflection {
@@ -80,9 +122,15 @@ func (c *ReflectorsController) startNamespaceReflection(namespace string) {
 			return
 		}
 
+		apis := c.outgoingApis(namespace)
 		for api := range outgoing.ReflectorBuilders {
+			if !apis[api] {
+				klog.V(3).Infof("%v not reflected in namespace %v because excluded by its offloading policy", apimgmt.ApiNames[api], namespace)
+				continue
+			}
 			c.apiReflectors[api].SetupHandlers(api, c.reflectionType, namespace, nattedNs)
 		}
+		c.namespacedApis[namespace] = apis
 
 		if err := c.cacheManager.StartHomeNamespace(namespace, c.namespacedStops[namespace]); err != nil {
 			klog.Errorf("error while starting namespace caching - ERR: %v", err)
@@ -90,12 +138,17 @@ func (c *ReflectorsController) startNamespaceReflection(namespace string) {
 		}
 	}
 
+	stop, cleanup := c.namespacedStops[namespace], &namespaceCleanup{done: make(chan struct{})}
+	c.namespacedCleanups[namespace] = cleanup
 	c.reflectionGroup.Add(1)
 	go func() {
-		<-c.namespacedStops[namespace]
-		for _, reflector := range c.apiReflectors {
-			reflector.(ri.SpecializedAPIReflector).CleanupNamespace(namespace)
+		<-stop
+		for api, reflector := range c.apiReflectors {
+			if cleanup.apis == nil || cleanup.apis[api] {
+				reflector.(ri.SpecializedAPIReflector).CleanupNamespace(namespace)
+			}
 		}
+		close(cleanup.done)
 		c.reflectionGroup.Done()
 	}()
 }
@@ -104,6 +157,16 @@ func (c *ReflectorsController) DispatchEvent(event apimgmt.ApiEvent) {
 	c.apiReflectors[event.Api].(ri.SpecializedAPIReflector).HandleEvent(event.Event)
 }
 
+// stopNamespaceReflection stops the reflection of the namespace, deleting all the reflected objects.
+func (c *ReflectorsController) stopNamespaceReflection(namespace string) {
+	if stop, ok := c.namespacedStops[namespace]; ok {
+		close(stop)
+		delete(c.namespacedStops, namespace)
+		delete(c.namespacedApis, namespace)
+		delete(c.namespacedCleanups, namespace)
+	}
+}
+
 func (c *ReflectorsController) Stop() {
 	for _, stop := range c.namespacedStops {
 		close(stop)
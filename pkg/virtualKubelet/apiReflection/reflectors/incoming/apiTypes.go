@@ -8,8 +8,15 @@ import (
 )
 
 var ReflectorBuilder = map[apimgmt.ApiType]func(reflector ri.APIReflector, opts map[options.OptionKey]options.Option) ri.IncomingAPIReflector{
-	apimgmt.Pods:        podsReflectorBuilder,
-	apimgmt.ReplicaSets: replicaSetsReflectorBuilder,
+	apimgmt.PersistentVolumeClaims: persistentVolumeClaimsReflectorBuilder,
+	apimgmt.Pods:                   podsReflectorBuilder,
+	apimgmt.ReplicaSets:            replicaSetsReflectorBuilder,
+}
+
+func persistentVolumeClaimsReflectorBuilder(reflector ri.APIReflector, _ map[options.OptionKey]options.Option) ri.IncomingAPIReflector {
+	return &PersistentVolumeClaimsIncomingReflector{
+		APIReflector: reflector,
+	}
 }
 
 func podsReflectorBuilder(reflector ri.APIReflector, opts map[options.OptionKey]options.Option) ri.IncomingAPIReflector {
@@ -0,0 +1,128 @@
+package incoming
+
+import (
+	"context"
+	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	ri "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors/reflectorsInterfaces"
+	vkContext "github.com/liqotech/liqo/pkg/virtualKubelet/context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog"
+)
+
+// PersistentVolumeClaimsIncomingReflector is the incoming reflector in charge of binding the home PersistentVolumeClaims
+// once the foreign ones are bound, by means of placeholder PersistentVolumes pre-bound to the home claims
+type PersistentVolumeClaimsIncomingReflector struct {
+	ri.APIReflector
+}
+
+// SetSpecializedPreProcessingHandlers allows to set the pre-routine handlers for the PersistentVolumeClaimsIncomingReflector
+func (r *PersistentVolumeClaimsIncomingReflector) SetSpecializedPreProcessingHandlers() {
+	r.SetPreProcessingHandlers(ri.PreProcessingHandlers{
+		AddFunc:    r.PreAdd,
+		UpdateFunc: r.PreUpdate,
+		DeleteFunc: r.PreDelete,
+		IsAllowed:  r.isAllowed,
+	})
+}
+
+// HandleEvent creates the placeholder PersistentVolume received from the pre-routines, which is then bound
+// to the home claim by the home control plane
+func (r *PersistentVolumeClaimsIncomingReflector) HandleEvent(e interface{}) {
+	event := e.(watch.Event)
+	pv, ok := event.Object.(*corev1.PersistentVolume)
+	if !ok {
+		klog.Error("INCOMING REFLECTION: cannot cast object to persistentVolume")
+		return
+	}
+
+	klog.V(3).Infof("INCOMING REFLECTION: received %v for persistentVolumeClaim %v/%v", event.Type, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+
+	_, err := r.GetHomeClient().CoreV1().PersistentVolumes().Create(context.TODO(), pv, metav1.CreateOptions{})
+	switch {
+	case kerrors.IsAlreadyExists(err):
+		klog.V(4).Infof("INCOMING REFLECTION: placeholder persistentVolume %v already existing", pv.Name)
+	case err != nil:
+		klog.Errorf("INCOMING REFLECTION: Error while creating the placeholder persistentVolume %v - ERR: %v", pv.Name, err)
+	default:
+		klog.V(3).Infof("INCOMING REFLECTION: placeholder persistentVolume %v for persistentVolumeClaim %v/%v correctly created",
+			pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+	}
+}
+
+// PreAdd is the pre-routine called in case of persistentVolumeClaim creation in the foreign cluster. It returns
+// the placeholder volume to bind to the home claim, if the foreign one is bound
+func (r *PersistentVolumeClaimsIncomingReflector) PreAdd(obj interface{}) (interface{}, watch.EventType) {
+	pv := r.sharedPreRoutine(obj.(*corev1.PersistentVolumeClaim))
+	if pv == nil {
+		return nil, watch.Added
+	}
+
+	return pv, watch.Added
+}
+
+// PreUpdate is the pre-routine called in case of persistentVolumeClaim update in the foreign cluster. It returns
+// the placeholder volume to bind to the home claim, if the foreign one is bound
+func (r *PersistentVolumeClaimsIncomingReflector) PreUpdate(newObj, _ interface{}) (interface{}, watch.EventType) {
+	pv := r.sharedPreRoutine(newObj.(*corev1.PersistentVolumeClaim))
+	if pv == nil {
+		return nil, watch.Modified
+	}
+
+	return pv, watch.Modified
+}
+
+// PreDelete ignores the foreign deletions, because the lifecycle of the foreign claims is driven by the home ones
+func (r *PersistentVolumeClaimsIncomingReflector) PreDelete(_ interface{}) (interface{}, watch.EventType) {
+	return nil, watch.Deleted
+}
+
+// sharedPreRoutine fetches the home persistentVolumeClaim related to the foreign one and returns the placeholder
+// volume to bind to it, or nil if the foreign claim is not bound yet or the home one is already bound
+func (r *PersistentVolumeClaimsIncomingReflector) sharedPreRoutine(foreignPvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolume {
+	if foreignPvc.Status.Phase != corev1.ClaimBound {
+		return nil
+	}
+
+	homeNamespace, err := r.NattingTable().DeNatNamespace(foreignPvc.Namespace)
+	if err != nil {
+		klog.Error(err)
+		return nil
+	}
+
+	homeObj, err := r.GetCacheManager().GetHomeNamespacedObject(apimgmt.PersistentVolumeClaims, homeNamespace, foreignPvc.Name)
+	if err != nil {
+		err = errors.Wrap(err, "local persistentVolumeClaim not found, incoming update blocked")
+		klog.V(4).Info(err)
+		return nil
+	}
+	homePvc := homeObj.(*corev1.PersistentVolumeClaim)
+
+	// the status of the home claim is managed by the home control plane, which binds it to the placeholder volume
+	if homePvc.Spec.VolumeName != "" {
+		return nil
+	}
+
+	return forge.PlaceholderVolume(homePvc, foreignPvc)
+}
+
+// CleanupNamespace does nothing, because the foreign persistentVolumeClaims are removed by the outgoing reflector
+func (r *PersistentVolumeClaimsIncomingReflector) CleanupNamespace(_ string) {}
+
+// isAllowed checks that the received object has been reflected by this virtual kubelet.
+func (r *PersistentVolumeClaimsIncomingReflector) isAllowed(ctx context.Context, obj interface{}) bool {
+	if value, ok := vkContext.IncomingMethod(ctx); ok && value == vkContext.IncomingDeleted {
+		return true
+	}
+
+	pvc, ok := obj.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		klog.Error("cannot convert obj to persistentVolumeClaim")
+		return false
+	}
+	return pvc.Labels[forge.LiqoOutgoingKey] == forge.LiqoNodeName()
+}
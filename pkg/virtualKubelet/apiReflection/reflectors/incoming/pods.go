@@ -2,6 +2,7 @@ package incoming
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/liqotech/liqo/pkg/virtualKubelet"
 	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
@@ -11,7 +12,9 @@ import (
 	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/options"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -19,6 +22,8 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
+	"sync"
+	"time"
 )
 
 // PodsIncomingReflector is the incoming reflector in charge of detecting status change in foreign pods
@@ -27,6 +32,27 @@ type PodsIncomingReflector struct {
 	ri.APIReflector
 
 	RemoteRemappedPodCIDR options.ReadOnlyOption
+
+	// assignmentLock serializes the assignment of the replicas of the shared foreign replicasets to the home pods
+	assignmentLock sync.Mutex
+	// pendingAssignments maps the foreign replicas to the home pods assigned to them, until the assignment is
+	// observed in the foreign cache
+	pendingAssignments map[string]string
+}
+
+// podStatusResyncPeriod is the period of the reconciliation of the status of the home pods with the foreign ones.
+const podStatusResyncPeriod = 1 * time.Minute
+
+// repairedPodStatuses counts the divergences between the status of the home and foreign pods repaired by the resync.
+var repairedPodStatuses = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "liqo",
+	Subsystem: "virtual_kubelet",
+	Name:      "repaired_pod_statuses_total",
+	Help:      "Number of divergences between the status of the home and foreign pods repaired by the resync.",
+})
+
+func init() {
+	prometheus.MustRegister(repairedPodStatuses)
 }
 
 // SetSpecializedPreProcessingHandlers allows to set the pre-routine handlers for the PodsIncomingReflector
@@ -73,7 +99,7 @@ func (r *PodsIncomingReflector) PreAdd(obj interface{}) (interface{}, watch.Even
 
 // PreAdd is the pre-routine called in case of pod update in the foreign cluster. It returns the home object with its
 // status updated
-func (r *PodsIncomingReflector) PreUpdate(newObj, _ interface{}) (interface{}, watch.EventType) {
+func (r *PodsIncomingReflector) PreUpdate(newObj, oldObj interface{}) (interface{}, watch.EventType) {
 	foreignPod := newObj.(*corev1.Pod)
 
 	if foreignPod == nil {
@@ -84,6 +110,9 @@ func (r *PodsIncomingReflector) PreUpdate(newObj, _ interface{}) (interface{}, w
 	if homePod == nil {
 		return nil, watch.Modified
 	}
+	if oldForeignPod, ok := oldObj.(*corev1.Pod); ok && oldForeignPod != nil {
+		r.reflectForeignMeta(homePod, oldForeignPod, foreignPod)
+	}
 
 	return homePod, watch.Modified
 }
@@ -97,6 +126,11 @@ func (r *PodsIncomingReflector) sharedPreRoutine(foreignPod *corev1.Pod) *corev1
 
 	homePodName, ok := foreignPod.Labels[virtualKubelet.ReflectedpodKey]
 	if !ok {
+		// the replicas of the shared foreign replicasets are created by the foreign cluster, hence they have to be
+		// assigned to a home pod before being reflected
+		if replicaSetName, shared := foreignPod.Labels[virtualKubelet.ReflectedReplicaSetKey]; shared {
+			r.assignSharedReplica(foreignPod, replicaSetName)
+		}
 		return nil
 	}
 
@@ -106,9 +140,15 @@ func (r *PodsIncomingReflector) sharedPreRoutine(foreignPod *corev1.Pod) *corev1
 		return nil
 	}
 
+	if _, shared := foreignPod.Labels[virtualKubelet.ReflectedReplicaSetKey]; shared {
+		r.assignmentLock.Lock()
+		delete(r.pendingAssignments, r.Keyer(foreignPod.Namespace, foreignPod.Name))
+		r.assignmentLock.Unlock()
+	}
+
 	homePod, err := r.GetCacheManager().GetHomeNamespacedObject(apimgmt.Pods, homeNamespace, homePodName)
 	if err != nil {
-		err = errors.Wrap(err, "local pod not found, incoming update blocked")
+		err = errors.Wrap(err, "local pod not found, incoming update deferred to the next resync")
 		klog.V(4).Info(err)
 		return nil
 	}
@@ -123,6 +163,40 @@ func (r *PodsIncomingReflector) sharedPreRoutine(foreignPod *corev1.Pod) *corev1
 	return homePod.(*corev1.Pod)
 }
 
+// reflectForeignMeta patches the labels and annotations of the home pod with the changes of the foreign one, so that
+// they are kept in sync in both directions (the home changes being reflected by the provider UpdatePod). Only the keys
+// of the home pod are reflected, while the ones added by the foreign cluster (e.g. by its CNI) are not.
+func (r *PodsIncomingReflector) reflectForeignMeta(homePod, oldForeignPod, newForeignPod *corev1.Pod) {
+	labels, annotations := forge.PodMetaForeignToHome(homePod, oldForeignPod, newForeignPod)
+	if len(labels) == 0 && len(annotations) == 0 {
+		return
+	}
+
+	metaPatch := map[string]interface{}{}
+	if len(labels) != 0 {
+		metaPatch["labels"] = labels
+	}
+	if len(annotations) != 0 {
+		metaPatch["annotations"] = annotations
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metaPatch})
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	_, err = r.GetHomeClient().CoreV1().Pods(homePod.Namespace).Patch(context.TODO(),
+		homePod.Name,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{})
+	if err != nil {
+		klog.Errorf("error while reflecting the metadata of the foreign pod %v/%v: %v", newForeignPod.Namespace, newForeignPod.Name, err)
+		return
+	}
+	klog.V(4).Infof("INCOMING REFLECTION: labels and annotations of pod %v/%v updated", homePod.Namespace, homePod.Name)
+}
+
 // PreDelete removes the received object from the blacklist for freeing the occupied space
 func (r *PodsIncomingReflector) PreDelete(obj interface{}) (interface{}, watch.EventType) {
 	foreignPod := obj.(*corev1.Pod)
@@ -130,9 +204,182 @@ func (r *PodsIncomingReflector) PreDelete(obj interface{}) (interface{}, watch.E
 	delete(reflectors.Blacklist[apimgmt.Pods], foreignKey)
 	klog.V(3).Infof("pod %s removed from blacklist because deleted", foreignKey)
 
+	if replicaSetName, shared := foreignPod.Labels[virtualKubelet.ReflectedReplicaSetKey]; shared {
+		r.assignmentLock.Lock()
+		delete(r.pendingAssignments, foreignKey)
+		r.assignmentLock.Unlock()
+
+		// the home pod of the deleted replica is assigned to a replica created in the meanwhile, if any
+		if _, assigned := foreignPod.Labels[virtualKubelet.ReflectedpodKey]; assigned {
+			r.assignSharedReplicas(foreignPod.Namespace, replicaSetName)
+		}
+	}
+
 	return nil, watch.Deleted
 }
 
+// assignSharedReplicas assigns the unassigned replicas of the shared foreign replicaset to the home pods.
+func (r *PodsIncomingReflector) assignSharedReplicas(foreignNamespace, replicaSetName string) {
+	foreignObjs, err := r.GetCacheManager().ListForeignNamespacedObject(apimgmt.Pods, foreignNamespace)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	for _, obj := range foreignObjs {
+		foreignPod := obj.(*corev1.Pod)
+		if _, assigned := foreignPod.Labels[virtualKubelet.ReflectedpodKey]; !assigned &&
+			foreignPod.Labels[virtualKubelet.ReflectedReplicaSetKey] == replicaSetName {
+			r.assignSharedReplica(foreignPod, replicaSetName)
+		}
+	}
+}
+
+// assignSharedReplica assigns the replica of the shared foreign replicaset to the oldest home pod of the home
+// replicaset running on the virtual node and not yet assigned, by labeling the replica with the home pod name.
+func (r *PodsIncomingReflector) assignSharedReplica(foreignPod *corev1.Pod, replicaSetName string) {
+	if foreignPod.DeletionTimestamp != nil {
+		return
+	}
+
+	homeNamespace, err := r.NattingTable().DeNatNamespace(foreignPod.Namespace)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	r.assignmentLock.Lock()
+	defer r.assignmentLock.Unlock()
+
+	foreignKey := r.Keyer(foreignPod.Namespace, foreignPod.Name)
+	if _, ok := r.pendingAssignments[foreignKey]; ok {
+		return
+	}
+
+	assigned := map[string]struct{}{}
+	for _, homePodName := range r.pendingAssignments {
+		assigned[homePodName] = struct{}{}
+	}
+	foreignObjs, err := r.GetCacheManager().ListForeignNamespacedObject(apimgmt.Pods, foreignPod.Namespace)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	for _, obj := range foreignObjs {
+		replica := obj.(*corev1.Pod)
+		if homePodName, ok := replica.Labels[virtualKubelet.ReflectedpodKey]; ok &&
+			replica.Labels[virtualKubelet.ReflectedReplicaSetKey] == replicaSetName {
+			assigned[homePodName] = struct{}{}
+		}
+	}
+
+	homeObjs, err := r.GetCacheManager().ListHomeNamespacedObject(apimgmt.Pods, homeNamespace)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	var candidate *corev1.Pod
+	for _, obj := range homeObjs {
+		homePod := obj.(*corev1.Pod)
+		owner := metav1.GetControllerOf(homePod)
+		if owner == nil || owner.Kind != "ReplicaSet" || owner.Name != replicaSetName ||
+			homePod.Spec.NodeName != forge.LiqoNodeName() || homePod.DeletionTimestamp != nil {
+			continue
+		}
+		if _, ok := assigned[homePod.Name]; ok {
+			continue
+		}
+		if candidate == nil || homePod.CreationTimestamp.Before(&candidate.CreationTimestamp) ||
+			(homePod.CreationTimestamp.Equal(&candidate.CreationTimestamp) && homePod.Name < candidate.Name) {
+			candidate = homePod
+		}
+	}
+	if candidate == nil {
+		klog.V(4).Infof("INCOMING REFLECTION: no home pod to be assigned to the foreign pod %v", foreignKey)
+		return
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{
+		"labels": map[string]string{virtualKubelet.ReflectedpodKey: candidate.Name},
+	}})
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	_, err = r.GetForeignClient().CoreV1().Pods(foreignPod.Namespace).Patch(context.TODO(),
+		foreignPod.Name,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{})
+	if err != nil {
+		klog.Errorf("error while assigning the foreign pod %v to the home pod %v/%v: %v", foreignKey, homeNamespace, candidate.Name, err)
+		return
+	}
+
+	if r.pendingAssignments == nil {
+		r.pendingAssignments = map[string]string{}
+	}
+	r.pendingAssignments[foreignKey] = candidate.Name
+	klog.V(3).Infof("INCOMING REFLECTION: foreign pod %v assigned to the home pod %v/%v", foreignKey, homeNamespace, candidate.Name)
+}
+
+// ResyncPeriod returns the period of the reconciliation of the status of the home pods with the foreign ones.
+func (r *PodsIncomingReflector) ResyncPeriod() time.Duration {
+	return podStatusResyncPeriod
+}
+
+// Resync compares the status of each foreign pod reflected in the namespace with the one of its home pod, and pushes
+// the foreign status again in case of divergence, e.g. because the update was dropped as the home pod was not cached
+// yet. The replicas of the shared foreign replicasets not yet assigned to a home pod are assigned as well.
+func (r *PodsIncomingReflector) Resync(namespace string) {
+	foreignNamespace, err := r.NattingTable().NatNamespace(namespace, false)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	objects, err := r.GetCacheManager().ListForeignNamespacedObject(apimgmt.Pods, foreignNamespace)
+	if err != nil {
+		klog.Errorf("error while listing foreign objects in namespace %v", foreignNamespace)
+		return
+	}
+
+	for _, obj := range objects {
+		foreignPod := obj.(*corev1.Pod)
+		if foreignPod.DeletionTimestamp != nil {
+			continue
+		}
+
+		homePodName, ok := foreignPod.Labels[virtualKubelet.ReflectedpodKey]
+		if !ok {
+			if replicaSetName, shared := foreignPod.Labels[virtualKubelet.ReflectedReplicaSetKey]; shared {
+				r.assignSharedReplica(foreignPod, replicaSetName)
+			}
+			continue
+		}
+
+		homeObj, err := r.GetCacheManager().GetHomeNamespacedObject(apimgmt.Pods, namespace, homePodName)
+		if err != nil {
+			klog.V(4).Infof("INCOMING REFLECTION: status of home pod %v/%v not reconciled: %v", namespace, homePodName, err)
+			continue
+		}
+		homePod := homeObj.(*corev1.Pod)
+
+		reflected, err := forge.ForeignToHomeStatus(foreignPod, homePod.DeepCopy())
+		if err != nil {
+			klog.Error(err)
+			continue
+		}
+		if equality.Semantic.DeepEqual(reflected.(*corev1.Pod).Status, homePod.Status) {
+			continue
+		}
+
+		repairedPodStatuses.Inc()
+		klog.Infof("INCOMING REFLECTION: status of home pod %v/%v diverged from the foreign one, reflected again",
+			namespace, homePodName)
+		r.PushToInforming(reflected)
+	}
+}
+
 // CleanupNamespace is in charge of cleaning a local namespace from all the reflected objects. All the home objects in
 // the home namespace are fetched and deleted locally. Their deletion will implies the delete of the remote replicasets
 func (r *PodsIncomingReflector) CleanupNamespace(namespace string) {
@@ -168,6 +415,14 @@ func (r *PodsIncomingReflector) CleanupNamespace(namespace string) {
 		if !ok {
 			continue
 		}
+		if _, shared := foreignPod.Labels[virtualKubelet.ReflectedReplicaSetKey]; shared {
+			// the home pods offloaded as replicas of the shared foreign replicasets have no finalizer
+			if err := r.GetHomeClient().CoreV1().Pods(namespace).Delete(context.TODO(), homePodName, metav1.DeleteOptions{}); err != nil &&
+				!kerrors.IsNotFound(err) {
+				klog.Errorf("Error while deleting home pod %v/%v - ERR: %v", namespace, homePodName, err)
+			}
+			continue
+		}
 		// allow deletion of the related homePod by removing its finalizer
 		finalizerPatch := []byte(fmt.Sprintf(
 			`[{"op":"remove","path":"/metadata/finalizers","value":["%s"]}]`,
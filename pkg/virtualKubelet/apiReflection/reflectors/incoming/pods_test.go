@@ -1,6 +1,7 @@
 package incoming_test
 
 import (
+	"context"
 	"github.com/liqotech/liqo/pkg/virtualKubelet"
 	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors"
@@ -12,8 +13,11 @@ import (
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/types"
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"time"
 )
 
 var _ = Describe("Pods", func() {
@@ -131,6 +135,199 @@ var _ = Describe("Pods", func() {
 					Expect(ret.(*corev1.Pod).Namespace).To(Equal(homePod.Namespace))
 					Expect(ret.(*corev1.Pod).Status).To(Equal(foreignPod.Status))
 				})
+
+				It("home labels and annotations reflected", func() {
+					homeClient := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+						Name:        "homePod",
+						Namespace:   "homeNamespace",
+						Labels:      map[string]string{"home": "true", "removed": "true"},
+						Annotations: map[string]string{"home": "true"},
+					}})
+					genericReflector.HomeClient = homeClient
+					homePod.Labels = map[string]string{"home": "true", "removed": "true"}
+					homePod.Annotations = map[string]string{"home": "true"}
+
+					oldForeignPod := foreignPod.DeepCopy()
+					oldForeignPod.Labels["home"] = "true"
+					oldForeignPod.Labels["removed"] = "true"
+					oldForeignPod.Annotations = map[string]string{"home": "true"}
+					foreignPod.Labels["home"] = "true"
+					foreignPod.Labels["foreign"] = "true"
+					foreignPod.Annotations = map[string]string{"home": "false", "cni.projectcalico.org/podIP": "10.0.0.1/32"}
+
+					_, _ = reflector.PreProcessUpdate(foreignPod, oldForeignPod)
+					updated, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), "homePod", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					// the keys set by the foreign cluster are not reflected
+					Expect(updated.Labels).To(Equal(map[string]string{"home": "true"}))
+					Expect(updated.Annotations).To(Equal(map[string]string{"home": "false"}))
+				})
+
+				It("foreign labels and annotations not reflected on creation", func() {
+					homeClient := fake.NewSimpleClientset(homePod.DeepCopy())
+					genericReflector.HomeClient = homeClient
+					foreignPod.Labels["foreign"] = "true"
+					foreignPod.Annotations = map[string]string{"cni.projectcalico.org/podIP": "10.0.0.1/32"}
+
+					_, _ = reflector.PreProcessAdd(foreignPod)
+					pod, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), "homePod", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(pod.Labels).To(BeEmpty())
+					Expect(pod.Annotations).To(BeEmpty())
+				})
+			})
+
+			Context("resync", func() {
+				var (
+					homePod, foreignPod *corev1.Pod
+					pushed              []*corev1.Pod
+					repaired            float64
+				)
+
+				// repairedStatuses returns the value of the metric exposed by the reflector
+				repairedStatuses := func() float64 {
+					families, err := prometheus.DefaultGatherer.Gather()
+					Expect(err).NotTo(HaveOccurred())
+					for _, family := range families {
+						if family.GetName() == "liqo_virtual_kubelet_repaired_pod_statuses_total" {
+							return family.GetMetric()[0].GetCounter().GetValue()
+						}
+					}
+					return 0
+				}
+
+				BeforeEach(func() {
+					_, _ = namespaceNattingTable.NatNamespace("homeNamespace", true)
+					_ = cacheManager.AddHomeNamespace("homeNamespace")
+					_ = cacheManager.AddForeignNamespace("homeNamespace-natted")
+
+					homePod = &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{Name: "homePod", Namespace: "homeNamespace"},
+						Status:     corev1.PodStatus{Phase: corev1.PodPending},
+					}
+					foreignPod = &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "foreignPod",
+							Namespace: "homeNamespace-natted",
+							Labels:    map[string]string{virtualKubelet.ReflectedpodKey: "homePod"},
+						},
+						Status: corev1.PodStatus{Phase: corev1.PodRunning, Message: "testing"},
+					}
+					cacheManager.AddHomeEntry("homeNamespace", apimgmt.Pods, homePod)
+					cacheManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, foreignPod)
+
+					pushed = nil
+					repaired = repairedStatuses()
+					genericReflector.SetInforming(func(obj interface{}) {
+						pushed = append(pushed, obj.(*corev1.Pod))
+					})
+				})
+
+				It("reflects again the diverged status", func() {
+					reflector.Resync("homeNamespace")
+					Expect(pushed).To(HaveLen(1))
+					Expect(pushed[0].Name).To(Equal(homePod.Name))
+					Expect(pushed[0].Status).To(Equal(foreignPod.Status))
+					Expect(repairedStatuses() - repaired).To(BeNumerically("==", 1))
+				})
+
+				It("ignores the pods in sync", func() {
+					homePod.Status = foreignPod.Status
+					reflector.Resync("homeNamespace")
+					Expect(pushed).To(BeEmpty())
+					Expect(repairedStatuses() - repaired).To(BeZero())
+				})
+
+				It("ignores the pods whose home pod is not cached", func() {
+					delete(cacheManager.HomeCache["homeNamespace"][apimgmt.Pods], homePod.Name)
+					reflector.Resync("homeNamespace")
+					Expect(pushed).To(BeEmpty())
+				})
+			})
+
+			Context("shared replicas", func() {
+				var (
+					foreignClient *fake.Clientset
+					replicas      []*corev1.Pod
+				)
+
+				newHomePod := func(name string, created time.Time) *corev1.Pod {
+					controller := true
+					return &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:              name,
+							Namespace:         "homeNamespace",
+							CreationTimestamp: metav1.NewTime(created),
+							OwnerReferences: []metav1.OwnerReference{{
+								Kind: "ReplicaSet", Name: "frontend-5d4f8", Controller: &controller,
+							}},
+						},
+						Spec: corev1.PodSpec{NodeName: forge.LiqoNodeName()},
+					}
+				}
+
+				BeforeEach(func() {
+					_, _ = namespaceNattingTable.NatNamespace("homeNamespace", true)
+					_ = cacheManager.AddHomeNamespace("homeNamespace")
+					_ = cacheManager.AddForeignNamespace("homeNamespace-natted")
+
+					now := time.Now()
+					cacheManager.AddHomeEntry("homeNamespace", apimgmt.Pods, newHomePod("frontend-5d4f8-bbbbb", now))
+					cacheManager.AddHomeEntry("homeNamespace", apimgmt.Pods, newHomePod("frontend-5d4f8-aaaaa", now.Add(-time.Minute)))
+
+					replicas = nil
+					foreignClient = fake.NewSimpleClientset()
+					for _, name := range []string{"frontend-5d4f8-xxxxx", "frontend-5d4f8-yyyyy"} {
+						replica := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+							Name:      name,
+							Namespace: "homeNamespace-natted",
+							Labels:    map[string]string{virtualKubelet.ReflectedReplicaSetKey: "frontend-5d4f8"},
+						}}
+						_, _ = foreignClient.CoreV1().Pods("homeNamespace-natted").Create(context.TODO(), replica, metav1.CreateOptions{})
+						cacheManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, replica)
+						replicas = append(replicas, replica)
+					}
+					genericReflector.ForeignClient = foreignClient
+				})
+
+				assignedTo := func(replica *corev1.Pod) string {
+					updated, err := foreignClient.CoreV1().Pods("homeNamespace-natted").Get(context.TODO(), replica.Name, metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					return updated.Labels[virtualKubelet.ReflectedpodKey]
+				}
+
+				It("assigns each replica to a different home pod, the oldest first", func() {
+					ret, _ := reflector.PreProcessAdd(replicas[0])
+					Expect(ret).To(BeNil())
+					Expect(assignedTo(replicas[0])).To(Equal("frontend-5d4f8-aaaaa"))
+
+					// the first assignment is not yet observed in the cache
+					_, _ = reflector.PreProcessAdd(replicas[1])
+					Expect(assignedTo(replicas[1])).To(Equal("frontend-5d4f8-bbbbb"))
+				})
+
+				It("reassigns the home pod of a deleted replica", func() {
+					assigned := replicas[0].DeepCopy()
+					assigned.Labels[virtualKubelet.ReflectedpodKey] = "frontend-5d4f8-aaaaa"
+					cacheManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, assigned)
+					assignedB := replicas[1].DeepCopy()
+					assignedB.Labels[virtualKubelet.ReflectedpodKey] = "frontend-5d4f8-bbbbb"
+					cacheManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, assignedB)
+
+					replacement := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+						Name:      "frontend-5d4f8-zzzzz",
+						Namespace: "homeNamespace-natted",
+						Labels:    map[string]string{virtualKubelet.ReflectedReplicaSetKey: "frontend-5d4f8"},
+					}}
+					_, _ = foreignClient.CoreV1().Pods("homeNamespace-natted").Create(context.TODO(), replacement, metav1.CreateOptions{})
+					cacheManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, replacement)
+					_, _ = reflector.PreProcessAdd(replacement)
+					Expect(assignedTo(replacement)).To(BeEmpty())
+
+					delete(cacheManager.ForeignCache["homeNamespace-natted"][apimgmt.Pods], assigned.Name)
+					_, _ = reflector.PreProcessDelete(assigned)
+					Expect(assignedTo(replacement)).To(Equal("frontend-5d4f8-aaaaa"))
+				})
 			})
 		})
 	})
@@ -8,10 +8,11 @@ import (
 )
 
 var ReflectorBuilders = map[apimgmt.ApiType]func(reflector ri.APIReflector, opts map[options.OptionKey]options.Option) ri.OutgoingAPIReflector{
-	apimgmt.Configmaps:     configmapsReflectorBuilder,
-	apimgmt.EndpointSlices: endpointslicesReflectorBuilder,
-	apimgmt.Secrets:        secretsReflectorBuilder,
-	apimgmt.Services:       servicesReflectorBuilder,
+	apimgmt.Configmaps:             configmapsReflectorBuilder,
+	apimgmt.EndpointSlices:         endpointslicesReflectorBuilder,
+	apimgmt.PersistentVolumeClaims: persistentVolumeClaimsReflectorBuilder,
+	apimgmt.Secrets:                secretsReflectorBuilder,
+	apimgmt.Services:               servicesReflectorBuilder,
 }
 
 func configmapsReflectorBuilder(reflector ri.APIReflector, _ map[options.OptionKey]options.Option) ri.OutgoingAPIReflector {
@@ -26,6 +27,13 @@ func endpointslicesReflectorBuilder(reflector ri.APIReflector, opts map[options.
 	}
 }
 
+func persistentVolumeClaimsReflectorBuilder(reflector ri.APIReflector, opts map[options.OptionKey]options.Option) ri.OutgoingAPIReflector {
+	return &PersistentVolumeClaimsReflector{
+		APIReflector:        reflector,
+		StorageClassMapping: opts[types.StorageClassMapping],
+	}
+}
+
 func secretsReflectorBuilder(reflector ri.APIReflector, _ map[options.OptionKey]options.Option) ri.OutgoingAPIReflector {
 	return &SecretsReflector{APIReflector: reflector}
 }
@@ -0,0 +1,287 @@
+package outgoing
+
+import (
+	"context"
+	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	ri "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors/reflectorsInterfaces"
+	vkContext "github.com/liqotech/liqo/pkg/virtualKubelet/context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/options"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/options/types"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+)
+
+// selectedNodeAnnotation is set by the scheduler on the claims whose binding is delayed until the first consumer
+// is scheduled, and it holds the name of the node selected for that consumer
+const selectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+
+// PersistentVolumeClaimsReflector is the outgoing reflector in charge of creating in the foreign cluster the
+// PersistentVolumeClaims requested by the pods offloaded through the virtual node, translating their StorageClass
+// according to the configured mapping
+type PersistentVolumeClaimsReflector struct {
+	ri.APIReflector
+
+	StorageClassMapping options.ReadOnlyOption
+}
+
+func (r *PersistentVolumeClaimsReflector) SetSpecializedPreProcessingHandlers() {
+	r.SetPreProcessingHandlers(ri.PreProcessingHandlers{
+		IsAllowed:  r.isAllowed,
+		AddFunc:    r.PreAdd,
+		UpdateFunc: r.PreUpdate,
+		DeleteFunc: r.PreDelete})
+}
+
+func (r *PersistentVolumeClaimsReflector) HandleEvent(e interface{}) {
+	event := e.(watch.Event)
+	pvc, ok := event.Object.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		klog.Error("OUTGOING REFLECTION: cannot cast object to persistentVolumeClaim")
+		return
+	}
+	klog.V(3).Infof("OUTGOING REFLECTION: received %v for persistentVolumeClaim %v/%v", event.Type, pvc.Namespace, pvc.Name)
+
+	switch event.Type {
+	case watch.Added:
+		_, err := r.GetForeignClient().CoreV1().PersistentVolumeClaims(pvc.Namespace).Create(context.TODO(), pvc, metav1.CreateOptions{})
+		if kerrors.IsAlreadyExists(err) {
+			klog.V(3).Infof("OUTGOING REFLECTION: The remote persistentVolumeClaim %v/%v has not been created because already existing", pvc.Namespace, pvc.Name)
+			break
+		}
+		if err != nil {
+			klog.Errorf("OUTGOING REFLECTION: Error while creating the remote persistentVolumeClaim %v/%v - ERR: %v", pvc.Namespace, pvc.Name, err)
+		} else {
+			klog.V(3).Infof("OUTGOING REFLECTION: remote persistentVolumeClaim %v/%v correctly created", pvc.Namespace, pvc.Name)
+		}
+
+	case watch.Modified:
+		if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			_, newErr := r.GetForeignClient().CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(context.TODO(), pvc, metav1.UpdateOptions{})
+			return newErr
+		}); err != nil {
+			klog.Errorf("OUTGOING REFLECTION: Error while updating the remote persistentVolumeClaim %v/%v - ERR: %v", pvc.Namespace, pvc.Name, err)
+		} else {
+			klog.V(3).Infof("OUTGOING REFLECTION: remote persistentVolumeClaim %v/%v correctly updated", pvc.Namespace, pvc.Name)
+		}
+
+	case watch.Deleted:
+		if err := r.GetForeignClient().CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(context.TODO(), pvc.Name, metav1.DeleteOptions{}); err != nil {
+			klog.Errorf("OUTGOING REFLECTION: Error while deleting the remote persistentVolumeClaim %v/%v - ERR: %v", pvc.Namespace, pvc.Name, err)
+		} else {
+			klog.V(3).Infof("OUTGOING REFLECTION: remote persistentVolumeClaim %v/%v correctly deleted", pvc.Namespace, pvc.Name)
+		}
+
+		// the placeholder volume is retained, hence it has to be removed together with the claim it was bound to
+		pvName := forge.PlaceholderVolumeName(pvc)
+		err := r.GetHomeClient().CoreV1().PersistentVolumes().Delete(context.TODO(), pvName, metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			klog.Errorf("OUTGOING REFLECTION: Error while deleting the placeholder persistentVolume %v - ERR: %v", pvName, err)
+		}
+	}
+}
+
+func (r *PersistentVolumeClaimsReflector) PreAdd(obj interface{}) (interface{}, watch.EventType) {
+	pvcLocal := obj.(*corev1.PersistentVolumeClaim)
+	klog.V(3).Infof("PreAdd routine started for persistentVolumeClaim %v/%v", pvcLocal.Namespace, pvcLocal.Name)
+
+	nattedNs, err := r.NattingTable().NatNamespace(pvcLocal.Namespace, false)
+	if err != nil {
+		klog.Error(err)
+		return nil, watch.Added
+	}
+
+	pvcRemote := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pvcLocal.Name,
+			Namespace:   nattedNs,
+			Labels:      make(map[string]string),
+			Annotations: make(map[string]string),
+		},
+		// the volumeName, the selector and the dataSource refer to objects existing in the home cluster only,
+		// hence they are not reflected
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      pvcLocal.Spec.AccessModes,
+			Resources:        pvcLocal.Spec.Resources,
+			VolumeMode:       pvcLocal.Spec.VolumeMode,
+			StorageClassName: r.foreignStorageClass(pvcLocal.Spec.StorageClassName),
+		},
+	}
+	for k, v := range pvcLocal.Labels {
+		pvcRemote.Labels[k] = v
+	}
+	pvcRemote.Labels[forge.LiqoOutgoingKey] = forge.LiqoNodeName()
+
+	klog.V(3).Infof("PreAdd routine completed for persistentVolumeClaim %v/%v", pvcLocal.Namespace, pvcLocal.Name)
+	return pvcRemote, watch.Added
+}
+
+// PreUpdate propagates the labels and the requested resources, which are the only mutable fields of the spec,
+// in order to support the volume expansion
+func (r *PersistentVolumeClaimsReflector) PreUpdate(newObj, _ interface{}) (interface{}, watch.EventType) {
+	newHomePvc := newObj.(*corev1.PersistentVolumeClaim)
+
+	klog.V(3).Infof("PreUpdate routine started for persistentVolumeClaim %v/%v", newHomePvc.Namespace, newHomePvc.Name)
+
+	nattedNs, err := r.NattingTable().NatNamespace(newHomePvc.Namespace, false)
+	if err != nil {
+		err = errors.Wrapf(err, "persistentVolumeClaim %v/%v", nattedNs, newHomePvc.Name)
+		klog.Error(err)
+		return nil, watch.Modified
+	}
+
+	oldForeignObj, err := r.GetCacheManager().GetForeignNamespacedObject(apimgmt.PersistentVolumeClaims, nattedNs, newHomePvc.Name)
+	if err != nil {
+		// the claim has been requested by the virtual node after its creation, hence it is reflected now
+		klog.V(4).Infof("persistentVolumeClaim %v/%v not reflected yet, creating it", nattedNs, newHomePvc.Name)
+		return r.PreAdd(newObj)
+	}
+
+	newRemotePvc := oldForeignObj.(*corev1.PersistentVolumeClaim).DeepCopy()
+	if newRemotePvc.Labels == nil {
+		newRemotePvc.Labels = make(map[string]string)
+	}
+	for k, v := range newHomePvc.Labels {
+		newRemotePvc.Labels[k] = v
+	}
+	newRemotePvc.Labels[forge.LiqoOutgoingKey] = forge.LiqoNodeName()
+	newRemotePvc.Spec.Resources = newHomePvc.Spec.Resources
+
+	klog.V(3).Infof("PreUpdate routine completed for persistentVolumeClaim %v/%v", newRemotePvc.Namespace, newRemotePvc.Name)
+	return newRemotePvc, watch.Modified
+}
+
+func (r *PersistentVolumeClaimsReflector) PreDelete(obj interface{}) (interface{}, watch.EventType) {
+	pvcLocal := obj.(*corev1.PersistentVolumeClaim).DeepCopy()
+	klog.V(3).Infof("PreDelete routine started for persistentVolumeClaim %v/%v", pvcLocal.Namespace, pvcLocal.Name)
+
+	nattedNs, err := r.NattingTable().NatNamespace(pvcLocal.Namespace, false)
+	if err != nil {
+		klog.Error(err)
+		return nil, watch.Deleted
+	}
+	pvcLocal.Namespace = nattedNs
+
+	klog.V(3).Infof("PreDelete routine completed for persistentVolumeClaim %v/%v", pvcLocal.Namespace, pvcLocal.Name)
+	return pvcLocal, watch.Deleted
+}
+
+func (r *PersistentVolumeClaimsReflector) CleanupNamespace(localNamespace string) {
+	foreignNamespace, err := r.NattingTable().NatNamespace(localNamespace, false)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	objects, err := r.GetCacheManager().ListForeignNamespacedObject(apimgmt.PersistentVolumeClaims, foreignNamespace)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	retriable := func(err error) bool {
+		switch kerrors.ReasonForError(err) {
+		case metav1.StatusReasonNotFound:
+			return false
+		default:
+			klog.Warningf("retrying while deleting persistentVolumeClaim because of- ERR; %v", err)
+			return true
+		}
+	}
+	for _, obj := range objects {
+		pvc := obj.(*corev1.PersistentVolumeClaim)
+		if err := retry.OnError(retry.DefaultBackoff, retriable, func() error {
+			return r.GetForeignClient().CoreV1().PersistentVolumeClaims(foreignNamespace).Delete(context.TODO(), pvc.Name, metav1.DeleteOptions{})
+		}); err != nil {
+			klog.Errorf("Error while deleting remote persistentVolumeClaim %v/%v", pvc.Namespace, pvc.Name)
+		}
+	}
+}
+
+// foreignStorageClass translates the home StorageClass into the foreign one. If no mapping is configured
+// for the given class, nil is returned, so that the foreign default StorageClass is used, while the empty class,
+// which disables the dynamic provisioning, is kept as it is
+func (r *PersistentVolumeClaimsReflector) foreignStorageClass(homeStorageClass *string) *string {
+	if homeStorageClass == nil {
+		return nil
+	}
+	if *homeStorageClass == "" {
+		foreignStorageClass := ""
+		return &foreignStorageClass
+	}
+	if r.StorageClassMapping == nil {
+		return nil
+	}
+
+	foreignStorageClass, ok := types.ParseStorageClassMapping(r.StorageClassMapping.Value())[*homeStorageClass]
+	if !ok {
+		return nil
+	}
+	return &foreignStorageClass
+}
+
+// isAllowed checks that the persistentVolumeClaim has been already reflected, or that it is requested by the
+// virtual node. The claims bound to home volumes are not reflected, since their data are not available remotely.
+func (r *PersistentVolumeClaimsReflector) isAllowed(ctx context.Context, obj interface{}) bool {
+	pvc, ok := obj.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		klog.Error("cannot convert obj to persistentVolumeClaim")
+		return false
+	}
+	// if this annotation is set, this persistentVolumeClaim will not be reflected to the remote cluster
+	if val, ok := pvc.Annotations["liqo.io/not-reflect"]; ok && val == "true" {
+		return false
+	}
+
+	nattedNs, err := r.NattingTable().NatNamespace(pvc.Namespace, false)
+	if err != nil {
+		klog.Error(err)
+		return false
+	}
+	if _, err := r.GetCacheManager().GetForeignNamespacedObject(apimgmt.PersistentVolumeClaims, nattedNs, pvc.Name); err == nil {
+		return true
+	}
+	if value, ok := vkContext.IncomingMethod(ctx); ok && value == vkContext.IncomingDeleted {
+		return false
+	}
+
+	if pvc.Spec.VolumeName != "" && pvc.Spec.VolumeName != forge.PlaceholderVolumeName(pvc) {
+		return false
+	}
+	return r.isRequestedByVirtualNode(pvc)
+}
+
+// isRequestedByVirtualNode checks whether the virtual node has been selected to bind the claim, or it runs a pod
+// mounting it
+func (r *PersistentVolumeClaimsReflector) isRequestedByVirtualNode(pvc *corev1.PersistentVolumeClaim) bool {
+	nodeName := forge.LiqoNodeName()
+	if nodeName == "" {
+		return false
+	}
+	if pvc.Annotations[selectedNodeAnnotation] == nodeName {
+		return true
+	}
+
+	pods, err := r.GetCacheManager().ListHomeNamespacedObject(apimgmt.Pods, pvc.Namespace)
+	if err != nil {
+		klog.Error(err)
+		return false
+	}
+	for _, obj := range pods {
+		pod := obj.(*corev1.Pod)
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		for i := range pod.Spec.Volumes {
+			if claim := pod.Spec.Volumes[i].PersistentVolumeClaim; claim != nil && claim.ClaimName == pvc.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/liqotech/liqo/pkg/virtualKubelet/storage"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"time"
 )
 
 type ReflectionType int
@@ -46,6 +47,13 @@ type SpecializedAPIReflector interface {
 	CleanupNamespace(namespace string)
 }
 
+// ResyncingAPIReflector is implemented by the reflectors periodically reconciling the objects reflected in a namespace,
+// to recover from the events dropped or failed.
+type ResyncingAPIReflector interface {
+	ResyncPeriod() time.Duration
+	Resync(namespace string)
+}
+
 type OutgoingAPIReflector interface {
 	APIReflector
 	SpecializedAPIReflector
@@ -9,4 +9,75 @@ const (
 	AdvertisementPrefix     = "advertisement-"
 	ReflectedpodKey         = "virtualkubelet.liqo.io/source-pod"
 	HomePodFinalizer        = "virtual-kubelet.liqo.io/provider"
+	RemoteClusterIdLabel    = "virtualkubelet.liqo.io/remote-cluster-id"
+	RemoteZonesAnnotation   = "virtualkubelet.liqo.io/remote-zones"
+	ReflectedDaemonSetKey   = "virtualkubelet.liqo.io/source-daemonset-pod"
+	ReflectedReplicaSetKey  = "virtualkubelet.liqo.io/source-replicaset"
 )
+
+// The topology labels of the virtual node, derived from the zones of the foreign cluster. The virtual node of a foreign
+// cluster spanning a single zone belongs to that zone; otherwise, the foreign cluster is a zone of its own.
+const (
+	// RemoteZoneLabelPrefix prefixes the labels, set to "true", naming each zone of the foreign cluster
+	// (e.g. zone.topology.liqo.io/eu-west-1a), so that node affinities can require the zones it spans.
+	RemoteZoneLabelPrefix = "zone.topology.liqo.io/"
+	// MultiZoneClusterPrefix prefixes the foreign cluster ID in the topology.kubernetes.io/zone label of the virtual
+	// nodes of the foreign clusters spanning several zones.
+	MultiZoneClusterPrefix = "liqo-"
+)
+
+// DaemonSetOffloadingAnnotation is the annotation to be set on a DaemonSet for its pods to be offloaded to the
+// foreign clusters, with one of the following modes. The pods of the DaemonSets without it are rejected.
+const DaemonSetOffloadingAnnotation = "liqo.io/daemonset-offloading"
+
+const (
+	// DaemonSetOffloadingVirtualNode runs a single instance of the pod for each virtual node.
+	DaemonSetOffloadingVirtualNode = "virtual-node"
+	// DaemonSetOffloadingRemoteNodes runs an instance of the pod on every node of the foreign cluster.
+	DaemonSetOffloadingRemoteNodes = "remote-nodes"
+)
+
+// The annotations to be set on a pod to constrain the scheduling of its offloaded instance within the foreign cluster,
+// since the scheduling constraints of the pod only apply to the home cluster.
+const (
+	// RemoteNodeSelectorAnnotation is the node selector of the foreign pod, as a list of comma-separated key=value pairs.
+	RemoteNodeSelectorAnnotation = "liqo.io/remote-node-selector"
+	// RemoteAffinityAnnotation is the affinity of the foreign pod, in JSON format.
+	RemoteAffinityAnnotation = "liqo.io/remote-affinity"
+	// RemoteTolerationsAnnotation is the list of the additional tolerations of the foreign pod, in JSON format.
+	RemoteTolerationsAnnotation = "liqo.io/remote-tolerations"
+	// RemoteTopologySpreadConstraintsAnnotation is the list of the topology spread constraints of the foreign pod,
+	// in JSON format.
+	RemoteTopologySpreadConstraintsAnnotation = "liqo.io/remote-topology-spread-constraints"
+)
+
+// ServiceAccountTokenAnnotation is the annotation to be set on a pod to select the ServiceAccount credentials provided
+// to its offloaded instance, according to one of the following policies (by default, ServiceAccountTokenRemote).
+const ServiceAccountTokenAnnotation = "liqo.io/service-account-token"
+
+const (
+	// ServiceAccountTokenRemote provides the credentials of the ServiceAccount with the same name in the foreign
+	// namespace, valid for the foreign cluster. The ServiceAccount is created if not existing.
+	ServiceAccountTokenRemote = "remote"
+	// ServiceAccountTokenHome provides the credentials of the ServiceAccount of the pod, valid for the home cluster.
+	ServiceAccountTokenHome = "home"
+	// ServiceAccountTokenNone provides no ServiceAccount credentials.
+	ServiceAccountTokenNone = "none"
+)
+
+// OffloadingModeAnnotation is the annotation to be set on a home namespace to select how its pods are offloaded,
+// according to one of the following modes (by default, OffloadingModePod).
+const OffloadingModeAnnotation = "liqo.io/offloading-mode"
+
+const (
+	// OffloadingModePod offloads each pod as a foreign ReplicaSet with a single replica.
+	OffloadingModePod = "pod"
+	// OffloadingModeController offloads the pods of each home ReplicaSet (e.g. the ones of a Deployment) as the
+	// replicas of a single foreign ReplicaSet, while the other pods are offloaded as in OffloadingModePod. The
+	// Deployments, StatefulSets and Jobs themselves are never reflected.
+	OffloadingModeController = "controller"
+)
+
+// NamespaceEnabledLabel is the label enabling the offloading of a home namespace without a NamespaceOffloading, with
+// the default policy, when set to "true".
+const NamespaceEnabledLabel = "liqo.io/enabled"
@@ -8,6 +8,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"reflect"
 )
@@ -49,6 +50,46 @@ func ReplicasetFromPod(pod *corev1.Pod) *appsv1.ReplicaSet {
 	return forger.replicasetFromPod(pod)
 }
 
+// SharedReplicasetFromPod returns the foreign replicaset whose replicas are the offloaded pods of the home replicaset,
+// with the pod as template.
+func SharedReplicasetFromPod(pod *corev1.Pod, homeReplicasetName string, replicas int32) *appsv1.ReplicaSet {
+	return forger.sharedReplicasetFromPod(pod, homeReplicasetName, replicas)
+}
+
+// DaemonsetFromPod returns the foreign daemonset running an instance of the pod on each foreign node.
+func DaemonsetFromPod(pod *corev1.Pod) *appsv1.DaemonSet {
+	return forger.daemonsetFromPod(pod)
+}
+
+// DaemonsetStatusForeignToHome returns a copy of the home pod whose status summarizes the one of the foreign daemonset.
+func DaemonsetStatusForeignToHome(foreignDaemonSet *appsv1.DaemonSet, homePod *corev1.Pod) *corev1.Pod {
+	return forger.daemonsetStatusForeignToHome(foreignDaemonSet, homePod)
+}
+
+// ReplicasetUpdateFromPod returns a copy of the foreign replicaset updated with the mutable fields of the home pod.
+func ReplicasetUpdateFromPod(homePod *corev1.Pod, replicaset *appsv1.ReplicaSet) *appsv1.ReplicaSet {
+	return forger.replicasetUpdateFromPod(homePod, replicaset)
+}
+
+// PodUpdateHomeToForeign returns a copy of the foreign pod updated with the mutable fields of the home pod.
+// reflectedMeta contains the labels and annotations previously reflected from the home pod, while the
+// protectedLabels are left untouched.
+func PodUpdateHomeToForeign(homePod, foreignPod *corev1.Pod, reflectedMeta *metav1.ObjectMeta, protectedLabels map[string]string) *corev1.Pod {
+	return forger.podUpdateHomeToForeign(homePod, foreignPod, reflectedMeta, protectedLabels)
+}
+
+// PodHomeViewFromForeign returns a copy of the home pod having the mutable fields of the foreign one, i.e. the home pod
+// as currently reflected in the foreign cluster.
+func PodHomeViewFromForeign(homePod, foreignPod *corev1.Pod) *corev1.Pod {
+	return forger.podHomeViewFromForeign(homePod, foreignPod)
+}
+
+// PodMetaForeignToHome returns the changes to apply to the labels and annotations of the home pod to reflect the
+// changes of the foreign one to the keys of the home pod, a nil value meaning that the key has to be removed.
+func PodMetaForeignToHome(homePod, oldForeignPod, newForeignPod *corev1.Pod) (labels, annotations map[string]*string) {
+	return forger.podMetaForeignToHome(homePod, oldForeignPod, newForeignPod)
+}
+
 func ForeignReplicasetDeleted(pod *corev1.Pod) *corev1.Pod {
 	return forger.setPodToBeDeleted(pod)
 }
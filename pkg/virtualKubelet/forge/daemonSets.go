@@ -0,0 +1,87 @@
+package forge
+
+import (
+	"fmt"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// daemonsetFromPod forges the foreign daemonset running an instance of the foreign pod on every foreign node.
+func (f *apiForger) daemonsetFromPod(pod *corev1.Pod) *appsv1.DaemonSet {
+	labels := make(map[string]string, len(pod.Labels)+1)
+	for k, v := range pod.Labels {
+		labels[k] = v
+	}
+	labels[virtualKubelet.ReflectedDaemonSetKey] = pod.Name
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pod.Name,
+			Namespace:   pod.Namespace,
+			Labels:      labels,
+			Annotations: pod.Annotations,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: pod.Annotations,
+				},
+				Spec: pod.Spec,
+			},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+		},
+	}
+}
+
+// daemonsetStatusForeignToHome sets the status of the home pod according to the one of the foreign daemonset running
+// its instances: the pod is running as soon as an instance is ready, and ready when all of them are.
+func (f *apiForger) daemonsetStatusForeignToHome(foreignDaemonSet *appsv1.DaemonSet, homePod *corev1.Pod) *corev1.Pod {
+	pod := homePod.DeepCopy()
+	status := foreignDaemonSet.Status
+
+	running := status.NumberReady > 0
+	ready := running && status.NumberReady == status.DesiredNumberScheduled
+	startTime := foreignDaemonSet.CreationTimestamp
+
+	pod.Status.Phase = corev1.PodPending
+	if running {
+		pod.Status.Phase = corev1.PodRunning
+	}
+	pod.Status.StartTime = &startTime
+	pod.Status.Message = fmt.Sprintf("%d/%d remote instances ready", status.NumberReady, status.DesiredNumberScheduled)
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		{Type: corev1.PodInitialized, Status: corev1.ConditionTrue},
+		{Type: corev1.ContainersReady, Status: conditionStatus(ready)},
+		{Type: corev1.PodReady, Status: conditionStatus(ready)},
+	}
+
+	pod.Status.ContainerStatuses = make([]corev1.ContainerStatus, 0, len(pod.Spec.Containers))
+	for i := range pod.Spec.Containers {
+		containerStatus := corev1.ContainerStatus{
+			Name:  pod.Spec.Containers[i].Name,
+			Image: pod.Spec.Containers[i].Image,
+			Ready: ready,
+		}
+		if running {
+			containerStatus.State.Running = &corev1.ContainerStateRunning{StartedAt: startTime}
+		} else {
+			containerStatus.State.Waiting = &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}
+		}
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, containerStatus)
+	}
+
+	return pod
+}
+
+func conditionStatus(value bool) corev1.ConditionStatus {
+	if value {
+		return corev1.ConditionTrue
+	}
+	return corev1.ConditionFalse
+}
@@ -0,0 +1,80 @@
+package forge
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+const (
+	// PlaceholderVolumeDriver is the CSI driver set in the placeholder volumes, which are never mounted in the
+	// home cluster since they can be consumed by the pods running on the virtual node only
+	PlaceholderVolumeDriver = "virtualkubelet.liqo.io"
+
+	hostnameLabel = "kubernetes.io/hostname"
+)
+
+// PlaceholderVolumeName returns the name of the home PersistentVolume standing for the foreign volume
+// bound to the reflection of the given home claim.
+func PlaceholderVolumeName(homePvc *corev1.PersistentVolumeClaim) string {
+	return strings.Join([]string{"liqo", string(homePvc.UID)}, "-")
+}
+
+// PlaceholderVolume returns the home PersistentVolume, pre-bound to the home claim, standing for the volume
+// bound to the foreign one, so that the home control plane binds the claim on its own.
+func PlaceholderVolume(homePvc, foreignPvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolume {
+	return forger.placeholderVolume(homePvc, foreignPvc)
+}
+
+func (f *apiForger) placeholderVolume(homePvc, foreignPvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolume {
+	// the binding requires the storage class of the volume to match the one of the claim
+	var storageClass string
+	if homePvc.Spec.StorageClassName != nil {
+		storageClass = *homePvc.Spec.StorageClassName
+	}
+
+	accessModes := foreignPvc.Status.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = homePvc.Spec.AccessModes
+	}
+
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: PlaceholderVolumeName(homePvc),
+			Labels: map[string]string{
+				LiqoIncomingKey: LiqoNodeName(),
+			},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:                      foreignPvc.Status.Capacity,
+			AccessModes:                   accessModes,
+			VolumeMode:                    homePvc.Spec.VolumeMode,
+			StorageClassName:              storageClass,
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			ClaimRef: &corev1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "PersistentVolumeClaim",
+				Namespace:  homePvc.Namespace,
+				Name:       homePvc.Name,
+				UID:        homePvc.UID,
+			},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:       PlaceholderVolumeDriver,
+					VolumeHandle: foreignPvc.Spec.VolumeName,
+				},
+			},
+			NodeAffinity: &corev1.VolumeNodeAffinity{
+				Required: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{{
+							Key:      hostnameLabel,
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   []string{LiqoNodeName()},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
@@ -10,6 +10,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog"
+	"reflect"
 	"strings"
 )
 
@@ -110,7 +111,12 @@ func (f *apiForger) podHomeToForeign(homeObj, foreignObj runtime.Object, reflect
 
 	if isNewObject {
 		foreignPod.Spec = f.forgePodSpec(homePod.Spec)
-		foreignPod.Spec.Affinity = forgeAffinity()
+		if err = forgeServiceAccount(homePod, &foreignPod.Spec); err != nil {
+			return nil, err
+		}
+		if err = forgeSchedulingConstraints(homePod, &foreignPod.Spec); err != nil {
+			return nil, err
+		}
 	}
 
 	return foreignPod, nil
@@ -158,11 +164,9 @@ func translateContainer(container corev1.Container, volumes []corev1.VolumeMount
 func forgeVolumes(volumesIn []corev1.Volume) []corev1.Volume {
 	volumesOut := make([]corev1.Volume, 0)
 	for _, v := range volumesIn {
-		if v.ConfigMap != nil || v.EmptyDir != nil || v.DownwardAPI != nil {
-			volumesOut = append(volumesOut, v)
-		}
-		// copy all volumes of type Secret except for the default token
-		if v.Secret != nil && !strings.Contains(v.Secret.SecretName, "default-token") {
+		// the ServiceAccount tokens are handled by forgeServiceAccount
+		if v.ConfigMap != nil || v.EmptyDir != nil || v.DownwardAPI != nil || v.PersistentVolumeClaim != nil ||
+			v.Secret != nil || v.Projected != nil {
 			volumesOut = append(volumesOut, v)
 		}
 	}
@@ -228,3 +232,146 @@ func forgeAffinity() *corev1.Affinity {
 		},
 	}
 }
+
+// podHomeViewFromForeign returns a copy of the home pod whose mutable fields (labels, annotations, container images,
+// activeDeadlineSeconds and tolerations) are the ones currently set on the foreign pod. Only the labels and annotations
+// of the home pod are considered, as the ones added by the foreign cluster (e.g. by its CNI) are never reflected.
+func (f *apiForger) podHomeViewFromForeign(homePod, foreignPod *corev1.Pod) *corev1.Pod {
+	pod := homePod.DeepCopy()
+
+	pod.Labels = foreignViewOfHomeMap(homePod.Labels, foreignPod.Labels)
+	pod.Annotations = foreignViewOfHomeMap(homePod.Annotations, foreignPod.Annotations)
+
+	setContainerImages(pod.Spec.Containers, foreignPod.Spec.Containers)
+	setContainerImages(pod.Spec.InitContainers, foreignPod.Spec.InitContainers)
+	pod.Spec.ActiveDeadlineSeconds = foreignPod.Spec.ActiveDeadlineSeconds
+	// the foreign pod has the tolerations added by the foreign cluster, hence it is up to date if it tolerates
+	// at least the taints tolerated by the home pod
+	if len(missingTolerations(foreignPod.Spec.Tolerations, homePod.Spec.Tolerations)) != 0 {
+		pod.Spec.Tolerations = foreignPod.Spec.Tolerations
+	}
+
+	return pod
+}
+
+// podUpdateHomeToForeign returns a copy of the foreign pod with the mutable fields of the home pod. The labels and
+// annotations previously reflected from the home pod (reflectedMeta) and no longer set are removed, while the ones
+// set by the foreign cluster are kept; the protected labels (i.e. the ones selecting the pod) are never changed.
+func (f *apiForger) podUpdateHomeToForeign(homePod, foreignPod *corev1.Pod, reflectedMeta *metav1.ObjectMeta,
+	protectedLabels map[string]string) *corev1.Pod {
+	pod := foreignPod.DeepCopy()
+
+	pod.Labels = mergeReflectedMap(pod.Labels, homePod.Labels, reflectedMeta.Labels, protectedLabels)
+	pod.Annotations = mergeReflectedMap(pod.Annotations, homePod.Annotations, reflectedMeta.Annotations, nil)
+	updatePodSpec(&pod.Spec, &homePod.Spec)
+
+	return pod
+}
+
+// podMetaForeignToHome returns the changes to the labels and annotations of the home pod, applying the ones of the
+// foreign pod from oldForeignPod to newForeignPod: a nil value means that the key has to be removed. Only the keys of
+// the home pod are changed, as the ones added by the foreign cluster (e.g. by its CNI) are never reflected.
+func (f *apiForger) podMetaForeignToHome(homePod, oldForeignPod, newForeignPod *corev1.Pod) (labels, annotations map[string]*string) {
+	return diffForeignMap(homePod.Labels, oldForeignPod.Labels, newForeignPod.Labels),
+		diffForeignMap(homePod.Annotations, oldForeignPod.Annotations, newForeignPod.Annotations)
+}
+
+// isLiqoPodLabel returns whether the label is set by liqo on the foreign pods, hence it is not reflected.
+func isLiqoPodLabel(key string) bool {
+	return key == LiqoOutgoingKey || key == virtualKubelet.ReflectedpodKey || key == virtualKubelet.ReflectedReplicaSetKey
+}
+
+// mergeReflectedMap sets in current the values of home, removing the keys only present in reflected.
+// The keys in protected and the liqo labels are left untouched.
+func mergeReflectedMap(current, home, reflected, protected map[string]string) map[string]string {
+	out := make(map[string]string, len(current))
+	for k, v := range current {
+		out[k] = v
+	}
+	for k := range reflected {
+		if _, ok := home[k]; !ok && !isProtectedKey(k, protected) {
+			delete(out, k)
+		}
+	}
+	for k, v := range home {
+		if !isProtectedKey(k, protected) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func isProtectedKey(key string, protected map[string]string) bool {
+	_, ok := protected[key]
+	return ok || isLiqoPodLabel(key)
+}
+
+// foreignViewOfHomeMap returns the keys of home with the values they have in foreign, if any.
+func foreignViewOfHomeMap(home, foreign map[string]string) map[string]string {
+	view := make(map[string]string, len(home))
+	for k := range home {
+		if v, ok := foreign[k]; ok && !isLiqoPodLabel(k) {
+			view[k] = v
+		}
+	}
+	return view
+}
+
+// diffForeignMap returns the changes to apply to the keys of home to reflect their changes from oldForeign to newForeign.
+func diffForeignMap(home, oldForeign, newForeign map[string]string) map[string]*string {
+	changes := map[string]*string{}
+	for k, homeValue := range home {
+		if isLiqoPodLabel(k) {
+			continue
+		}
+		oldValue, inOld := oldForeign[k]
+		newValue, inNew := newForeign[k]
+		switch {
+		case inNew && newValue != homeValue && (!inOld || oldValue != newValue):
+			changes[k] = &newValue
+		case !inNew && inOld:
+			changes[k] = nil
+		}
+	}
+	return changes
+}
+
+// updatePodSpec sets in spec the fields of homeSpec that can be updated on a running pod: the container images,
+// the activeDeadlineSeconds and the tolerations, which can only be added.
+func updatePodSpec(spec, homeSpec *corev1.PodSpec) {
+	setContainerImages(spec.Containers, homeSpec.Containers)
+	setContainerImages(spec.InitContainers, homeSpec.InitContainers)
+	spec.ActiveDeadlineSeconds = homeSpec.ActiveDeadlineSeconds
+	spec.Tolerations = append(spec.Tolerations, missingTolerations(spec.Tolerations, homeSpec.Tolerations)...)
+}
+
+// setContainerImages sets the image of each container to the one of the source container with the same name.
+func setContainerImages(containers, source []corev1.Container) {
+	for i := range containers {
+		for j := range source {
+			if containers[i].Name == source[j].Name {
+				containers[i].Image = source[j].Image
+				break
+			}
+		}
+	}
+}
+
+// missingTolerations returns the tolerations in desired which are not in current.
+func missingTolerations(current, desired []corev1.Toleration) []corev1.Toleration {
+	var missing []corev1.Toleration
+	for i := range desired {
+		found := false
+		for j := range current {
+			if desired[i].MatchToleration(&current[j]) && desired[i].Value == current[j].Value &&
+				reflect.DeepEqual(desired[i].TolerationSeconds, current[j].TolerationSeconds) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, desired[i])
+		}
+	}
+	return missing
+}
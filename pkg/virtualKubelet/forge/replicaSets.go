@@ -39,3 +39,61 @@ func (f *apiForger) replicasetFromPod(pod *corev1.Pod) *appsv1.ReplicaSet {
 
 	return replicaset
 }
+
+// replicasetUpdateFromPod returns a copy of the replicaset whose pod template has the mutable fields of the home pod.
+// The labels selecting the pods are never changed, as the selector cannot be updated.
+func (f *apiForger) replicasetUpdateFromPod(homePod *corev1.Pod, replicaset *appsv1.ReplicaSet) *appsv1.ReplicaSet {
+	rs := replicaset.DeepCopy()
+
+	var protectedLabels map[string]string
+	if rs.Spec.Selector != nil {
+		protectedLabels = rs.Spec.Selector.MatchLabels
+	}
+	template := &rs.Spec.Template
+	reflected := template.ObjectMeta.DeepCopy()
+
+	template.Labels = mergeReflectedMap(template.Labels, homePod.Labels, reflected.Labels, protectedLabels)
+	template.Annotations = mergeReflectedMap(template.Annotations, homePod.Annotations, reflected.Annotations, nil)
+	rs.Labels = mergeReflectedMap(rs.Labels, homePod.Labels, reflected.Labels, protectedLabels)
+	rs.Annotations = mergeReflectedMap(rs.Annotations, homePod.Annotations, reflected.Annotations, nil)
+	updatePodSpec(&template.Spec, &homePod.Spec)
+
+	return rs
+}
+
+// sharedReplicasetFromPod forges the foreign replicaset whose replicas are the offloaded pods of the home replicaset.
+// The replicas are assigned to the home pods through the ReflectedpodKey label, hence it is not part of the selector.
+func (f *apiForger) sharedReplicasetFromPod(pod *corev1.Pod, homeReplicasetName string, replicas int32) *appsv1.ReplicaSet {
+	labels := make(map[string]string, len(pod.Labels)+1)
+	for k, v := range pod.Labels {
+		if k != virtualKubelet.ReflectedpodKey {
+			labels[k] = v
+		}
+	}
+	labels[virtualKubelet.ReflectedReplicaSetKey] = homeReplicasetName
+
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        homeReplicasetName,
+			Namespace:   pod.Namespace,
+			Labels:      labels,
+			Annotations: pod.Annotations,
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: pod.Annotations,
+				},
+				Spec: pod.Spec,
+			},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					virtualKubelet.ReflectedReplicaSetKey: homeReplicasetName,
+					LiqoOutgoingKey:                       labels[LiqoOutgoingKey],
+				},
+			},
+		},
+	}
+}
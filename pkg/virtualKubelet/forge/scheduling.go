@@ -0,0 +1,74 @@
+package forge
+
+import (
+	"encoding/json"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ErrInvalidSchedulingConstraints is the cause of the errors due to a malformed remote scheduling constraint.
+var ErrInvalidSchedulingConstraints = errors.New("invalid remote scheduling constraints")
+
+// forgeSchedulingConstraints sets in the foreign pod spec the scheduling constraints of the home pod aimed at the
+// foreign cluster, i.e. the ones set through the remote scheduling annotations, together with the tolerations of the
+// home pod. Whatever the constraints, the foreign pod is never scheduled on a virtual node.
+func forgeSchedulingConstraints(homePod *corev1.Pod, spec *corev1.PodSpec) error {
+	annotations := homePod.Annotations
+
+	spec.Tolerations = append([]corev1.Toleration(nil), homePod.Spec.Tolerations...)
+	var tolerations []corev1.Toleration
+	if err := unmarshalConstraint(annotations, virtualKubelet.RemoteTolerationsAnnotation, &tolerations); err != nil {
+		return err
+	}
+	spec.Tolerations = append(spec.Tolerations, missingTolerations(spec.Tolerations, tolerations)...)
+
+	if value, ok := annotations[virtualKubelet.RemoteNodeSelectorAnnotation]; ok {
+		nodeSelector, err := labels.ConvertSelectorToLabelsMap(value)
+		if err != nil {
+			return errors.Wrapf(ErrInvalidSchedulingConstraints, "annotation %s: %v", virtualKubelet.RemoteNodeSelectorAnnotation, err)
+		}
+		spec.NodeSelector = nodeSelector
+	}
+
+	var affinity corev1.Affinity
+	if err := unmarshalConstraint(annotations, virtualKubelet.RemoteAffinityAnnotation, &affinity); err != nil {
+		return err
+	}
+	spec.Affinity = excludeVirtualNodes(&affinity)
+
+	return unmarshalConstraint(annotations, virtualKubelet.RemoteTopologySpreadConstraintsAnnotation, &spec.TopologySpreadConstraints)
+}
+
+// unmarshalConstraint decodes the JSON value of the annotation, if set, into out.
+func unmarshalConstraint(annotations map[string]string, annotation string, out interface{}) error {
+	value, ok := annotations[annotation]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), out); err != nil {
+		return errors.Wrapf(ErrInvalidSchedulingConstraints, "annotation %s: %v", annotation, err)
+	}
+	return nil
+}
+
+// excludeVirtualNodes adds to the required node affinity the requirement excluding the virtual nodes. Since the node
+// selector terms are ORed, the requirement is added to each of them.
+func excludeVirtualNodes(affinity *corev1.Affinity) *corev1.Affinity {
+	virtualNodesExcluded := forgeAffinity().NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+
+	required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if required == nil || len(required.NodeSelectorTerms) == 0 {
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = virtualNodesExcluded
+		return affinity
+	}
+	for i := range required.NodeSelectorTerms {
+		required.NodeSelectorTerms[i].MatchExpressions = append(required.NodeSelectorTerms[i].MatchExpressions,
+			virtualNodesExcluded.NodeSelectorTerms[0].MatchExpressions...)
+	}
+	return affinity
+}
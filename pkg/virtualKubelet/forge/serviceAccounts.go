@@ -0,0 +1,229 @@
+package forge
+
+import (
+	"fmt"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+const (
+	// HomeTokenSecretCAKey is the key of the home token secret storing the CA of the home cluster.
+	HomeTokenSecretCAKey = "ca.crt"
+	// HomeTokenSecretNamespaceKey is the key of the home token secret storing the home namespace of the pod.
+	HomeTokenSecretNamespaceKey = "namespace"
+
+	// homeTokenSecretPrefix is the prefix of the name of the foreign secret storing the home tokens of a pod.
+	homeTokenSecretPrefix = "liqo-sa-token-"
+	// defaultTokenExpirationSeconds is the expiration of the projected tokens, if not specified.
+	defaultTokenExpirationSeconds = 3600
+	// rootCAConfigMapName is the name of the configmap publishing the CA of the cluster in each namespace.
+	rootCAConfigMapName = "kube-root-ca.crt"
+	// serviceAccountVolumePrefix is the prefix of the name of the projected volume injected by the ServiceAccount
+	// admission plugin.
+	serviceAccountVolumePrefix = "kube-api-access-"
+)
+
+// ErrInvalidServiceAccountTokenPolicy is the cause of the errors due to an invalid ServiceAccount token policy.
+var ErrInvalidServiceAccountTokenPolicy = errors.New("invalid service account token policy")
+
+// ServiceAccountTokenProjection is a token of the home ServiceAccount projected in a volume of an offloaded pod.
+type ServiceAccountTokenProjection struct {
+	// Key is the key of the token in the home token secret.
+	Key               string
+	Audience          string
+	ExpirationSeconds int64
+}
+
+// ServiceAccountTokenPolicy returns the ServiceAccount token policy of the pod.
+func ServiceAccountTokenPolicy(pod *corev1.Pod) (string, error) {
+	switch policy, ok := pod.Annotations[virtualKubelet.ServiceAccountTokenAnnotation]; {
+	case !ok:
+		return virtualKubelet.ServiceAccountTokenRemote, nil
+	case policy == virtualKubelet.ServiceAccountTokenRemote, policy == virtualKubelet.ServiceAccountTokenHome,
+		policy == virtualKubelet.ServiceAccountTokenNone:
+		return policy, nil
+	default:
+		return "", errors.Wrapf(ErrInvalidServiceAccountTokenPolicy, "annotation %s: unknown value %q",
+			virtualKubelet.ServiceAccountTokenAnnotation, policy)
+	}
+}
+
+// HomeTokenSecretName returns the name of the foreign secret storing the home tokens projected in the volumes of
+// the given home pod.
+func HomeTokenSecretName(homePodName string) string {
+	return homeTokenSecretPrefix + homePodName
+}
+
+// HomeServiceAccountTokens returns the tokens of the home ServiceAccount projected in the pod volumes.
+func HomeServiceAccountTokens(pod *corev1.Pod) []ServiceAccountTokenProjection {
+	var tokens []ServiceAccountTokenProjection
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Projected == nil {
+			continue
+		}
+		for i, source := range volume.Projected.Sources {
+			if source.ServiceAccountToken == nil {
+				continue
+			}
+			token := ServiceAccountTokenProjection{
+				Key:               homeTokenKey(volume.Name, i),
+				Audience:          source.ServiceAccountToken.Audience,
+				ExpirationSeconds: defaultTokenExpirationSeconds,
+			}
+			if source.ServiceAccountToken.ExpirationSeconds != nil {
+				token.ExpirationSeconds = *source.ServiceAccountToken.ExpirationSeconds
+			}
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func homeTokenKey(volumeName string, source int) string {
+	return fmt.Sprintf("%s.%d.token", volumeName, source)
+}
+
+// forgeServiceAccount sets in the foreign pod spec the ServiceAccount credentials selected by the token policy:
+//   - remote: the home ServiceAccount token volumes are dropped, and the ServiceAccount with the same name is used,
+//     whose token is mounted by the foreign cluster if it was mounted in the home pod;
+//   - home: the home tokens are projected from the home token secret, together with the CA and the namespace of the
+//     home cluster, while the legacy ServiceAccount token secrets are reflected to the foreign cluster as they are;
+//   - none: the ServiceAccount tokens are dropped.
+func forgeServiceAccount(homePod *corev1.Pod, spec *corev1.PodSpec) error {
+	policy, err := ServiceAccountTokenPolicy(homePod)
+	if err != nil {
+		return err
+	}
+
+	automounted := false
+	volumes := make([]corev1.Volume, 0, len(spec.Volumes))
+	for _, volume := range spec.Volumes {
+		switch {
+		case isServiceAccountSecretVolume(&volume, homePod.Spec.ServiceAccountName):
+			automounted = true
+			if policy == virtualKubelet.ServiceAccountTokenHome {
+				volumes = append(volumes, volume)
+			}
+
+		case volume.Projected != nil && hasServiceAccountTokenSource(volume.Projected):
+			injected := strings.HasPrefix(volume.Name, serviceAccountVolumePrefix)
+			automounted = automounted || injected
+			switch {
+			case policy == virtualKubelet.ServiceAccountTokenHome:
+				volumes = append(volumes, homeTokenVolume(homePod, volume))
+			case policy == virtualKubelet.ServiceAccountTokenRemote && !injected:
+				// the token is issued by the foreign kubelet for the foreign ServiceAccount
+				volumes = append(volumes, volume)
+			case policy == virtualKubelet.ServiceAccountTokenNone && !injected:
+				if volume = withoutServiceAccountTokens(volume); len(volume.Projected.Sources) > 0 {
+					volumes = append(volumes, volume)
+				}
+			}
+
+		default:
+			volumes = append(volumes, volume)
+		}
+	}
+
+	spec.Volumes = volumes
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].VolumeMounts = filterVolumeMounts(volumes, spec.InitContainers[i].VolumeMounts)
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = filterVolumeMounts(volumes, spec.Containers[i].VolumeMounts)
+	}
+
+	if policy == virtualKubelet.ServiceAccountTokenRemote {
+		spec.ServiceAccountName = homePod.Spec.ServiceAccountName
+	} else {
+		automounted = false
+	}
+	spec.AutomountServiceAccountToken = &automounted
+	return nil
+}
+
+// isServiceAccountSecretVolume returns whether the volume mounts a legacy token secret of the ServiceAccount.
+func isServiceAccountSecretVolume(volume *corev1.Volume, serviceAccountName string) bool {
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+	return volume.Secret != nil && strings.HasPrefix(volume.Secret.SecretName, serviceAccountName+"-token-")
+}
+
+func hasServiceAccountTokenSource(projected *corev1.ProjectedVolumeSource) bool {
+	for i := range projected.Sources {
+		if projected.Sources[i].ServiceAccountToken != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutServiceAccountTokens(volume corev1.Volume) corev1.Volume {
+	projected := volume.Projected.DeepCopy()
+	projected.Sources = nil
+	for _, source := range volume.Projected.Sources {
+		if source.ServiceAccountToken == nil {
+			projected.Sources = append(projected.Sources, source)
+		}
+	}
+	volume.Projected = projected
+	return volume
+}
+
+// homeTokenVolume replaces the sources of the projected volume depending on the home cluster (i.e. the tokens, the
+// CA and the namespace) with the corresponding keys of the home token secret.
+func homeTokenVolume(homePod *corev1.Pod, volume corev1.Volume) corev1.Volume {
+	secretName := HomeTokenSecretName(homePod.Name)
+	secretProjection := func(items ...corev1.KeyToPath) corev1.VolumeProjection {
+		return corev1.VolumeProjection{Secret: &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+			Items:                items,
+		}}
+	}
+
+	projected := volume.Projected.DeepCopy()
+	projected.Sources = nil
+	for i, source := range volume.Projected.Sources {
+		switch {
+		case source.ServiceAccountToken != nil:
+			projected.Sources = append(projected.Sources, secretProjection(corev1.KeyToPath{
+				Key:  homeTokenKey(volume.Name, i),
+				Path: source.ServiceAccountToken.Path,
+			}))
+
+		case source.ConfigMap != nil && source.ConfigMap.Name == rootCAConfigMapName:
+			items := source.ConfigMap.Items
+			if len(items) == 0 {
+				items = []corev1.KeyToPath{{Key: HomeTokenSecretCAKey, Path: HomeTokenSecretCAKey}}
+			}
+			projected.Sources = append(projected.Sources, secretProjection(items...))
+
+		case source.DownwardAPI != nil:
+			// the namespace of the foreign pod differs from the home one
+			var items []corev1.DownwardAPIVolumeFile
+			var namespaceItems []corev1.KeyToPath
+			for _, item := range source.DownwardAPI.Items {
+				if item.FieldRef != nil && item.FieldRef.FieldPath == "metadata.namespace" {
+					namespaceItems = append(namespaceItems, corev1.KeyToPath{Key: HomeTokenSecretNamespaceKey, Path: item.Path, Mode: item.Mode})
+				} else {
+					items = append(items, item)
+				}
+			}
+			if len(items) > 0 {
+				projected.Sources = append(projected.Sources, corev1.VolumeProjection{DownwardAPI: &corev1.DownwardAPIProjection{Items: items}})
+			}
+			if len(namespaceItems) > 0 {
+				projected.Sources = append(projected.Sources, secretProjection(namespaceItems...))
+			}
+
+		default:
+			projected.Sources = append(projected.Sources, source)
+		}
+	}
+
+	volume.Projected = projected
+	return volume
+}
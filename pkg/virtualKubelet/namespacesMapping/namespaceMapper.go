@@ -3,21 +3,33 @@ package namespacesMapping
 import (
 	"context"
 	"errors"
+	"fmt"
 	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
 	"github.com/liqotech/liqo/pkg/crdClient"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
 	v1 "k8s.io/api/core/v1"
 	kerror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
+	"reflect"
 	"strings"
 	"time"
 )
 
 var cacheResyncPeriod = 10 * time.Second
 
+// foreignNamespaceAccessBackoff is the backoff waiting for the permissions in the foreign namespaces just created,
+// which the foreign cluster grants once it notices them.
+var foreignNamespaceAccessBackoff = wait.Backoff{
+	Steps:    8,
+	Duration: 250 * time.Millisecond,
+	Factor:   1.5,
+}
+
 type namespaceNTCache struct {
 	Store            cache.Store
 	Controller       chan struct{}
@@ -29,6 +41,7 @@ type NamespaceMapper struct {
 	foreignClient kubernetes.Interface
 
 	cache            namespaceNTCache
+	offloadingCache  cache.Store
 	foreignClusterId string
 	homeClusterId    string
 
@@ -36,9 +49,11 @@ type NamespaceMapper struct {
 	startIncomingReflection chan string
 	stopOutgoingReflection  chan string
 	stopIncomingReflection  chan string
-	startMapper             chan struct{}
-	stopMapper              chan struct{}
-	restartReady            chan struct{}
+	// restartOutgoingReflection receives the namespaces whose offloading policy has changed
+	restartOutgoingReflection chan string
+	startMapper               chan struct{}
+	stopMapper                chan struct{}
+	restartReady              chan struct{}
 }
 
 func (m *NamespaceMapper) startNattingCache(clientSet crdClient.NamespacedCRDClientInterface) error {
@@ -71,6 +86,69 @@ func (m *NamespaceMapper) startNattingCache(clientSet crdClient.NamespacedCRDCli
 	return nil
 }
 
+// startOffloadingCache starts the cache of the NamespaceOffloadings of all the namespaces.
+func (m *NamespaceMapper) startOffloadingCache(clientSet crdClient.NamespacedCRDClientInterface) error {
+	var err error
+
+	m.offloadingCache, _, err = crdClient.WatchResources(clientSet,
+		"namespaceoffloadings", "",
+		cacheResyncPeriod, m.offloadingEventHandlers(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	klog.Info("namespaceOffloading cache initialized")
+
+	return nil
+}
+
+// offloadingEventHandlers returns the handlers of the NamespaceOffloadings, restarting the outgoing reflection of their
+// namespaces when the reflected resources may have changed.
+func (m *NamespaceMapper) offloadingEventHandlers() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: m.restartReflection,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSpec := oldObj.(*nattingv1.NamespaceOffloading).Spec
+			newSpec := newObj.(*nattingv1.NamespaceOffloading).Spec
+			if !reflect.DeepEqual(oldSpec.ReflectedResources, newSpec.ReflectedResources) {
+				m.restartReflection(newObj)
+			}
+		},
+		DeleteFunc: m.restartReflection,
+	}
+}
+
+// restartReflection requests the restart of the outgoing reflection of the namespace of the NamespaceOffloading, for
+// the changes of the reflected resources to be applied. The namespaces not reflected are ignored by the controller.
+func (m *NamespaceMapper) restartReflection(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	if name == nattingv1.NamespaceOffloadingName {
+		m.restartOutgoingReflection <- namespace
+	}
+}
+
+// OffloadingPolicy returns the offloading policy of the namespace, if its NamespaceOffloading exists.
+func (m *NamespaceMapper) OffloadingPolicy(namespace string) (*nattingv1.NamespaceOffloadingSpec, bool) {
+	obj, exists, err := m.offloadingCache.GetByKey(strings.Join([]string{namespace, nattingv1.NamespaceOffloadingName}, "/"))
+	if err != nil {
+		klog.Errorf("error while retrieving the offloading policy of namespace %v - ERR: %v", namespace, err)
+		return nil, false
+	}
+	if !exists {
+		return nil, false
+	}
+
+	return obj.(*nattingv1.NamespaceOffloading).Spec.DeepCopy(), true
+}
+
 func (nt *namespaceNTCache) WaitNamespaceNattingTableSync() {
 	cache.WaitForCacheSync(nt.Controller, func() bool {
 		_, exists, _ := nt.Store.GetByKey(nt.nattingTableName)
@@ -96,6 +174,15 @@ func (m *NamespaceMapper) NatNamespace(namespace string, create bool) (string, e
 
 	if !ok && create {
 		nattedNS = strings.Join([]string{namespace, m.homeClusterId}, "-")
+		if policy, ok := m.OffloadingPolicy(namespace); ok && policy.RemoteNamespace != "" {
+			nattedNS = policy.RemoteNamespace
+		}
+		if homeNS, ok := nattingTable.Spec.DeNattingTable[nattedNS]; ok {
+			return "", fmt.Errorf("remote namespace %v already mapped to namespace %v", nattedNS, homeNS)
+		}
+		if err := m.checkForeignNamespace(nattedNS); err != nil {
+			return "", err
+		}
 		if nattingTable.Spec.NattingTable == nil {
 			nattingTable.Spec.NattingTable = make(map[string]string)
 			nattingTable.Spec.DeNattingTable = make(map[string]string)
@@ -135,11 +222,17 @@ func (m *NamespaceMapper) NatNamespace(namespace string, create bool) (string, e
 		ns := &v1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: nattedNS,
+				Labels: map[string]string{
+					virtualKubelet.RemoteClusterIdLabel: m.homeClusterId,
+				},
 			},
 		}
 
 		_, err = m.foreignClient.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
-		if err != nil && !kerror.IsAlreadyExists(err) {
+		if kerror.IsAlreadyExists(err) {
+			err = m.checkForeignNamespace(nattedNS)
+		}
+		if err != nil {
 			return "", err
 		}
 	}
@@ -147,6 +240,31 @@ func (m *NamespaceMapper) NatNamespace(namespace string, create bool) (string, e
 	return nattedNS, nil
 }
 
+// checkForeignNamespace returns an error if the foreign namespace already exists, but it has not been created on behalf
+// of the home cluster, so that the pods are never offloaded to the namespaces of the foreign cluster (e.g. kube-system).
+func (m *NamespaceMapper) checkForeignNamespace(name string) error {
+	ns, err := m.foreignClient.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
+	if kerror.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ns.Labels[virtualKubelet.RemoteClusterIdLabel] != m.homeClusterId {
+		return fmt.Errorf("remote namespace %v already exists and it has not been created by cluster %v", name, m.homeClusterId)
+	}
+	return nil
+}
+
+// waitForeignNamespaceAccess waits until the virtual kubelet is granted the permissions in the foreign namespace,
+// bound by the foreign cluster to the namespaces created on behalf of the home clusters.
+func (m *NamespaceMapper) waitForeignNamespaceAccess(name string) error {
+	return retry.OnError(foreignNamespaceAccessBackoff, kerror.IsForbidden, func() error {
+		_, err := m.foreignClient.CoreV1().Pods(name).List(context.TODO(), metav1.ListOptions{Limit: 1})
+		return err
+	})
+}
+
 func (m *NamespaceMapper) DeNatNamespace(namespace string) (string, error) {
 	nt, exists, err := m.cache.Store.GetByKey(m.foreignClusterId)
 	if err != nil {
@@ -225,6 +343,9 @@ func (m *NamespaceMapper) manageReflections(oldObj interface{}, newObj interface
 			ns := &v1.Namespace{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: remoteNs,
+					Labels: map[string]string{
+						virtualKubelet.RemoteClusterIdLabel: m.homeClusterId,
+					},
 				},
 			}
 
@@ -239,6 +360,9 @@ func (m *NamespaceMapper) manageReflections(oldObj interface{}, newObj interface
 				klog.Error(err, "error in namespace creation")
 				continue
 			}
+			if err = m.waitForeignNamespaceAccess(ns.Name); err != nil {
+				klog.Warningf("permissions in remote namespace %v not granted yet, the reflection may fail: %v", ns.Name, err)
+			}
 
 			m.startOutgoingReflection <- localNs
 			m.startIncomingReflection <- localNs
@@ -1,6 +1,7 @@
 package namespacesMapping
 
 import (
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
@@ -10,6 +11,7 @@ type MapperController interface {
 	NamespaceReflectionController
 	NamespaceMIrroringController
 	NamespaceNatter
+	NamespaceOffloadingPolicies
 
 	PollStartMapper() chan struct{}
 	PollStopMapper() chan struct{}
@@ -21,6 +23,7 @@ type MapperController interface {
 type NamespaceReflectionController interface {
 	PollStartOutgoingReflection() chan string
 	PollStopOutgoingReflection() chan string
+	PollRestartOutgoingReflection() chan string
 }
 
 type NamespaceMIrroringController interface {
@@ -33,6 +36,11 @@ type NamespaceNatter interface {
 	DeNatNamespace(namespace string) (string, error)
 }
 
+// NamespaceOffloadingPolicies provides the offloading policies set by the NamespaceOffloadings.
+type NamespaceOffloadingPolicies interface {
+	OffloadingPolicy(namespace string) (*nattingv1.NamespaceOffloadingSpec, bool)
+}
+
 type NamespaceMapperController struct {
 	mapper *NamespaceMapper
 }
@@ -44,22 +52,26 @@ func NewNamespaceMapperController(client crdClient.NamespacedCRDClientInterface,
 			cache: namespaceNTCache{
 				nattingTableName: foreignClusterId,
 			},
-			foreignClient:           foreignClient,
-			homeClusterId:           homeClusterId,
-			foreignClusterId:        foreignClusterId,
-			startOutgoingReflection: make(chan string, 100),
-			startIncomingReflection: make(chan string, 100),
-			stopIncomingReflection:  make(chan string, 100),
-			stopOutgoingReflection:  make(chan string, 100),
-			startMapper:             make(chan struct{}, 100),
-			stopMapper:              make(chan struct{}, 100),
-			restartReady:            make(chan struct{}, 100),
+			foreignClient:             foreignClient,
+			homeClusterId:             homeClusterId,
+			foreignClusterId:          foreignClusterId,
+			startOutgoingReflection:   make(chan string, 100),
+			startIncomingReflection:   make(chan string, 100),
+			stopIncomingReflection:    make(chan string, 100),
+			stopOutgoingReflection:    make(chan string, 100),
+			restartOutgoingReflection: make(chan string, 100),
+			startMapper:               make(chan struct{}, 100),
+			stopMapper:                make(chan struct{}, 100),
+			restartReady:              make(chan struct{}, 100),
 		},
 	}
 
 	if err := controller.mapper.startNattingCache(client); err != nil {
 		return nil, err
 	}
+	if err := controller.mapper.startOffloadingCache(client); err != nil {
+		return nil, err
+	}
 	if err := controller.mapper.createNattingTable(controller.mapper.foreignClusterId); err != nil {
 		klog.Error(err, "cannot initialize namespaceNattingTable")
 	}
@@ -79,6 +91,12 @@ func (c *NamespaceMapperController) PollStopOutgoingReflection() chan string {
 	return c.mapper.stopOutgoingReflection
 }
 
+// PollRestartOutgoingReflection returns the channel of the namespaces whose outgoing reflection has to be restarted,
+// since their offloading policy has changed.
+func (c *NamespaceMapperController) PollRestartOutgoingReflection() chan string {
+	return c.mapper.restartOutgoingReflection
+}
+
 func (c *NamespaceMapperController) PollStopIncomingReflection() chan string {
 	return c.mapper.stopIncomingReflection
 }
@@ -103,6 +121,10 @@ func (c *NamespaceMapperController) DeNatNamespace(namespace string) (string, er
 	return c.mapper.DeNatNamespace(namespace)
 }
 
+func (c *NamespaceMapperController) OffloadingPolicy(namespace string) (*nattingv1.NamespaceOffloadingSpec, bool) {
+	return c.mapper.OffloadingPolicy(namespace)
+}
+
 func (c *NamespaceMapperController) WaitForSync() {
 	c.mapper.cache.WaitNamespaceNattingTableSync()
 }
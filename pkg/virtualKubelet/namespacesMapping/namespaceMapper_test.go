@@ -0,0 +1,124 @@
+package namespacesMapping
+
+import (
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+	"time"
+)
+
+func TestCheckForeignNamespace(t *testing.T) {
+	m := &NamespaceMapper{
+		homeClusterId: "home-cluster",
+		foreignClient: fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "reflected",
+				Labels: map[string]string{virtualKubelet.RemoteClusterIdLabel: "home-cluster"},
+			}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "other-reflected",
+				Labels: map[string]string{virtualKubelet.RemoteClusterIdLabel: "other-cluster"},
+			}},
+		),
+	}
+
+	tests := []struct {
+		namespace string
+		allowed   bool
+	}{
+		{namespace: "missing", allowed: true},
+		{namespace: "reflected", allowed: true},
+		{namespace: "kube-system", allowed: false},
+		{namespace: "other-reflected", allowed: false},
+	}
+
+	for _, test := range tests {
+		err := m.checkForeignNamespace(test.namespace)
+		if test.allowed && err != nil {
+			t.Errorf("namespace %v refused: %v", test.namespace, err)
+		}
+		if !test.allowed && err == nil {
+			t.Errorf("namespace %v not refused", test.namespace)
+		}
+	}
+}
+
+func TestWaitForeignNamespaceAccess(t *testing.T) {
+	defer func(backoff wait.Backoff) { foreignNamespaceAccessBackoff = backoff }(foreignNamespaceAccessBackoff)
+	foreignNamespaceAccessBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+
+	// the permissions are granted after the second attempt
+	client := fake.NewSimpleClientset()
+	attempts := 0
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		attempts++
+		if attempts <= 2 {
+			return true, nil, kerror.NewForbidden(corev1.Resource("pods"), "", nil)
+		}
+		return false, nil, nil
+	})
+	m := &NamespaceMapper{foreignClient: client}
+	if err := m.waitForeignNamespaceAccess("reflected"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the permissions are never granted
+	attempts = -10
+	if err := m.waitForeignNamespaceAccess("reflected"); !kerror.IsForbidden(err) {
+		t.Errorf("expected a forbidden error, got %v", err)
+	}
+}
+
+func TestOffloadingEventHandlers(t *testing.T) {
+	m := &NamespaceMapper{restartOutgoingReflection: make(chan string, 10)}
+	handlers := m.offloadingEventHandlers()
+
+	offloading := func(name string, resources ...nattingv1.ReflectedResource) *nattingv1.NamespaceOffloading {
+		return &nattingv1.NamespaceOffloading{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "homeNamespace"},
+			Spec:       nattingv1.NamespaceOffloadingSpec{Enabled: true, ReflectedResources: resources},
+		}
+	}
+	all := offloading(nattingv1.NamespaceOffloadingName)
+	configMaps := offloading(nattingv1.NamespaceOffloadingName, nattingv1.ReflectedConfigMaps)
+
+	tests := []struct {
+		name    string
+		event   func()
+		restart bool
+	}{
+		{name: "created", event: func() { handlers.OnAdd(configMaps) }, restart: true},
+		{name: "resynced", event: func() { handlers.OnUpdate(configMaps, configMaps.DeepCopy()) }, restart: false},
+		{name: "reflected resources changed", event: func() { handlers.OnUpdate(all, configMaps) }, restart: true},
+		{name: "deleted", event: func() { handlers.OnDelete(configMaps) }, restart: true},
+		{name: "deleted while not watched", event: func() {
+			handlers.OnDelete(cache.DeletedFinalStateUnknown{Key: "homeNamespace/" + nattingv1.NamespaceOffloadingName, Obj: configMaps})
+		}, restart: true},
+		{name: "other name", event: func() { handlers.OnAdd(offloading("other")) }, restart: false},
+	}
+
+	for _, test := range tests {
+		test.event()
+		select {
+		case namespace := <-m.restartOutgoingReflection:
+			if !test.restart {
+				t.Errorf("%v: unexpected restart of namespace %v", test.name, namespace)
+			} else if namespace != "homeNamespace" {
+				t.Errorf("%v: expected the restart of namespace homeNamespace, got %v", test.name, namespace)
+			}
+		default:
+			if test.restart {
+				t.Errorf("%v: expected the restart of namespace homeNamespace", test.name)
+			}
+		}
+	}
+}
@@ -2,10 +2,13 @@ package test
 
 import (
 	"errors"
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
 )
 
 type MockNamespaceMapper struct {
 	Cache map[string]string
+	// Offloading stores the offloading policies of the namespaces
+	Offloading map[string]*nattingv1.NamespaceOffloadingSpec
 }
 
 func (m *MockNamespaceMapper) NatNamespace(namespace string, create bool) (string, error) {
@@ -28,3 +31,8 @@ func (m *MockNamespaceMapper) DeNatNamespace(namespace string) (string, error) {
 	}
 	return "", errors.New("not found")
 }
+
+func (m *MockNamespaceMapper) OffloadingPolicy(namespace string) (*nattingv1.NamespaceOffloadingSpec, bool) {
+	policy, ok := m.Offloading[namespace]
+	return policy, ok
+}
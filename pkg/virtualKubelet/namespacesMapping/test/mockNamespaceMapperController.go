@@ -1,6 +1,9 @@
 package test
 
-import "github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping"
+import (
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping"
+)
 
 type MockNamespaceMapperController struct {
 	Mapper *MockNamespaceMapper
@@ -26,6 +29,10 @@ func (c *MockNamespaceMapperController) PollStopOutgoingReflection() chan string
 	panic("to implement")
 }
 
+func (c *MockNamespaceMapperController) PollRestartOutgoingReflection() chan string {
+	panic("to implement")
+}
+
 func (c *MockNamespaceMapperController) PollStopIncomingReflection() chan string {
 	panic("to implement")
 }
@@ -50,6 +57,10 @@ func (c *MockNamespaceMapperController) DeNatNamespace(namespace string) (string
 	return c.Mapper.DeNatNamespace(namespace)
 }
 
+func (c *MockNamespaceMapperController) OffloadingPolicy(namespace string) (*nattingv1.NamespaceOffloadingSpec, bool) {
+	return c.Mapper.OffloadingPolicy(namespace)
+}
+
 func (c *MockNamespaceMapperController) MappedNamespaces() map[string]string {
 	panic("implement me")
 }
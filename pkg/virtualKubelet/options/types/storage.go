@@ -0,0 +1,81 @@
+package types
+
+import (
+	"github.com/liqotech/liqo/pkg/virtualKubelet/options"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type StorageKey string
+type StorageValue string
+
+const (
+	StorageClassMapping = "storageClassMapping"
+)
+
+func NewStorageOption(key StorageKey, value StorageValue) *StorageOption {
+	return &StorageOption{
+		key:   key,
+		value: value,
+		lock:  sync.RWMutex{},
+	}
+}
+
+type StorageOption struct {
+	key   StorageKey
+	value StorageValue
+
+	isSet bool
+	lock  sync.RWMutex
+}
+
+func (o *StorageOption) Key() options.OptionKey {
+	return options.OptionKey(o.key)
+}
+
+func (o *StorageOption) Value() options.OptionValue {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	return options.OptionValue(o.value)
+}
+
+func (o *StorageOption) SetValue(v options.OptionValue) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.value = StorageValue(v)
+	o.isSet = true
+}
+
+func (o *StorageOption) IsSet() bool {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	return o.isSet
+}
+
+// StorageClassMappingValue encodes a local-to-foreign StorageClass mapping as a sorted, comma separated
+// list of `local=foreign` pairs, suitable to be stored in a StorageOption
+func StorageClassMappingValue(mapping map[string]string) options.OptionValue {
+	pairs := make([]string, 0, len(mapping))
+	for local, foreign := range mapping {
+		pairs = append(pairs, strings.Join([]string{local, foreign}, "="))
+	}
+	sort.Strings(pairs)
+	return options.OptionValue(strings.Join(pairs, ","))
+}
+
+// ParseStorageClassMapping decodes a value produced by StorageClassMappingValue, skipping malformed pairs
+func ParseStorageClassMapping(value options.OptionValue) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value.ToString(), ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			continue
+		}
+		mapping[kv[0]] = kv[1]
+	}
+	return mapping
+}
@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	apimgmgt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// podStatusReasonDaemonSetNotOffloaded is the reason set on the pods of the DaemonSets not enabled to be offloaded.
+const podStatusReasonDaemonSetNotOffloaded = "DaemonSetNotOffloaded"
+
+// daemonSetOwner returns the name of the DaemonSet owning the pod, if any.
+func daemonSetOwner(pod *corev1.Pod) (string, bool) {
+	for i := range pod.OwnerReferences {
+		if pod.OwnerReferences[i].Kind == "DaemonSet" {
+			return pod.OwnerReferences[i].Name, true
+		}
+	}
+	return "", false
+}
+
+// daemonSetOffloadingMode returns the offloading mode set on the DaemonSet owning the home pod. If the DaemonSet has not
+// been enabled to be offloaded, an empty mode is returned, together with the reason. An error is returned if the
+// DaemonSet cannot be retrieved, for the creation of the pod to be retried.
+func (p *LiqoProvider) daemonSetOffloadingMode(ctx context.Context, homePod *corev1.Pod, daemonSetName string) (mode, reason string, err error) {
+	daemonSet, err := p.nntClient.Client().AppsV1().DaemonSets(homePod.Namespace).Get(ctx, daemonSetName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", kerror.NewServiceUnavailable(fmt.Sprintf("unable to retrieve DaemonSet %v: %v", daemonSetName, err))
+	}
+
+	switch mode = daemonSet.Annotations[virtualKubelet.DaemonSetOffloadingAnnotation]; mode {
+	case virtualKubelet.DaemonSetOffloadingVirtualNode, virtualKubelet.DaemonSetOffloadingRemoteNodes:
+		return mode, "", nil
+	case "":
+		return "", fmt.Sprintf("DaemonSet %v is not enabled to be offloaded: set the %v annotation to %q or %q",
+			daemonSetName, virtualKubelet.DaemonSetOffloadingAnnotation,
+			virtualKubelet.DaemonSetOffloadingVirtualNode, virtualKubelet.DaemonSetOffloadingRemoteNodes), nil
+	default:
+		return "", fmt.Sprintf("invalid value %q of the %v annotation of DaemonSet %v", mode,
+			virtualKubelet.DaemonSetOffloadingAnnotation, daemonSetName), nil
+	}
+}
+
+// rejectPod sets the home pod as failed, as the kubelet does for the pods it cannot admit, so that its controller
+// is aware that it cannot run on the virtual node. The pods of the DaemonSets are kept pending instead, as the
+// DaemonSet controller would replace the failed ones on the same node over and over.
+func (p *LiqoProvider) rejectPod(ctx context.Context, homePod *corev1.Pod, reason, message string) {
+	phase := corev1.PodFailed
+	if _, ok := daemonSetOwner(homePod); ok {
+		phase = corev1.PodPending
+	}
+	if homePod.Status.Phase == phase && homePod.Status.Reason == reason {
+		return
+	}
+
+	pod := homePod.DeepCopy()
+	pod.Status.Phase = phase
+	pod.Status.Reason = reason
+	pod.Status.Message = message
+
+	if _, err := p.nntClient.Client().CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		klog.Errorf("PROVIDER: unable to reject pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return
+	}
+	klog.V(3).Infof("PROVIDER: pod %s/%s rejected: %s", pod.Namespace, pod.Name, message)
+}
+
+// createForeignDaemonSet creates the foreign daemonset running the pod on every foreign node.
+func (p *LiqoProvider) createForeignDaemonSet(ctx context.Context, foreignPod *corev1.Pod) {
+	foreignDaemonSet := forge.DaemonsetFromPod(foreignPod)
+
+	_, err := p.foreignClient.AppsV1().DaemonSets(foreignDaemonSet.Namespace).Create(ctx, foreignDaemonSet, metav1.CreateOptions{})
+	if kerror.IsAlreadyExists(err) {
+		klog.V(4).Infof("PROVIDER: creation of foreign daemonset %s/%s aborted, already existing", foreignDaemonSet.Namespace, foreignDaemonSet.Name)
+		return
+	}
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	klog.V(3).Infof("PROVIDER: daemonset %v/%v successfully created on remote cluster", foreignDaemonSet.Namespace, foreignDaemonSet.Name)
+}
+
+// watchForeignDaemonSets reflects the status of the foreign daemonsets on the related home pods, until the provider is
+// stopped.
+func (p *LiqoProvider) watchForeignDaemonSets(notifier func(interface{})) {
+	factory := informers.NewSharedInformerFactoryWithOptions(p.foreignClient, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = fmt.Sprintf("%s=%s", forge.LiqoOutgoingKey, forge.LiqoNodeName())
+		}))
+
+	factory.Apps().V1().DaemonSets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			p.reflectForeignDaemonSetStatus(obj.(*appsv1.DaemonSet), notifier)
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			p.reflectForeignDaemonSetStatus(newObj.(*appsv1.DaemonSet), notifier)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if daemonSet, ok := obj.(*appsv1.DaemonSet); ok {
+				p.handleForeignDaemonSetDeletion(daemonSet, notifier)
+			}
+		},
+	})
+
+	factory.Start(p.foreignPodWatcherStop)
+}
+
+func (p *LiqoProvider) getDaemonSetHomePod(foreignDaemonSet *appsv1.DaemonSet) (*corev1.Pod, error) {
+	homeNamespace, err := p.namespaceMapper.DeNatNamespace(foreignDaemonSet.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	homePodName, ok := foreignDaemonSet.Labels[virtualKubelet.ReflectedDaemonSetKey]
+	if !ok {
+		return nil, fmt.Errorf("missing %s label", virtualKubelet.ReflectedDaemonSetKey)
+	}
+	homeObj, err := p.apiController.CacheManager().GetHomeNamespacedObject(apimgmgt.Pods, homeNamespace, homePodName)
+	if err != nil {
+		return nil, err
+	}
+	return homeObj.(*corev1.Pod), nil
+}
+
+func (p *LiqoProvider) reflectForeignDaemonSetStatus(foreignDaemonSet *appsv1.DaemonSet, notifier func(interface{})) {
+	homePod, err := p.getDaemonSetHomePod(foreignDaemonSet)
+	if err != nil {
+		klog.V(4).Infof("PROVIDER: status of daemonset %s/%s not reflected: %v", foreignDaemonSet.Namespace, foreignDaemonSet.Name, err)
+		return
+	}
+	notifier(forge.DaemonsetStatusForeignToHome(foreignDaemonSet, homePod))
+}
+
+// handleForeignDaemonSetDeletion allows the home pod to be deleted once its foreign daemonset has been deleted, and
+// deletes the home pod if the daemonset has not been deleted on its behalf.
+func (p *LiqoProvider) handleForeignDaemonSetDeletion(foreignDaemonSet *appsv1.DaemonSet, notifier func(interface{})) {
+	homePod, err := p.getDaemonSetHomePod(foreignDaemonSet)
+	if err != nil {
+		klog.V(4).Infof("PROVIDER: deletion of daemonset %s/%s not reflected: %v", foreignDaemonSet.Namespace, foreignDaemonSet.Name, err)
+		return
+	}
+	homePod = homePod.DeepCopy()
+
+	// allow deletion of the related homePod by removing its finalizer
+	finalizerPatch := []byte(fmt.Sprintf(
+		`[{"op":"remove","path":"/metadata/finalizers","value":["%s"]}]`,
+		virtualKubelet.HomePodFinalizer))
+
+	_, err = p.nntClient.Client().CoreV1().Pods(homePod.Namespace).Patch(context.TODO(),
+		homePod.Name,
+		types.JSONPatchType,
+		finalizerPatch,
+		metav1.PatchOptions{})
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	// if the DeletionTimestamp is already set, the daemonset deletion has been triggered by a homePod delete event
+	if homePod.DeletionTimestamp != nil {
+		return
+	}
+
+	if err = p.nntClient.Client().CoreV1().Pods(homePod.Namespace).Delete(context.TODO(), homePod.Name, metav1.DeleteOptions{}); err != nil {
+		klog.Errorf("PROVIDER: error while deleting home pod %s/%s: %v", homePod.Namespace, homePod.Name, err)
+		return
+	}
+
+	notifier(forge.ForeignReplicasetDeleted(homePod))
+}
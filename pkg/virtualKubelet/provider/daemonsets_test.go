@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("DaemonSets", func() {
+	var (
+		provider      *LiqoProvider
+		foreignClient kubernetes.Interface
+		homeClient    kubernetes.Interface
+		daemonSet     *appsv1.DaemonSet
+		pod           *corev1.Pod
+		createErr     error
+	)
+
+	BeforeEach(func() {
+		provider, _, _ = newTestProvider(nil)
+		homeClient = provider.nntClient.Client()
+		foreignClient = provider.foreignClient
+
+		daemonSet = &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "testDaemonSet", Namespace: "homeNamespace"},
+		}
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "testDaemonSet-abcde",
+				Namespace:       "homeNamespace",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "testDaemonSet"}},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c1", Image: "image:v1"}}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		_, err := homeClient.AppsV1().DaemonSets("homeNamespace").Create(context.TODO(), daemonSet, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = homeClient.CoreV1().Pods("homeNamespace").Create(context.TODO(), pod, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		createErr = provider.CreatePod(context.TODO(), pod)
+	})
+
+	When("the daemonset cannot be retrieved", func() {
+		BeforeEach(func() {
+			homeClient.(*fake.Clientset).PrependReactor("get", "daemonsets",
+				func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("connection refused")
+				})
+		})
+
+		It("returns an error for the creation to be retried", func() {
+			Expect(kerror.IsServiceUnavailable(createErr)).To(BeTrue())
+			current, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(current.Status.Phase).To(BeEmpty())
+		})
+	})
+
+	When("the daemonset is not enabled to be offloaded", func() {
+		// the DaemonSet controller would replace a failed pod on the same node over and over
+		It("keeps the pod pending", func() {
+			Expect(createErr).NotTo(HaveOccurred())
+			rejected, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rejected.Status.Phase).To(Equal(corev1.PodPending))
+			Expect(rejected.Status.Reason).To(Equal(podStatusReasonDaemonSetNotOffloaded))
+			Expect(rejected.Status.Message).To(ContainSubstring(virtualKubelet.DaemonSetOffloadingAnnotation))
+
+			replicasets, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicasets.Items).To(BeEmpty())
+		})
+	})
+
+	When("the daemonset is offloaded to the remote nodes", func() {
+		BeforeEach(func() {
+			daemonSet.Annotations = map[string]string{
+				virtualKubelet.DaemonSetOffloadingAnnotation: virtualKubelet.DaemonSetOffloadingRemoteNodes,
+			}
+		})
+
+		It("creates the foreign daemonset", func() {
+			Expect(createErr).NotTo(HaveOccurred())
+			foreignDaemonSet, err := foreignClient.AppsV1().DaemonSets("homeNamespace-natted").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(foreignDaemonSet.Labels).To(HaveKeyWithValue(virtualKubelet.ReflectedDaemonSetKey, pod.Name))
+			Expect(foreignDaemonSet.Spec.Selector.MatchLabels).To(Equal(foreignDaemonSet.Spec.Template.Labels))
+
+			_, err = foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(kerror.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("reflects the status of the foreign daemonset", func() {
+			foreignDaemonSet := &appsv1.DaemonSet{
+				Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, NumberReady: 1},
+			}
+			homePod := forge.DaemonsetStatusForeignToHome(foreignDaemonSet, pod)
+			Expect(homePod.Status.Phase).To(Equal(corev1.PodRunning))
+			Expect(homePod.Status.ContainerStatuses).To(HaveLen(1))
+			Expect(homePod.Status.ContainerStatuses[0].Ready).To(BeFalse())
+
+			foreignDaemonSet.Status.NumberReady = 3
+			homePod = forge.DaemonsetStatusForeignToHome(foreignDaemonSet, pod)
+			Expect(homePod.Status.ContainerStatuses[0].Ready).To(BeTrue())
+		})
+	})
+
+	When("the daemonset is offloaded to the virtual node", func() {
+		BeforeEach(func() {
+			daemonSet.Annotations = map[string]string{
+				virtualKubelet.DaemonSetOffloadingAnnotation: virtualKubelet.DaemonSetOffloadingVirtualNode,
+			}
+		})
+
+		It("creates the foreign replicaset", func() {
+			Expect(createErr).NotTo(HaveOccurred())
+			_, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
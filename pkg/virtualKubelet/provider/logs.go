@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"time"
+)
+
+const (
+	// logStreamMaxRetries is the number of consecutive attempts to re-open a followed log stream before giving up
+	logStreamMaxRetries = 5
+	// logStreamRetryInterval is the base interval between two attempts, linearly increased at each retry
+	logStreamRetryInterval = 1 * time.Second
+)
+
+// logStreamOpener opens the stream of the logs of a foreign container with the given options.
+type logStreamOpener func(ctx context.Context, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+
+// reconnectingLogStream follows the logs of a foreign container, transparently re-opening the foreign stream
+// after transient errors. The foreign logs are always requested with timestamps, which are used to resume
+// the stream from the last line received and to drop the lines already forwarded.
+type reconnectingLogStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	open   logStreamOpener
+	opts   *corev1.PodLogOptions
+
+	reader *io.PipeReader
+	writer *io.PipeWriter
+
+	// the timestamp of the last line forwarded
+	last time.Time
+	// the lines older than this timestamp have already been forwarded by a previous stream
+	resumeFrom time.Time
+	// the number of bytes which can still be forwarded, if opts.LimitBytes is set
+	remaining int64
+}
+
+// newReconnectingLogStream opens the foreign log stream matching opts, and returns a stream which is
+// re-opened in case of errors different from the regular end of the logs, until the context is done.
+func newReconnectingLogStream(ctx context.Context, open logStreamOpener, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	foreignOpts := opts.DeepCopy()
+	foreignOpts.Timestamps = true
+	// the foreign stream is longer than the local one, because of the timestamps: the limit is applied locally
+	foreignOpts.LimitBytes = nil
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := open(ctx, foreignOpts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	s := &reconnectingLogStream{
+		ctx:    ctx,
+		cancel: cancel,
+		open:   open,
+		opts:   opts.DeepCopy(),
+		reader: reader,
+		writer: writer,
+	}
+	if opts.LimitBytes != nil {
+		s.remaining = *opts.LimitBytes
+	}
+
+	go s.run(stream)
+	return s, nil
+}
+
+func (s *reconnectingLogStream) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
+
+func (s *reconnectingLogStream) Close() error {
+	s.cancel()
+	return s.reader.Close()
+}
+
+func (s *reconnectingLogStream) run(stream io.ReadCloser) {
+	defer s.cancel()
+
+	retries := 0
+	for {
+		forwarded, done, err := s.forward(stream)
+		_ = stream.Close()
+		if done || s.ctx.Err() != nil {
+			_ = s.writer.CloseWithError(err)
+			return
+		}
+		if forwarded {
+			retries = 0
+		}
+
+		for {
+			retries++
+			if retries > logStreamMaxRetries {
+				klog.Errorf("unable to resume the logs of container %v: %v", s.opts.Container, err)
+				_ = s.writer.CloseWithError(err)
+				return
+			}
+			klog.V(3).Infof("resuming the logs of container %v after error: %v", s.opts.Container, err)
+
+			select {
+			case <-s.ctx.Done():
+				_ = s.writer.Close()
+				return
+			case <-time.After(time.Duration(retries) * logStreamRetryInterval):
+			}
+
+			if stream, err = s.open(s.ctx, s.resumeOptions()); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// resumeOptions returns the options to re-open the foreign stream from the last line forwarded. The line
+// timestamps are more precise than sinceTime, hence the lines already forwarded are filtered by forward.
+func (s *reconnectingLogStream) resumeOptions() *corev1.PodLogOptions {
+	opts := s.opts.DeepCopy()
+	opts.Timestamps = true
+	opts.LimitBytes = nil
+	if !s.last.IsZero() {
+		opts.TailLines = nil
+		opts.SinceSeconds = nil
+		opts.SinceTime = &metav1.Time{Time: s.last}
+	}
+	s.resumeFrom = s.last
+	return opts
+}
+
+// forward copies the lines of the foreign stream to the local one, stripping the timestamps when not
+// requested. It returns whether any line has been forwarded, whether the stream is over (because the
+// foreign logs are over, the limit has been reached or the local stream has been closed), and the error.
+func (s *reconnectingLogStream) forward(stream io.Reader) (forwarded, done bool, err error) {
+	buffer := bufio.NewReader(stream)
+	for {
+		line, rerr := buffer.ReadBytes('\n')
+		if rerr != nil && rerr != io.EOF {
+			// the partial line is dropped, as it is entirely sent again once the stream is resumed
+			return forwarded, false, rerr
+		}
+
+		if len(line) > 0 {
+			skip, out := s.processLine(line)
+			if !skip {
+				if done, werr := s.write(out); werr != nil || done {
+					return true, true, werr
+				}
+				forwarded = true
+			}
+		}
+
+		if rerr == io.EOF {
+			return forwarded, true, nil
+		}
+	}
+}
+
+// processLine parses the timestamp of the line, and returns whether it has already been forwarded and
+// the content to be written on the local stream.
+func (s *reconnectingLogStream) processLine(line []byte) (skip bool, out []byte) {
+	idx := bytes.IndexByte(line, ' ')
+	if idx < 0 {
+		return false, line
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, string(line[:idx]))
+	if err != nil {
+		return false, line
+	}
+	if !s.resumeFrom.IsZero() && !timestamp.After(s.resumeFrom) {
+		return true, nil
+	}
+	s.last = timestamp
+
+	if s.opts.Timestamps {
+		return false, line
+	}
+	return false, line[idx+1:]
+}
+
+// write forwards data to the local stream, honouring opts.LimitBytes. It returns true once the limit is reached.
+func (s *reconnectingLogStream) write(data []byte) (bool, error) {
+	if s.opts.LimitBytes == nil {
+		_, err := s.writer.Write(data)
+		return false, err
+	}
+	if int64(len(data)) > s.remaining {
+		data = data[:s.remaining]
+	}
+	_, err := s.writer.Write(data)
+	s.remaining -= int64(len(data))
+	return s.remaining <= 0, err
+}
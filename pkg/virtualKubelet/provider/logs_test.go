@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+	"time"
+)
+
+// failingReader returns the given content, followed by the given error.
+type failingReader struct {
+	io.Reader
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func (r *failingReader) Close() error {
+	return nil
+}
+
+var _ = Describe("Logs", func() {
+	var (
+		streams []io.ReadCloser
+		opened  []*corev1.PodLogOptions
+		open    logStreamOpener
+	)
+
+	BeforeEach(func() {
+		streams = nil
+		opened = nil
+		open = func(_ context.Context, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			opened = append(opened, opts)
+			if len(streams) == 0 {
+				return nil, errors.New("no more streams")
+			}
+			stream := streams[0]
+			streams = streams[1:]
+			return stream, nil
+		}
+	})
+
+	When("the foreign stream is interrupted by an error", func() {
+		BeforeEach(func() {
+			streams = []io.ReadCloser{
+				&failingReader{
+					Reader: strings.NewReader("2021-01-01T10:00:00.100000000Z first\n2021-01-01T10:00:00.200000000Z sec"),
+					err:    io.ErrUnexpectedEOF,
+				},
+				ioutil.NopCloser(strings.NewReader(
+					"2021-01-01T10:00:00.100000000Z first\n2021-01-01T10:00:00.200000000Z second\n2021-01-01T10:00:01.000000000Z third\n")),
+			}
+		})
+
+		It("resumes the stream without duplicating the lines", func() {
+			stream, err := newReconnectingLogStream(context.Background(), open, &corev1.PodLogOptions{Follow: true})
+			Expect(err).NotTo(HaveOccurred())
+			defer stream.Close()
+
+			logs, err := ioutil.ReadAll(stream)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(logs)).To(Equal("first\nsecond\nthird\n"))
+
+			Expect(opened).To(HaveLen(2))
+			Expect(opened[0].Timestamps).To(BeTrue())
+			Expect(opened[1].SinceTime).NotTo(BeNil())
+			Expect(opened[1].SinceTime.Time).To(BeTemporally("==", time.Date(2021, 1, 1, 10, 0, 0, 100000000, time.UTC)))
+		})
+	})
+
+	When("timestamps and a byte limit are requested", func() {
+		BeforeEach(func() {
+			streams = []io.ReadCloser{
+				ioutil.NopCloser(strings.NewReader(
+					"2021-01-01T10:00:00.100000000Z first\n2021-01-01T10:00:00.200000000Z second\n")),
+			}
+		})
+
+		It("keeps the timestamps and applies the limit locally", func() {
+			limit := int64(40)
+			stream, err := newReconnectingLogStream(context.Background(), open,
+				&corev1.PodLogOptions{Follow: true, Timestamps: true, LimitBytes: &limit})
+			Expect(err).NotTo(HaveOccurred())
+			defer stream.Close()
+
+			logs, err := ioutil.ReadAll(stream)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(logs)).To(Equal("2021-01-01T10:00:00.100000000Z first\n202"))
+			Expect(opened[0].LimitBytes).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"fmt"
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// podStatusReasonNamespaceNotOffloaded is the reason set on the pods whose namespace may not be offloaded to the
+// foreign cluster.
+const podStatusReasonNamespaceNotOffloaded = "NamespaceNotOffloaded"
+
+// namespaceOffloadingEnabled returns whether the namespace may be offloaded to the foreign cluster, according to its
+// NamespaceOffloading or, if missing, to the NamespaceEnabledLabel (unless all the namespaces are allowed). Otherwise, it also returns the reason. An error is
+// returned if the namespace cannot be retrieved, for the creation of the pod to be retried.
+func (p *LiqoProvider) namespaceOffloadingEnabled(namespace string) (bool, string, error) {
+	if policy, ok := p.namespaceMapper.OffloadingPolicy(namespace); ok {
+		if !policy.AllowsCluster(p.foreignClusterId) {
+			return false, fmt.Sprintf("the NamespaceOffloading of namespace %s does not allow the offloading to cluster %s",
+				namespace, p.foreignClusterId), nil
+		}
+		return true, "", nil
+	}
+	if p.allowAllNamespaces {
+		return true, "", nil
+	}
+
+	homeNamespace, err := p.homeNamespaces.Get(namespace)
+	if err != nil {
+		return false, "", kerror.NewServiceUnavailable(fmt.Sprintf("unable to retrieve namespace %s: %v", namespace, err))
+	}
+	if homeNamespace.Labels[virtualKubelet.NamespaceEnabledLabel] != "true" {
+		return false, fmt.Sprintf("namespace %s has neither a NamespaceOffloading named %s nor the %s=true label",
+			namespace, nattingv1.NamespaceOffloadingName, virtualKubelet.NamespaceEnabledLabel), nil
+	}
+	return true, "", nil
+}
+
+// newHomeNamespaceLister returns a lister of the home namespaces, backed by an informer running until stop is closed.
+func newHomeNamespaceLister(client kubernetes.Interface, stop <-chan struct{}) corelisters.NamespaceLister {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	lister := factory.Core().V1().Namespaces().Lister()
+	factory.Start(stop)
+	factory.WaitForCacheSync(stop)
+	return lister
+}
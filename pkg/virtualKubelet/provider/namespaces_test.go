@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var _ = Describe("Namespace offloading", func() {
+	var (
+		provider              *LiqoProvider
+		foreignClient         kubernetes.Interface
+		homeClient            kubernetes.Interface
+		namespaceNattingTable *test.MockNamespaceMapper
+		namespace             *corev1.Namespace
+		pod                   *corev1.Pod
+		createErr             error
+	)
+
+	BeforeEach(func() {
+		provider, namespaceNattingTable, _ = newTestProvider(nil)
+		delete(namespaceNattingTable.Offloading, "homeNamespace")
+		homeClient = provider.nntClient.Client()
+		foreignClient = provider.foreignClient
+
+		namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "homeNamespace"}}
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "testObject", Namespace: "homeNamespace"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "c1", Image: "image:v1"}}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		if namespace != nil {
+			createHomeNamespace(provider, namespace)
+		}
+		_, err := homeClient.CoreV1().Pods("homeNamespace").Create(context.TODO(), pod, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		createErr = provider.CreatePod(context.TODO(), pod)
+	})
+
+	expectOffloaded := func() {
+		Expect(createErr).NotTo(HaveOccurred())
+		_, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	expectRejected := func() {
+		Expect(createErr).NotTo(HaveOccurred())
+		rejected, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rejected.Status.Phase).To(Equal(corev1.PodFailed))
+		Expect(rejected.Status.Reason).To(Equal(podStatusReasonNamespaceNotOffloaded))
+
+		replicasets, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").List(context.TODO(), metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicasets.Items).To(BeEmpty())
+	}
+
+	When("the namespace is not enabled", func() {
+		It("rejects the pod", expectRejected)
+	})
+
+	When("the namespace is not cached yet", func() {
+		BeforeEach(func() {
+			namespace = nil
+		})
+
+		It("returns an error for the creation to be retried", func() {
+			Expect(kerror.IsServiceUnavailable(createErr)).To(BeTrue())
+			current, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(current.Status.Phase).To(BeEmpty())
+		})
+	})
+
+	When("the namespace is enabled by the label", func() {
+		BeforeEach(func() {
+			namespace.Labels = map[string]string{virtualKubelet.NamespaceEnabledLabel: "true"}
+		})
+
+		It("offloads the pod", expectOffloaded)
+	})
+
+	When("all the namespaces are allowed", func() {
+		BeforeEach(func() {
+			provider.allowAllNamespaces = true
+		})
+
+		It("offloads the pod of a namespace not enabled", expectOffloaded)
+
+		When("the NamespaceOffloading is disabled", func() {
+			BeforeEach(func() {
+				namespaceNattingTable.Offloading["homeNamespace"] = &nattingv1.NamespaceOffloadingSpec{Enabled: false}
+			})
+
+			It("rejects the pod", expectRejected)
+		})
+	})
+
+	When("the NamespaceOffloading enables the foreign cluster", func() {
+		BeforeEach(func() {
+			namespaceNattingTable.Offloading["homeNamespace"] = &nattingv1.NamespaceOffloadingSpec{
+				Enabled:    true,
+				ClusterIDs: []string{"other-cluster", "foreign-cluster"},
+			}
+		})
+
+		It("offloads the pod", expectOffloaded)
+	})
+
+	When("the NamespaceOffloading does not enable the foreign cluster", func() {
+		BeforeEach(func() {
+			namespace.Labels = map[string]string{virtualKubelet.NamespaceEnabledLabel: "true"}
+			namespaceNattingTable.Offloading["homeNamespace"] = &nattingv1.NamespaceOffloadingSpec{
+				Enabled:    true,
+				ClusterIDs: []string{"other-cluster"},
+			}
+		})
+
+		It("rejects the pod", expectRejected)
+	})
+
+	When("the NamespaceOffloading is disabled", func() {
+		BeforeEach(func() {
+			namespaceNattingTable.Offloading["homeNamespace"] = &nattingv1.NamespaceOffloadingSpec{Enabled: false}
+		})
+
+		It("rejects the pod", expectRejected)
+	})
+})
@@ -12,20 +12,31 @@ import (
 	"github.com/modern-go/reflect2"
 	"github.com/pkg/errors"
 	"io"
+	"io/ioutil"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerror "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
-	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
-	"time"
+	"net/http"
+	"net/url"
+	"strconv"
 )
 
+// podStatusReasonInvalidSchedulingConstraints is the reason set on the pods whose remote scheduling constraints are malformed.
+const podStatusReasonInvalidSchedulingConstraints = "InvalidRemoteSchedulingConstraints"
+
 // CreatePod accepts a Pod definition and stores it in memory.
-func (p *LiqoProvider) CreatePod(_ context.Context, homePod *corev1.Pod) error {
+func (p *LiqoProvider) CreatePod(ctx context.Context, homePod *corev1.Pod) error {
 	if reflect2.IsNil(homePod) {
 		klog.V(4).Info("received nil pod to create")
 		return nil
@@ -33,12 +44,35 @@ func (p *LiqoProvider) CreatePod(_ context.Context, homePod *corev1.Pod) error {
 
 	klog.V(3).Infof("PROVIDER: pod %s/%s asked to be created in the provider", homePod.Namespace, homePod.Name)
 
-	if homePod.OwnerReferences != nil && len(homePod.OwnerReferences) != 0 && homePod.OwnerReferences[0].Kind == "DaemonSet" {
-		klog.Infof("PROVIDER: Skip to create DaemonSet homePod %q", homePod.Name)
+	enabled, reason, err := p.namespaceOffloadingEnabled(homePod.Namespace)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		p.rejectPod(ctx, homePod, podStatusReasonNamespaceNotOffloaded, reason)
 		return nil
 	}
 
+	var offloadingMode string
+	if daemonSetName, ok := daemonSetOwner(homePod); ok {
+		if offloadingMode, reason, err = p.daemonSetOffloadingMode(ctx, homePod, daemonSetName); err != nil {
+			return err
+		}
+		if offloadingMode == "" {
+			p.rejectPod(ctx, homePod, podStatusReasonDaemonSetNotOffloaded, reason)
+			return nil
+		}
+	}
+
 	foreignObj, err := forge.HomeToForeign(homePod, nil, forge.LiqoOutgoingKey)
+	switch errors.Cause(err) {
+	case forge.ErrInvalidSchedulingConstraints:
+		p.rejectPod(ctx, homePod, podStatusReasonInvalidSchedulingConstraints, err.Error())
+		return nil
+	case forge.ErrInvalidServiceAccountTokenPolicy:
+		p.rejectPod(ctx, homePod, podStatusReasonInvalidServiceAccountTokenPolicy, err.Error())
+		return nil
+	}
 	if err != nil {
 		klog.V(4).Infof("PROVIDER: error while forging remote pod %s/%s because of error %v", homePod.Namespace, homePod.Name, err)
 		return nil
@@ -51,7 +85,18 @@ func (p *LiqoProvider) CreatePod(_ context.Context, homePod *corev1.Pod) error {
 		return nil
 	}
 
-	foreignReplicaset := forge.ReplicasetFromPod(foreignPod)
+	// in case of errors, the pod is created anyway, as it waits for the missing credentials to be available
+	if err = p.setupServiceAccountCredentials(ctx, homePod, foreignPod.Namespace); err != nil {
+		klog.Error(err)
+	}
+
+	// the replicas of the shared replicasets are deleted by the provider, hence no finalizer is needed
+	if replicaSetName, ok := p.sharedReplicaSetOwner(homePod); ok {
+		if err = p.createSharedReplica(ctx, homePod, foreignPod, replicaSetName); err != nil {
+			klog.Error(err)
+		}
+		return nil
+	}
 
 	// add a finalizer to allow the pod to be garbage collected by the incoming replicaset reflector
 	finalizerPatch := []byte(fmt.Sprintf(
@@ -68,6 +113,12 @@ func (p *LiqoProvider) CreatePod(_ context.Context, homePod *corev1.Pod) error {
 		return nil
 	}
 
+	if offloadingMode == virtualKubelet.DaemonSetOffloadingRemoteNodes {
+		p.createForeignDaemonSet(ctx, foreignPod)
+		return nil
+	}
+
+	foreignReplicaset := forge.ReplicasetFromPod(foreignPod)
 	_, err = p.foreignClient.AppsV1().ReplicaSets(foreignReplicaset.Namespace).Create(context.TODO(), foreignReplicaset, metav1.CreateOptions{})
 	if kerror.IsAlreadyExists(err) {
 		klog.V(4).Infof("PROVIDER: creation of foreign replicaset %s/%s aborted, already existing", foreignReplicaset.Namespace, foreignReplicaset.Name)
@@ -83,15 +134,74 @@ func (p *LiqoProvider) CreatePod(_ context.Context, homePod *corev1.Pod) error {
 	return nil
 }
 
-// UpdatePod accepts a Pod definition and updates its reference.
-func (p *LiqoProvider) UpdatePod(_ context.Context, pod *corev1.Pod) error {
+// UpdatePod accepts a Pod definition and reflects its mutable fields (labels, annotations, container images,
+// activeDeadlineSeconds and tolerations) to the foreign pod and to the template of the foreign replicaset.
+func (p *LiqoProvider) UpdatePod(ctx context.Context, pod *corev1.Pod) error {
 	if reflect2.IsNil(pod) {
-		klog.V(4).Info("received nil pod to create")
+		klog.V(4).Info("received nil pod to update")
 		return nil
 	}
 
 	klog.V(3).Infof("PROVIDER: pod %s/%s asked to be updated in the provider", pod.Namespace, pod.Name)
 
+	foreignNamespace, err := p.namespaceMapper.NatNamespace(pod.Namespace, false)
+	if err != nil {
+		return err
+	}
+
+	foreignReplicaset, err := p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if kerror.IsNotFound(err) {
+		klog.V(4).Infof("PROVIDER: update of pod %s/%s aborted, foreign replicaset not existing", pod.Namespace, pod.Name)
+		return nil
+	}
+	if err != nil {
+		return kerror.NewServiceUnavailable(fmt.Sprintf("error while retrieving foreign replicaset %s/%s: %v", foreignNamespace, pod.Name, err))
+	}
+	// the labels and annotations reflected so far, used to detect the ones removed from the home pod
+	reflectedMeta := foreignReplicaset.Spec.Template.ObjectMeta.DeepCopy()
+	var protectedLabels map[string]string
+	if foreignReplicaset.Spec.Selector != nil {
+		protectedLabels = foreignReplicaset.Spec.Selector.MatchLabels
+	}
+
+	// the running pod is updated first, as changing the template does not affect the existing pods
+	foreignObj, err := p.apiController.CacheManager().GetForeignApiByIndex(apimgmgt.Pods, foreignNamespace, pod.Name)
+	if err == nil {
+		foreignPod := foreignObj.(*corev1.Pod)
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current, err := p.foreignClient.CoreV1().Pods(foreignNamespace).Get(ctx, foreignPod.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			updated := forge.PodUpdateHomeToForeign(pod, current, reflectedMeta, protectedLabels)
+			if equality.Semantic.DeepEqual(current, updated) {
+				return nil
+			}
+			_, err = p.foreignClient.CoreV1().Pods(foreignNamespace).Update(ctx, updated, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil && !kerror.IsNotFound(err) {
+			return kerror.NewServiceUnavailable(fmt.Sprintf("error while updating foreign pod %s/%s: %v", foreignNamespace, foreignPod.Name, err))
+		}
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		updated := forge.ReplicasetUpdateFromPod(pod, current)
+		if equality.Semantic.DeepEqual(current, updated) {
+			return nil
+		}
+		_, err = p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil && !kerror.IsNotFound(err) {
+		return kerror.NewServiceUnavailable(fmt.Sprintf("error while updating foreign replicaset %s/%s: %v", foreignNamespace, pod.Name, err))
+	}
+
+	klog.V(3).Infof("PROVIDER: pod %s/%s successfully updated on remote cluster", pod.Namespace, pod.Name)
 	return nil
 }
 
@@ -112,6 +222,15 @@ func (p *LiqoProvider) DeletePod(ctx context.Context, pod *corev1.Pod) (err erro
 		if pod.Labels != nil {
 			replicasetName = pod.Labels[virtualKubelet.ReflectedpodKey]
 		}
+		if sharedReplicaSetName, ok := pod.Labels[virtualKubelet.ReflectedReplicaSetKey]; ok {
+			// the replicas of the shared replicasets are named by the foreign cluster, hence they are always reported
+			// as dangling: only the ones of the home pods no longer offloaded are deleted
+			sharedForeignNamespace, err := p.namespaceMapper.NatNamespace(pod.Namespace, false)
+			if err != nil {
+				return err
+			}
+			return p.resyncSharedReplicaSet(ctx, pod.Namespace, sharedForeignNamespace, sharedReplicaSetName)
+		}
 		if replicasetName == "" {
 			klog.V(3).Infof("PROVIDER: home pod %s/%s foreign replica not deleted because unlabeled", pod.Namespace, pod.Name)
 			return nil
@@ -122,6 +241,24 @@ func (p *LiqoProvider) DeletePod(ctx context.Context, pod *corev1.Pod) (err erro
 		if err != nil {
 			return err
 		}
+		if sharedReplicaSetName, ok := p.isSharedReplica(pod, foreignNamespace); ok {
+			return p.deleteSharedReplica(ctx, pod, foreignNamespace, sharedReplicaSetName)
+		}
+	}
+
+	if err = p.deleteHomeServiceAccountTokens(ctx, replicasetName, foreignNamespace); err != nil {
+		return err
+	}
+
+	if _, ok := daemonSetOwner(pod); ok {
+		err = p.foreignClient.AppsV1().DaemonSets(foreignNamespace).Delete(context.TODO(), replicasetName, metav1.DeleteOptions{})
+		if err == nil {
+			klog.V(3).Infof("PROVIDER: daemonset %v/%v successfully deleted on remote cluster", foreignNamespace, replicasetName)
+			return nil
+		}
+		if !kerror.IsNotFound(err) {
+			return errors.Wrap(err, "Unable to delete foreign daemonset")
+		}
 	}
 
 	err = p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Delete(context.TODO(), replicasetName, metav1.DeleteOptions{})
@@ -140,34 +277,28 @@ func (p *LiqoProvider) DeletePod(ctx context.Context, pod *corev1.Pod) (err erro
 
 // GetPod returns a pod by name that is stored in memory.
 func (p *LiqoProvider) GetPod(_ context.Context, namespace, name string) (pod *corev1.Pod, err error) {
-	if reflect2.IsNil(pod) {
-		klog.V(4).Info("PROVIDER: received nil pod")
-		return nil, nil
-	}
-
 	klog.V(3).Infof("PROVIDER: pod %s/%s requested to the provider", namespace, name)
 
 	foreignNamespace, err := p.namespaceMapper.NatNamespace(namespace, false)
 	if err != nil {
-		klog.V(4).Infof("PROVIDER: cannot get remote pod %s/%s because of error %v, requeueing", pod.Namespace, pod.Name, err)
+		klog.V(4).Infof("PROVIDER: cannot get remote pod %s/%s because of error %v, requeueing", namespace, name, err)
 		return nil, nil
 	}
 
-	_, err = p.apiController.CacheManager().GetForeignApiByIndex(apimgmgt.Pods, foreignNamespace, name)
+	foreignPod, err := p.apiController.CacheManager().GetForeignApiByIndex(apimgmgt.Pods, foreignNamespace, name)
 	if err != nil {
-		klog.V(4).Infof("PROVIDER: cannot get remote pod %s/%s because of error %v, requeueing", pod.Namespace, pod.Name, err)
+		klog.V(4).Infof("PROVIDER: cannot get remote pod %s/%s because of error %v, requeueing", namespace, name, err)
 		return nil, nil
 	}
 
 	homePod, err := p.apiController.CacheManager().GetHomeNamespacedObject(apimgmgt.Pods, namespace, name)
 	if err != nil {
-		klog.V(4).Infof("PROVIDER: cannot get remote pod %s/%s because of error %v, requeueing", pod.Namespace, pod.Name, err)
+		klog.V(4).Infof("PROVIDER: cannot get remote pod %s/%s because of error %v, requeueing", namespace, name, err)
 		return nil, nil
 	}
 
-	// if we want to enforce some foreign pod fields we should return a homePod having the fields to enforce
-	// taken from the foreign pod
-	return homePod.(*corev1.Pod), nil
+	// the mutable fields are taken from the foreign pod, so that the differences are reflected by UpdatePod
+	return forge.PodHomeViewFromForeign(homePod.(*corev1.Pod), foreignPod.(*corev1.Pod)), nil
 }
 
 // GetPodStatus returns the status of a pod by name that is "running".
@@ -243,7 +374,7 @@ func (p *LiqoProvider) RunInContainer(_ context.Context, homeNamespace string, h
 			TTY:       true,
 		}, scheme.ParameterCodec)
 
-	exec, err := remotecommand.NewSPDYExecutor(p.restConfig, "POST", req.URL())
+	exec, err := p.newExecutor("POST", req.URL())
 	if err != nil {
 		return fmt.Errorf("could not make remote command: %v", err)
 	}
@@ -261,173 +392,246 @@ func (p *LiqoProvider) RunInContainer(_ context.Context, homeNamespace string, h
 	return nil
 }
 
-// GetContainerLogs retrieves the logs of a container by name from the provider.
-func (p *LiqoProvider) GetContainerLogs(_ context.Context, homeNamespace string, homePodName string, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error) {
+// AttachToContainer attaches to the executing process of a container in the pod, copying data
+// between in/out/err and the container's stdin/stdout/stderr.
+func (p *LiqoProvider) AttachToContainer(ctx context.Context, homeNamespace string, homePodName string, containerName string, attach api.AttachIO) error {
 	foreignNamespace, err := p.namespaceMapper.NatNamespace(homeNamespace, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	foreignObj, err := p.apiController.CacheManager().GetForeignApiByIndex(apimgmgt.Pods, foreignNamespace, homePodName)
 	if err != nil {
-		return nil, errors.Wrap(err, "error while retrieving foreign pod")
+		return errors.Wrap(err, "error while retrieving foreign pod")
 	}
 	foreignPod := foreignObj.(*corev1.Pod)
 
-	logOptions := &corev1.PodLogOptions{
-		Container:  containerName,
-		Follow:     opts.Follow,
-		Previous:   opts.Previous,
-		Timestamps: opts.Timestamps,
-	}
+	req := p.foreignClient.CoreV1().RESTClient().
+		Post().
+		Namespace(foreignNamespace).
+		Resource("pods").
+		Name(foreignPod.Name).
+		SubResource("attach").
+		VersionedParams(&corev1.PodAttachOptions{
+			Container: containerName,
+			Stdin:     attach.Stdin() != nil,
+			Stdout:    attach.Stdout() != nil,
+			Stderr:    attach.Stderr() != nil,
+			TTY:       attach.TTY(),
+		}, scheme.ParameterCodec)
 
-	if opts.SinceSeconds > 0 {
-		logOptions.SinceSeconds = &opts.SinceSeconds
+	exec, err := p.newExecutor("POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("could not make remote command: %v", err)
 	}
-	if !opts.SinceTime.IsZero() {
-		logOptions.SinceTime = &opts.SinceTime
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  attach.Stdin(),
+		Stdout: attach.Stdout(),
+		Stderr: attach.Stderr(),
+		Tty:    attach.TTY(),
 	}
-	if opts.LimitBytes > 0 {
-		logOptions.LimitBytes = &opts.LimitBytes
+	if attach.TTY() {
+		streamOptions.TerminalSizeQueue = &terminalSizeQueue{ctx: ctx, resize: attach.Resize()}
 	}
-	if opts.Tail > 0 {
-		logOptions.TailLines = &opts.Tail
+	if err = exec.Stream(streamOptions); err != nil {
+		return fmt.Errorf("streaming error: %v", err)
 	}
 
-	logs := p.foreignClient.CoreV1().Pods(foreignNamespace).GetLogs(foreignPod.Name, logOptions)
-	stream, err := logs.Stream(context.TODO())
-	if err != nil {
-		return nil, fmt.Errorf("could not get stream from logs request: %v", err)
+	return nil
+}
+
+// spdyExecutorFactory creates the executors of the remote commands (exec and attach) on the foreign API server
+type spdyExecutorFactory func(method string, url *url.URL) (remotecommand.Executor, error)
+
+// spdyDialerFactory creates the dialers of the SPDY connections used to forward the ports of the foreign pods
+type spdyDialerFactory func(method string, url *url.URL) (httpstream.Dialer, error)
+
+func newSPDYExecutorFactory(config *rest.Config) spdyExecutorFactory {
+	return func(method string, url *url.URL) (remotecommand.Executor, error) {
+		return remotecommand.NewSPDYExecutor(config, method, url)
 	}
-	return stream, nil
 }
 
-// GetStatsSummary returns dummy stats for all pods known by this provider.
-func (p *LiqoProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
-	// Grab the current timestamp so we can report it as the time the stats were generated.
-	t := metav1.NewTime(time.Now())
-
-	// Create the Summary object that will later be populated with node and pod stats.
-	res := &stats.Summary{}
-
-	// Populate the Summary object with basic node stats.
-	res.Node = stats.NodeStats{
-		NodeName:  p.nodeName.Value().ToString(),
-		StartTime: metav1.NewTime(p.startTime),
-	}
-
-	var (
-		// nodeTotalNanoCore will be populated with the sum of the values of UsageNanoCores computes across all
-		// containers in all the pods running in the remote cluster on behalf of this virtual node.
-		nodeTotalNanoCore uint64
-		// nodeTotalNanoBytes will be populated with the sum of the values of UsageBytes computed across all containers
-		// in all the pods running in the remote cluster on behalf of this virtual node.
-		nodeTotalNanoBytes uint64
-	)
-
-	// iterates over all the mapped namespaces
-	for home, foreign := range p.namespaceMapper.MappedNamespaces() {
-		// get the metricses of the foreign pods in each namespace by filtering with the liqoOutgoingKey
-		podMetrics, err := p.foreignMetricsClient.MetricsV1beta1().PodMetricses(foreign).List(ctx, metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("%s=%s", forge.LiqoOutgoingKey, forge.LiqoNodeName()),
-		})
+func newSPDYDialerFactory(config *rest.Config) spdyDialerFactory {
+	return func(method string, url *url.URL) (httpstream.Dialer, error) {
+		transport, upgrader, err := spdy.RoundTripperFor(config)
 		if err != nil {
-			return nil, errors.Wrapf(err, "error while listing foreign pod metricses in namespace %s", foreign)
+			return nil, err
 		}
+		return spdy.NewDialer(upgrader, &http.Client{Transport: transport}, method, url), nil
+	}
+}
 
-		for _, foreignPodMetrics := range podMetrics.Items {
-			// fetch foreign pod from cache
-			foreignObj, err := p.apiController.CacheManager().GetForeignNamespacedObject(apimgmgt.Pods, foreign, foreignPodMetrics.Name)
-			if err != nil {
-				return nil, errors.Errorf("error while retrieving foreign pod %s/%s from cache", foreign, foreignPodMetrics.Name)
-			}
-			// retrieve foreign pod name by the foreign pod label
-			foreignPod := foreignObj.(*corev1.Pod)
-			if foreignPod.Labels == nil {
-				return nil, errors.Errorf("error in foreign pod, empty %s label set in pod %s/%s", virtualKubelet.ReflectedpodKey, foreign, foreignPodMetrics.Name)
-			}
-			homePodName, ok := foreignPod.Labels[virtualKubelet.ReflectedpodKey]
-			if !ok {
-				return nil, errors.Errorf("error in foreign pod, missing %s label in pod %s/%s", virtualKubelet.ReflectedpodKey, foreign, foreignPodMetrics.Name)
-			}
+// terminalSizeQueue forwards the terminal resize events of the home client to the foreign pod
+type terminalSizeQueue struct {
+	ctx    context.Context
+	resize <-chan api.TermSize
+}
 
-			// fetch home pod from cache
-			homeObj, err := p.apiController.CacheManager().GetHomeNamespacedObject(apimgmgt.Pods, home, homePodName)
-			if err != nil {
-				return nil, errors.Errorf("error while retrieving home pod %s/%s from cache", home, homePodName)
-			}
-			homePod := homeObj.(*corev1.Pod)
-
-			// Create a PodStats object to populate with pod stats.
-			podStats := stats.PodStats{
-				PodRef: stats.PodReference{
-					Name:      homePodName,
-					Namespace: home,
-					UID:       string(homePod.UID),
-				},
-				StartTime: homePod.CreationTimestamp,
-			}
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size, ok := <-q.resize:
+		if !ok {
+			return nil
+		}
+		return &remotecommand.TerminalSize{Width: size.Width, Height: size.Height}
+	case <-q.ctx.Done():
+		return nil
+	}
+}
 
-			var (
-				// totalUsageNanoCores will be populated with the sum of the values of UsageNanoCores computes across all containers in the pod.
-				totalUsageNanoCores uint64
-				// totalUsageBytes will be populated with the sum of the values of UsageBytes computed across all containers in the pod.
-				totalUsageBytes uint64
-			)
-
-			// Iterate over all containers in the current pod to get stats
-			for _, container := range foreignPodMetrics.Containers {
-
-				nanoCpuUsage := uint64(container.Usage.Cpu().ScaledValue(resource.Nano))
-				totalUsageNanoCores += nanoCpuUsage
-
-				nanoMemoryUsage := uint64(container.Usage.Memory().Value())
-				totalUsageBytes += nanoMemoryUsage
-
-				// Append a ContainerStats object containing the dummy stats to the PodStats object.
-				podStats.Containers = append(podStats.Containers, stats.ContainerStats{
-					Name:      container.Name,
-					StartTime: homePod.CreationTimestamp,
-					CPU: &stats.CPUStats{
-						Time:           t,
-						UsageNanoCores: &nanoCpuUsage,
-					},
-					Memory: &stats.MemoryStats{
-						Time:            t,
-						UsageBytes:      &nanoMemoryUsage,
-						WorkingSetBytes: &nanoMemoryUsage,
-					},
-				})
-			}
+// PortForward forwards the data of the stream to a port of the foreign pod, through the foreign API server.
+func (p *LiqoProvider) PortForward(_ context.Context, homeNamespace string, homePodName string, port int32, stream io.ReadWriteCloser) error {
+	defer stream.Close()
 
-			nodeTotalNanoCore += totalUsageNanoCores
-			nodeTotalNanoBytes += totalUsageBytes
+	foreignNamespace, err := p.namespaceMapper.NatNamespace(homeNamespace, false)
+	if err != nil {
+		return err
+	}
 
-			// Populate the CPU and RAM stats for the pod and append the PodsStats object to the Summary object to be returned.
-			podStats.CPU = &stats.CPUStats{
-				Time:           t,
-				UsageNanoCores: &totalUsageNanoCores,
-			}
-			podStats.Memory = &stats.MemoryStats{
-				Time:            t,
-				UsageBytes:      &totalUsageBytes,
-				WorkingSetBytes: &totalUsageBytes,
-			}
-			res.Pods = append(res.Pods, podStats)
+	foreignObj, err := p.apiController.CacheManager().GetForeignApiByIndex(apimgmgt.Pods, foreignNamespace, homePodName)
+	if err != nil {
+		return errors.Wrap(err, "error while retrieving foreign pod")
+	}
+	foreignPod := foreignObj.(*corev1.Pod)
+
+	req := p.foreignClient.CoreV1().RESTClient().
+		Post().
+		Namespace(foreignNamespace).
+		Resource("pods").
+		Name(foreignPod.Name).
+		SubResource("portforward")
+
+	dialer, err := p.newDialer("POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("could not create round tripper: %v", err)
+	}
+	conn, _, err := dialer.Dial(portforward.PortForwardProtocolV1Name)
+	if err != nil {
+		return fmt.Errorf("could not upgrade connection: %v", err)
+	}
+	defer conn.Close()
+
+	// the streams are created as done by kubectl port-forward, one request per call
+	headers := http.Header{}
+	headers.Set(corev1.StreamType, corev1.StreamTypeError)
+	headers.Set(corev1.PortHeader, strconv.Itoa(int(port)))
+	headers.Set(corev1.PortForwardRequestIDHeader, "0")
+	errorStream, err := conn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("error creating error stream for port %d: %v", port, err)
+	}
+	// the error stream is only read
+	errorStream.Close()
+
+	// buffered, for the goroutine not to leak when returning before reading from it
+	errorChan := make(chan error, 1)
+	go func() {
+		message, err := ioutil.ReadAll(errorStream)
+		switch {
+		case err != nil:
+			errorChan <- fmt.Errorf("error reading from error stream for port %d: %v", port, err)
+		case len(message) > 0:
+			errorChan <- fmt.Errorf("an error occurred forwarding port %d: %v", port, string(message))
+		}
+		close(errorChan)
+	}()
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeData)
+	dataStream, err := conn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("error creating forwarding stream for port %d: %v", port, err)
+	}
+
+	remoteDone := make(chan struct{})
+	localError := make(chan error, 1)
+	go func() {
+		// copy from the foreign pod to the home client
+		if _, err := io.Copy(stream, dataStream); err != nil {
+			klog.V(4).Infof("error copying from the foreign pod %v/%v: %v", foreignNamespace, foreignPod.Name, err)
+		}
+		close(remoteDone)
+	}()
+	go func() {
+		// inform the foreign side that the home client is done
+		defer dataStream.Close()
+		// copy from the home client to the foreign pod
+		if _, err := io.Copy(dataStream, stream); err != nil {
+			localError <- err
 		}
+	}()
+
+	select {
+	case <-remoteDone:
+	case err = <-localError:
+		return fmt.Errorf("error copying to the foreign pod %v/%v: %v", foreignNamespace, foreignPod.Name, err)
+	}
+
+	// wait for any error from the foreign side
+	return <-errorChan
+}
+
+// GetContainerLogs retrieves the logs of a container by name from the provider. When following the logs of a
+// running container, the stream is resumed after transient errors of the foreign API server.
+func (p *LiqoProvider) GetContainerLogs(ctx context.Context, homeNamespace string, homePodName string, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error) {
+	foreignNamespace, err := p.namespaceMapper.NatNamespace(homeNamespace, false)
+	if err != nil {
+		return nil, err
+	}
+
+	foreignObj, err := p.apiController.CacheManager().GetForeignApiByIndex(apimgmgt.Pods, foreignNamespace, homePodName)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while retrieving foreign pod")
+	}
+	foreignPod := foreignObj.(*corev1.Pod)
+
+	logOptions := forgeLogOptions(containerName, opts)
+	open := func(ctx context.Context, logOptions *corev1.PodLogOptions) (io.ReadCloser, error) {
+		return p.foreignClient.CoreV1().Pods(foreignNamespace).GetLogs(foreignPod.Name, logOptions).Stream(ctx)
 	}
 
-	res.Node.CPU = &stats.CPUStats{
-		Time:           t,
-		UsageNanoCores: &nodeTotalNanoCore,
+	var stream io.ReadCloser
+	if opts.Follow && !opts.Previous {
+		stream, err = newReconnectingLogStream(ctx, open, logOptions)
+	} else {
+		stream, err = open(ctx, logOptions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get stream from logs request: %v", err)
 	}
-	res.Node.Memory = &stats.MemoryStats{
-		Time:            t,
-		UsageBytes:      &nodeTotalNanoBytes,
-		WorkingSetBytes: &nodeTotalNanoBytes,
+	return stream, nil
+}
+
+// forgeLogOptions translates the options of a log request into the PodLogOptions for the foreign cluster.
+func forgeLogOptions(containerName string, opts api.ContainerLogOpts) *corev1.PodLogOptions {
+	logOptions := &corev1.PodLogOptions{
+		Container:  containerName,
+		Follow:     opts.Follow,
+		Previous:   opts.Previous,
+		Timestamps: opts.Timestamps,
 	}
 
-	return res, nil
+	if opts.SinceSeconds > 0 {
+		sinceSeconds := opts.SinceSeconds
+		logOptions.SinceSeconds = &sinceSeconds
+	}
+	if !opts.SinceTime.IsZero() {
+		sinceTime := opts.SinceTime
+		logOptions.SinceTime = &sinceTime
+	}
+	if opts.LimitBytes > 0 {
+		limitBytes := opts.LimitBytes
+		logOptions.LimitBytes = &limitBytes
+	}
+	if opts.Tail > 0 {
+		tail := opts.Tail
+		logOptions.TailLines = &tail
+	}
+	return logOptions
 }
 
 // NotifyPods is called to set a pod informing callback function. This should be called before any operations are ready
@@ -435,4 +639,7 @@ func (p *LiqoProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, err
 func (p *LiqoProvider) NotifyPods(_ context.Context, notifier func(interface{})) {
 	p.apiController.SetInformingFunc(apimgmgt.Pods, notifier)
 	p.apiController.SetInformingFunc(apimgmgt.ReplicaSets, notifier)
+	p.watchForeignDaemonSets(notifier)
+	go wait.Until(p.refreshHomeServiceAccountTokens, homeTokensResyncPeriod, p.foreignPodWatcherStop)
+	go wait.Until(p.resyncSharedReplicaSets, sharedReplicaSetsResyncPeriod, p.foreignPodWatcherStop)
 }
@@ -4,11 +4,9 @@ import (
 	"bytes"
 	"context"
 	"flag"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
 	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
-	test2 "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/controller/test"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
-	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping"
-	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping/test"
 	test3 "github.com/liqotech/liqo/pkg/virtualKubelet/storage/test"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -16,33 +14,19 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/klog"
 	"strings"
 )
 
 var _ = Describe("Pods", func() {
 	var (
-		provider              *LiqoProvider
-		namespaceMapper       namespacesMapping.MapperController
-		namespaceNattingTable *test.MockNamespaceMapper
-		foreignClient         kubernetes.Interface
+		provider      *LiqoProvider
+		foreignClient kubernetes.Interface
 	)
 
 	BeforeEach(func() {
-		foreignClient = fake.NewSimpleClientset()
-		namespaceNattingTable = &test.MockNamespaceMapper{Cache: map[string]string{}}
-		namespaceNattingTable.Cache["homeNamespace"] = "homeNamespace-natted"
-		namespaceMapper = test.NewMockNamespaceMapperController(namespaceNattingTable)
-		mockManager := &test3.MockManager{
-			HomeCache:    map[string]map[apimgmt.ApiType]map[string]metav1.Object{},
-			ForeignCache: map[string]map[apimgmt.ApiType]map[string]metav1.Object{},
-		}
-		provider = &LiqoProvider{
-			namespaceMapper: namespaceMapper,
-			foreignClient:   foreignClient,
-			apiController:   &test2.MockController{Manager: mockManager},
-		}
+		provider, _, _ = newTestProvider(nil)
+		foreignClient = provider.foreignClient
 	})
 
 	Context("with legit input pod", func() {
@@ -59,7 +43,6 @@ var _ = Describe("Pods", func() {
 						Namespace: "homeNamespace",
 					},
 				}
-				forge.InitForger(namespaceMapper)
 			})
 
 			/*
@@ -80,6 +63,93 @@ var _ = Describe("Pods", func() {
 				Expect(err).NotTo(HaveOccurred())
 			})
 
+			Describe("update pod with the foreign replica existing", func() {
+				var (
+					mockManager *test3.MockManager
+					foreignPod  *corev1.Pod
+				)
+
+				BeforeEach(func() {
+					reflectedLabels := map[string]string{
+						"app":                          "test",
+						"removed":                      "true",
+						forge.LiqoOutgoingKey:          "",
+						virtualKubelet.ReflectedpodKey: "testObject",
+					}
+					replicaset := &appsv1.ReplicaSet{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testObject",
+							Namespace: "homeNamespace-natted",
+							Labels:    reflectedLabels,
+						},
+						Spec: appsv1.ReplicaSetSpec{
+							Selector: &metav1.LabelSelector{MatchLabels: reflectedLabels},
+							Template: corev1.PodTemplateSpec{
+								ObjectMeta: metav1.ObjectMeta{
+									Labels:      map[string]string{"app": "test", "removed": "true", "stale": "true"},
+									Annotations: map[string]string{"stale": "true"},
+								},
+								Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c1", Image: "image:v1"}}},
+							},
+						},
+					}
+					foreignPod = &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testObject-abcde",
+							Namespace: "homeNamespace-natted",
+							Labels: map[string]string{
+								"app": "test", "removed": "true", "stale": "true",
+								forge.LiqoOutgoingKey: "", virtualKubelet.ReflectedpodKey: "testObject",
+							},
+							Annotations: map[string]string{"stale": "true", "foreign": "true"},
+						},
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c1", Image: "image:v1"}}},
+					}
+
+					pod.Labels = map[string]string{"app": "test", "new": "true"}
+					pod.Annotations = map[string]string{"new": "true"}
+					pod.Spec.Containers = []corev1.Container{{Name: "c1", Image: "image:v2"}}
+
+					_, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Create(context.TODO(), replicaset, metav1.CreateOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					_, err = foreignClient.CoreV1().Pods("homeNamespace-natted").Create(context.TODO(), foreignPod, metav1.CreateOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					mockManager = provider.apiController.CacheManager().(*test3.MockManager)
+					mockManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, foreignPod)
+					mockManager.AddHomeEntry("homeNamespace", apimgmt.Pods, pod)
+				})
+
+				It("reflects the mutable fields to the foreign pod and replicaset", func() {
+					Expect(provider.UpdatePod(context.TODO(), pod)).To(Succeed())
+
+					updatedPod, err := foreignClient.CoreV1().Pods("homeNamespace-natted").Get(context.TODO(), foreignPod.Name, metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(updatedPod.Spec.Containers[0].Image).To(Equal("image:v2"))
+					// the labels selecting the pod are kept, while the ones previously reflected are removed
+					Expect(updatedPod.Labels).To(Equal(map[string]string{
+						"app": "test", "removed": "true", "new": "true",
+						forge.LiqoOutgoingKey: "", virtualKubelet.ReflectedpodKey: "testObject",
+					}))
+					// the annotations set by the foreign cluster are kept
+					Expect(updatedPod.Annotations).To(Equal(map[string]string{"new": "true", "foreign": "true"}))
+
+					rs, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), "testObject", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rs.Spec.Template.Spec.Containers[0].Image).To(Equal("image:v2"))
+					Expect(rs.Spec.Template.Labels).To(Equal(map[string]string{"app": "test", "removed": "true", "new": "true"}))
+					Expect(rs.Spec.Template.Annotations).To(Equal(map[string]string{"new": "true"}))
+				})
+
+				It("returns the home pod as reflected in the foreign cluster", func() {
+					reflected, err := provider.GetPod(context.TODO(), "homeNamespace", "testObject")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(reflected.Spec.Containers[0].Image).To(Equal("image:v1"))
+					// only the keys of the home pod are considered, the ones set by the foreign cluster are ignored
+					Expect(reflected.Labels).To(Equal(map[string]string{"app": "test"}))
+					Expect(reflected.Annotations).To(BeEmpty())
+				})
+			})
+
 			Describe("delete pod", func() {
 				var (
 					replicaset *appsv1.ReplicaSet
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	nettypes "github.com/liqotech/liqo/apis/net/v1alpha1"
 	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
@@ -14,6 +15,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
@@ -29,8 +31,12 @@ type LiqoProvider struct { // nolint:golint]
 	advClient            *crdClient.CRDClient
 	tunEndClient         *crdClient.CRDClient
 	nntClient            *crdClient.CRDClient
+	foreignClusterClient *crdClient.CRDClient
 	foreignClient        kubernetes.Interface
 	foreignMetricsClient metricsv.Interface
+	homeNamespaces       corelisters.NamespaceLister
+	// whether the namespaces with neither a NamespaceOffloading nor the NamespaceEnabledLabel may be offloaded
+	allowAllNamespaces bool
 
 	operatingSystem    string
 	internalIP         string
@@ -42,10 +48,19 @@ type LiqoProvider struct { // nolint:golint]
 	nodeController     *node.NodeController
 	providerKubeconfig string
 	restConfig         *rest.Config
+	// the SPDY streams towards the foreign API server, for the exec, attach and port-forward requests
+	newExecutor spdyExecutorFactory
+	newDialer   spdyDialerFactory
 
 	nodeName              options.Option
 	RemoteRemappedPodCidr options.Option
 	LocalRemappedPodCidr  options.Option
+	storageClassMapping   options.Option
+	// set when the Advertisement has expired without being renewed: the node is kept NotReady
+	advExpired bool
+	statsCache statsCache
+	// the home pods offloaded as replicas of the shared foreign replicasets
+	sharedReplicaSets sharedReplicaSets
 
 	foreignPodWatcherStop chan struct{}
 	nodeUpdateStop        chan struct{}
@@ -53,7 +68,7 @@ type LiqoProvider struct { // nolint:golint]
 }
 
 // NewKubernetesProviderKubernetes creates a new KubernetesV0Provider. Kubernetes legacy provider does not implement the new asynchronous podnotifier interface
-func NewLiqoProvider(nodeName, foreignClusterId, homeClusterId string, internalIP string, daemonEndpointPort int32, kubeconfig, remoteKubeConfig string) (*LiqoProvider, error) {
+func NewLiqoProvider(nodeName, foreignClusterId, homeClusterId string, internalIP string, daemonEndpointPort int32, kubeconfig, remoteKubeConfig string, allowAllNamespaces bool) (*LiqoProvider, error) {
 	var err error
 
 	if err = nattingv1.AddToScheme(clientgoscheme.Scheme); err != nil {
@@ -75,6 +90,11 @@ func NewLiqoProvider(nodeName, foreignClusterId, homeClusterId string, internalI
 		return nil, err
 	}
 
+	foreignClusterClient, err := discoveryv1alpha1.CreateForeignClusterClient(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
 	restConfig, err := crdClient.NewKubeconfig(remoteKubeConfig, &schema.GroupVersion{})
 	if err != nil {
 		return nil, err
@@ -99,15 +119,18 @@ func NewLiqoProvider(nodeName, foreignClusterId, homeClusterId string, internalI
 	remoteRemappedPodCIDROpt := optTypes.NewNetworkingOption(optTypes.RemoteRemappedPodCIDR, "")
 	localRemappedPodCIDROpt := optTypes.NewNetworkingOption(optTypes.LocalRemappedPodCIDR, "")
 	virtualNodeNameOpt := optTypes.NewNetworkingOption(optTypes.VirtualNodeName, optTypes.NetworkingValue(nodeName))
+	storageClassMappingOpt := optTypes.NewStorageOption(optTypes.StorageClassMapping, "")
 
 	forge.InitForger(mapper, remoteRemappedPodCIDROpt, localRemappedPodCIDROpt, virtualNodeNameOpt)
 
 	opts := forgeOptionsMap(
 		remoteRemappedPodCIDROpt,
 		localRemappedPodCIDROpt,
-		virtualNodeNameOpt)
+		virtualNodeNameOpt,
+		storageClassMappingOpt)
 
 	tepReady := make(chan struct{})
+	foreignPodWatcherStop := make(chan struct{}, 1)
 
 	provider := LiqoProvider{
 		apiController:         controller.NewApiController(client.Client(), foreignClient, mapper, opts, tepReady),
@@ -120,14 +143,20 @@ func NewLiqoProvider(nodeName, foreignClusterId, homeClusterId string, internalI
 		homeClusterID:         homeClusterId,
 		providerKubeconfig:    remoteKubeConfig,
 		nntClient:             client,
-		foreignPodWatcherStop: make(chan struct{}, 1),
+		foreignPodWatcherStop: foreignPodWatcherStop,
 		restConfig:            restConfig,
+		newExecutor:           newSPDYExecutorFactory(restConfig),
+		newDialer:             newSPDYDialerFactory(restConfig),
 		foreignClient:         foreignClient,
 		foreignMetricsClient:  foreignMetricsClient,
+		homeNamespaces:        newHomeNamespaceLister(client.Client(), foreignPodWatcherStop),
+		allowAllNamespaces:    allowAllNamespaces,
 		advClient:             advClient,
 		tunEndClient:          tepClient,
+		foreignClusterClient:  foreignClusterClient,
 		RemoteRemappedPodCidr: remoteRemappedPodCIDROpt,
 		LocalRemappedPodCidr:  localRemappedPodCIDROpt,
+		storageClassMapping:   storageClassMappingOpt,
 		tepReady:              tepReady,
 	}
 
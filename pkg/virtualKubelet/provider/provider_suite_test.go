@@ -1,13 +1,77 @@
 package provider
 
 import (
+	"context"
 	"testing"
 
+	nattingv1 "github.com/liqotech/liqo/apis/virtualKubelet/v1alpha1"
+	"github.com/liqotech/liqo/pkg/crdClient"
+	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	test2 "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/controller/test"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping/test"
+	test3 "github.com/liqotech/liqo/pkg/virtualKubelet/storage/test"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 )
 
+// testProviderStop stops the informers of the provider of the running test.
+var testProviderStop chan struct{}
+
 func TestProvider(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Provider Suite")
 }
+
+var _ = AfterEach(func() {
+	if testProviderStop != nil {
+		close(testProviderStop)
+		testProviderStop = nil
+	}
+})
+
+// newTestProvider returns a provider offloading to the foreign-cluster cluster, with fake home and foreign clients,
+// which maps the homeNamespace namespace, enabled to be offloaded, to the homeNamespace-natted one. The home client
+// is built from the given config, if any.
+func newTestProvider(homeConfig *rest.Config) (*LiqoProvider, *test.MockNamespaceMapper, *test3.MockManager) {
+	crdClient.Fake = true
+	nntClient, err := crdClient.NewFromConfig(homeConfig)
+	Expect(err).NotTo(HaveOccurred())
+
+	namespaceNattingTable := &test.MockNamespaceMapper{
+		Cache:      map[string]string{"homeNamespace": "homeNamespace-natted"},
+		Offloading: map[string]*nattingv1.NamespaceOffloadingSpec{"homeNamespace": {Enabled: true}},
+	}
+	namespaceMapper := test.NewMockNamespaceMapperController(namespaceNattingTable)
+	forge.InitForger(namespaceMapper)
+	mockManager := &test3.MockManager{
+		HomeCache:    map[string]map[apimgmt.ApiType]map[string]metav1.Object{},
+		ForeignCache: map[string]map[apimgmt.ApiType]map[string]metav1.Object{},
+	}
+
+	testProviderStop = make(chan struct{})
+	provider := &LiqoProvider{
+		namespaceMapper:       namespaceMapper,
+		foreignClient:         fake.NewSimpleClientset(),
+		nntClient:             nntClient,
+		homeNamespaces:        newHomeNamespaceLister(nntClient.Client(), testProviderStop),
+		apiController:         &test2.MockController{Manager: mockManager},
+		foreignClusterId:      "foreign-cluster",
+		foreignPodWatcherStop: testProviderStop,
+	}
+	return provider, namespaceNattingTable, mockManager
+}
+
+// createHomeNamespace creates the home namespace, waiting for it to be cached by the provider.
+func createHomeNamespace(provider *LiqoProvider, namespace *corev1.Namespace) {
+	_, err := provider.nntClient.Client().CoreV1().Namespaces().Create(context.TODO(), namespace, metav1.CreateOptions{})
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(func() error {
+		_, err := provider.homeNamespaces.Get(namespace.Name)
+		return err
+	}).Should(Succeed())
+}
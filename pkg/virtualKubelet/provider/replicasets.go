@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	apimgmgt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sync"
+	"time"
+)
+
+// sharedReplicaSetsResyncPeriod is the period of the reconciliation of the replicas of the shared foreign replicasets.
+const sharedReplicaSetsResyncPeriod = 1 * time.Minute
+
+// sharedReplicaSets stores the home pods offloaded as the replicas of each shared foreign replicaset, indexed by
+// foreign namespace and name. The replicas are changed while holding the lock, to avoid lost updates.
+type sharedReplicaSets struct {
+	sync.Mutex
+	pods map[string]map[string]struct{}
+}
+
+// sharedReplicaSetOwner returns the name of the home replicaset owning the pod, if the pod has to be offloaded as a
+// replica of a shared foreign replicaset according to the offloading mode of its namespace.
+func (p *LiqoProvider) sharedReplicaSetOwner(homePod *corev1.Pod) (string, bool) {
+	owner := metav1.GetControllerOf(homePod)
+	if owner == nil || owner.Kind != "ReplicaSet" {
+		return "", false
+	}
+	// the home tokens are bound to each single pod
+	if policy, _ := forge.ServiceAccountTokenPolicy(homePod); policy == virtualKubelet.ServiceAccountTokenHome {
+		return "", false
+	}
+
+	namespace, err := p.homeNamespaces.Get(homePod.Namespace)
+	if err != nil {
+		klog.Warningf("PROVIDER: unable to retrieve the offloading mode of namespace %s, falling back to %s: %v",
+			homePod.Namespace, virtualKubelet.OffloadingModePod, err)
+		return "", false
+	}
+	return owner.Name, namespace.Annotations[virtualKubelet.OffloadingModeAnnotation] == virtualKubelet.OffloadingModeController
+}
+
+// isSharedReplica returns whether the home pod has been offloaded as a replica of a shared foreign replicaset, and
+// the name of the replicaset.
+func (p *LiqoProvider) isSharedReplica(homePod *corev1.Pod, foreignNamespace string) (string, bool) {
+	owner := metav1.GetControllerOf(homePod)
+	if owner == nil || owner.Kind != "ReplicaSet" {
+		return "", false
+	}
+	foreignObj, err := p.apiController.CacheManager().GetForeignNamespacedObject(apimgmgt.ReplicaSets, foreignNamespace, owner.Name)
+	if err != nil {
+		return "", false
+	}
+	_, ok := foreignObj.(*appsv1.ReplicaSet).Labels[virtualKubelet.ReflectedReplicaSetKey]
+	return owner.Name, ok
+}
+
+// createSharedReplica offloads the home pod as a replica of the shared foreign replicaset, creating it if needed.
+func (p *LiqoProvider) createSharedReplica(ctx context.Context, homePod, foreignPod *corev1.Pod, replicaSetName string) error {
+	p.sharedReplicaSets.Lock()
+	defer p.sharedReplicaSets.Unlock()
+
+	pods := p.sharedReplicaSetPods(homePod.Namespace, foreignPod.Namespace, replicaSetName)
+	pods[homePod.Name] = struct{}{}
+	return p.scaleSharedReplicaSet(ctx, foreignPod.Namespace, replicaSetName, foreignPod)
+}
+
+// deleteSharedReplica deletes the foreign replica of the home pod, scaling down the shared foreign replicaset. The
+// replica is deleted before scaling down, so that the foreign replicaset does not choose a different one.
+func (p *LiqoProvider) deleteSharedReplica(ctx context.Context, homePod *corev1.Pod, foreignNamespace, replicaSetName string) error {
+	p.sharedReplicaSets.Lock()
+	defer p.sharedReplicaSets.Unlock()
+
+	pods := p.sharedReplicaSetPods(homePod.Namespace, foreignNamespace, replicaSetName)
+	delete(pods, homePod.Name)
+
+	if foreignObj, err := p.apiController.CacheManager().GetForeignApiByIndex(apimgmgt.Pods, foreignNamespace, homePod.Name); err == nil {
+		if err = p.deleteForeignReplica(ctx, foreignObj.(*corev1.Pod)); err != nil {
+			return err
+		}
+	}
+	return p.scaleSharedReplicaSet(ctx, foreignNamespace, replicaSetName, nil)
+}
+
+// resyncSharedReplicaSets reconciles the replicas of the shared foreign replicasets with the home pods offloaded,
+// e.g. in case of deletions missed by the provider.
+func (p *LiqoProvider) resyncSharedReplicaSets() {
+	ctx := context.TODO()
+
+	for home, foreign := range p.namespaceMapper.MappedNamespaces() {
+		foreignObjs, err := p.apiController.CacheManager().ListForeignNamespacedObject(apimgmgt.ReplicaSets, foreign)
+		if err != nil {
+			klog.Warningf("PROVIDER: shared replicasets in namespace %s not reconciled: %v", foreign, err)
+			continue
+		}
+
+		for _, obj := range foreignObjs {
+			replicaSet := obj.(*appsv1.ReplicaSet)
+			if _, ok := replicaSet.Labels[virtualKubelet.ReflectedReplicaSetKey]; !ok {
+				continue
+			}
+			if err := p.resyncSharedReplicaSet(ctx, home, foreign, replicaSet.Name); err != nil {
+				klog.Warningf("PROVIDER: shared replicaset %s/%s not reconciled: %v", foreign, replicaSet.Name, err)
+			}
+		}
+	}
+}
+
+// resyncSharedReplicaSet deletes the foreign replicas of the home pods no longer offloaded, and scales the shared
+// foreign replicaset accordingly.
+func (p *LiqoProvider) resyncSharedReplicaSet(ctx context.Context, homeNamespace, foreignNamespace, replicaSetName string) error {
+	p.sharedReplicaSets.Lock()
+	defer p.sharedReplicaSets.Unlock()
+
+	delete(p.sharedReplicaSets.pods, foreignNamespace+"/"+replicaSetName)
+	pods := p.sharedReplicaSetPods(homeNamespace, foreignNamespace, replicaSetName)
+
+	foreignObjs, err := p.apiController.CacheManager().ListForeignNamespacedObject(apimgmgt.Pods, foreignNamespace)
+	if err != nil {
+		return err
+	}
+	for _, obj := range foreignObjs {
+		foreignPod := obj.(*corev1.Pod)
+		homePodName, ok := foreignPod.Labels[virtualKubelet.ReflectedpodKey]
+		if !ok || foreignPod.Labels[virtualKubelet.ReflectedReplicaSetKey] != replicaSetName || foreignPod.DeletionTimestamp != nil {
+			continue
+		}
+		if _, ok := pods[homePodName]; !ok {
+			if err = p.deleteForeignReplica(ctx, foreignPod); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.scaleSharedReplicaSet(ctx, foreignNamespace, replicaSetName, nil)
+}
+
+// sharedReplicaSetPods returns the home pods offloaded as replicas of the shared foreign replicaset, initializing
+// them with the home pods of the home replicaset running on the virtual node. It must be called holding the lock.
+func (p *LiqoProvider) sharedReplicaSetPods(homeNamespace, foreignNamespace, replicaSetName string) map[string]struct{} {
+	key := foreignNamespace + "/" + replicaSetName
+	if pods, ok := p.sharedReplicaSets.pods[key]; ok {
+		return pods
+	}
+
+	pods := map[string]struct{}{}
+	homeObjs, err := p.apiController.CacheManager().ListHomeNamespacedObject(apimgmgt.Pods, homeNamespace)
+	if err != nil {
+		klog.Warningf("PROVIDER: unable to list the home pods of replicaset %s/%s: %v", homeNamespace, replicaSetName, err)
+	}
+	for _, obj := range homeObjs {
+		homePod := obj.(*corev1.Pod)
+		owner := metav1.GetControllerOf(homePod)
+		if owner == nil || owner.Kind != "ReplicaSet" || owner.Name != replicaSetName || homePod.Spec.NodeName != forge.LiqoNodeName() ||
+			homePod.DeletionTimestamp != nil || homePod.Status.Phase == corev1.PodFailed || homePod.Status.Phase == corev1.PodSucceeded {
+			continue
+		}
+		pods[homePod.Name] = struct{}{}
+	}
+
+	if p.sharedReplicaSets.pods == nil {
+		p.sharedReplicaSets.pods = map[string]map[string]struct{}{}
+	}
+	p.sharedReplicaSets.pods[key] = pods
+	return pods
+}
+
+// scaleSharedReplicaSet sets the replicas of the shared foreign replicaset to the number of home pods offloaded,
+// deleting it once they are over. If the replicaset does not exist, it is created from the template, if any.
+func (p *LiqoProvider) scaleSharedReplicaSet(ctx context.Context, foreignNamespace, replicaSetName string, template *corev1.Pod) error {
+	key := foreignNamespace + "/" + replicaSetName
+	replicas := int32(len(p.sharedReplicaSets.pods[key]))
+
+	if replicas == 0 {
+		delete(p.sharedReplicaSets.pods, key)
+		err := p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Delete(ctx, replicaSetName, metav1.DeleteOptions{})
+		if err != nil && !kerror.IsNotFound(err) {
+			return errors.Wrap(err, "Unable to delete shared foreign replicaset")
+		}
+		klog.V(3).Infof("PROVIDER: shared replicaset %v/%v deleted on remote cluster", foreignNamespace, replicaSetName)
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		replicaSet, err := p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Get(ctx, replicaSetName, metav1.GetOptions{})
+		if kerror.IsNotFound(err) {
+			if template == nil {
+				return nil
+			}
+			replicaSet = forge.SharedReplicasetFromPod(template, replicaSetName, replicas)
+			if _, err = p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Create(ctx, replicaSet, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			klog.V(3).Infof("PROVIDER: shared replicaset %v/%v successfully created on remote cluster", foreignNamespace, replicaSetName)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if replicaSet.Spec.Replicas != nil && *replicaSet.Spec.Replicas == replicas {
+			return nil
+		}
+		replicaSet.Spec.Replicas = &replicas
+		if _, err = p.foreignClient.AppsV1().ReplicaSets(foreignNamespace).Update(ctx, replicaSet, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.V(3).Infof("PROVIDER: shared replicaset %v/%v scaled to %d replicas", foreignNamespace, replicaSetName, replicas)
+		return nil
+	})
+}
+
+func (p *LiqoProvider) deleteForeignReplica(ctx context.Context, foreignPod *corev1.Pod) error {
+	err := p.foreignClient.CoreV1().Pods(foreignPod.Namespace).Delete(ctx, foreignPod.Name, metav1.DeleteOptions{})
+	if err != nil && !kerror.IsNotFound(err) {
+		return errors.Wrap(err, "Unable to delete foreign replica")
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	test3 "github.com/liqotech/liqo/pkg/virtualKubelet/storage/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var _ = Describe("Shared ReplicaSets", func() {
+	var (
+		provider      *LiqoProvider
+		mockManager   *test3.MockManager
+		foreignClient kubernetes.Interface
+		homeClient    kubernetes.Interface
+		namespace     *corev1.Namespace
+		pods          []*corev1.Pod
+	)
+
+	newPod := func(name, ownerKind string) *corev1.Pod {
+		controller := true
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "homeNamespace",
+				Labels:    map[string]string{"app": "frontend"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "apps/v1",
+					Kind:       ownerKind,
+					Name:       "frontend-5d4f8",
+					Controller: &controller,
+				}},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "c1", Image: "image:v1"}},
+				NodeName:   forge.LiqoNodeName(),
+			},
+		}
+	}
+
+	createPods := func() {
+		for _, pod := range pods {
+			_, err := homeClient.CoreV1().Pods("homeNamespace").Create(context.TODO(), pod, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			mockManager.AddHomeEntry("homeNamespace", apimgmt.Pods, pod)
+			Expect(provider.CreatePod(context.TODO(), pod)).To(Succeed())
+		}
+	}
+
+	getSharedReplicaSet := func() *appsv1.ReplicaSet {
+		rs, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), "frontend-5d4f8", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return rs
+	}
+
+	BeforeEach(func() {
+		provider, _, mockManager = newTestProvider(nil)
+		homeClient = provider.nntClient.Client()
+		foreignClient = provider.foreignClient
+
+		namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "homeNamespace",
+			Annotations: map[string]string{virtualKubelet.OffloadingModeAnnotation: virtualKubelet.OffloadingModeController},
+		}}
+		pods = []*corev1.Pod{newPod("frontend-5d4f8-aaaaa", "ReplicaSet"), newPod("frontend-5d4f8-bbbbb", "ReplicaSet")}
+	})
+
+	JustBeforeEach(func() {
+		createHomeNamespace(provider, namespace)
+		createPods()
+	})
+
+	When("the controller offloading mode is selected", func() {
+		It("offloads the pods as replicas of a single foreign replicaset", func() {
+			rs := getSharedReplicaSet()
+			Expect(*rs.Spec.Replicas).To(BeNumerically("==", 2))
+			Expect(rs.Labels).To(HaveKeyWithValue(virtualKubelet.ReflectedReplicaSetKey, "frontend-5d4f8"))
+			Expect(rs.Labels).NotTo(HaveKey(virtualKubelet.ReflectedpodKey))
+			Expect(rs.Spec.Selector.MatchLabels).To(HaveKeyWithValue(virtualKubelet.ReflectedReplicaSetKey, "frontend-5d4f8"))
+			Expect(rs.Spec.Template.Labels).To(HaveKeyWithValue("app", "frontend"))
+
+			replicasets, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicasets.Items).To(HaveLen(1))
+		})
+
+		It("deletes the foreign replicas of the deleted pods and scales the foreign replicaset", func() {
+			mockManager.AddForeignEntry("homeNamespace-natted", apimgmt.ReplicaSets, getSharedReplicaSet())
+			replica := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:      "frontend-5d4f8-xxxxx",
+				Namespace: "homeNamespace-natted",
+				Labels: map[string]string{
+					virtualKubelet.ReflectedReplicaSetKey: "frontend-5d4f8",
+					virtualKubelet.ReflectedpodKey:        pods[0].Name,
+				},
+			}}
+			_, err := foreignClient.CoreV1().Pods("homeNamespace-natted").Create(context.TODO(), replica, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			mockManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, replica)
+
+			now := metav1.Now()
+			pods[0].DeletionTimestamp = &now
+			Expect(provider.DeletePod(context.TODO(), pods[0])).To(Succeed())
+			Expect(*getSharedReplicaSet().Spec.Replicas).To(BeNumerically("==", 1))
+			_, err = foreignClient.CoreV1().Pods("homeNamespace-natted").Get(context.TODO(), replica.Name, metav1.GetOptions{})
+			Expect(err).To(HaveOccurred())
+
+			pods[1].DeletionTimestamp = &now
+			Expect(provider.DeletePod(context.TODO(), pods[1])).To(Succeed())
+			replicasets, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicasets.Items).To(BeEmpty())
+		})
+	})
+
+	When("the pods are not owned by a replicaset", func() {
+		BeforeEach(func() {
+			pods = []*corev1.Pod{newPod("frontend-0", "StatefulSet")}
+		})
+
+		It("offloads each pod with its own foreign replicaset", func() {
+			rs, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), "frontend-0", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*rs.Spec.Replicas).To(BeNumerically("==", 1))
+		})
+	})
+
+	When("the pod offloading mode is selected", func() {
+		BeforeEach(func() {
+			namespace.Annotations = nil
+		})
+
+		It("offloads each pod with its own foreign replicaset", func() {
+			replicasets, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicasets.Items).To(HaveLen(2))
+			for i := range replicasets.Items {
+				Expect(replicasets.Items[i].Labels).To(HaveKey(virtualKubelet.ReflectedpodKey))
+			}
+		})
+	})
+})
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var _ = Describe("Remote scheduling constraints", func() {
+	var (
+		provider      *LiqoProvider
+		foreignClient kubernetes.Interface
+		homeClient    kubernetes.Interface
+		pod           *corev1.Pod
+	)
+
+	virtualNodesExcluded := corev1.NodeSelectorRequirement{
+		Key:      "type",
+		Operator: corev1.NodeSelectorOpNotIn,
+		Values:   []string{"virtual-node"},
+	}
+
+	BeforeEach(func() {
+		provider, _, _ = newTestProvider(nil)
+		homeClient = provider.nntClient.Client()
+		foreignClient = provider.foreignClient
+
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "testObject",
+				Namespace:   "homeNamespace",
+				Annotations: map[string]string{},
+			},
+			Spec: corev1.PodSpec{
+				Containers:   []corev1.Container{{Name: "c1", Image: "image:v1"}},
+				NodeSelector: map[string]string{"type": "virtual-node"},
+				Tolerations:  []corev1.Toleration{{Key: "virtual-node.liqo.io/not-allowed", Operator: corev1.TolerationOpExists}},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		_, err := homeClient.CoreV1().Pods("homeNamespace").Create(context.TODO(), pod, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provider.CreatePod(context.TODO(), pod)).To(Succeed())
+	})
+
+	getForeignSpec := func() corev1.PodSpec {
+		rs, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return rs.Spec.Template.Spec
+	}
+
+	When("no remote constraint is set", func() {
+		It("only excludes the virtual nodes", func() {
+			spec := getForeignSpec()
+			Expect(spec.NodeSelector).To(BeEmpty())
+			Expect(spec.Tolerations).To(Equal(pod.Spec.Tolerations))
+			Expect(spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(Equal(
+				[]corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{virtualNodesExcluded}}}))
+		})
+	})
+
+	When("the remote constraints are set", func() {
+		BeforeEach(func() {
+			pod.Annotations[virtualKubelet.RemoteNodeSelectorAnnotation] = "accelerator=nvidia,disk=ssd"
+			pod.Annotations[virtualKubelet.RemoteTolerationsAnnotation] =
+				`[{"key":"nvidia.com/gpu","operator":"Exists","effect":"NoSchedule"}]`
+			pod.Annotations[virtualKubelet.RemoteAffinityAnnotation] = `{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":
+				{"nodeSelectorTerms":[{"matchExpressions":[{"key":"topology.kubernetes.io/zone","operator":"In","values":["a","b"]}]},
+				{"matchExpressions":[{"key":"dedicated","operator":"Exists"}]}]}}}`
+			pod.Annotations[virtualKubelet.RemoteTopologySpreadConstraintsAnnotation] =
+				`[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"DoNotSchedule"}]`
+		})
+
+		It("passes them to the foreign pod", func() {
+			spec := getForeignSpec()
+			Expect(spec.NodeSelector).To(Equal(map[string]string{"accelerator": "nvidia", "disk": "ssd"}))
+			Expect(spec.Tolerations).To(HaveLen(2))
+			Expect(spec.Tolerations[1].Key).To(Equal("nvidia.com/gpu"))
+			Expect(spec.TopologySpreadConstraints).To(HaveLen(1))
+			Expect(spec.TopologySpreadConstraints[0].TopologyKey).To(Equal("topology.kubernetes.io/zone"))
+
+			terms := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			Expect(terms).To(HaveLen(2))
+			for _, term := range terms {
+				Expect(term.MatchExpressions).To(HaveLen(2))
+				Expect(term.MatchExpressions[1]).To(Equal(virtualNodesExcluded))
+			}
+		})
+	})
+
+	When("a remote constraint is malformed", func() {
+		BeforeEach(func() {
+			pod.Annotations[virtualKubelet.RemoteAffinityAnnotation] = `{"nodeAffinity":`
+		})
+
+		It("rejects the pod", func() {
+			rejected, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rejected.Status.Phase).To(Equal(corev1.PodFailed))
+			Expect(rejected.Status.Reason).To(Equal(podStatusReasonInvalidSchedulingConstraints))
+			Expect(rejected.Status.Message).To(ContainSubstring(virtualKubelet.RemoteAffinityAnnotation))
+
+			replicasets, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicasets.Items).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,217 @@
+package provider
+
+import (
+	"context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	apimgmgt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"time"
+)
+
+const (
+	// podStatusReasonInvalidServiceAccountTokenPolicy is the reason set on the pods with an invalid ServiceAccount
+	// token policy.
+	podStatusReasonInvalidServiceAccountTokenPolicy = "InvalidServiceAccountTokenPolicy"
+	// homeTokensResyncPeriod is the period of the check of the home tokens to be refreshed.
+	homeTokensResyncPeriod = 1 * time.Minute
+	// homeTokensRefreshAnnotation is the annotation of the home token secret storing the time after which its tokens
+	// have to be refreshed.
+	homeTokensRefreshAnnotation = "virtualkubelet.liqo.io/refresh-after"
+)
+
+// setupServiceAccountCredentials prepares in the foreign cluster the ServiceAccount credentials of the pod, according
+// to its token policy.
+func (p *LiqoProvider) setupServiceAccountCredentials(ctx context.Context, homePod *corev1.Pod, foreignNamespace string) error {
+	policy, err := forge.ServiceAccountTokenPolicy(homePod)
+	if err != nil {
+		return err
+	}
+
+	switch policy {
+	case virtualKubelet.ServiceAccountTokenRemote:
+		return p.ensureForeignServiceAccount(ctx, homePod.Spec.ServiceAccountName, foreignNamespace)
+	case virtualKubelet.ServiceAccountTokenHome:
+		return p.reflectHomeServiceAccountTokens(ctx, homePod, foreignNamespace)
+	}
+	return nil
+}
+
+// ensureForeignServiceAccount creates the foreign ServiceAccount, if not existing.
+func (p *LiqoProvider) ensureForeignServiceAccount(ctx context.Context, name, foreignNamespace string) error {
+	if name == "" || name == "default" {
+		// the default ServiceAccount is created by the foreign cluster in each namespace
+		return nil
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: foreignNamespace,
+			Labels:    map[string]string{forge.LiqoOutgoingKey: forge.LiqoNodeName()},
+		},
+	}
+	_, err := p.foreignClient.CoreV1().ServiceAccounts(foreignNamespace).Create(ctx, serviceAccount, metav1.CreateOptions{})
+	if err != nil && !kerror.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "error while creating foreign service account %s/%s", foreignNamespace, name)
+	}
+	return nil
+}
+
+// reflectHomeServiceAccountTokens requests to the home cluster the tokens projected in the volumes of the pod, bound
+// to the home pod, and stores them in the foreign home token secret, together with the CA and the home namespace.
+// The tokens are refreshed once 80% of their validity has elapsed, as the kubelet does.
+func (p *LiqoProvider) reflectHomeServiceAccountTokens(ctx context.Context, homePod *corev1.Pod, foreignNamespace string) error {
+	tokens := forge.HomeServiceAccountTokens(homePod)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	ca, err := p.homeClusterCA()
+	if err != nil {
+		return err
+	}
+	data := map[string][]byte{
+		forge.HomeTokenSecretCAKey:        ca,
+		forge.HomeTokenSecretNamespaceKey: []byte(homePod.Namespace),
+	}
+
+	serviceAccountName := homePod.Spec.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+
+	var refreshAfter time.Time
+	for _, token := range tokens {
+		expirationSeconds := token.ExpirationSeconds
+		request := &authv1.TokenRequest{
+			Spec: authv1.TokenRequestSpec{
+				ExpirationSeconds: &expirationSeconds,
+				BoundObjectRef: &authv1.BoundObjectReference{
+					Kind:       "Pod",
+					APIVersion: "v1",
+					Name:       homePod.Name,
+					UID:        homePod.UID,
+				},
+			},
+		}
+		if token.Audience != "" {
+			request.Spec.Audiences = []string{token.Audience}
+		}
+
+		now := time.Now()
+		response, err := p.nntClient.Client().CoreV1().ServiceAccounts(homePod.Namespace).CreateToken(ctx, serviceAccountName,
+			request, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "error while requesting a token for service account %s/%s", homePod.Namespace, serviceAccountName)
+		}
+		data[token.Key] = []byte(response.Status.Token)
+
+		validity := response.Status.ExpirationTimestamp.Sub(now)
+		if tokenRefresh := now.Add(validity * 8 / 10); refreshAfter.IsZero() || tokenRefresh.Before(refreshAfter) {
+			refreshAfter = tokenRefresh
+		}
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        forge.HomeTokenSecretName(homePod.Name),
+			Namespace:   foreignNamespace,
+			Labels:      map[string]string{forge.LiqoOutgoingKey: forge.LiqoNodeName()},
+			Annotations: map[string]string{homeTokensRefreshAnnotation: refreshAfter.Format(time.RFC3339)},
+		},
+		Data: data,
+		Type: corev1.SecretTypeOpaque,
+	}
+
+	_, err = p.foreignClient.CoreV1().Secrets(foreignNamespace).Create(ctx, secret, metav1.CreateOptions{})
+	if kerror.IsAlreadyExists(err) {
+		_, err = p.foreignClient.CoreV1().Secrets(foreignNamespace).Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error while reflecting the home tokens of pod %s/%s", homePod.Namespace, homePod.Name)
+	}
+
+	klog.V(4).Infof("PROVIDER: home tokens of pod %s/%s reflected, to be refreshed after %v", homePod.Namespace, homePod.Name, refreshAfter)
+	return nil
+}
+
+// refreshHomeServiceAccountTokens refreshes the home tokens of the offloaded pods which are about to expire.
+func (p *LiqoProvider) refreshHomeServiceAccountTokens() {
+	ctx := context.TODO()
+
+	for home, foreign := range p.namespaceMapper.MappedNamespaces() {
+		homeObjs, err := p.apiController.CacheManager().ListHomeNamespacedObject(apimgmgt.Pods, home)
+		if err != nil {
+			klog.Warningf("PROVIDER: home tokens of the pods in namespace %s not refreshed: %v", home, err)
+			continue
+		}
+
+		for _, obj := range homeObjs {
+			homePod := obj.(*corev1.Pod)
+			if policy, err := forge.ServiceAccountTokenPolicy(homePod); err != nil || policy != virtualKubelet.ServiceAccountTokenHome ||
+				homePod.DeletionTimestamp != nil || !isOffloaded(homePod) {
+				continue
+			}
+
+			secret, err := p.foreignClient.CoreV1().Secrets(foreign).Get(ctx, forge.HomeTokenSecretName(homePod.Name), metav1.GetOptions{})
+			switch {
+			case kerror.IsNotFound(err):
+				// the tokens have not been reflected at the creation of the pod
+			case err != nil:
+				klog.Warningf("PROVIDER: home tokens of pod %s/%s not refreshed: %v", homePod.Namespace, homePod.Name, err)
+				continue
+			default:
+				refreshAfter, err := time.Parse(time.RFC3339, secret.Annotations[homeTokensRefreshAnnotation])
+				if err == nil && time.Now().Before(refreshAfter) {
+					continue
+				}
+			}
+
+			if err = p.reflectHomeServiceAccountTokens(ctx, homePod, foreign); err != nil {
+				klog.Warningf("PROVIDER: home tokens of pod %s/%s not refreshed: %v", homePod.Namespace, homePod.Name, err)
+			}
+		}
+	}
+}
+
+// deleteHomeServiceAccountTokens deletes the foreign home token secret of the pod, if any.
+func (p *LiqoProvider) deleteHomeServiceAccountTokens(ctx context.Context, homePodName, foreignNamespace string) error {
+	err := p.foreignClient.CoreV1().Secrets(foreignNamespace).Delete(ctx, forge.HomeTokenSecretName(homePodName), metav1.DeleteOptions{})
+	if err != nil && !kerror.IsNotFound(err) {
+		return errors.Wrap(err, "Unable to delete foreign home token secret")
+	}
+	return nil
+}
+
+// isOffloaded returns whether the home pod has been offloaded to the foreign cluster, i.e. it has the finalizer added
+// before the creation of its foreign replica.
+func isOffloaded(homePod *corev1.Pod) bool {
+	for _, finalizer := range homePod.Finalizers {
+		if finalizer == virtualKubelet.HomePodFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// homeClusterCA returns the CA of the home cluster API server.
+func (p *LiqoProvider) homeClusterCA() ([]byte, error) {
+	config := p.nntClient.Config()
+	switch {
+	case config == nil:
+		return nil, errors.New("home cluster configuration not available")
+	case len(config.CAData) > 0:
+		return config.CAData, nil
+	case config.CAFile != "":
+		return ioutil.ReadFile(config.CAFile)
+	default:
+		return nil, errors.New("home cluster CA not available")
+	}
+}
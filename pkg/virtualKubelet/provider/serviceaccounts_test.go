@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"time"
+)
+
+var _ = Describe("ServiceAccounts", func() {
+	var (
+		provider      *LiqoProvider
+		foreignClient kubernetes.Interface
+		homeClient    kubernetes.Interface
+		pod           *corev1.Pod
+		tokenRequests []*authv1.TokenRequest
+	)
+
+	BeforeEach(func() {
+		provider, _, _ = newTestProvider(&rest.Config{
+			ContentConfig:   rest.ContentConfig{GroupVersion: &schema.GroupVersion{}},
+			TLSClientConfig: rest.TLSClientConfig{CAData: []byte("home-ca")},
+		})
+		homeClient = provider.nntClient.Client()
+		foreignClient = provider.foreignClient
+
+		tokenRequests = nil
+		homeClient.(*fake.Clientset).PrependReactor("create", "serviceaccounts",
+			func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "token" {
+					return false, nil, nil
+				}
+				request := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenRequest)
+				tokenRequests = append(tokenRequests, request)
+				return true, &authv1.TokenRequest{Status: authv1.TokenRequestStatus{
+					Token:               "home-token",
+					ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Hour)),
+				}}, nil
+			})
+
+		expirationSeconds := int64(3607)
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "testObject",
+				Namespace:   "homeNamespace",
+				UID:         "home-uid",
+				Annotations: map[string]string{},
+			},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "builder",
+				Containers: []corev1.Container{{
+					Name:  "c1",
+					Image: "image:v1",
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "config", MountPath: "/etc/config"},
+						{Name: "kube-api-access-abcde", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount"},
+					},
+				}},
+				Volumes: []corev1.Volume{
+					{Name: "config", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{
+							{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}},
+							{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"}}},
+						},
+					}}},
+					{Name: "kube-api-access-abcde", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{
+							{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: &expirationSeconds}},
+							{ConfigMap: &corev1.ConfigMapProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "kube-root-ca.crt"},
+								Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+							}},
+							{DownwardAPI: &corev1.DownwardAPIProjection{Items: []corev1.DownwardAPIVolumeFile{
+								{Path: "namespace", FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"}},
+							}}},
+						},
+					}}},
+				},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		_, err := homeClient.CoreV1().Pods("homeNamespace").Create(context.TODO(), pod, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provider.CreatePod(context.TODO(), pod)).To(Succeed())
+	})
+
+	getForeignSpec := func() corev1.PodSpec {
+		rs, err := foreignClient.AppsV1().ReplicaSets("homeNamespace-natted").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		return rs.Spec.Template.Spec
+	}
+
+	When("the remote policy is selected", func() {
+		It("uses the foreign service account with the same name", func() {
+			spec := getForeignSpec()
+			Expect(spec.ServiceAccountName).To(Equal("builder"))
+			Expect(*spec.AutomountServiceAccountToken).To(BeTrue())
+			Expect(spec.Volumes).To(HaveLen(1))
+			Expect(spec.Volumes[0].Name).To(Equal("config"))
+			Expect(spec.Volumes[0].Projected.Sources).To(HaveLen(2))
+			Expect(spec.Containers[0].VolumeMounts).To(HaveLen(1))
+
+			_, err := foreignClient.CoreV1().ServiceAccounts("homeNamespace-natted").Get(context.TODO(), "builder", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("the home policy is selected", func() {
+		BeforeEach(func() {
+			pod.Annotations[virtualKubelet.ServiceAccountTokenAnnotation] = virtualKubelet.ServiceAccountTokenHome
+		})
+
+		It("projects the home token, CA and namespace", func() {
+			spec := getForeignSpec()
+			Expect(*spec.AutomountServiceAccountToken).To(BeFalse())
+			Expect(spec.Volumes).To(HaveLen(2))
+			Expect(spec.Containers[0].VolumeMounts).To(HaveLen(2))
+
+			secretName := forge.HomeTokenSecretName(pod.Name)
+			sources := spec.Volumes[1].Projected.Sources
+			Expect(sources).To(HaveLen(3))
+			for _, source := range sources {
+				Expect(source.Secret).NotTo(BeNil())
+				Expect(source.Secret.Name).To(Equal(secretName))
+			}
+			Expect(sources[0].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "kube-api-access-abcde.0.token", Path: "token"}}))
+			Expect(sources[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: forge.HomeTokenSecretCAKey, Path: "ca.crt"}}))
+			Expect(sources[2].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: forge.HomeTokenSecretNamespaceKey, Path: "namespace"}}))
+
+			Expect(tokenRequests).To(HaveLen(1))
+			Expect(*tokenRequests[0].Spec.ExpirationSeconds).To(BeNumerically("==", 3607))
+			Expect(tokenRequests[0].Spec.BoundObjectRef.UID).To(BeEquivalentTo("home-uid"))
+
+			secret, err := foreignClient.CoreV1().Secrets("homeNamespace-natted").Get(context.TODO(), secretName, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Data).To(Equal(map[string][]byte{
+				"kube-api-access-abcde.0.token":   []byte("home-token"),
+				forge.HomeTokenSecretCAKey:        []byte("home-ca"),
+				forge.HomeTokenSecretNamespaceKey: []byte("homeNamespace"),
+			}))
+		})
+
+		It("deletes the token secret with the pod", func() {
+			Expect(provider.DeletePod(context.TODO(), pod)).To(Succeed())
+			secrets, err := foreignClient.CoreV1().Secrets("homeNamespace-natted").List(context.TODO(), metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secrets.Items).To(BeEmpty())
+		})
+	})
+
+	When("the none policy is selected", func() {
+		BeforeEach(func() {
+			pod.Annotations[virtualKubelet.ServiceAccountTokenAnnotation] = virtualKubelet.ServiceAccountTokenNone
+		})
+
+		It("provides no service account credentials", func() {
+			spec := getForeignSpec()
+			Expect(*spec.AutomountServiceAccountToken).To(BeFalse())
+			Expect(spec.ServiceAccountName).To(BeEmpty())
+			Expect(spec.Volumes).To(HaveLen(1))
+			Expect(spec.Containers[0].VolumeMounts).To(HaveLen(1))
+		})
+	})
+
+	When("an invalid policy is selected", func() {
+		BeforeEach(func() {
+			pod.Annotations[virtualKubelet.ServiceAccountTokenAnnotation] = "foreign"
+		})
+
+		It("rejects the pod", func() {
+			rejected, err := homeClient.CoreV1().Pods("homeNamespace").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rejected.Status.Phase).To(Equal(corev1.PodFailed))
+			Expect(rejected.Status.Reason).To(Equal(podStatusReasonInvalidServiceAccountTokenPolicy))
+		})
+	})
+})
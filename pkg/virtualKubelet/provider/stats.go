@@ -0,0 +1,266 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	apimgmgt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	"sync"
+	"time"
+)
+
+// statsCacheTTL is the time the stats summary is served from the cache, before being collected again from
+// the foreign cluster. It is shorter than the default resolution of the metrics-server (60s).
+const statsCacheTTL = 15 * time.Second
+
+// statsCache stores the last stats summary computed, to limit the requests to the foreign cluster.
+type statsCache struct {
+	sync.Mutex
+	summary   *stats.Summary
+	timestamp time.Time
+}
+
+// GetStatsSummary returns the stats of the pods offloaded to the foreign cluster, and the ones of the virtual node
+// as their sum. The stats of each pod are retrieved from the kubelet of the foreign node hosting it, falling back to
+// the foreign metrics-server if not available. The pods whose stats cannot be retrieved are skipped.
+func (p *LiqoProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
+	p.statsCache.Lock()
+	defer p.statsCache.Unlock()
+
+	if p.statsCache.summary != nil && time.Since(p.statsCache.timestamp) < statsCacheTTL {
+		return p.statsCache.summary, nil
+	}
+
+	summary := p.collectStatsSummary(ctx)
+	p.statsCache.summary = summary
+	p.statsCache.timestamp = time.Now()
+	return summary, nil
+}
+
+func (p *LiqoProvider) collectStatsSummary(ctx context.Context) *stats.Summary {
+	// the summaries of the foreign nodes hosting the offloaded pods, nil if not available
+	nodeSummaries := map[string]map[string]*stats.PodStats{}
+
+	res := &stats.Summary{
+		Node: stats.NodeStats{
+			NodeName:  p.nodeName.Value().ToString(),
+			StartTime: metav1.NewTime(p.startTime),
+		},
+	}
+
+	for home, foreign := range p.namespaceMapper.MappedNamespaces() {
+		foreignObjs, err := p.apiController.CacheManager().ListForeignNamespacedObject(apimgmgt.Pods, foreign)
+		if err != nil {
+			klog.Warningf("skipping the stats of the pods in namespace %s: %v", foreign, err)
+			continue
+		}
+
+		// the metrics of the pods in the namespace, retrieved only if some node summary is not available
+		var podMetrics map[string]*metricsv1beta1.PodMetrics
+
+		for _, obj := range foreignObjs {
+			foreignPod := obj.(*corev1.Pod)
+			if _, ok := foreignPod.Labels[virtualKubelet.ReflectedpodKey]; !ok {
+				// e.g. the instances of the offloaded DaemonSets, not reflected as single pods
+				continue
+			}
+			homePod, err := p.getHomePod(home, foreignPod)
+			if err != nil {
+				klog.Warningf("skipping the stats of pod %s/%s: %v", foreign, foreignPod.Name, err)
+				continue
+			}
+			if foreignPod.Spec.NodeName == "" {
+				// the pod is not scheduled yet
+				continue
+			}
+
+			podSummaries, ok := nodeSummaries[foreignPod.Spec.NodeName]
+			if !ok {
+				podSummaries, err = p.getForeignNodeStats(ctx, foreignPod.Spec.NodeName)
+				if err != nil {
+					klog.V(3).Infof("stats summary of foreign node %s not available, falling back to the metrics-server: %v",
+						foreignPod.Spec.NodeName, err)
+				}
+				nodeSummaries[foreignPod.Spec.NodeName] = podSummaries
+			}
+
+			if podStats, ok := podSummaries[foreignPod.Namespace+"/"+foreignPod.Name]; ok {
+				res.Pods = append(res.Pods, forgePodStats(podStats, homePod))
+				continue
+			}
+
+			if podMetrics == nil {
+				if podMetrics, err = p.getForeignPodMetrics(ctx, foreign); err != nil {
+					klog.Warningf("skipping the stats of the pods in namespace %s: %v", foreign, err)
+					podMetrics = map[string]*metricsv1beta1.PodMetrics{}
+				}
+			}
+			if metrics, ok := podMetrics[foreignPod.Name]; ok {
+				res.Pods = append(res.Pods, forgePodStatsFromMetrics(metrics, homePod))
+			} else {
+				klog.V(3).Infof("skipping the stats of pod %s/%s: not available", foreign, foreignPod.Name)
+			}
+		}
+	}
+
+	aggregateNodeStats(&res.Node, res.Pods, metav1.Now())
+	return res
+}
+
+// getHomePod returns the home pod the given foreign pod has been reflected from.
+func (p *LiqoProvider) getHomePod(homeNamespace string, foreignPod *corev1.Pod) (*corev1.Pod, error) {
+	homePodName, ok := foreignPod.Labels[virtualKubelet.ReflectedpodKey]
+	if !ok {
+		return nil, errors.Errorf("missing %s label", virtualKubelet.ReflectedpodKey)
+	}
+	homeObj, err := p.apiController.CacheManager().GetHomeNamespacedObject(apimgmgt.Pods, homeNamespace, homePodName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while retrieving home pod %s/%s from cache", homeNamespace, homePodName)
+	}
+	return homeObj.(*corev1.Pod), nil
+}
+
+// getForeignNodeStats retrieves the stats summary of a foreign node through the API server proxy, and returns
+// the stats of its pods indexed by namespace/name.
+func (p *LiqoProvider) getForeignNodeStats(ctx context.Context, nodeName string) (map[string]*stats.PodStats, error) {
+	raw, err := p.foreignClient.CoreV1().RESTClient().Get().
+		Resource("nodes").Name(nodeName).SubResource("proxy").Suffix("stats/summary").DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var summary stats.Summary
+	if err = json.Unmarshal(raw, &summary); err != nil {
+		return nil, errors.Wrap(err, "error while decoding the stats summary")
+	}
+
+	podStats := make(map[string]*stats.PodStats, len(summary.Pods))
+	for i := range summary.Pods {
+		podStats[summary.Pods[i].PodRef.Namespace+"/"+summary.Pods[i].PodRef.Name] = &summary.Pods[i]
+	}
+	return podStats, nil
+}
+
+// getForeignPodMetrics retrieves from the foreign metrics-server the metrics of the pods offloaded in a namespace,
+// indexed by name.
+func (p *LiqoProvider) getForeignPodMetrics(ctx context.Context, foreignNamespace string) (map[string]*metricsv1beta1.PodMetrics, error) {
+	podMetrics, err := p.foreignMetricsClient.MetricsV1beta1().PodMetricses(foreignNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", forge.LiqoOutgoingKey, forge.LiqoNodeName()),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while listing foreign pod metricses in namespace %s", foreignNamespace)
+	}
+
+	res := make(map[string]*metricsv1beta1.PodMetrics, len(podMetrics.Items))
+	for i := range podMetrics.Items {
+		res[podMetrics.Items[i].Name] = &podMetrics.Items[i]
+	}
+	return res, nil
+}
+
+// forgePodStats translates the stats of a foreign pod, collected by the foreign kubelet, to refer to the home pod.
+func forgePodStats(foreignStats *stats.PodStats, homePod *corev1.Pod) stats.PodStats {
+	podStats := *foreignStats
+	podStats.PodRef = stats.PodReference{
+		Name:      homePod.Name,
+		Namespace: homePod.Namespace,
+		UID:       string(homePod.UID),
+	}
+
+	podStats.VolumeStats = make([]stats.VolumeStats, len(foreignStats.VolumeStats))
+	for i, volume := range foreignStats.VolumeStats {
+		if volume.PVCRef != nil {
+			volume.PVCRef = &stats.PVCReference{Name: volume.PVCRef.Name, Namespace: homePod.Namespace}
+		}
+		podStats.VolumeStats[i] = volume
+	}
+	return podStats
+}
+
+// forgePodStatsFromMetrics creates the stats of the home pod from the metrics of the foreign one, which only include
+// the cpu usage and the memory working set of the containers.
+func forgePodStatsFromMetrics(podMetrics *metricsv1beta1.PodMetrics, homePod *corev1.Pod) stats.PodStats {
+	t := podMetrics.Timestamp
+	podStats := stats.PodStats{
+		PodRef: stats.PodReference{
+			Name:      homePod.Name,
+			Namespace: homePod.Namespace,
+			UID:       string(homePod.UID),
+		},
+		StartTime: homePod.CreationTimestamp,
+		CPU:       &stats.CPUStats{Time: t},
+		Memory:    &stats.MemoryStats{Time: t},
+	}
+
+	for _, container := range podMetrics.Containers {
+		nanoCores := uint64(container.Usage.Cpu().ScaledValue(resource.Nano))
+		workingSetBytes := uint64(container.Usage.Memory().Value())
+
+		podStats.Containers = append(podStats.Containers, stats.ContainerStats{
+			Name:      container.Name,
+			StartTime: homePod.CreationTimestamp,
+			CPU: &stats.CPUStats{
+				Time:           t,
+				UsageNanoCores: &nanoCores,
+			},
+			Memory: &stats.MemoryStats{
+				Time:            t,
+				WorkingSetBytes: &workingSetBytes,
+			},
+		})
+
+		podStats.CPU.UsageNanoCores = addUint64(podStats.CPU.UsageNanoCores, &nanoCores)
+		podStats.Memory.WorkingSetBytes = addUint64(podStats.Memory.WorkingSetBytes, &workingSetBytes)
+	}
+	return podStats
+}
+
+// aggregateNodeStats sets the stats of the virtual node as the sum of the ones of its pods.
+func aggregateNodeStats(node *stats.NodeStats, pods []stats.PodStats, t metav1.Time) {
+	node.CPU = &stats.CPUStats{Time: t}
+	node.Memory = &stats.MemoryStats{Time: t}
+	node.Network = &stats.NetworkStats{Time: t}
+	node.Fs = &stats.FsStats{Time: t}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.CPU != nil {
+			node.CPU.UsageNanoCores = addUint64(node.CPU.UsageNanoCores, pod.CPU.UsageNanoCores)
+			node.CPU.UsageCoreNanoSeconds = addUint64(node.CPU.UsageCoreNanoSeconds, pod.CPU.UsageCoreNanoSeconds)
+		}
+		if pod.Memory != nil {
+			node.Memory.UsageBytes = addUint64(node.Memory.UsageBytes, pod.Memory.UsageBytes)
+			node.Memory.WorkingSetBytes = addUint64(node.Memory.WorkingSetBytes, pod.Memory.WorkingSetBytes)
+			node.Memory.RSSBytes = addUint64(node.Memory.RSSBytes, pod.Memory.RSSBytes)
+		}
+		if pod.Network != nil {
+			node.Network.RxBytes = addUint64(node.Network.RxBytes, pod.Network.RxBytes)
+			node.Network.RxErrors = addUint64(node.Network.RxErrors, pod.Network.RxErrors)
+			node.Network.TxBytes = addUint64(node.Network.TxBytes, pod.Network.TxBytes)
+			node.Network.TxErrors = addUint64(node.Network.TxErrors, pod.Network.TxErrors)
+		}
+		if pod.EphemeralStorage != nil {
+			node.Fs.UsedBytes = addUint64(node.Fs.UsedBytes, pod.EphemeralStorage.UsedBytes)
+		}
+	}
+}
+
+// addUint64 returns the sum of the given values, ignoring the nil ones. The result is nil if both are nil.
+func addUint64(a, b *uint64) *uint64 {
+	if b == nil {
+		return a
+	}
+	sum := *b
+	if a != nil {
+		sum += *a
+	}
+	return &sum
+}
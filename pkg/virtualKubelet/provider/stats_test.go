@@ -0,0 +1,84 @@
+package provider
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+var _ = Describe("Stats", func() {
+	var homePod *corev1.Pod
+
+	BeforeEach(func() {
+		homePod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "homePod",
+				Namespace: "homeNamespace",
+				UID:       "home-uid",
+			},
+		}
+	})
+
+	It("translates the stats collected by the foreign kubelet", func() {
+		foreignStats := &stats.PodStats{
+			PodRef: stats.PodReference{Name: "foreignPod", Namespace: "homeNamespace-natted", UID: "foreign-uid"},
+			CPU:    &stats.CPUStats{UsageNanoCores: uint64Ptr(100), UsageCoreNanoSeconds: uint64Ptr(5000)},
+			VolumeStats: []stats.VolumeStats{
+				{Name: "data", PVCRef: &stats.PVCReference{Name: "claim", Namespace: "homeNamespace-natted"}},
+			},
+		}
+
+		podStats := forgePodStats(foreignStats, homePod)
+		Expect(podStats.PodRef).To(Equal(stats.PodReference{Name: "homePod", Namespace: "homeNamespace", UID: "home-uid"}))
+		Expect(*podStats.CPU.UsageCoreNanoSeconds).To(BeNumerically("==", 5000))
+		Expect(podStats.VolumeStats[0].PVCRef.Namespace).To(Equal("homeNamespace"))
+		Expect(foreignStats.VolumeStats[0].PVCRef.Namespace).To(Equal("homeNamespace-natted"))
+	})
+
+	It("reports the metrics-server memory as working set", func() {
+		podMetrics := &metricsv1beta1.PodMetrics{
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{Name: "c1", Usage: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("1Mi")}},
+				{Name: "c2", Usage: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("50m"), corev1.ResourceMemory: resource.MustParse("1Mi")}},
+			},
+		}
+
+		podStats := forgePodStatsFromMetrics(podMetrics, homePod)
+		Expect(podStats.Containers).To(HaveLen(2))
+		Expect(*podStats.CPU.UsageNanoCores).To(BeNumerically("==", 150000000))
+		Expect(*podStats.Memory.WorkingSetBytes).To(BeNumerically("==", 2*1024*1024))
+		Expect(podStats.Memory.UsageBytes).To(BeNil())
+	})
+
+	It("aggregates the stats of the pods on the virtual node", func() {
+		pods := []stats.PodStats{
+			{
+				CPU:              &stats.CPUStats{UsageNanoCores: uint64Ptr(100)},
+				Network:          &stats.NetworkStats{InterfaceStats: stats.InterfaceStats{RxBytes: uint64Ptr(10)}},
+				EphemeralStorage: &stats.FsStats{UsedBytes: uint64Ptr(1000)},
+			},
+			{
+				CPU:    &stats.CPUStats{UsageNanoCores: uint64Ptr(50)},
+				Memory: &stats.MemoryStats{WorkingSetBytes: uint64Ptr(20)},
+			},
+		}
+
+		node := &stats.NodeStats{}
+		aggregateNodeStats(node, pods, metav1.Now())
+		Expect(*node.CPU.UsageNanoCores).To(BeNumerically("==", 150))
+		Expect(node.CPU.UsageCoreNanoSeconds).To(BeNil())
+		Expect(*node.Memory.WorkingSetBytes).To(BeNumerically("==", 20))
+		Expect(*node.Network.RxBytes).To(BeNumerically("==", 10))
+		Expect(*node.Fs.UsedBytes).To(BeNumerically("==", 1000))
+	})
+})
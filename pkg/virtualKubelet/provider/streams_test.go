@@ -0,0 +1,280 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/liqotech/liqo/internal/virtualKubelet/node/api"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	test3 "github.com/liqotech/liqo/pkg/virtualKubelet/storage/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// fakeExecutor records the remote command it is asked to run, writing the given output to its stdout.
+type fakeExecutor struct {
+	url     *url.URL
+	options remotecommand.StreamOptions
+	sizes   []remotecommand.TerminalSize
+	output  string
+	err     error
+}
+
+func (e *fakeExecutor) Stream(options remotecommand.StreamOptions) error {
+	e.options = options
+	if options.TerminalSizeQueue != nil {
+		for size := options.TerminalSizeQueue.Next(); size != nil; size = options.TerminalSizeQueue.Next() {
+			e.sizes = append(e.sizes, *size)
+		}
+	}
+	if e.err != nil {
+		return e.err
+	}
+	_, err := options.Stdout.Write([]byte(e.output))
+	return err
+}
+
+// fakeAttachIO is the home side of a remote command.
+type fakeAttachIO struct {
+	stdin  io.Reader
+	stdout *bytes.Buffer
+	tty    bool
+	resize chan api.TermSize
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func (a *fakeAttachIO) Stdin() io.Reader {
+	return a.stdin
+}
+
+func (a *fakeAttachIO) Stdout() io.WriteCloser {
+	return nopWriteCloser{a.stdout}
+}
+
+func (a *fakeAttachIO) Stderr() io.WriteCloser {
+	return nil
+}
+
+func (a *fakeAttachIO) TTY() bool {
+	return a.tty
+}
+
+func (a *fakeAttachIO) Resize() <-chan api.TermSize {
+	return a.resize
+}
+
+// fakeStream is a foreign port-forward stream: the data stream echoes what it receives, once closed by the writer,
+// while the error stream returns the given message.
+type fakeStream struct {
+	io.Reader
+	io.WriteCloser
+	headers http.Header
+}
+
+func (s *fakeStream) Reset() error {
+	return s.Close()
+}
+
+func (s *fakeStream) Headers() http.Header {
+	return s.headers
+}
+
+func (s *fakeStream) Identifier() uint32 {
+	return 0
+}
+
+// fakeConnection is a foreign port-forward connection, recording the streams created.
+type fakeConnection struct {
+	errorMessage string
+	streams      []http.Header
+	closed       chan bool
+}
+
+func (c *fakeConnection) CreateStream(headers http.Header) (httpstream.Stream, error) {
+	headers = headers.Clone()
+	c.streams = append(c.streams, headers)
+	if headers.Get(corev1.StreamType) == corev1.StreamTypeError {
+		return &fakeStream{Reader: strings.NewReader(c.errorMessage), WriteCloser: nopWriteCloser{ioutil.Discard}, headers: headers}, nil
+	}
+	reader, writer := io.Pipe()
+	return &fakeStream{Reader: reader, WriteCloser: writer, headers: headers}, nil
+}
+
+func (c *fakeConnection) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func (c *fakeConnection) CloseChan() <-chan bool {
+	return c.closed
+}
+
+func (c *fakeConnection) SetIdleTimeout(time.Duration) {}
+
+type fakeDialer struct {
+	connection *fakeConnection
+	protocols  []string
+}
+
+func (d *fakeDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	d.protocols = protocols
+	return d.connection, protocols[0], nil
+}
+
+// fakeClientStream is the home side of a port-forward stream.
+type fakeClientStream struct {
+	io.Reader
+	received bytes.Buffer
+	closed   bool
+}
+
+func (s *fakeClientStream) Write(p []byte) (int, error) {
+	return s.received.Write(p)
+}
+
+func (s *fakeClientStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+var _ = Describe("Remote streams", func() {
+	var (
+		provider *LiqoProvider
+		executor *fakeExecutor
+		dialer   *fakeDialer
+	)
+
+	BeforeEach(func() {
+		var mockManager *test3.MockManager
+		provider, _, mockManager = newTestProvider(nil)
+		mockManager.AddForeignEntry("homeNamespace-natted", apimgmt.Pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foreignPod",
+				Namespace: "homeNamespace-natted",
+				Labels:    map[string]string{virtualKubelet.ReflectedpodKey: "homePod"},
+			},
+		})
+		executor = &fakeExecutor{output: "output"}
+		dialer = &fakeDialer{connection: &fakeConnection{closed: make(chan bool)}}
+		provider.foreignClient = kubernetes.NewForConfigOrDie(&rest.Config{Host: "https://foreign-cluster"})
+		provider.newExecutor = func(method string, url *url.URL) (remotecommand.Executor, error) {
+			executor.url = url
+			return executor, nil
+		}
+		provider.newDialer = func(method string, url *url.URL) (httpstream.Dialer, error) {
+			return dialer, nil
+		}
+	})
+
+	Describe("attach", func() {
+		It("attaches to the container of the foreign pod", func() {
+			attach := &fakeAttachIO{stdin: strings.NewReader("input"), stdout: &bytes.Buffer{}}
+			Expect(provider.AttachToContainer(context.TODO(), "homeNamespace", "homePod", "c1", attach)).To(Succeed())
+
+			Expect(executor.url.Path).To(Equal("/api/v1/namespaces/homeNamespace-natted/pods/foreignPod/attach"))
+			Expect(executor.url.Query().Get("container")).To(Equal("c1"))
+			Expect(executor.url.Query().Get("stdin")).To(Equal("true"))
+			Expect(executor.url.Query().Get("stderr")).To(BeEmpty())
+			Expect(executor.options.Stdin).To(Equal(attach.stdin))
+			Expect(executor.options.TerminalSizeQueue).To(BeNil())
+			Expect(attach.stdout.String()).To(Equal("output"))
+		})
+
+		It("forwards the terminal resize events to the foreign pod", func() {
+			attach := &fakeAttachIO{stdout: &bytes.Buffer{}, tty: true, resize: make(chan api.TermSize, 2)}
+			attach.resize <- api.TermSize{Width: 80, Height: 24}
+			attach.resize <- api.TermSize{Width: 120, Height: 40}
+			close(attach.resize)
+			Expect(provider.AttachToContainer(context.TODO(), "homeNamespace", "homePod", "c1", attach)).To(Succeed())
+
+			Expect(executor.url.Query().Get("tty")).To(Equal("true"))
+			Expect(executor.sizes).To(Equal([]remotecommand.TerminalSize{{Width: 80, Height: 24}, {Width: 120, Height: 40}}))
+		})
+
+		It("returns the streaming errors", func() {
+			executor.err = errors.New("connection reset")
+			attach := &fakeAttachIO{stdout: &bytes.Buffer{}}
+			Expect(provider.AttachToContainer(context.TODO(), "homeNamespace", "homePod", "c1", attach)).To(MatchError(ContainSubstring("connection reset")))
+		})
+
+		It("fails if the foreign pod does not exist", func() {
+			attach := &fakeAttachIO{stdout: &bytes.Buffer{}}
+			Expect(provider.AttachToContainer(context.TODO(), "homeNamespace", "missingPod", "c1", attach)).NotTo(Succeed())
+			Expect(executor.url).To(BeNil())
+		})
+	})
+
+	Describe("terminal size queue", func() {
+		It("stops when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.TODO())
+			queue := &terminalSizeQueue{ctx: ctx, resize: make(chan api.TermSize)}
+			cancel()
+			Expect(queue.Next()).To(BeNil())
+		})
+
+		It("translates the resize events", func() {
+			resize := make(chan api.TermSize, 1)
+			queue := &terminalSizeQueue{ctx: context.TODO(), resize: resize}
+			resize <- api.TermSize{Width: 10, Height: 20}
+			Expect(queue.Next()).To(Equal(&remotecommand.TerminalSize{Width: 10, Height: 20}))
+			close(resize)
+			Expect(queue.Next()).To(BeNil())
+		})
+	})
+
+	Describe("port-forward", func() {
+		It("forwards the data to the port of the foreign pod", func() {
+			stream := &fakeClientStream{Reader: strings.NewReader("ping")}
+			Expect(provider.PortForward(context.TODO(), "homeNamespace", "homePod", 8080, stream)).To(Succeed())
+
+			Expect(stream.received.String()).To(Equal("ping"))
+			Expect(stream.closed).To(BeTrue())
+			Expect(dialer.protocols).To(ConsistOf("portforward.k8s.io"))
+			Expect(dialer.connection.streams).To(HaveLen(2))
+			for _, headers := range dialer.connection.streams {
+				Expect(headers.Get(corev1.PortHeader)).To(Equal("8080"))
+			}
+			Expect(dialer.connection.streams[1].Get(corev1.StreamType)).To(Equal(corev1.StreamTypeData))
+			Expect(dialer.connection.CloseChan()).To(BeClosed())
+		})
+
+		It("returns the errors reported by the foreign side", func() {
+			dialer.connection.errorMessage = "connection refused"
+			stream := &fakeClientStream{Reader: strings.NewReader("ping")}
+			Expect(provider.PortForward(context.TODO(), "homeNamespace", "homePod", 8080, stream)).To(MatchError(ContainSubstring("connection refused")))
+		})
+
+		It("does not leak the reader of the error stream when the home client fails", func() {
+			dialer.connection.errorMessage = "connection refused"
+			stream := &fakeClientStream{Reader: &failingReader{Reader: strings.NewReader(""), err: errors.New("client gone")}}
+			Expect(provider.PortForward(context.TODO(), "homeNamespace", "homePod", 8080, stream)).To(MatchError(ContainSubstring("client gone")))
+
+			// the goroutine reading the error stream is the first one started by PortForward
+			Eventually(func() string {
+				buf := make([]byte, 1<<20)
+				return string(buf[:runtime.Stack(buf, true)])
+			}).ShouldNot(ContainSubstring("(*LiqoProvider).PortForward.func1"))
+		})
+	})
+})
@@ -3,16 +3,20 @@ package provider
 import (
 	"context"
 	"errors"
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	nettypes "github.com/liqotech/liqo/apis/net/v1alpha1"
 	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 	advertisementOperator "github.com/liqotech/liqo/internal/advertisement-operator"
 	"github.com/liqotech/liqo/internal/liqonet/tunnelEndpointCreator"
 	"github.com/liqotech/liqo/internal/virtualKubelet/node"
+	"github.com/liqotech/liqo/pkg/discovery"
 	"github.com/liqotech/liqo/pkg/virtualKubelet"
 	"github.com/liqotech/liqo/pkg/virtualKubelet/options"
+	optTypes "github.com/liqotech/liqo/pkg/virtualKubelet/options/types"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
@@ -39,6 +43,14 @@ func (p *LiqoProvider) StartNodeUpdater(nodeRunner *node.NodeController) (chan s
 		return nil, nil, err
 	}
 
+	fcWatcher, err := p.foreignClusterClient.Resource("foreignclusters").Watch(metav1.ListOptions{
+		LabelSelector: strings.Join([]string{discovery.ClusterIdLabel, p.foreignClusterId}, "="),
+		Watch:         true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	p.nodeController = nodeRunner
 
 	ready := make(chan struct{}, 1)
@@ -73,9 +85,23 @@ func (p *LiqoProvider) StartNodeUpdater(nodeRunner *node.NodeController) (chan s
 						klog.Error(err)
 					}
 				}
+			case ev := <-fcWatcher.ResultChan():
+				err = p.ReconcileStorageFromForeignCluster(ev)
+				if err != nil {
+					klog.Error(err)
+					fcWatcher.Stop()
+					fcWatcher, err = p.foreignClusterClient.Resource("foreignclusters").Watch(metav1.ListOptions{
+						LabelSelector: strings.Join([]string{discovery.ClusterIdLabel, p.foreignClusterId}, "="),
+						Watch:         true,
+					})
+					if err != nil {
+						klog.Error(err)
+					}
+				}
 			case <-stop:
 				advWatcher.Stop()
 				tepWatcher.Stop()
+				fcWatcher.Stop()
 				return
 			}
 		}
@@ -158,6 +184,25 @@ func (p *LiqoProvider) ReconcileNodeFromTep(event watch.Event) error {
 	return nil
 }
 
+// ReconcileStorageFromForeignCluster keeps the StorageClass mapping used by the PersistentVolumeClaims reflection
+// aligned with the one configured in the ForeignCluster
+func (p *LiqoProvider) ReconcileStorageFromForeignCluster(event watch.Event) error {
+	fc, ok := event.Object.(*discoveryv1alpha1.ForeignCluster)
+	if !ok {
+		return errors.New("error in casting foreign cluster: recreate watcher")
+	}
+	if event.Type == watch.Deleted {
+		return nil
+	}
+
+	value := optTypes.StorageClassMappingValue(fc.Spec.StorageClassMapping)
+	if p.storageClassMapping.Value() != value {
+		klog.Infof("storage class mapping for cluster %v set to %q", p.foreignClusterId, value)
+		p.storageClassMapping.SetValue(value)
+	}
+	return nil
+}
+
 // updateFromAdv gets and  advertisement and updates the node status accordingly
 func (p *LiqoProvider) updateFromAdv(adv advtypes.Advertisement) error {
 	var err error
@@ -167,24 +212,35 @@ func (p *LiqoProvider) updateFromAdv(adv advtypes.Advertisement) error {
 		return err
 	}
 
-	no.SetAnnotations(map[string]string{
+	annotations := map[string]string{
 		"cluster-id": p.foreignClusterId,
-	})
-	no.SetLabels(mergeMaps(no.GetLabels(), adv.Spec.Labels))
+	}
+	if len(adv.Spec.Zones) > 0 {
+		annotations[virtualKubelet.RemoteZonesAnnotation] = forgeZonesAnnotation(adv.Spec.Zones)
+	}
+	no.SetAnnotations(annotations)
+	// the topology labels are recomputed from scratch, as the zones of the foreign cluster may have changed
+	labels := no.GetLabels()
+	for key := range labels {
+		if key == v1.LabelZoneRegionStable || key == v1.LabelZoneFailureDomainStable ||
+			strings.HasPrefix(key, virtualKubelet.RemoteZoneLabelPrefix) {
+			delete(labels, key)
+		}
+	}
+	labels = mergeMaps(labels, forgeTopologyLabels(p.foreignClusterId, adv.Spec.Zones))
+	no.SetLabels(mergeMaps(labels, adv.Spec.Labels))
 	no, err = p.nntClient.Client().CoreV1().Nodes().Update(context.TODO(), no, metav1.UpdateOptions{})
 	if err != nil {
 		return err
 	}
 
-	if no.Status.Capacity == nil {
-		no.Status.Capacity = v1.ResourceList{}
-	}
-	if no.Status.Allocatable == nil {
-		no.Status.Allocatable = v1.ResourceList{}
-	}
+	// the node exposes exactly the announced resources (including the extended ones, such as nvidia.com/gpu),
+	// so that the resources no longer announced are removed
+	no.Status.Capacity = v1.ResourceList{}
+	no.Status.Allocatable = v1.ResourceList{}
 	for k, v := range adv.Spec.ResourceQuota.Hard {
-		no.Status.Capacity[k] = v
-		no.Status.Allocatable[k] = v
+		no.Status.Capacity[k] = v.DeepCopy()
+		no.Status.Allocatable[k] = v.DeepCopy()
 	}
 	if no.Status.Conditions == nil {
 		no.Status.Conditions = []v1.NodeCondition{
@@ -214,9 +270,55 @@ func (p *LiqoProvider) updateFromAdv(adv advtypes.Advertisement) error {
 	no.Status.Images = []v1.ContainerImage{}
 	no.Status.Images = append(no.Status.Images, adv.Spec.Images...)
 
+	if adv.Status.Expired != p.advExpired {
+		klog.Infof("advertisement %v expired: %v", adv.Name, adv.Status.Expired)
+		p.advExpired = adv.Status.Expired
+	}
+
 	return p.updateNode(no)
 }
 
+// forgeZonesAnnotation lists the zones of the foreign cluster, in the region/zone format
+func forgeZonesAnnotation(zones []advtypes.Zone) string {
+	values := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		values = append(values, strings.Join([]string{zone.Region, zone.Zone}, "/"))
+	}
+	return strings.Join(values, ",")
+}
+
+// forgeTopologyLabels returns the topology labels of the virtual node: the region, if shared by all the zones of the
+// foreign cluster, the zone, which is the foreign cluster itself if it spans several zones, and a label for each zone
+func forgeTopologyLabels(foreignClusterId string, zones []advtypes.Zone) map[string]string {
+	labels := map[string]string{}
+	regions := map[string]bool{}
+	var zoneNames []string
+	for _, zone := range zones {
+		regions[zone.Region] = true
+		if zone.Zone == "" {
+			continue
+		}
+		zoneNames = append(zoneNames, zone.Zone)
+		if key := virtualKubelet.RemoteZoneLabelPrefix + zone.Zone; len(validation.IsQualifiedName(key)) == 0 {
+			labels[key] = "true"
+		}
+	}
+	if len(regions) == 1 {
+		for region := range regions {
+			if region != "" {
+				labels[v1.LabelZoneRegionStable] = region
+			}
+		}
+	}
+	switch {
+	case len(zoneNames) == 1 && len(zones) == 1:
+		labels[v1.LabelZoneFailureDomainStable] = zoneNames[0]
+	case len(zoneNames) > 0:
+		labels[v1.LabelZoneFailureDomainStable] = virtualKubelet.MultiZoneClusterPrefix + foreignClusterId
+	}
+	return labels
+}
+
 func mergeMaps(m1 map[string]string, m2 map[string]string) map[string]string {
 	for k, v := range m2 {
 		m1[k] = v
@@ -312,6 +414,14 @@ func (p *LiqoProvider) updateNode(node *v1.Node) error {
 			}
 		}
 	}
+	if p.advExpired {
+		// the Advertisement has not been renewed: the resources of the foreign cluster cannot be relied upon
+		for i, condition := range node.Status.Conditions {
+			if condition.Type == v1.NodeReady {
+				node.Status.Conditions[i].Status = v1.ConditionFalse
+			}
+		}
+	}
 	return p.nodeController.UpdateNodeFromOutside(false, node)
 }
 
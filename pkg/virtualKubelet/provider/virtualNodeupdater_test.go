@@ -0,0 +1,49 @@
+package provider
+
+import (
+	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Topology labels", func() {
+	const clusterID = "foreign-cluster"
+
+	It("places the virtual node in the zone of a single-zone foreign cluster", func() {
+		labels := forgeTopologyLabels(clusterID, []advtypes.Zone{{Region: "eu-west-1", Zone: "eu-west-1a", Nodes: 3}})
+		Expect(labels).To(Equal(map[string]string{
+			corev1.LabelZoneRegionStable:                        "eu-west-1",
+			corev1.LabelZoneFailureDomainStable:                 "eu-west-1a",
+			virtualKubelet.RemoteZoneLabelPrefix + "eu-west-1a": "true",
+		}))
+	})
+
+	It("makes a multi-zone foreign cluster a zone of its own, listing its zones", func() {
+		labels := forgeTopologyLabels(clusterID, []advtypes.Zone{
+			{Region: "eu-west-1", Zone: "eu-west-1a", Nodes: 2},
+			{Region: "eu-west-1", Zone: "eu-west-1b", Nodes: 1},
+		})
+		Expect(labels).To(HaveKeyWithValue(corev1.LabelZoneRegionStable, "eu-west-1"))
+		Expect(labels).To(HaveKeyWithValue(corev1.LabelZoneFailureDomainStable, virtualKubelet.MultiZoneClusterPrefix+clusterID))
+		Expect(labels).To(HaveKeyWithValue(virtualKubelet.RemoteZoneLabelPrefix+"eu-west-1a", "true"))
+		Expect(labels).To(HaveKeyWithValue(virtualKubelet.RemoteZoneLabelPrefix+"eu-west-1b", "true"))
+	})
+
+	It("sets no region if the foreign cluster spans several of them", func() {
+		labels := forgeTopologyLabels(clusterID, []advtypes.Zone{
+			{Region: "eu-west-1", Zone: "eu-west-1a", Nodes: 1},
+			{Region: "us-east-1", Zone: "us-east-1a", Nodes: 1},
+		})
+		Expect(labels).NotTo(HaveKey(corev1.LabelZoneRegionStable))
+		Expect(labels).To(HaveKeyWithValue(corev1.LabelZoneFailureDomainStable, virtualKubelet.MultiZoneClusterPrefix+clusterID))
+	})
+
+	It("sets no topology label without zones", func() {
+		Expect(forgeTopologyLabels(clusterID, nil)).To(BeEmpty())
+		Expect(forgeTopologyLabels(clusterID, []advtypes.Zone{{Region: "eu-west-1", Nodes: 1}})).To(Equal(map[string]string{
+			corev1.LabelZoneRegionStable: "eu-west-1",
+		}))
+	})
+})
@@ -12,12 +12,13 @@ import (
 )
 
 var InformerIndexers = map[apimgmt.ApiType]func() cache.Indexers{
-	apimgmt.Configmaps:     configmapsIndexers,
-	apimgmt.EndpointSlices: endpointSlicesIndexers,
-	apimgmt.Pods:           podsIndexers,
-	apimgmt.ReplicaSets:    replicasetsIndexers,
-	apimgmt.Secrets:        secretsIndexers,
-	apimgmt.Services:       servicesIndexers,
+	apimgmt.Configmaps:             configmapsIndexers,
+	apimgmt.EndpointSlices:         endpointSlicesIndexers,
+	apimgmt.PersistentVolumeClaims: persistentVolumeClaimsIndexers,
+	apimgmt.Pods:                   podsIndexers,
+	apimgmt.ReplicaSets:            replicasetsIndexers,
+	apimgmt.Secrets:                secretsIndexers,
+	apimgmt.Services:               servicesIndexers,
 }
 
 func configmapsIndexers() cache.Indexers {
@@ -48,6 +49,20 @@ func endpointSlicesIndexers() cache.Indexers {
 	return i
 }
 
+func persistentVolumeClaimsIndexers() cache.Indexers {
+	i := cache.Indexers{}
+	i["persistentvolumeclaims"] = func(obj interface{}) ([]string, error) {
+		pvc, ok := obj.(*corev1.PersistentVolumeClaim)
+		if !ok {
+			return []string{}, errors.New("cannot convert obj to persistentvolumeclaim")
+		}
+		return []string{
+			strings.Join([]string{pvc.Namespace, pvc.Name}, "/"),
+		}, nil
+	}
+	return i
+}
+
 func podsIndexers() cache.Indexers {
 	i := cache.Indexers{}
 	i["pods"] = func(obj interface{}) ([]string, error) {
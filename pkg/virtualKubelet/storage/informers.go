@@ -7,12 +7,13 @@ import (
 )
 
 var InformerBuilders = map[apimgmt.ApiType]func(informers.SharedInformerFactory) cache.SharedIndexInformer{
-	apimgmt.Configmaps:     configmapsInformerBuilder,
-	apimgmt.EndpointSlices: endpointSlicesInformerBuilder,
-	apimgmt.Pods:           podsInformerBuilder,
-	apimgmt.ReplicaSets:    replicaSetsInformerBuilder,
-	apimgmt.Services:       servicesInformerBuilder,
-	apimgmt.Secrets:        secretsInformerBuilder,
+	apimgmt.Configmaps:             configmapsInformerBuilder,
+	apimgmt.EndpointSlices:         endpointSlicesInformerBuilder,
+	apimgmt.PersistentVolumeClaims: persistentVolumeClaimsInformerBuilder,
+	apimgmt.Pods:                   podsInformerBuilder,
+	apimgmt.ReplicaSets:            replicaSetsInformerBuilder,
+	apimgmt.Services:               servicesInformerBuilder,
+	apimgmt.Secrets:                secretsInformerBuilder,
 }
 
 func configmapsInformerBuilder(factory informers.SharedInformerFactory) cache.SharedIndexInformer {
@@ -23,6 +24,10 @@ func endpointSlicesInformerBuilder(factory informers.SharedInformerFactory) cach
 	return factory.Discovery().V1beta1().EndpointSlices().Informer()
 }
 
+func persistentVolumeClaimsInformerBuilder(factory informers.SharedInformerFactory) cache.SharedIndexInformer {
+	return factory.Core().V1().PersistentVolumeClaims().Informer()
+}
+
 func podsInformerBuilder(factory informers.SharedInformerFactory) cache.SharedIndexInformer {
 	return factory.Core().V1().Pods().Informer()
 }
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"github.com/liqotech/liqo/pkg/virtualKubelet"
 	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
 	"github.com/pkg/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -119,6 +120,13 @@ func (m *MockManager) GetHomeApiByIndex(apiType apimgmt.ApiType, s string, s2 st
 	panic("implement me")
 }
 
+// GetForeignApiByIndex returns the foreign object reflected from the home one with the given name, i.e. labeled
+// with the ReflectedpodKey label.
 func (m *MockManager) GetForeignApiByIndex(apiType apimgmt.ApiType, s string, s2 string) (interface{}, error) {
-	panic("implement me")
+	for _, v := range m.ForeignCache[s][apiType] {
+		if v.GetLabels()[virtualKubelet.ReflectedpodKey] == s2 {
+			return v, nil
+		}
+	}
+	return nil, errors.New("not found")
 }
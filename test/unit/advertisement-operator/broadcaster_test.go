@@ -192,8 +192,9 @@ func createResourcesOnCluster(client *crdClient.CRDClient, pNodes *corev1.NodeLi
 
 func prepareAdv(b *advop.AdvertisementBroadcaster) advtypes.Advertisement {
 	pNodes, vNodes, images, _, pods := createFakeResources()
-	reqs, limits := advop.GetAllPodsResources(pods)
+	reqs, _ := advop.GetAllPodsResources(pods)
 	availability, _ := advop.ComputeAnnouncedResources(pNodes, reqs, int64(b.ClusterConfig.AdvertisementConfig.OutgoingConfig.ResourceSharingPercentage))
+	limits := advop.ComputeContainerLimits(pNodes, availability)
 	neighbours := make(map[corev1.ResourceName]corev1.ResourceList)
 	labels := make(map[string]string)
 	for _, vNode := range vNodes.Items {
@@ -232,6 +233,48 @@ func TestGetNodeImages(t *testing.T) {
 	assert.Equal(t, expected, images)
 }
 
+func TestGetZones(t *testing.T) {
+	pNodes, _, _, _, _ := createFakeResources()
+	assert.Empty(t, advop.GetZones(pNodes))
+
+	zones := []string{"zone-b", "zone-a", "zone-b", "", "zone-a"}
+	for i := range pNodes.Items {
+		pNodes.Items[i].Labels[corev1.LabelZoneRegionStable] = "region"
+		if zones[i] != "" {
+			pNodes.Items[i].Labels[corev1.LabelZoneFailureDomainStable] = zones[i]
+		}
+	}
+	assert.Equal(t, []advtypes.Zone{
+		{Region: "region", Zone: "", Nodes: 1},
+		{Region: "region", Zone: "zone-a", Nodes: 2},
+		{Region: "region", Zone: "zone-b", Nodes: 2},
+	}, advop.GetZones(pNodes))
+}
+
+func TestComputeContainerLimits(t *testing.T) {
+	pNodes, _, _, _, _ := createFakeResources()
+	for i := range pNodes.Items {
+		pNodes.Items[i].Status.Allocatable = corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewQuantity(int64(i+1), resource.DecimalSI),
+			corev1.ResourceMemory: resource.MustParse("8Gi"),
+		}
+	}
+	availability := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("20"),
+		corev1.ResourceMemory: resource.MustParse("2Gi"),
+		corev1.ResourcePods:   resource.MustParse("50"),
+	}
+
+	limits := advop.ComputeContainerLimits(pNodes, availability)
+	// bounded by the biggest node
+	assert.Equal(t, int64(len(pNodes.Items)), limits.Cpu().Value())
+	// bounded by the announced resources
+	assert.Equal(t, availability.Memory().Value(), limits.Memory().Value())
+	// the number of pods is not a container resource
+	_, found := limits[corev1.ResourcePods]
+	assert.False(t, found)
+}
+
 func TestComputePrices(t *testing.T) {
 	_, _, images, _, _ := createFakeResources()
 	prices := advop.ComputePrices(images)
@@ -251,11 +294,108 @@ func TestComputePrices(t *testing.T) {
 	assert.ElementsMatch(t, keys1, keys2)
 }
 
+func TestComputePeerShare(t *testing.T) {
+	free := corev1.ResourceList{
+		corev1.ResourceCPU:                    resource.MustParse("10"),
+		corev1.ResourceMemory:                 resource.MustParse("10G"),
+		corev1.ResourceName("nvidia.com/gpu"): resource.MustParse("4"),
+	}
+	peers := []string{"cluster-1", "cluster-2"}
+	percentage := int32(20)
+	config := &configv1alpha1.BroadcasterConfig{
+		ResourceSharingPercentage: 50,
+		PeerSharingPolicies: []configv1alpha1.PeerSharingPolicy{
+			{
+				ClusterId:                 "cluster-2",
+				ResourceSharingPercentage: &percentage,
+				Resources: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("2"),
+				},
+				HiddenResources: []corev1.ResourceName{"nvidia.com/gpu"},
+			},
+		},
+	}
+
+	// the demands do not exceed the free resources: each peer gets its own share
+	share := advop.ComputePeerShare(free, "cluster-1", peers, config)
+	assert.Equal(t, int64(5000), share.Cpu().MilliValue())
+	assert.Equal(t, int64(5000), share.Memory().ScaledValue(resource.Mega))
+	gpu := share["nvidia.com/gpu"]
+	assert.Equal(t, int64(2), gpu.Value())
+
+	share = advop.ComputePeerShare(free, "cluster-2", peers, config)
+	assert.Equal(t, int64(2000), share.Cpu().MilliValue())
+	assert.Equal(t, int64(2000), share.Memory().ScaledValue(resource.Mega))
+	_, ok := share["nvidia.com/gpu"]
+	assert.False(t, ok)
+
+	// three peers with the default 50% share: the shares are reduced so that the cluster is not overcommitted
+	peers = []string{"cluster-1", "cluster-3", "cluster-4"}
+	total := resource.Quantity{}
+	for _, peer := range peers {
+		share = advop.ComputePeerShare(free, peer, peers, config)
+		assert.Equal(t, int64(3333), share.Cpu().MilliValue())
+		total.Add(*share.Cpu())
+	}
+	assert.True(t, total.Cmp(free[corev1.ResourceCPU]) <= 0)
+}
+
+func TestAdvertisedResources(t *testing.T) {
+	free := corev1.ResourceList{
+		corev1.ResourceCPU:                    resource.MustParse("10"),
+		corev1.ResourceMemory:                 resource.MustParse("10G"),
+		corev1.ResourceEphemeralStorage:       resource.MustParse("100G"),
+		corev1.ResourceName("hugepages-2Mi"):  resource.MustParse("1Gi"),
+		corev1.ResourceName("nvidia.com/gpu"): resource.MustParse("3"),
+	}
+
+	// without an allow-list, all the resources are announced, including the extended resources and the hugepages
+	config := &configv1alpha1.BroadcasterConfig{ResourceSharingPercentage: 50}
+	filtered := advop.FilterAdvertisedResources(free, config)
+	assert.Equal(t, free, filtered)
+
+	// with an allow-list, only the listed resources are announced
+	config.AdvertisedResources = []configv1alpha1.AdvertisedResource{{Name: corev1.ResourceCPU}, {Name: corev1.ResourceMemory}}
+	filtered = advop.FilterAdvertisedResources(free, config)
+	assert.Len(t, filtered, 2)
+	_, ok := filtered["nvidia.com/gpu"]
+	assert.False(t, ok)
+
+	config.AdvertisedResources = []configv1alpha1.AdvertisedResource{
+		{Name: corev1.ResourceCPU},
+		{Name: "hugepages-2Mi"},
+		{Name: "nvidia.com/gpu"},
+	}
+	filtered = advop.FilterAdvertisedResources(free, config)
+	assert.Len(t, filtered, 3)
+	assert.Equal(t, configv1alpha1.MilliScaling, advop.GetResourceScaling(corev1.ResourceCPU, config))
+	assert.Equal(t, configv1alpha1.MegaScaling, advop.GetResourceScaling("hugepages-2Mi", config))
+	assert.Equal(t, configv1alpha1.IntegerScaling, advop.GetResourceScaling("nvidia.com/gpu", config))
+
+	// the devices are rounded down to integer units
+	share := advop.ComputePeerShare(filtered, "cluster-1", nil, config)
+	assert.Equal(t, int64(5000), share.Cpu().MilliValue())
+	gpu := share["nvidia.com/gpu"]
+	assert.Equal(t, "1", gpu.String())
+	// the memory is rounded down to megabytes, never beyond the free amount
+	hugepages := share["hugepages-2Mi"]
+	assert.Equal(t, int64(536), hugepages.ScaledValue(resource.Mega))
+	freeHugepages := free["hugepages-2Mi"]
+	assert.True(t, hugepages.Value()*2 <= freeHugepages.Value())
+
+	// the scaling rule can be overridden
+	config.AdvertisedResources[2].Scaling = configv1alpha1.MilliScaling
+	share = advop.ComputePeerShare(filtered, "cluster-1", nil, config)
+	gpu = share["nvidia.com/gpu"]
+	assert.Equal(t, int64(1500), gpu.MilliValue())
+}
+
 func TestCreateAdvertisement(t *testing.T) {
 	pNodes, vNodes, images, _, pods := createFakeResources()
 	sharingPercentage := int32(50)
-	reqs, limits := advop.GetAllPodsResources(pods)
+	reqs, _ := advop.GetAllPodsResources(pods)
 	availability, _ := advop.ComputeAnnouncedResources(pNodes, reqs, int64(sharingPercentage))
+	limits := advop.ComputeContainerLimits(pNodes, availability)
 	neighbours := make(map[corev1.ResourceName]corev1.ResourceList)
 	labels := make(map[string]string)
 	for _, vNode := range vNodes.Items {
@@ -277,6 +417,7 @@ func TestCreateAdvertisement(t *testing.T) {
 	assert.Equal(t, images, adv.Spec.Images)
 	assert.Equal(t, availability, adv.Spec.ResourceQuota.Hard)
 	assert.Equal(t, limits, adv.Spec.LimitRange.Limits[0].Max)
+	assert.Equal(t, corev1.LimitTypeContainer, adv.Spec.LimitRange.Limits[0].Type)
 	assert.Equal(t, neighbours, adv.Spec.Neighbors)
 	assert.Empty(t, adv.Status, "Status should not be set")
 }
@@ -292,8 +433,9 @@ func TestGetResourceForAdv(t *testing.T) {
 	}
 	time.Sleep(5 * time.Second)
 
-	reqs, limits := advop.GetAllPodsResources(pods)
+	reqs, _ := advop.GetAllPodsResources(pods)
 	availability, _ := advop.ComputeAnnouncedResources(pNodes, reqs, int64(b.ClusterConfig.AdvertisementConfig.OutgoingConfig.ResourceSharingPercentage))
+	limits := advop.ComputeContainerLimits(pNodes, availability)
 	if availability.Cpu().Value() < 0 || availability.Memory().Value() < 0 {
 		t.Fatal("Available resources cannot be negative")
 	}
@@ -330,6 +472,74 @@ func TestSendAdvertisement(t *testing.T) {
 	assert.Equal(t, adv.Spec.ResourceQuota.Hard.Cpu().Value(), adv3.Spec.ResourceQuota.Hard.Cpu().Value())
 }
 
+func TestEnforceAdvertisedResources(t *testing.T) {
+	config := createFakeClusterConfig()
+	b := createBroadcaster(config.Spec)
+
+	// namespace used by the foreign cluster, and a namespace not related to it
+	guestNs := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "guest",
+			Labels: map[string]string{pkg.RemoteClusterIdLabel: b.ForeignClusterId},
+		},
+	}
+	otherNs := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "other",
+		},
+	}
+	for _, ns := range []*corev1.Namespace{guestNs, otherNs} {
+		if _, err := b.LocalClient.Client().CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	adv := prepareAdv(&b)
+	adv.Spec.ResourceQuota.Hard[corev1.ResourceCPU] = resource.MustParse("10")
+	adv.Spec.ResourceQuota.Hard["nvidia.com/gpu"] = resource.MustParse("2")
+	adv.Spec.LimitRange.Limits[0].Max[corev1.ResourceCPU] = resource.MustParse("8")
+	b.EnforceAdvertisedResources(&adv)
+
+	quota, err := b.LocalClient.Client().CoreV1().ResourceQuotas("guest").Get(context.TODO(), advop.GuestResourceQuotaName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), quota.Spec.Hard.Cpu().Value())
+	assert.Equal(t, adv.Spec.ResourceQuota.Hard.Memory().Value(), quota.Spec.Hard.Memory().Value())
+	gpu := quota.Spec.Hard["requests.nvidia.com/gpu"]
+	assert.Equal(t, int64(2), gpu.Value())
+
+	limitRange, err := b.LocalClient.Client().CoreV1().LimitRanges("guest").Get(context.TODO(), advop.GuestLimitRangeName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, limitRange.Spec.Limits, len(adv.Spec.LimitRange.Limits))
+	assert.Equal(t, corev1.LimitTypeContainer, limitRange.Spec.Limits[0].Type)
+	assert.Equal(t, int64(8), limitRange.Spec.Limits[0].Max.Cpu().Value())
+	// the containers not declaring their resources are admitted
+	assert.Equal(t, int64(8), limitRange.Spec.Limits[0].Default.Cpu().Value())
+	assert.Equal(t, int64(100), limitRange.Spec.Limits[0].DefaultRequest.Cpu().MilliValue())
+
+	_, err = b.LocalClient.Client().CoreV1().ResourceQuotas("other").Get(context.TODO(), advop.GuestResourceQuotaName, metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	// the resources are split between the namespaces used by the foreign cluster, and the per-container limits
+	// are bounded by the quota of each namespace
+	otherNs.Labels = map[string]string{pkg.RemoteClusterIdLabel: b.ForeignClusterId}
+	if _, err = b.LocalClient.Client().CoreV1().Namespaces().Update(context.TODO(), otherNs, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	adv.Spec.ResourceQuota.Hard[corev1.ResourceCPU] = resource.MustParse("15")
+	b.EnforceAdvertisedResources(&adv)
+	for _, ns := range []string{"guest", "other"} {
+		quota, err = b.LocalClient.Client().CoreV1().ResourceQuotas(ns).Get(context.TODO(), advop.GuestResourceQuotaName, metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7500), quota.Spec.Hard.Cpu().MilliValue())
+		gpu = quota.Spec.Hard["requests.nvidia.com/gpu"]
+		assert.Equal(t, int64(1), gpu.Value())
+
+		limitRange, err = b.LocalClient.Client().CoreV1().LimitRanges(ns).Get(context.TODO(), advop.GuestLimitRangeName, metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7500), limitRange.Spec.Limits[0].Max.Cpu().MilliValue())
+	}
+}
+
 func TestSendSecret(t *testing.T) {
 	config := createFakeClusterConfig()
 	b := createBroadcaster(config.Spec)
@@ -193,10 +193,11 @@ func waitEvent(client *crdClient.CRDClient, resourcetype string, name string) er
 
 func TestWatchAdvOperatorConfig(t *testing.T) {
 	t.Run("testManageMaximumUpdate", testManageMaximumUpdate)
+	t.Run("testManageAcceptRulesUpdate", testManageAcceptRulesUpdate)
 }
 
 func testManageMaximumUpdate(t *testing.T) {
-	r := createReconciler(0, 10, configv1alpha1.AutoAcceptMax)
+	r := createReconciler(10, configv1alpha1.AutoAcceptMax)
 	advList := advtypes.AdvertisementList{
 		Items: []advtypes.Advertisement{},
 	}
@@ -211,7 +212,7 @@ func testManageMaximumUpdate(t *testing.T) {
 			t.Fatal(err)
 		}
 		r.CheckAdvertisement(adv)
-		r.UpdateAdvertisement(adv)
+		assert.Nil(t, r.UpdateAdvertisement(adv))
 		advList.Items = append(advList.Items, *adv)
 	}
 
@@ -236,10 +237,9 @@ func testManageMaximumUpdate(t *testing.T) {
 	assert.NotEmpty(t, advToUpdate)
 	assert.NotEmpty(t, advToUpdate.Items)
 	assert.Equal(t, config.Spec.AdvertisementConfig, r.ClusterConfig)
-	assert.Equal(t, int32(advCount), r.AcceptedAdvNum)
+	assertAcceptedAdvNum(t, &r, int32(advCount))
 	for _, adv := range advToUpdate.Items {
 		assert.Equal(t, advtypes.AdvertisementAccepted, adv.Status.AdvertisementStatus)
-		r.UpdateAdvertisement(&adv)
 	}
 
 	// FALSE TEST
@@ -250,7 +250,7 @@ func testManageMaximumUpdate(t *testing.T) {
 	assert.NotEmpty(t, advToUpdate)
 	assert.Empty(t, advToUpdate.Items)
 	assert.Equal(t, config.Spec.AdvertisementConfig, r.ClusterConfig)
-	assert.Equal(t, int32(advCount), r.AcceptedAdvNum)
+	assertAcceptedAdvNum(t, &r, int32(advCount))
 
 	// FALSE TEST with new config
 	// check the new config is saved
@@ -272,3 +272,38 @@ func testManageMaximumUpdate(t *testing.T) {
 	assert.Empty(t, advToUpdate.Items)
 	assert.Equal(t, config.Spec.AdvertisementConfig, r.ClusterConfig)
 }
+
+func testManageAcceptRulesUpdate(t *testing.T) {
+	r := createReconciler(10, configv1alpha1.PolicyAccept)
+	r.ClusterConfig.IngoingConfig.AcceptRules = configv1alpha1.AcceptRules{
+		AllowedClusterIds: []string{"cluster-0"},
+	}
+	advList := advtypes.AdvertisementList{
+		Items: []advtypes.Advertisement{},
+	}
+
+	// given the rules allowing only cluster-0, its Advertisement is accepted and the one of cluster-1 is refused
+	for i := 0; i < 2; i++ {
+		adv := createFakeAdv("adv-"+strconv.Itoa(i), "default")
+		adv.Spec.ClusterId = "cluster-" + strconv.Itoa(i)
+		createAndCheckAdv(t, &r, adv)
+		advList.Items = append(advList.Items, *adv)
+	}
+	assert.Equal(t, advtypes.AdvertisementAccepted, advList.Items[0].Status.AdvertisementStatus)
+	assert.Equal(t, advtypes.AdvertisementRefused, advList.Items[1].Status.AdvertisementStatus)
+
+	// the new rules allow only cluster-1: the Advertisement of cluster-0 is revoked and the one of cluster-1 is accepted
+	config := r.ClusterConfig
+	config.IngoingConfig.AcceptRules = configv1alpha1.AcceptRules{
+		AllowedClusterIds: []string{"cluster-1"},
+	}
+	err, advToUpdate := r.ManageAcceptRulesUpdate(config, &advList)
+	assert.Nil(t, err)
+	assert.Len(t, advToUpdate.Items, 2)
+	assert.Equal(t, config, r.ClusterConfig)
+	assert.Equal(t, advtypes.AdvertisementAccepted, advList.Items[1].Status.AdvertisementStatus)
+	var revoked advtypes.Advertisement
+	err = r.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "adv-0"}, &revoked)
+	assert.True(t, k8serrors.IsNotFound(err) || !revoked.DeletionTimestamp.IsZero(), "the Advertisement of cluster-0 has not been revoked")
+	assertAcceptedAdvNum(t, &r, 1)
+}
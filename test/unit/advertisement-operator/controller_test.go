@@ -1,19 +1,25 @@
 package advertisement_operator
 
 import (
+	"context"
 	configv1alpha1 "github.com/liqotech/liqo/apis/config/v1alpha1"
 	advtypes "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 	advop "github.com/liqotech/liqo/internal/advertisement-operator"
 	"github.com/liqotech/liqo/pkg/crdClient"
 	"github.com/stretchr/testify/assert"
 	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strconv"
 	"testing"
+	"time"
 )
 
-func createReconciler(acceptedAdv, maxAcceptableAdv int32, acceptPolicy configv1alpha1.AcceptPolicy) advop.AdvertisementReconciler {
-	c, evRecorder := createFakeKubebuilderClient()
+func createReconciler(maxAcceptableAdv int32, acceptPolicy configv1alpha1.AcceptPolicy) advop.AdvertisementReconciler {
+	c, apiReader, evRecorder := createFakeKubebuilderClient()
 	// set the client in fake mode
 	crdClient.Fake = true
 	// create fake client for the home cluster
@@ -31,7 +37,7 @@ func createReconciler(acceptedAdv, maxAcceptableAdv int32, acceptPolicy configv1
 		VKImage:          "",
 		InitVKImage:      "",
 		HomeClusterId:    "",
-		AcceptedAdvNum:   acceptedAdv,
+		APIReader:        apiReader,
 		ClusterConfig: configv1alpha1.AdvertisementConfig{
 			IngoingConfig: configv1alpha1.AdvOperatorConfig{
 				MaxAcceptableAdvertisement: maxAcceptableAdv,
@@ -46,42 +52,140 @@ func TestCheckAdvertisement(t *testing.T) {
 	t.Run("testAutoAcceptMax", testAutoAcceptMax)
 	t.Run("testManualAccept", testManualAccept)
 	t.Run("testRefuseInvalidAdvertisement", testRefuseInvalidAdvertisement)
+	t.Run("testRefuseExpensiveAdvertisement", testRefuseExpensiveAdvertisement)
+}
+
+// createAndCheckAdv creates the Advertisement, checks it and saves its status, as done by the Reconcile
+func createAndCheckAdv(t *testing.T, r *advop.AdvertisementReconciler, adv *advtypes.Advertisement) {
+	if err := r.Create(context.Background(), adv, &client.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, r.CheckAdvertisement(adv))
+	assert.Nil(t, r.UpdateAdvertisement(adv))
+}
+
+func assertAcceptedAdvNum(t *testing.T, r *advop.AdvertisementReconciler, expected int32) {
+	acceptedAdvNum, err := r.GetAcceptedAdvNum()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, acceptedAdvNum)
 }
 
 func testAutoAcceptMax(t *testing.T) {
-	r := createReconciler(0, 10, configv1alpha1.AutoAcceptMax)
+	r := createReconciler(10, configv1alpha1.AutoAcceptMax)
 
 	// given a configuration with max 10 Advertisements, create 10 Advertisements
 	for i := 0; i < 10; i++ {
 		adv := createFakeAdv("cluster-"+strconv.Itoa(i), "default")
-		r.CheckAdvertisement(adv)
+		createAndCheckAdv(t, &r, adv)
+		assert.Equal(t, advtypes.AdvertisementAccepted, adv.Status.AdvertisementStatus)
 	}
 
 	// create 5 more Advertisements and check that they are all refused, since the maximum has been reached
 	for i := 10; i < 15; i++ {
 		adv := createFakeAdv("cluster-"+strconv.Itoa(i), "default")
-		r.CheckAdvertisement(adv)
+		createAndCheckAdv(t, &r, adv)
 		assert.Equal(t, advtypes.AdvertisementRefused, adv.Status.AdvertisementStatus)
 	}
-	// check that the Adv counter has not been modified
-	assert.Equal(t, int32(10), r.AcceptedAdvNum)
+	// check that the accepted Adv are still 10
+	assertAcceptedAdvNum(t, &r, 10)
+
+	// delete an accepted Advertisement and check that its slot is freed
+	if err := r.Delete(context.Background(), createFakeAdv("cluster-0", "default")); err != nil {
+		t.Fatal(err)
+	}
+	assertAcceptedAdvNum(t, &r, 9)
+	adv := createFakeAdv("cluster-15", "default")
+	createAndCheckAdv(t, &r, adv)
+	assert.Equal(t, advtypes.AdvertisementAccepted, adv.Status.AdvertisementStatus)
+	assertAcceptedAdvNum(t, &r, 10)
 }
 
 func testManualAccept(t *testing.T) {
-	r := createReconciler(0, 10, configv1alpha1.ManualAccept)
+	r := createReconciler(10, configv1alpha1.ManualAccept)
 
-	// given a configuration with max 10 Advertisements and ManualAccept policy, create 5 Advertisements and check they are refused
+	// given a configuration with max 10 Advertisements and ManualAccept policy, create 5 Advertisements and check they are pending
 	for i := 0; i < 5; i++ {
 		adv := createFakeAdv("cluster-"+strconv.Itoa(i), "default")
-		r.CheckAdvertisement(adv)
-		assert.Equal(t, advtypes.AdvertisementRefused, adv.Status.AdvertisementStatus)
+		createAndCheckAdv(t, &r, adv)
+		assert.Equal(t, advtypes.AdvertisementPending, adv.Status.AdvertisementStatus)
 	}
-	// check that the Adv counter has not been incremented
-	assert.Equal(t, int32(0), r.AcceptedAdvNum)
+	// check that no Adv has been accepted
+	assertAcceptedAdvNum(t, &r, 0)
+}
+
+func TestCheckManualDecision(t *testing.T) {
+	r := createReconciler(10, configv1alpha1.ManualAccept)
+	recorder := record.NewFakeRecorder(10)
+	r.EventsRecorder = recorder
+
+	// without a decision the Advertisement stays pending
+	adv := createFakeAdv("cluster-1", "default")
+	adv.Spec.ClusterId = "cluster-1"
+	adv.UID = "uid-1"
+	r.CheckAdvertisement(adv)
+	assert.False(t, r.CheckManualDecision(adv))
+	assert.Equal(t, advtypes.AdvertisementPending, adv.Status.AdvertisementStatus)
+
+	// the decisions set on the Advertisement, which can be modified by the foreign cluster, are ignored
+	adv.Annotations = map[string]string{"sharing.liqo.io/decision": string(advtypes.AdvertisementAccepted)}
+	assert.False(t, r.CheckManualDecision(adv))
+	assert.Equal(t, advtypes.AdvertisementPending, adv.Status.AdvertisementStatus)
+
+	// an invalid decision is ignored and reported only once
+	r.ClusterConfig.IngoingConfig.ManualDecisions = map[string]configv1alpha1.AdvertisementDecision{"cluster-1/uid-1": "Maybe"}
+	assert.False(t, r.CheckManualDecision(adv))
+	assert.False(t, r.CheckManualDecision(adv))
+	assert.Equal(t, advtypes.AdvertisementPending, adv.Status.AdvertisementStatus)
+	assert.Len(t, recorder.Events, 1)
+
+	// accept the Advertisement
+	r.ClusterConfig.IngoingConfig.ManualDecisions["cluster-1/uid-1"] = configv1alpha1.DecisionAccepted
+	assert.True(t, r.CheckManualDecision(adv))
+	assert.Equal(t, advtypes.AdvertisementAccepted, adv.Status.AdvertisementStatus)
+
+	// the decision does not apply to the Advertisement recreated by the same foreign cluster
+	adv = createFakeAdv("cluster-1", "default")
+	adv.Spec.ClusterId = "cluster-1"
+	adv.UID = "uid-2"
+	r.CheckAdvertisement(adv)
+	assert.False(t, r.CheckManualDecision(adv))
+	assert.Equal(t, advtypes.AdvertisementPending, adv.Status.AdvertisementStatus)
+
+	// refuse another Advertisement
+	adv = createFakeAdv("cluster-2", "default")
+	adv.Spec.ClusterId = "cluster-2"
+	adv.UID = "uid-3"
+	r.CheckAdvertisement(adv)
+	r.ClusterConfig.IngoingConfig.ManualDecisions[advop.ManualDecisionKey(adv)] = configv1alpha1.DecisionRefused
+	assert.True(t, r.CheckManualDecision(adv))
+	assert.Equal(t, advtypes.AdvertisementRefused, adv.Status.AdvertisementStatus)
+}
+
+func TestCheckPendingAdvertisement(t *testing.T) {
+	r := createReconciler(10, configv1alpha1.ManualAccept)
+	r.EventsRecorder = record.NewFakeRecorder(10)
+
+	adv := createFakeAdv("cluster-1", "default")
+	adv.Spec.ClusterId = "cluster-1"
+	adv.UID = "uid-1"
+	r.CheckAdvertisement(adv)
+
+	// with the Manual AcceptPolicy, the Advertisement waits for a decision
+	decided, err := r.CheckPendingAdvertisement(adv)
+	assert.NoError(t, err)
+	assert.False(t, decided)
+	assert.Equal(t, advtypes.AdvertisementPending, adv.Status.AdvertisementStatus)
+
+	// once the AcceptPolicy is no longer Manual, the Advertisement is checked again
+	r.ClusterConfig.IngoingConfig.AcceptPolicy = configv1alpha1.AutoAcceptMax
+	decided, err = r.CheckPendingAdvertisement(adv)
+	assert.NoError(t, err)
+	assert.True(t, decided)
+	assert.Equal(t, advtypes.AdvertisementAccepted, adv.Status.AdvertisementStatus)
 }
 
 func testRefuseInvalidAdvertisement(t *testing.T) {
-	r := createReconciler(0, 10, configv1alpha1.AutoAcceptMax)
+	r := createReconciler(10, configv1alpha1.AutoAcceptMax)
 
 	// create 5 advertisements with negative values in ResourceQuota field and check they are refused
 	for i := 1; i <= 5; i++ {
@@ -92,9 +196,156 @@ func testRefuseInvalidAdvertisement(t *testing.T) {
 			},
 		}
 		adv := createFakeInvalidAdv("cluster-"+strconv.Itoa(i), "default", quota)
-		r.CheckAdvertisement(adv)
+		createAndCheckAdv(t, &r, adv)
 		assert.Equal(t, advtypes.AdvertisementRefused, adv.Status.AdvertisementStatus)
 	}
-	// check that the Adv counter has not been incremented
-	assert.Equal(t, int32(0), r.AcceptedAdvNum)
+	// check that no Adv has been accepted
+	assertAcceptedAdvNum(t, &r, 0)
+}
+
+func testRefuseExpensiveAdvertisement(t *testing.T) {
+	r := createReconciler(10, configv1alpha1.AutoAcceptMax)
+	r.ClusterConfig.IngoingConfig.MaxPrices = v12.ResourceList{
+		v12.ResourceCPU: resource.MustParse("2"),
+	}
+
+	// an Advertisement with a cpu price below the ceiling is accepted
+	adv := createFakeAdv("cluster-1", "default")
+	adv.Spec.Prices = v12.ResourceList{v12.ResourceCPU: resource.MustParse("1")}
+	createAndCheckAdv(t, &r, adv)
+	assert.Equal(t, advtypes.AdvertisementAccepted, adv.Status.AdvertisementStatus)
+
+	// an Advertisement with a cpu price above the ceiling is refused
+	adv = createFakeAdv("cluster-2", "default")
+	adv.Spec.Prices = v12.ResourceList{v12.ResourceCPU: resource.MustParse("3")}
+	createAndCheckAdv(t, &r, adv)
+	assert.Equal(t, advtypes.AdvertisementRefused, adv.Status.AdvertisementStatus)
+	assertAcceptedAdvNum(t, &r, 1)
+}
+
+func TestCheckMaxPrices(t *testing.T) {
+	adv := createFakeAdv("cluster-1", "default")
+	adv.Spec.Prices = v12.ResourceList{v12.ResourceCPU: resource.MustParse("1")}
+
+	// without a ceiling any price is accepted
+	_, ok := advop.CheckMaxPrices(adv, nil)
+	assert.True(t, ok)
+
+	_, ok = advop.CheckMaxPrices(adv, v12.ResourceList{v12.ResourceCPU: resource.MustParse("2")})
+	assert.True(t, ok)
+	_, ok = advop.CheckMaxPrices(adv, v12.ResourceList{v12.ResourceCPU: resource.MustParse("500m")})
+	assert.False(t, ok)
+
+	// a resource with a ceiling but without a price is a violation
+	_, ok = advop.CheckMaxPrices(adv, v12.ResourceList{v12.ResourceMemory: resource.MustParse("1m")})
+	assert.False(t, ok)
+}
+
+func TestCheckAcceptRules(t *testing.T) {
+	adv := createFakeAdv("cluster-1", "default")
+	adv.Spec.ResourceQuota.Hard = v12.ResourceList{
+		v12.ResourceCPU:    resource.MustParse("4"),
+		v12.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	adv.Spec.Labels = map[string]string{"region": "eu"}
+
+	// empty rules are always satisfied
+	_, ok := advop.CheckAcceptRules(adv, &configv1alpha1.AcceptRules{})
+	assert.True(t, ok)
+
+	rules := &configv1alpha1.AcceptRules{
+		MinResources: v12.ResourceList{
+			v12.ResourceCPU:    resource.MustParse("2"),
+			v12.ResourceMemory: resource.MustParse("4Gi"),
+		},
+		RequiredLabels:    map[string]string{"region": "eu"},
+		AllowedClusterIds: []string{adv.Spec.ClusterId},
+	}
+	_, ok = advop.CheckAcceptRules(adv, rules)
+	assert.True(t, ok)
+
+	// not enough cpu
+	rules.MinResources[v12.ResourceCPU] = resource.MustParse("8")
+	_, ok = advop.CheckAcceptRules(adv, rules)
+	assert.False(t, ok)
+	rules.MinResources[v12.ResourceCPU] = resource.MustParse("2")
+
+	// wrong label value
+	rules.RequiredLabels["region"] = "us"
+	_, ok = advop.CheckAcceptRules(adv, rules)
+	assert.False(t, ok)
+	rules.RequiredLabels["region"] = "eu"
+
+	// cluster not allowed
+	rules.AllowedClusterIds = []string{"another-cluster"}
+	_, ok = advop.CheckAcceptRules(adv, rules)
+	assert.False(t, ok)
+}
+
+func TestCheckAdvertisementExpiration(t *testing.T) {
+	r := createReconciler(10, configv1alpha1.AutoAcceptMax)
+	r.ExpirationGracePeriod = 10 * time.Minute
+
+	node := &v12.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "liqo-cluster-expiring",
+		},
+	}
+	if err := r.Create(context.Background(), node); err != nil {
+		t.Fatal(err)
+	}
+	adv := createFakeAdv("cluster-expiring", "default")
+	createAndCheckAdv(t, &r, adv)
+	adv.Status.VnodeReference.Name = node.Name
+	if err := r.Status().Update(context.Background(), adv); err != nil {
+		t.Fatal(err)
+	}
+	assertNodeUnschedulable := func(expected bool) {
+		var n v12.Node
+		assert.Nil(t, r.APIReader.Get(context.Background(), client.ObjectKey{Name: node.Name}, &n))
+		assert.Equal(t, expected, n.Spec.Unschedulable)
+	}
+
+	// before the TimeToLive nothing happens
+	assert.Nil(t, r.CheckAdvertisementExpiration(adv, time.Now()))
+	assert.False(t, adv.Status.Expired)
+	assertNodeUnschedulable(false)
+
+	// after the TimeToLive the Advertisement is expired and the virtual node cordoned
+	expiration := adv.Spec.TimeToLive.Add(time.Minute)
+	assert.Nil(t, r.CheckAdvertisementExpiration(adv, expiration))
+	assert.True(t, adv.Status.Expired)
+	assertNodeUnschedulable(true)
+
+	// the renewal during the grace period makes the virtual node available again
+	adv.Spec.TimeToLive = metav1.NewTime(expiration.Add(30 * time.Minute))
+	if err := r.Update(context.Background(), adv); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, r.CheckAdvertisementExpiration(adv, expiration))
+	assert.False(t, adv.Status.Expired)
+	assertNodeUnschedulable(false)
+
+	// a virtual node cordoned by the administrator is not uncordoned on renewal
+	var n v12.Node
+	assert.Nil(t, r.APIReader.Get(context.Background(), client.ObjectKey{Name: node.Name}, &n))
+	n.Spec.Unschedulable = true
+	if err := r.Update(context.Background(), &n); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, r.CheckAdvertisementExpiration(adv, adv.Spec.TimeToLive.Add(time.Minute)))
+	assert.True(t, adv.Status.Expired)
+	adv.Spec.TimeToLive = metav1.NewTime(adv.Spec.TimeToLive.Add(30 * time.Minute))
+	if err := r.Update(context.Background(), adv); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, r.CheckAdvertisementExpiration(adv, expiration))
+	assert.False(t, adv.Status.Expired)
+	assertNodeUnschedulable(true)
+
+	// without a renewal the Advertisement is deleted at the end of the grace period
+	assert.Nil(t, r.CheckAdvertisementExpiration(adv, adv.Spec.TimeToLive.Add(11*time.Minute)))
+	var deleted advtypes.Advertisement
+	err := r.APIReader.Get(context.Background(), client.ObjectKey{Name: adv.Name, Namespace: adv.Namespace}, &deleted)
+	assert.True(t, errors.IsNotFound(err))
 }
@@ -77,7 +77,7 @@ func createFakePod(name, namespace string) *corev1.Pod {
 	}
 }
 
-func createFakeKubebuilderClient() (client.Client, record.EventRecorder) {
+func createFakeKubebuilderClient() (client.Client, client.Reader, record.EventRecorder) {
 	env := &envtest.Environment{
 		CRDDirectoryPaths: []string{filepath.Join("..", "..", "..", "deployments", "liqo", "crds")},
 	}
@@ -110,7 +110,7 @@ func createFakeKubebuilderClient() (client.Client, record.EventRecorder) {
 		}
 	}()
 	manager.GetCache().WaitForCacheSync(cacheStarted)
-	return manager.GetClient(), manager.GetEventRecorderFor("AdvertisementOperator")
+	return manager.GetClient(), manager.GetAPIReader(), manager.GetEventRecorderFor("AdvertisementOperator")
 }
 
 func TestCreateVkDeployment(t *testing.T) {
@@ -123,7 +123,7 @@ func TestCreateVkDeployment(t *testing.T) {
 	initVkImage := "liqo/init-vk"
 	homeClusterId := "cluster2"
 
-	deploy := pkg.CreateVkDeployment(adv, vkName, vkNamespace, vkImage, initVkImage, nodeName, homeClusterId)
+	deploy := pkg.CreateVkDeployment(adv, vkName, vkNamespace, vkImage, initVkImage, nodeName, homeClusterId, false)
 
 	assert.Equal(t, vkName, deploy.Name)
 	assert.Equal(t, vkNamespace, deploy.Namespace)
@@ -138,15 +138,19 @@ func TestCreateVkDeployment(t *testing.T) {
 	assert.Contains(t, deploy.Spec.Template.Spec.Containers[0].Args, nodeName)
 	assert.Contains(t, deploy.Spec.Template.Spec.Containers[0].Args, vkNamespace)
 	assert.Contains(t, deploy.Spec.Template.Spec.Containers[0].Args, homeClusterId)
+	assert.NotContains(t, deploy.Spec.Template.Spec.Containers[0].Args, "--allow-all-namespaces")
 	assert.NotEmpty(t, deploy.Spec.Template.Spec.Containers[0].Command)
 	assert.NotEmpty(t, deploy.Spec.Template.Spec.Containers[0].VolumeMounts)
 	assert.NotEmpty(t, deploy.Spec.Template.Spec.Containers[0].Env)
 	assert.Equal(t, vkName, deploy.Spec.Template.Spec.ServiceAccountName)
 	assert.NotEmpty(t, deploy.Spec.Template.Spec.Affinity)
+
+	deploy = pkg.CreateVkDeployment(adv, vkName, vkNamespace, vkImage, initVkImage, nodeName, homeClusterId, true)
+	assert.Contains(t, deploy.Spec.Template.Spec.Containers[0].Args, "--allow-all-namespaces")
 }
 
 func TestCreateOrUpdate(t *testing.T) {
-	c, _ := createFakeKubebuilderClient()
+	c, _, _ := createFakeKubebuilderClient()
 
 	testPod(t, c)
 	testAdvertisement(t, c)
@@ -0,0 +1,223 @@
+package reflection
+
+import (
+	"context"
+	apimgmt "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection"
+	api "github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/apiReflection/reflectors/outgoing"
+	vkContext "github.com/liqotech/liqo/pkg/virtualKubelet/context"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/namespacesMapping/test"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/options/types"
+	storageTest "github.com/liqotech/liqo/pkg/virtualKubelet/storage/test"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func newPersistentVolumeClaimsReflector(mapping map[string]string) (*outgoing.PersistentVolumeClaimsReflector, *test.MockNamespaceMapper) {
+	cacheManager := &storageTest.MockManager{
+		HomeCache:    map[string]map[apimgmt.ApiType]map[string]metav1.Object{},
+		ForeignCache: map[string]map[apimgmt.ApiType]map[string]metav1.Object{},
+	}
+	nattingTable := &test.MockNamespaceMapper{Cache: map[string]string{}}
+
+	Greflector := &api.GenericAPIReflector{
+		ForeignClient:    fake.NewSimpleClientset(),
+		NamespaceNatting: nattingTable,
+		CacheManager:     cacheManager,
+	}
+
+	storageClassMapping := types.NewStorageOption(types.StorageClassMapping, "")
+	storageClassMapping.SetValue(types.StorageClassMappingValue(mapping))
+
+	reflector := &outgoing.PersistentVolumeClaimsReflector{
+		APIReflector:        Greflector,
+		StorageClassMapping: storageClassMapping,
+	}
+	reflector.SetSpecializedPreProcessingHandlers()
+
+	return reflector, nattingTable
+}
+
+func TestPersistentVolumeClaimAdd(t *testing.T) {
+	reflector, nattingTable := newPersistentVolumeClaimsReflector(map[string]string{"local-ssd": "remote-ssd"})
+
+	storageClass := "local-ssd"
+	pvc := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "homeNamespace",
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: resource.MustParse("1Gi"),
+				},
+			},
+			StorageClassName: &storageClass,
+			VolumeName:       "home-pv",
+		},
+	}
+
+	_, _ = nattingTable.NatNamespace("homeNamespace", true)
+
+	pa, _ := reflector.PreProcessAdd(&pvc)
+	postadd := pa.(*v1.PersistentVolumeClaim)
+
+	assert.Equal(t, postadd.Namespace, "homeNamespace-natted")
+	assert.Equal(t, *postadd.Spec.StorageClassName, "remote-ssd")
+	assert.Equal(t, postadd.Spec.VolumeName, "", "the home volume name must not be reflected")
+	assert.Assert(t, postadd.Spec.Resources.Requests.Storage().Equal(resource.MustParse("1Gi")))
+}
+
+func TestPersistentVolumeClaimAddNotMapped(t *testing.T) {
+	reflector, nattingTable := newPersistentVolumeClaimsReflector(map[string]string{"local-ssd": "remote-ssd"})
+
+	storageClass := "local-hdd"
+	pvc := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "homeNamespace",
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+		},
+	}
+
+	_, _ = nattingTable.NatNamespace("homeNamespace", true)
+
+	pa, _ := reflector.PreProcessAdd(&pvc)
+	postadd := pa.(*v1.PersistentVolumeClaim)
+
+	assert.Assert(t, postadd.Spec.StorageClassName == nil, "not mapped storage classes must fall back to the foreign default")
+}
+
+func TestPersistentVolumeClaimAddEmptyClass(t *testing.T) {
+	reflector, nattingTable := newPersistentVolumeClaimsReflector(map[string]string{"local-ssd": "remote-ssd"})
+
+	storageClass := ""
+	pvc := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "homeNamespace",
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+		},
+	}
+
+	_, _ = nattingTable.NatNamespace("homeNamespace", true)
+
+	pa, _ := reflector.PreProcessAdd(&pvc)
+	postadd := pa.(*v1.PersistentVolumeClaim)
+
+	assert.Assert(t, postadd.Spec.StorageClassName != nil, "the empty storage class must be preserved")
+	assert.Equal(t, *postadd.Spec.StorageClassName, "")
+}
+
+func TestPersistentVolumeClaimIsAllowed(t *testing.T) {
+	reflector, nattingTable := newPersistentVolumeClaimsReflector(nil)
+	cacheManager := reflector.GetCacheManager().(*storageTest.MockManager)
+	forge.InitForger(nattingTable, types.NewNetworkingOption(types.VirtualNodeName, "vk-node"))
+	_, _ = nattingTable.NatNamespace("homeNamespace", true)
+
+	newPvc := func(name string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "homeNamespace",
+				UID:         "home-uid",
+				Annotations: map[string]string{},
+			},
+		}
+	}
+	newPod := func(name, nodeName, claimName string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "homeNamespace"},
+			Spec: v1.PodSpec{
+				NodeName: nodeName,
+				Volumes: []v1.Volume{{
+					Name: "volume",
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+					},
+				}},
+			},
+		}
+	}
+	added := vkContext.SetIncomingMethod(context.TODO(), vkContext.IncomingAdded)
+	deleted := vkContext.SetIncomingMethod(context.TODO(), vkContext.IncomingDeleted)
+
+	unused := newPvc("unused")
+	assert.Assert(t, !reflector.PreProcessIsAllowed(added, unused), "claims not requested by the virtual node must not be reflected")
+
+	cacheManager.AddHomeEntry("homeNamespace", apimgmt.Pods, newPod("local", "local-node", "local"))
+	assert.Assert(t, !reflector.PreProcessIsAllowed(added, newPvc("local")), "claims used by local pods must not be reflected")
+
+	cacheManager.AddHomeEntry("homeNamespace", apimgmt.Pods, newPod("offloaded", "vk-node", "offloaded"))
+	assert.Assert(t, reflector.PreProcessIsAllowed(added, newPvc("offloaded")), "claims used by offloaded pods must be reflected")
+
+	selected := newPvc("selected")
+	selected.Annotations["volume.kubernetes.io/selected-node"] = "vk-node"
+	assert.Assert(t, reflector.PreProcessIsAllowed(added, selected), "claims whose binding is delayed to the virtual node must be reflected")
+
+	boundAtHome := newPvc("offloaded")
+	boundAtHome.Spec.VolumeName = "home-pv"
+	assert.Assert(t, !reflector.PreProcessIsAllowed(added, boundAtHome), "claims bound to home volumes must not be reflected")
+
+	boundToPlaceholder := newPvc("offloaded")
+	boundToPlaceholder.Spec.VolumeName = forge.PlaceholderVolumeName(boundToPlaceholder)
+	assert.Assert(t, reflector.PreProcessIsAllowed(added, boundToPlaceholder))
+
+	assert.Assert(t, !reflector.PreProcessIsAllowed(deleted, unused), "deletions of claims never reflected must be ignored")
+	reflected := newPvc("reflected")
+	cacheManager.AddForeignEntry("homeNamespace-natted", apimgmt.PersistentVolumeClaims, reflected)
+	assert.Assert(t, reflector.PreProcessIsAllowed(deleted, reflected), "deletions of reflected claims must be propagated")
+}
+
+func TestPlaceholderVolume(t *testing.T) {
+	nattingTable := &test.MockNamespaceMapper{Cache: map[string]string{}}
+	forge.InitForger(nattingTable, types.NewNetworkingOption(types.VirtualNodeName, "vk-node"))
+
+	homePvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "homeNamespace", UID: "home-uid"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+		},
+	}
+	foreignPvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "homeNamespace-natted"},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "foreign-pv"},
+		Status: v1.PersistentVolumeClaimStatus{
+			Phase:    v1.ClaimBound,
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("2Gi")},
+		},
+	}
+
+	pv := forge.PlaceholderVolume(homePvc, foreignPvc)
+
+	assert.Equal(t, pv.Name, "liqo-home-uid")
+	assert.Equal(t, pv.Spec.ClaimRef.UID, homePvc.UID)
+	assert.Equal(t, pv.Spec.ClaimRef.Namespace, "homeNamespace")
+	assert.Equal(t, pv.Spec.StorageClassName, "")
+	assert.Equal(t, pv.Spec.PersistentVolumeReclaimPolicy, v1.PersistentVolumeReclaimRetain)
+	assert.DeepEqual(t, pv.Spec.AccessModes, homePvc.Spec.AccessModes)
+	assert.Assert(t, pv.Spec.Capacity.Storage().Equal(resource.MustParse("2Gi")))
+	assert.Equal(t, pv.Spec.CSI.VolumeHandle, "foreign-pv")
+	assert.DeepEqual(t, pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions[0].Values, []string{"vk-node"})
+}
+
+func TestStorageClassMappingValue(t *testing.T) {
+	mapping := map[string]string{"b": "remote-b", "a": "remote-a"}
+
+	value := types.StorageClassMappingValue(mapping)
+
+	assert.Equal(t, value.ToString(), "a=remote-a,b=remote-b")
+	assert.DeepEqual(t, types.ParseStorageClassMapping(value), mapping)
+	assert.Equal(t, len(types.ParseStorageClassMapping("")), 0)
+}